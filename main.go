@@ -1,12 +1,39 @@
 package main
 
 import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"io"
 	"log"
+	"log/slog"
 	"net/http"
+	"net/netip"
 	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+	_ "time/tzdata"
 
+	"smit/server/api/audit"
+	"smit/server/api/auth"
+	"smit/server/api/certs"
+	"smit/server/api/cors"
+	"smit/server/api/events"
 	"smit/server/api/handlers"
+	"smit/server/api/health"
+	"smit/server/api/idempotency"
+	"smit/server/api/ipam"
+	"smit/server/api/metrics"
+	"smit/server/api/models"
+	"smit/server/api/ratelimit"
+	"smit/server/api/rbac"
+	"smit/server/api/router"
+	"smit/server/api/scheduler"
 	"smit/server/api/storage"
+	"smit/server/api/tracing"
+	"smit/server/api/webhooks"
 )
 
 func main() {
@@ -14,16 +41,29 @@ func main() {
 	port := getEnv("SERVER_PORT", "1234")
 	dataFilePath := getEnv("DATA_FILE_PATH", "./data/data.json")
 
-	// Setup and start server
-	server, err := setupServer(dataFilePath)
+	// Setup server
+	srv, err := newServer(dataFilePath)
 	if err != nil {
 		log.Fatalf("Failed to setup server: %v", err)
 	}
 
-	// Start server
-	log.Printf("Starting SMIT Network API server on port %s", port)
-	if err := http.ListenAndServe(":"+port, server); err != nil {
-		log.Fatalf("Failed to start server: %v", err)
+	// Background workers log through the default logger
+	slog.SetDefault(srv.logger)
+
+	// Start background workers
+	srv.start(context.Background())
+
+	// Start server, with TLS when a certificate is configured
+	httpServer := &http.Server{Addr: ":" + port, Handler: srv.handler, TLSConfig: srv.tls}
+	srv.logger.Info("Starting SMIT Network API server", "port", port, "tls", srv.tls != nil)
+	if srv.tls != nil {
+		err = httpServer.ListenAndServeTLS("", "")
+	} else {
+		err = httpServer.ListenAndServe()
+	}
+	if err != nil {
+		srv.logger.Error("Failed to start server", "error", err)
+		os.Exit(1)
 	}
 }
 
@@ -35,43 +75,453 @@ func getEnv(key, defaultValue string) string {
 	return defaultValue
 }
 
+// getEnvFloat gets a numeric environment variable with a default value
+func getEnvFloat(key string, defaultValue float64) float64 {
+	value := os.Getenv(key)
+	if value == "" {
+		return defaultValue
+	}
+
+	parsed, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		log.Printf("Invalid value %q for %s, using default %v", value, key, defaultValue)
+		return defaultValue
+	}
+	return parsed
+}
+
+// getEnvInt gets an integer environment variable with a default value
+func getEnvInt(key string, defaultValue int) int {
+	value := os.Getenv(key)
+	if value == "" {
+		return defaultValue
+	}
+
+	parsed, err := strconv.Atoi(value)
+	if err != nil {
+		log.Printf("Invalid value %q for %s, using default %v", value, key, defaultValue)
+		return defaultValue
+	}
+	return parsed
+}
+
+// getEnvDuration gets a duration environment variable with a default value
+func getEnvDuration(key string, defaultValue time.Duration) time.Duration {
+	value := os.Getenv(key)
+	if value == "" {
+		return defaultValue
+	}
+
+	parsed, err := time.ParseDuration(value)
+	if err != nil {
+		log.Printf("Invalid value %q for %s, using default %v", value, key, defaultValue)
+		return defaultValue
+	}
+	return parsed
+}
+
+// getEnvList gets a comma-separated list environment variable with a default value
+func getEnvList(key string, defaultValue []string) []string {
+	value := os.Getenv(key)
+	if value == "" {
+		return defaultValue
+	}
+
+	var list []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			list = append(list, item)
+		}
+	}
+	return list
+}
+
+// server holds the HTTP handler and the background workers behind it
+type server struct {
+	handler    http.Handler
+	logger     *slog.Logger
+	scheduler  *scheduler.Scheduler
+	dispatcher *webhooks.Dispatcher
+	// Span exporter; nil when tracing is off
+	tracer *tracing.Tracer
+	// TLS settings and the certificate behind them; nil when serving plain HTTP
+	tls   *tls.Config
+	certs *certs.Reloader
+}
+
+// start runs the background workers until the context is cancelled
+func (s *server) start(ctx context.Context) {
+	go s.scheduler.Run(ctx)
+	go s.dispatcher.Run(ctx)
+	if s.certs != nil {
+		go s.certs.Run(ctx)
+	}
+	if s.tracer != nil {
+		go s.tracer.Run(ctx)
+	}
+}
+
 // setupServer sets up the HTTP server with all routes and middleware
 func setupServer(dataFilePath string) (http.Handler, error) {
+	srv, err := newServer(dataFilePath)
+	if err != nil {
+		return nil, err
+	}
+	return srv.handler, nil
+}
+
+// newServer builds the storage, handlers, routes and background workers
+func newServer(dataFilePath string) (*server, error) {
+	// JSON logger for requests and background workers
+	logLevel := getEnv("LOG_LEVEL", "info")
+	var level slog.Level
+	if err := level.UnmarshalText([]byte(logLevel)); err != nil {
+		return nil, fmt.Errorf("invalid LOG_LEVEL %q, must be debug, info, warn or error", logLevel)
+	}
+	logger := slog.New(slog.NewJSONHandler(os.Stderr, &slog.HandlerOptions{Level: level}))
+
+	// Load the status lifecycle
+	if lifecyclePath := getEnv("LIFECYCLE_FILE", ""); lifecyclePath != "" {
+		lifecycle, err := models.LoadLifecycle(lifecyclePath)
+		if err != nil {
+			return nil, err
+		}
+		models.SetLifecycle(lifecycle)
+	}
+
+	// Load the custom field schemas
+	if customFieldsPath := getEnv("CUSTOM_FIELDS_FILE", ""); customFieldsPath != "" {
+		schemas, err := models.LoadCustomFields(customFieldsPath)
+		if err != nil {
+			return nil, err
+		}
+		models.SetCustomFields(schemas)
+	}
+
 	// Initialize storage
-	store, err := storage.NewJSONStorage(dataFilePath)
+	store, err := storage.NewJSONStorage(dataFilePath,
+		storage.WithOverlapCheck(getEnv("SUBNET_OVERLAP", "allow") == "reject"),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	// Metrics of requests, storage calls and the VLAN data
+	registry := metrics.NewRegistry()
+	metrics.RegisterDataGauges(registry, store)
+	httpMetrics := metrics.NewHTTPMetrics(registry)
+
+	// Publish VLAN changes to the event stream
+	broker := events.NewBroker(getEnvInt("EVENT_LOG_SIZE", events.DefaultLogSize))
+	vlans := events.NewStorage(metrics.NewStorage(store, "json", registry), broker)
+
+	// Trace requests down to the storage calls they make
+	tracer, err := newTracer()
+	if err != nil {
+		return nil, err
+	}
+
+	// Deliver VLAN changes to webhook subscribers; internal receivers must be allowed explicitly
+	var allowedNetworks []netip.Prefix
+	for _, network := range getEnvList("WEBHOOK_ALLOWED_NETWORKS", nil) {
+		prefix, err := netip.ParsePrefix(network)
+		if err != nil {
+			return nil, fmt.Errorf("invalid WEBHOOK_ALLOWED_NETWORKS entry %q: %w", network, err)
+		}
+		allowedNetworks = append(allowedNetworks, prefix)
+	}
+	dispatcher := webhooks.New(store, broker, webhooks.Options{Tracer: tracer, AllowedNetworks: allowedNetworks})
+
+	// Load API keys; authentication is required once a keys file is configured
+	var keys *auth.KeyStore
+	if keysPath := getEnv("AUTH_KEYS_FILE", ""); keysPath != "" {
+		keys, err = auth.LoadKeyStore(keysPath)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	// Accept JWT bearer tokens signed by keys from the configured JWKS
+	var validator *auth.JWTValidator
+	if jwks := getEnv("JWT_JWKS", ""); jwks != "" {
+		roleMap, err := auth.ParseRoleMap(getEnv("JWT_ROLE_MAP", ""))
+		if err != nil {
+			return nil, err
+		}
+		config := auth.JWTConfig{
+			JWKS:      jwks,
+			Issuer:    getEnv("JWT_ISSUER", ""),
+			Audience:  getEnv("JWT_AUDIENCE", ""),
+			ClockSkew: getEnvDuration("JWT_CLOCK_SKEW", auth.DefaultClockSkew),
+			RoleClaim: getEnv("JWT_ROLE_CLAIM", auth.DefaultRoleClaim),
+			RoleMap:   roleMap,
+		}
+		if config.Issuer == "" || config.Audience == "" {
+			return nil, fmt.Errorf("JWT_JWKS requires JWT_ISSUER and JWT_AUDIENCE")
+		}
+		validator, err = auth.NewJWTValidator(config)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	// Serve TLS, optionally verifying client certificates against a CA bundle
+	tlsConfig, reloader, err := newTLSConfig()
+	if err != nil {
+		return nil, err
+	}
+	clientCerts := tlsConfig != nil && tlsConfig.ClientCAs != nil
+	certRoles, err := auth.ParseRoleMap(getEnv("TLS_CLIENT_ROLE_MAP", ""))
 	if err != nil {
 		return nil, err
 	}
 
+	// Load the access policy; roles come from API keys, tokens and certificates, so it needs authentication
+	var policy *rbac.Policy
+	var auditLog *audit.Log
+	if policyPath := getEnv("RBAC_POLICY_FILE", ""); policyPath != "" {
+		if keys == nil && validator == nil && !clientCerts {
+			return nil, fmt.Errorf("RBAC_POLICY_FILE requires AUTH_KEYS_FILE, JWT_JWKS or TLS_CLIENT_CA_FILE")
+		}
+		policy, err = rbac.LoadPolicy(policyPath)
+		if err != nil {
+			return nil, err
+		}
+
+		var out io.Writer
+		if auditPath := getEnv("AUDIT_LOG_FILE", ""); auditPath != "" {
+			file, err := os.OpenFile(auditPath, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+			if err != nil {
+				return nil, fmt.Errorf("failed to open audit log: %w", err)
+			}
+			out = file
+		}
+		auditLog = audit.NewLog(out, getEnvInt("AUDIT_LOG_SIZE", audit.DefaultSize))
+	}
+
+	// Default per-client limits; zero leaves that kind of request unlimited
+	rateLimits := models.RateLimit{
+		ReadPerMinute:  getEnvInt("RATE_LIMIT_READ_PER_MINUTE", 0),
+		WritePerMinute: getEnvInt("RATE_LIMIT_WRITE_PER_MINUTE", 0),
+	}
+	if err := rateLimits.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid rate limits: %w", err)
+	}
+
+	// Failed authentications per client address, counted before credentials are checked
+	limiter := ratelimit.NewLimiter()
+	authFailures := getEnvInt("RATE_LIMIT_AUTH_FAILURES_PER_MINUTE", 30)
+	if authFailures < 0 {
+		return nil, fmt.Errorf("invalid RATE_LIMIT_AUTH_FAILURES_PER_MINUTE %d, must not be negative", authFailures)
+	}
+
+	// Readiness needs readable data, a writable data directory and a current certificate;
+	// liveness only needs the server to answer
+	readiness := health.NewRegistry(getEnvDuration("PROBE_CHECK_TIMEOUT", health.DefaultTimeout))
+	readiness.Register("storage", health.StorageChecker(store))
+	readiness.Register("disk", health.WritableChecker(filepath.Dir(dataFilePath)))
+	if reloader != nil {
+		readiness.Register("tls", reloader)
+	}
+	liveness := health.NewRegistry(getEnvDuration("PROBE_CHECK_TIMEOUT", health.DefaultTimeout))
+
+	// Utilization percentage above which VLANs are flagged, checked like the threshold query parameter
+	threshold := getEnvFloat("UTILIZATION_THRESHOLD", handlers.DefaultUtilizationThreshold)
+	if threshold < 0 || threshold > 100 {
+		return nil, fmt.Errorf("invalid UTILIZATION_THRESHOLD %v, must be between 0 and 100", threshold)
+	}
+
 	// Initialize handlers
-	handler := handlers.NewHandler(store)
+	handler := handlers.NewHandler(tracing.NewStorage(vlans, "json", tracer),
+		handlers.WithUtilizationThreshold(threshold),
+		handlers.WithPrefixStorage(store),
+		handlers.WithMaintenanceStorage(store),
+		handlers.WithGatewayPolicy(getEnv("GATEWAY_POLICY", ipam.GatewayPolicyFirst)),
+		handlers.WithLegacyErrors(getEnv("ERROR_FORMAT", "problem") == "legacy"),
+		handlers.WithProblemTypeBase(getEnv("PROBLEM_TYPE_BASE", handlers.DefaultProblemTypeBase)),
+		handlers.WithEventBroker(broker),
+		handlers.WithWebhooks(store, dispatcher),
+		handlers.WithIdempotencyStore(idempotency.NewStore(
+			getEnvDuration("IDEMPOTENCY_TTL", idempotency.DefaultTTL),
+			getEnvInt("IDEMPOTENCY_MAX_KEYS", idempotency.DefaultMaxEntries),
+		)),
+		handlers.WithAPIKeys(keys),
+		handlers.WithJWTValidator(validator),
+		handlers.WithClientCertificates(clientCerts, certRoles),
+		handlers.WithAccessPolicy(policy),
+		handlers.WithAuditLog(auditLog),
+		handlers.WithRateLimits(limiter, rateLimits, getEnv("RATE_LIMIT_TRUST_FORWARDED", "false") == "true"),
+		handlers.WithAuthFailureLimit(limiter, authFailures),
+		handlers.WithLogger(logger),
+		handlers.WithMetrics(registry),
+		handlers.WithTracer(tracer),
+		handlers.WithProbes(readiness, liveness),
+	)
 
-	// Setup routes
-	mux := http.NewServeMux()
+	// Setup routes; the methods listed per route answer CORS preflights
+	routes := router.New()
 
 	// VLAN endpoints
-	mux.HandleFunc("/api/v1/vlans", handler.VLANHandler)
-	mux.HandleFunc("/api/v1/vlans/", handler.VLANHandler)
+	routes.Handle("/api/v1/vlans", handler.VLANHandler, "GET", "HEAD", "POST")
+	routes.Handle("/api/v1/vlans/{id}", handler.VLANHandler, "GET", "HEAD", "PUT", "PATCH", "DELETE")
+	routes.Handle("/api/v1/vlans/by-tag/{vlan_id}", handler.VLANHandler, "GET", "HEAD", "PUT", "PATCH", "DELETE")
+	routes.Handle("/api/v1/vlans/{id}/transitions", handler.VLANHandler, "GET", "HEAD", "POST")
+	routes.Handle("/api/v1/vlans/{id}/utilization", handler.VLANHandler, "GET", "HEAD")
+
+	// Prefix endpoints
+	routes.Handle("/api/v1/prefixes", handler.PrefixHandler, "GET", "HEAD", "POST")
+	routes.Handle("/api/v1/prefixes/{id}", handler.PrefixHandler, "GET", "HEAD", "DELETE")
+	routes.Handle("/api/v1/prefixes/{id}/allocate", handler.PrefixHandler, "POST")
+
+	// Lookup endpoints
+	routes.Handle("/api/v1/lookup/ip/{address}", handler.LookupIP, "GET", "HEAD")
+
+	// Maintenance endpoints
+	routes.Handle("/api/v1/maintenance", handler.MaintenanceHandler, "GET", "HEAD", "POST")
+	routes.Handle("/api/v1/maintenance/windows", handler.MaintenanceHandler, "GET", "HEAD")
+	routes.Handle("/api/v1/maintenance/{id}", handler.MaintenanceHandler, "GET", "HEAD", "DELETE")
 
-	// Health endpoint
-	mux.HandleFunc("/health", handler.HealthCheck)
+	// Webhook endpoints
+	routes.Handle("/api/v1/webhooks", handler.WebhookHandler, "GET", "HEAD", "POST")
+	routes.Handle("/api/v1/webhooks/dead-letters", handler.WebhookHandler, "GET", "HEAD")
+	routes.Handle("/api/v1/webhooks/{id}", handler.WebhookHandler, "GET", "HEAD", "DELETE")
+	routes.Handle("/api/v1/webhooks/{id}/deliveries", handler.WebhookHandler, "GET", "HEAD")
+	routes.Handle("/api/v1/webhooks/{id}/dead-letters", handler.WebhookHandler, "GET", "HEAD")
 
-	// Add CORS middleware
-	return cors(mux), nil
+	// API key endpoints
+	routes.Handle("/api/v1/api-keys", handler.APIKeyHandler, "GET", "HEAD", "POST")
+	routes.Handle("/api/v1/api-keys/{id}", handler.APIKeyHandler, "GET", "HEAD", "DELETE")
+
+	// Audit log endpoint
+	routes.Handle("/api/v1/audit", handler.GetAuditLog, "GET", "HEAD")
+
+	// Event stream; HEAD is left out as the stream never ends
+	routes.Handle("/api/v1/events", handler.StreamEvents, "GET")
+
+	// Lifecycle endpoint
+	routes.Handle("/api/v1/lifecycle", handler.GetLifecycle, "GET", "HEAD")
+
+	// Custom field schema endpoint
+	routes.Handle("/api/v1/custom-fields", handler.GetCustomFields, "GET", "HEAD")
+
+	// Report endpoints
+	routes.Handle("/api/v1/reports/utilization", handler.UtilizationReport, "GET", "HEAD")
+
+	// Health and probe endpoints
+	routes.Handle("/health", handler.HealthCheck, "GET", "HEAD")
+	routes.Handle("/ready", handler.Readiness, "GET", "HEAD")
+	routes.Handle("/live", handler.Liveness, "GET", "HEAD")
+
+	// Prometheus metrics endpoint
+	routes.Handle("/metrics", handler.GetMetrics, "GET", "HEAD")
+
+	// Cross-origin policy
+	corsPolicy := &cors.Policy{
+		AllowedOrigins:   getEnvList("CORS_ALLOWED_ORIGINS", []string{"*"}),
+		AllowedHeaders:   cors.DefaultAllowedHeaders,
+		ExposedHeaders:   getEnvList("CORS_EXPOSED_HEADERS", cors.DefaultExposedHeaders),
+		AllowCredentials: getEnv("CORS_ALLOW_CREDENTIALS", "false") == "true",
+		MaxAge:           getEnvDuration("CORS_MAX_AGE", 10*time.Minute),
+	}
+	if err := corsPolicy.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid CORS policy: %w", err)
+	}
+
+	// Add tracing, logging, metrics, CORS, authentication, rate limiting and idempotency middleware
+	api := handler.Authenticate(handler.RateLimit(handler.Idempotent(routes)))
+	chain := tracer.Handler(routes, handler.LogRequests(httpMetrics.Handler(routes, corsPolicy.Handler(routes, api))))
+
+	return &server{
+		handler:    chain,
+		logger:     logger,
+		scheduler:  scheduler.New(vlans, store, getEnvDuration("MAINTENANCE_INTERVAL", scheduler.DefaultInterval)),
+		dispatcher: dispatcher,
+		tracer:     tracer,
+		tls:        tlsConfig,
+		certs:      reloader,
+	}, nil
 }
 
-// cors adds CORS headers to responses
-func cors(next http.Handler) http.Handler {
-	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		w.Header().Set("Access-Control-Allow-Origin", "*")
-		w.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS")
-		w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization")
+// newTracer builds the tracer from the environment, nil when tracing is off
+func newTracer() (*tracing.Tracer, error) {
+	var exporter tracing.Exporter
+	switch kind := getEnv("TRACING_EXPORTER", "none"); kind {
+	case "none":
+		return nil, nil
+	case "otlp":
+		headers, err := tracing.ParseHeaders(getEnv("OTEL_EXPORTER_OTLP_HEADERS", ""))
+		if err != nil {
+			return nil, fmt.Errorf("invalid OTEL_EXPORTER_OTLP_HEADERS: %w", err)
+		}
+		endpoint := getEnv("OTEL_EXPORTER_OTLP_ENDPOINT", "http://localhost:4318")
+		exporter = tracing.NewOTLPExporter(endpoint, headers, &http.Client{Timeout: 10 * time.Second})
+	case "stdout":
+		exporter = tracing.NewWriterExporter(os.Stdout)
+	case "file":
+		path := getEnv("TRACING_FILE", "")
+		if path == "" {
+			return nil, fmt.Errorf("TRACING_EXPORTER=file requires TRACING_FILE")
+		}
+		file, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+		if err != nil {
+			return nil, fmt.Errorf("failed to open trace file: %w", err)
+		}
+		exporter = tracing.NewWriterExporter(file)
+	default:
+		return nil, fmt.Errorf("invalid TRACING_EXPORTER %q, must be none, otlp, stdout or file", kind)
+	}
+
+	ratio := getEnvFloat("TRACING_SAMPLE_RATIO", 1)
+	if ratio < 0 || ratio > 1 {
+		return nil, fmt.Errorf("invalid TRACING_SAMPLE_RATIO %v, must be between 0 and 1", ratio)
+	}
+	return tracing.New(exporter, tracing.Options{
+		ServiceName: getEnv("OTEL_SERVICE_NAME", "smit"),
+		SampleRatio: ratio,
+	}), nil
+}
 
-		if r.Method == "OPTIONS" {
-			w.WriteHeader(http.StatusOK)
-			return
+// newTLSConfig builds the TLS settings from the environment, nil when no certificate is configured
+func newTLSConfig() (*tls.Config, *certs.Reloader, error) {
+	certFile, keyFile := getEnv("TLS_CERT_FILE", ""), getEnv("TLS_KEY_FILE", "")
+	if certFile == "" && keyFile == "" {
+		if getEnv("TLS_CLIENT_CA_FILE", "") != "" {
+			return nil, nil, fmt.Errorf("TLS_CLIENT_CA_FILE requires TLS_CERT_FILE and TLS_KEY_FILE")
 		}
+		return nil, nil, nil
+	}
+	if certFile == "" || keyFile == "" {
+		return nil, nil, fmt.Errorf("TLS_CERT_FILE and TLS_KEY_FILE must be set together")
+	}
 
-		next.ServeHTTP(w, r)
-	})
+	minVersion, err := certs.ParseVersion(getEnv("TLS_MIN_VERSION", "1.2"))
+	if err != nil {
+		return nil, nil, err
+	}
+	reloader, err := certs.NewReloader(certFile, keyFile, getEnvDuration("TLS_RELOAD_INTERVAL", certs.DefaultInterval))
+	if err != nil {
+		return nil, nil, err
+	}
+	config := &tls.Config{
+		MinVersion:     minVersion,
+		GetCertificate: reloader.GetCertificate,
+	}
+
+	if caFile := getEnv("TLS_CLIENT_CA_FILE", ""); caFile != "" {
+		pool, err := certs.LoadCertPool(caFile)
+		if err != nil {
+			return nil, nil, err
+		}
+		config.ClientCAs = pool
+		// Clients without a certificate can still use a key or token unless certificates are required
+		switch clientAuth := getEnv("TLS_CLIENT_AUTH", "optional"); clientAuth {
+		case "optional":
+			config.ClientAuth = tls.VerifyClientCertIfGiven
+		case "require":
+			config.ClientAuth = tls.RequireAndVerifyClientCert
+		default:
+			return nil, nil, fmt.Errorf("invalid TLS_CLIENT_AUTH %q, must be optional or require", clientAuth)
+		}
+	}
+	return config, reloader, nil
 }
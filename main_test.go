@@ -1,14 +1,29 @@
 package main
 
 import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
 	"encoding/json"
+	"encoding/pem"
+	"fmt"
 	"io"
+	"math/big"
+	"net"
 	"net/http"
 	"net/http/httptest"
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
+	"time"
 
+	"smit/server/api/auth"
 	"smit/server/api/models"
 )
 
@@ -59,87 +74,415 @@ func TestGetEnv(t *testing.T) {
 	}
 }
 
-func TestCorsMiddleware(t *testing.T) {
-	// Create a test handler
-	testHandler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		w.WriteHeader(http.StatusOK)
-		w.Write([]byte("OK"))
-	})
+func TestGetEnvFloat(t *testing.T) {
+	os.Setenv("TEST_FLOAT", "92.5")
+	defer os.Unsetenv("TEST_FLOAT")
+	if got := getEnvFloat("TEST_FLOAT", 80); got != 92.5 {
+		t.Errorf("Expected 92.5, got %v", got)
+	}
+
+	os.Setenv("TEST_FLOAT_INVALID", "abc")
+	defer os.Unsetenv("TEST_FLOAT_INVALID")
+	if got := getEnvFloat("TEST_FLOAT_INVALID", 80); got != 80 {
+		t.Errorf("Expected default 80 for invalid value, got %v", got)
+	}
+
+	if got := getEnvFloat("UNSET_FLOAT", 80); got != 80 {
+		t.Errorf("Expected default 80, got %v", got)
+	}
+}
+
+func TestGetEnvInt(t *testing.T) {
+	os.Setenv("TEST_INT", "250")
+	defer os.Unsetenv("TEST_INT")
+	if got := getEnvInt("TEST_INT", 1000); got != 250 {
+		t.Errorf("Expected 250, got %d", got)
+	}
 
-	// Wrap with CORS middleware
-	handler := cors(testHandler)
+	os.Setenv("TEST_INT_INVALID", "many")
+	defer os.Unsetenv("TEST_INT_INVALID")
+	if got := getEnvInt("TEST_INT_INVALID", 1000); got != 1000 {
+		t.Errorf("Expected default 1000 for invalid value, got %d", got)
+	}
+}
+
+func TestGetEnvDuration(t *testing.T) {
+	os.Setenv("TEST_DURATION", "90s")
+	defer os.Unsetenv("TEST_DURATION")
+	if got := getEnvDuration("TEST_DURATION", time.Minute); got != 90*time.Second {
+		t.Errorf("Expected 90s, got %v", got)
+	}
+
+	os.Setenv("TEST_DURATION_INVALID", "soon")
+	defer os.Unsetenv("TEST_DURATION_INVALID")
+	if got := getEnvDuration("TEST_DURATION_INVALID", time.Minute); got != time.Minute {
+		t.Errorf("Expected default 1m for invalid value, got %v", got)
+	}
+
+	if got := getEnvDuration("UNSET_DURATION", time.Minute); got != time.Minute {
+		t.Errorf("Expected default 1m, got %v", got)
+	}
+}
+
+func TestServerCORS(t *testing.T) {
+	tmpDir := t.TempDir()
+	t.Setenv("CORS_ALLOWED_ORIGINS", "https://app.example.com, https://*.noc.example.com")
+	t.Setenv("CORS_ALLOW_CREDENTIALS", "true")
+	t.Setenv("CORS_MAX_AGE", "1h")
+
+	handler, err := setupServer(filepath.Join(tmpDir, "cors_test_data.json"))
+	if err != nil {
+		t.Fatalf("Failed to setup server: %v", err)
+	}
 
 	tests := []struct {
-		name           string
-		method         string
-		expectedStatus int
-		expectedBody   string
+		name            string
+		method          string
+		path            string
+		origin          string
+		expectedStatus  int
+		expectedOrigin  string
+		expectedMethods string
 	}{
-		{
-			name:           "GET request",
-			method:         "GET",
-			expectedStatus: http.StatusOK,
-			expectedBody:   "OK",
-		},
-		{
-			name:           "POST request",
-			method:         "POST",
-			expectedStatus: http.StatusOK,
-			expectedBody:   "OK",
-		},
-		{
-			name:           "OPTIONS request",
-			method:         "OPTIONS",
-			expectedStatus: http.StatusOK,
-			expectedBody:   "",
-		},
-		{
-			name:           "PUT request",
-			method:         "PUT",
-			expectedStatus: http.StatusOK,
-			expectedBody:   "OK",
-		},
-		{
-			name:           "DELETE request",
-			method:         "DELETE",
-			expectedStatus: http.StatusOK,
-			expectedBody:   "OK",
-		},
+		{"Preflight for a collection", "OPTIONS", "/api/v1/vlans", "https://app.example.com", http.StatusOK, "https://app.example.com", "GET, HEAD, POST"},
+		{"Preflight for an item", "OPTIONS", "/api/v1/vlans/7", "https://app.example.com", http.StatusOK, "https://app.example.com", "GET, HEAD, PUT, PATCH, DELETE"},
+		{"Preflight for a sub-resource", "OPTIONS", "/api/v1/prefixes/1/allocate", "https://tallinn.noc.example.com", http.StatusOK, "https://tallinn.noc.example.com", "POST"},
+		{"Preflight from another origin", "OPTIONS", "/api/v1/vlans", "https://evil.example.com", http.StatusOK, "", ""},
+		{"Preflight for an unknown path", "OPTIONS", "/api/v1/unknown", "https://app.example.com", http.StatusNotFound, "https://app.example.com", ""},
+		{"Request from an allowed origin", "GET", "/api/v1/vlans", "https://app.example.com", http.StatusOK, "https://app.example.com", ""},
+		{"Request from another origin", "GET", "/api/v1/vlans", "https://noc.example.com", http.StatusOK, "", ""},
+		{"HEAD request", "HEAD", "/api/v1/vlans", "", http.StatusOK, "", ""},
 	}
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			req := httptest.NewRequest(tt.method, "/test", nil)
+			req := httptest.NewRequest(tt.method, tt.path, nil)
+			if tt.origin != "" {
+				req.Header.Set("Origin", tt.origin)
+			}
+			if tt.method == "OPTIONS" {
+				req.Header.Set("Access-Control-Request-Method", "POST")
+			}
 			w := httptest.NewRecorder()
-
 			handler.ServeHTTP(w, req)
 
 			if w.Code != tt.expectedStatus {
-				t.Errorf("Expected status %d, got %d", tt.expectedStatus, w.Code)
+				t.Fatalf("Expected status %d, got %d", tt.expectedStatus, w.Code)
 			}
-
-			// Check CORS headers
-			if origin := w.Header().Get("Access-Control-Allow-Origin"); origin != "*" {
-				t.Errorf("Expected Access-Control-Allow-Origin to be *, got %s", origin)
+			if origin := w.Header().Get("Access-Control-Allow-Origin"); origin != tt.expectedOrigin {
+				t.Errorf("Expected Access-Control-Allow-Origin %q, got %q", tt.expectedOrigin, origin)
 			}
-
-			if methods := w.Header().Get("Access-Control-Allow-Methods"); methods != "GET, POST, PUT, DELETE, OPTIONS" {
-				t.Errorf("Expected Access-Control-Allow-Methods to be 'GET, POST, PUT, DELETE, OPTIONS', got %s", methods)
+			if methods := w.Header().Get("Access-Control-Allow-Methods"); methods != tt.expectedMethods {
+				t.Errorf("Expected Access-Control-Allow-Methods %q, got %q", tt.expectedMethods, methods)
 			}
-
-			if headers := w.Header().Get("Access-Control-Allow-Headers"); headers != "Content-Type, Authorization" {
-				t.Errorf("Expected Access-Control-Allow-Headers to be 'Content-Type, Authorization', got %s", headers)
+			if tt.expectedOrigin == "" && w.Header().Get("Access-Control-Allow-Credentials") != "" {
+				t.Error("Expected no CORS headers for a disallowed origin")
 			}
+			if tt.expectedOrigin != "" && w.Header().Get("Access-Control-Allow-Credentials") != "true" {
+				t.Error("Expected credentials to be allowed")
+			}
+			if tt.expectedMethods != "" && w.Header().Get("Access-Control-Max-Age") != "3600" {
+				t.Errorf("Expected a max age of 3600, got %q", w.Header().Get("Access-Control-Max-Age"))
+			}
+		})
+	}
+
+	// Credentials cannot be combined with every origin
+	t.Setenv("CORS_ALLOWED_ORIGINS", "*")
+	if _, err := setupServer(filepath.Join(tmpDir, "cors_test_data.json")); err == nil {
+		t.Error("Expected an error for credentials with a wildcard origin")
+	}
+}
+
+func TestServerRateLimit(t *testing.T) {
+	tmpDir := t.TempDir()
+	t.Setenv("RATE_LIMIT_READ_PER_MINUTE", "2")
+	t.Setenv("RATE_LIMIT_WRITE_PER_MINUTE", "1")
+
+	handler, err := setupServer(filepath.Join(tmpDir, "ratelimit_test_data.json"))
+	if err != nil {
+		t.Fatalf("Failed to setup server: %v", err)
+	}
+
+	send := func(method, path, origin string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, nil)
+		if origin != "" {
+			req.Header.Set("Origin", origin)
+		}
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+		return w
+	}
+
+	for i := 0; i < 2; i++ {
+		if w := send("GET", "/api/v1/vlans", ""); w.Code != http.StatusOK {
+			t.Fatalf("Expected status %d, got %d", http.StatusOK, w.Code)
+		}
+	}
+	w := send("GET", "/api/v1/vlans", "https://app.example.com")
+	if w.Code != http.StatusTooManyRequests || w.Header().Get("Retry-After") == "" {
+		t.Fatalf("Expected status %d with Retry-After, got %d", http.StatusTooManyRequests, w.Code)
+	}
+	// Browsers may read the limit headers
+	if exposed := w.Header().Get("Access-Control-Expose-Headers"); !strings.Contains(exposed, "Retry-After") {
+		t.Errorf("Expected Retry-After to be exposed, got %q", exposed)
+	}
+
+	// Preflights and health checks are not counted
+	if w := send("OPTIONS", "/api/v1/vlans", "https://app.example.com"); w.Code != http.StatusOK {
+		t.Errorf("Expected preflight status %d, got %d", http.StatusOK, w.Code)
+	}
+	if w := send("GET", "/health", ""); w.Code != http.StatusOK {
+		t.Errorf("Expected health status %d, got %d", http.StatusOK, w.Code)
+	}
+
+	t.Setenv("RATE_LIMIT_WRITE_PER_MINUTE", "-1")
+	if _, err := setupServer(filepath.Join(tmpDir, "ratelimit_test_data.json")); err == nil {
+		t.Error("Expected an error for a negative rate limit")
+	}
+}
+
+func TestServerRequestIDs(t *testing.T) {
+	tmpDir := t.TempDir()
+	t.Setenv("LOG_LEVEL", "error")
+
+	handler, err := setupServer(filepath.Join(tmpDir, "logging_test_data.json"))
+	if err != nil {
+		t.Fatalf("Failed to setup server: %v", err)
+	}
+	ts := httptest.NewServer(handler)
+	defer ts.Close()
+
+	req, _ := http.NewRequest("GET", ts.URL+"/api/v1/vlans/999", nil)
+	req.Header.Set("X-Request-ID", "trace-0001")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("Failed to make request: %v", err)
+	}
+	var problem models.Problem
+	json.NewDecoder(resp.Body).Decode(&problem)
+	resp.Body.Close()
+	if resp.Header.Get("X-Request-ID") != "trace-0001" || problem.RequestID != "trace-0001" {
+		t.Errorf("Expected the request ID in the header and body, got %q and %q", resp.Header.Get("X-Request-ID"), problem.RequestID)
+	}
+
+	// The event stream still flushes through the logging middleware
+	resp, err = http.Get(ts.URL + "/api/v1/events")
+	if err != nil {
+		t.Fatalf("Failed to open event stream: %v", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK || resp.Header.Get("Content-Type") != "text/event-stream" {
+		t.Errorf("Expected an event stream, got %d %q", resp.StatusCode, resp.Header.Get("Content-Type"))
+	}
+
+	t.Setenv("LOG_LEVEL", "verbose")
+	if _, err := setupServer(filepath.Join(tmpDir, "logging_test_data.json")); err == nil {
+		t.Error("Expected an error for an unknown log level")
+	}
+}
+
+func TestServerMetrics(t *testing.T) {
+	tmpDir := t.TempDir()
+	t.Setenv("LOG_LEVEL", "error")
+
+	handler, err := setupServer(filepath.Join(tmpDir, "metrics_test_data.json"))
+	if err != nil {
+		t.Fatalf("Failed to setup server: %v", err)
+	}
+
+	send := func(method, path, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+		return w
+	}
+
+	send("POST", "/api/v1/vlans", `{"name": "Office", "vlan_id": 100, "subnet": "10.1.0.0/24", "gateway": "10.1.0.1", "status": "active"}`)
+	send("GET", "/api/v1/vlans/1", "")
+	send("GET", "/api/v1/vlans/999", "")
+
+	w := send("GET", "/metrics", "")
+	if w.Code != http.StatusOK || !strings.HasPrefix(w.Header().Get("Content-Type"), "text/plain; version=0.0.4") {
+		t.Fatalf("Expected the text exposition format, got %d %q", w.Code, w.Header().Get("Content-Type"))
+	}
+	for _, expected := range []string{
+		`smit_http_requests_total{method="POST",route="/api/v1/vlans",status="201"} 1`,
+		`smit_http_requests_total{method="GET",route="/api/v1/vlans/{id}",status="200"} 1`,
+		`smit_http_requests_total{method="GET",route="/api/v1/vlans/{id}",status="404"} 1`,
+		`smit_http_request_duration_seconds_bucket{method="POST",route="/api/v1/vlans",status="201",le="+Inf"} 1`,
+		`smit_storage_operation_duration_seconds_count{backend="json",method="Create"} 1`,
+		`smit_storage_errors_total{backend="json",method="GetByID"}`,
+		`smit_vlans{status="active"} 1`,
+		"smit_data_file_size_bytes ",
+		"smit_data_last_save_timestamp_seconds ",
+	} {
+		if !strings.Contains(w.Body.String(), expected) {
+			t.Errorf("Expected %q in:\n%s", expected, w.Body.String())
+		}
+	}
+}
+
+func TestServerTracing(t *testing.T) {
+	tmpDir := t.TempDir()
+	traceFile := filepath.Join(tmpDir, "traces.jsonl")
+	t.Setenv("LOG_LEVEL", "error")
+	t.Setenv("TRACING_EXPORTER", "file")
+	t.Setenv("TRACING_FILE", traceFile)
+	t.Setenv("OTEL_SERVICE_NAME", "smit-test")
+	t.Setenv("WEBHOOK_ALLOWED_NETWORKS", "127.0.0.0/8, ::1/128")
+
+	srv, err := newServer(filepath.Join(tmpDir, "tracing_test_data.json"))
+	if err != nil {
+		t.Fatalf("Failed to setup server: %v", err)
+	}
+
+	// Webhook deliveries of the change join the request's trace
+	traceparents := make(chan string, 1)
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case traceparents <- r.Header.Get("traceparent"):
+		default:
+		}
+	}))
+	defer receiver.Close()
+	req := httptest.NewRequest("POST", "/api/v1/webhooks", strings.NewReader(`{"url": "`+receiver.URL+`"}`))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	srv.handler.ServeHTTP(w, req)
+	if w.Code != http.StatusCreated {
+		t.Fatalf("Expected status %d, got %d: %s", http.StatusCreated, w.Code, w.Body.String())
+	}
+	dispatching, stopDispatcher := context.WithCancel(context.Background())
+	dispatched := make(chan struct{})
+	go func() {
+		srv.dispatcher.Run(dispatching)
+		close(dispatched)
+	}()
+
+	// Changes made before the dispatcher subscribed are not delivered, so keep creating until one is
+	var traceparent string
+	deadline := time.After(5 * time.Second)
+	for tag := 100; traceparent == ""; tag++ {
+		body := fmt.Sprintf(`{"name": "Office", "vlan_id": %d, "subnet": "10.1.%d.0/24", "gateway": "10.1.%d.1", "status": "active"}`, tag, tag, tag)
+		req = httptest.NewRequest("POST", "/api/v1/vlans", strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("traceparent", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+		w = httptest.NewRecorder()
+		srv.handler.ServeHTTP(w, req)
+		if w.Code != http.StatusCreated {
+			t.Fatalf("Expected status %d, got %d", http.StatusCreated, w.Code)
+		}
+
+		select {
+		case traceparent = <-traceparents:
+		case <-deadline:
+			t.Fatal("Timed out waiting for a webhook delivery")
+		case <-time.After(10 * time.Millisecond):
+		}
+	}
+	if !strings.HasPrefix(traceparent, "00-4bf92f3577b34da6a3ce929d0e0e4736-") {
+		t.Errorf("Expected the delivery to carry the request's trace, got %q", traceparent)
+	}
+	// Stopping the dispatcher waits for the delivery to finish
+	stopDispatcher()
+	<-dispatched
+
+	// Run exports what is queued once its context is done
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	srv.tracer.Run(ctx)
 
-			// Check body
-			body := w.Body.String()
-			if body != tt.expectedBody {
-				t.Errorf("Expected body '%s', got '%s'", tt.expectedBody, body)
+	data, err := os.ReadFile(traceFile)
+	if err != nil {
+		t.Fatalf("Failed to read traces: %v", err)
+	}
+	for _, expected := range []string{
+		`"stringValue":"smit-test"`,
+		`"traceId":"4bf92f3577b34da6a3ce929d0e0e4736"`,
+		`"parentSpanId":"00f067aa0ba902b7"`,
+		`"name":"POST /api/v1/vlans"`,
+		`"name":"CreateVLAN"`,
+		`"name":"ValidateVLAN"`,
+		`"name":"storage.Create"`,
+		`"name":"webhook.deliver"`,
+	} {
+		if !strings.Contains(string(data), expected) {
+			t.Errorf("Expected %s in the exported traces:\n%s", expected, data)
+		}
+	}
+
+	for env, value := range map[string]string{
+		"TRACING_EXPORTER":           "zipkin",
+		"TRACING_FILE":               "",
+		"TRACING_SAMPLE_RATIO":       "2",
+		"OTEL_EXPORTER_OTLP_HEADERS": "Authorization",
+	} {
+		t.Run(env, func(t *testing.T) {
+			if env == "OTEL_EXPORTER_OTLP_HEADERS" {
+				t.Setenv("TRACING_EXPORTER", "otlp")
+			}
+			t.Setenv(env, value)
+			if _, err := newServer(filepath.Join(tmpDir, "invalid_tracing_data.json")); err == nil {
+				t.Errorf("Expected %s=%q to be rejected", env, value)
 			}
 		})
 	}
 }
 
+func TestServerProbes(t *testing.T) {
+	tmpDir := t.TempDir()
+	dataFile := filepath.Join(tmpDir, "probes_test_data.json")
+	t.Setenv("LOG_LEVEL", "error")
+
+	// Probes stay reachable when authentication is on
+	keysFile := filepath.Join(tmpDir, "keys.json")
+	config := `[{"id": 1, "name": "bootstrap", "hash": "` + auth.HashKey("bootstrap-key-0123456789") + `"}]`
+	if err := os.WriteFile(keysFile, []byte(config), 0600); err != nil {
+		t.Fatalf("Failed to write keys file: %v", err)
+	}
+	t.Setenv("AUTH_KEYS_FILE", keysFile)
+
+	handler, err := setupServer(dataFile)
+	if err != nil {
+		t.Fatalf("Failed to setup server: %v", err)
+	}
+
+	send := func(path string) (*httptest.ResponseRecorder, models.ProbeResponse) {
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, httptest.NewRequest("GET", path, nil))
+		var response models.ProbeResponse
+		json.NewDecoder(w.Body).Decode(&response)
+		return w, response
+	}
+
+	w, response := send("/ready")
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status %d, got %d: %+v", http.StatusOK, w.Code, response)
+	}
+	for _, name := range []string{"storage", "disk"} {
+		if response.Checks[name].Status != "pass" {
+			t.Errorf("Expected the %s check to pass, got %+v", name, response.Checks[name])
+		}
+	}
+
+	// A corrupt data file takes the server out of rotation without restarting it
+	if err := os.WriteFile(dataFile, []byte("{corrupt"), 0644); err != nil {
+		t.Fatalf("Failed to corrupt data file: %v", err)
+	}
+	w, response = send("/ready")
+	if w.Code != http.StatusServiceUnavailable || response.Checks["storage"].Status != "fail" || response.Checks["storage"].Error == "" {
+		t.Errorf("Expected the storage check to fail, got %d %+v", w.Code, response)
+	}
+	if w, _ := send("/live"); w.Code != http.StatusOK {
+		t.Errorf("Expected liveness to pass, got %d", w.Code)
+	}
+	if w, _ := send("/health"); w.Code != http.StatusOK {
+		t.Errorf("Expected the health check to keep answering, got %d", w.Code)
+	}
+}
+
 func TestSetupServer(t *testing.T) {
 	// Create temporary directory for test data
 	tmpDir := t.TempDir()
@@ -181,7 +524,9 @@ func TestSetupServer(t *testing.T) {
 	}
 
 	// Test VLAN endpoints
-	resp2, err := http.Get(ts.URL + "/api/v1/vlans")
+	req, _ := http.NewRequest("GET", ts.URL+"/api/v1/vlans", nil)
+	req.Header.Set("Origin", "https://app.example.com")
+	resp2, err := http.DefaultClient.Do(req)
 	if err != nil {
 		t.Fatalf("Failed to make request: %v", err)
 	}
@@ -207,6 +552,21 @@ func TestSetupServerError(t *testing.T) {
 	}
 }
 
+func TestSetupServerUtilizationThreshold(t *testing.T) {
+	tmpDir := t.TempDir()
+	for _, value := range []string{"-1", "100.5"} {
+		t.Setenv("UTILIZATION_THRESHOLD", value)
+		if _, err := setupServer(filepath.Join(tmpDir, "threshold_test_data.json")); err == nil {
+			t.Errorf("Expected UTILIZATION_THRESHOLD=%s to be rejected", value)
+		}
+	}
+
+	t.Setenv("UTILIZATION_THRESHOLD", "100")
+	if _, err := setupServer(filepath.Join(tmpDir, "threshold_test_data.json")); err != nil {
+		t.Errorf("Expected a threshold of 100 to be accepted, got %v", err)
+	}
+}
+
 func TestFullServerIntegration(t *testing.T) {
 	// Create temporary directory for test data
 	tmpDir := t.TempDir()
@@ -285,6 +645,288 @@ func TestFullServerIntegration(t *testing.T) {
 	})
 }
 
+func TestServerAuthentication(t *testing.T) {
+	tmpDir := t.TempDir()
+	key := "bootstrap-key-0123456789"
+	keysFile := filepath.Join(tmpDir, "keys.json")
+	config := `[{"id": 1, "name": "bootstrap", "hash": "` + auth.HashKey(key) + `"}]`
+	if err := os.WriteFile(keysFile, []byte(config), 0600); err != nil {
+		t.Fatalf("Failed to write keys file: %v", err)
+	}
+	t.Setenv("AUTH_KEYS_FILE", keysFile)
+
+	handler, err := setupServer(filepath.Join(tmpDir, "auth_test_data.json"))
+	if err != nil {
+		t.Fatalf("Failed to setup server: %v", err)
+	}
+	ts := httptest.NewServer(handler)
+	defer ts.Close()
+
+	tests := []struct {
+		name     string
+		method   string
+		path     string
+		key      string
+		expected int
+	}{
+		{"Health without key", "GET", "/health", "", http.StatusOK},
+		{"VLANs without key", "GET", "/api/v1/vlans", "", http.StatusUnauthorized},
+		{"Unknown route without key", "GET", "/invalid/endpoint", "", http.StatusUnauthorized},
+		{"Preflight without key", "OPTIONS", "/api/v1/vlans", "", http.StatusOK},
+		{"VLANs with key", "GET", "/api/v1/vlans", key, http.StatusOK},
+		{"API keys with key", "GET", "/api/v1/api-keys", key, http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, _ := http.NewRequest(tt.method, ts.URL+tt.path, nil)
+			if tt.key != "" {
+				req.Header.Set("Authorization", "Bearer "+tt.key)
+			}
+			resp, err := http.DefaultClient.Do(req)
+			if err != nil {
+				t.Fatalf("Failed to make request: %v", err)
+			}
+			defer resp.Body.Close()
+
+			if resp.StatusCode != tt.expected {
+				t.Errorf("Expected status %d, got %d", tt.expected, resp.StatusCode)
+			}
+		})
+	}
+}
+
+func TestServerAccessPolicy(t *testing.T) {
+	tmpDir := t.TempDir()
+	policyFile := filepath.Join(tmpDir, "policy.json")
+	policy := `{"roles": {"viewer": {"actions": ["vlan:read"]}, "admin": {"actions": ["*"]}}}`
+	if err := os.WriteFile(policyFile, []byte(policy), 0644); err != nil {
+		t.Fatalf("Failed to write policy file: %v", err)
+	}
+	t.Setenv("RBAC_POLICY_FILE", policyFile)
+
+	// Roles come from API keys, so a policy without authentication is a configuration error
+	if _, err := setupServer(filepath.Join(tmpDir, "policy_test_data.json")); err == nil {
+		t.Fatal("Expected an error without AUTH_KEYS_FILE")
+	}
+
+	keysFile := filepath.Join(tmpDir, "keys.json")
+	keys := `[{"id": 1, "name": "dashboard", "role": "viewer", "hash": "` + auth.HashKey("viewer-key-0123456789") + `"}]`
+	if err := os.WriteFile(keysFile, []byte(keys), 0600); err != nil {
+		t.Fatalf("Failed to write keys file: %v", err)
+	}
+	t.Setenv("AUTH_KEYS_FILE", keysFile)
+	t.Setenv("AUDIT_LOG_FILE", filepath.Join(tmpDir, "audit.log"))
+
+	handler, err := setupServer(filepath.Join(tmpDir, "policy_test_data.json"))
+	if err != nil {
+		t.Fatalf("Failed to setup server: %v", err)
+	}
+	ts := httptest.NewServer(handler)
+	defer ts.Close()
+
+	for _, tt := range []struct {
+		method   string
+		expected int
+	}{
+		{"GET", http.StatusOK},
+		{"POST", http.StatusForbidden},
+	} {
+		req, _ := http.NewRequest(tt.method, ts.URL+"/api/v1/vlans", strings.NewReader(`{"name": "Lab", "vlan_id": 310}`))
+		req.Header.Set("X-API-Key", "viewer-key-0123456789")
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("Failed to make request: %v", err)
+		}
+		resp.Body.Close()
+
+		if resp.StatusCode != tt.expected {
+			t.Errorf("%s: expected status %d, got %d", tt.method, tt.expected, resp.StatusCode)
+		}
+	}
+
+	data, err := os.ReadFile(filepath.Join(tmpDir, "audit.log"))
+	if err != nil || !strings.Contains(string(data), `"decision":"denied"`) {
+		t.Errorf("Expected the denial in the audit log file, got %q, %v", data, err)
+	}
+}
+
+func TestServerJWT(t *testing.T) {
+	tmpDir := t.TempDir()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("Failed to generate key: %v", err)
+	}
+	jwks := `{"keys": [{"kty": "EC", "crv": "P-256", "x": "` + base64.RawURLEncoding.EncodeToString(key.X.Bytes()) +
+		`", "y": "` + base64.RawURLEncoding.EncodeToString(key.Y.Bytes()) + `"}]}`
+	jwksFile := filepath.Join(tmpDir, "jwks.json")
+	if err := os.WriteFile(jwksFile, []byte(jwks), 0644); err != nil {
+		t.Fatalf("Failed to write JWKS: %v", err)
+	}
+	policyFile := filepath.Join(tmpDir, "policy.json")
+	if err := os.WriteFile(policyFile, []byte(`{"roles": {"viewer": {"actions": ["vlan:read"]}}}`), 0644); err != nil {
+		t.Fatalf("Failed to write policy file: %v", err)
+	}
+	t.Setenv("JWT_JWKS", jwksFile)
+	t.Setenv("RBAC_POLICY_FILE", policyFile)
+
+	// Tokens for any issuer or audience would be accepted otherwise
+	if _, err := setupServer(filepath.Join(tmpDir, "jwt_test_data.json")); err == nil {
+		t.Fatal("Expected an error without JWT_ISSUER and JWT_AUDIENCE")
+	}
+
+	// A JWKS is enough authentication for an access policy
+	t.Setenv("JWT_ISSUER", "https://idp.example.com")
+	t.Setenv("JWT_AUDIENCE", "smit")
+	t.Setenv("JWT_ROLE_MAP", "netops=viewer")
+	handler, err := setupServer(filepath.Join(tmpDir, "jwt_test_data.json"))
+	if err != nil {
+		t.Fatalf("Failed to setup server: %v", err)
+	}
+	ts := httptest.NewServer(handler)
+	defer ts.Close()
+
+	for _, token := range []string{"", "a.b.c"} {
+		req, _ := http.NewRequest("GET", ts.URL+"/api/v1/vlans", nil)
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("Failed to make request: %v", err)
+		}
+		resp.Body.Close()
+
+		if resp.StatusCode != http.StatusUnauthorized {
+			t.Errorf("Token %q: expected status %d, got %d", token, http.StatusUnauthorized, resp.StatusCode)
+		}
+	}
+
+	t.Setenv("JWT_ROLE_MAP", "netops")
+	if _, err := setupServer(filepath.Join(tmpDir, "jwt_test_data.json")); err == nil {
+		t.Error("Expected an error for an invalid role map")
+	}
+}
+
+// Issue a certificate signed by the parent, or self-signed when parent is nil, and write it as PEM
+func issueCertificate(t *testing.T, dir, name string, template *x509.Certificate, parent *tls.Certificate) tls.Certificate {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("Failed to generate key: %v", err)
+	}
+	template.SerialNumber = big.NewInt(time.Now().UnixNano())
+	template.NotBefore = time.Now().Add(-time.Hour)
+	template.NotAfter = time.Now().Add(time.Hour)
+
+	issuer, signer := template, interface{}(key)
+	if parent != nil {
+		issuer, signer = parent.Leaf, parent.PrivateKey
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, issuer, &key.PublicKey, signer)
+	if err != nil {
+		t.Fatalf("Failed to create certificate: %v", err)
+	}
+	keyDER, _ := x509.MarshalECPrivateKey(key)
+	os.WriteFile(filepath.Join(dir, name+".crt"), pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0644)
+	os.WriteFile(filepath.Join(dir, name+".key"), pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0600)
+
+	leaf, _ := x509.ParseCertificate(der)
+	return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key, Leaf: leaf}
+}
+
+func TestServerTLS(t *testing.T) {
+	tmpDir := t.TempDir()
+	ca := issueCertificate(t, tmpDir, "ca", &x509.Certificate{
+		Subject: pkix.Name{CommonName: "Test CA"}, IsCA: true, BasicConstraintsValid: true,
+		KeyUsage: x509.KeyUsageCertSign,
+	}, nil)
+	issueCertificate(t, tmpDir, "server", &x509.Certificate{
+		Subject: pkix.Name{CommonName: "localhost"}, IPAddresses: []net.IP{net.ParseIP("127.0.0.1")},
+		ExtKeyUsage: []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}, &ca)
+	client := issueCertificate(t, tmpDir, "client", &x509.Certificate{
+		Subject:     pkix.Name{CommonName: "deploy-bot", OrganizationalUnit: []string{"automation"}},
+		ExtKeyUsage: []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}, &ca)
+
+	policyFile := filepath.Join(tmpDir, "policy.json")
+	os.WriteFile(policyFile, []byte(`{"roles": {"viewer": {"actions": ["vlan:read"]}}}`), 0644)
+	t.Setenv("RBAC_POLICY_FILE", policyFile)
+	dataFile := filepath.Join(tmpDir, "tls_test_data.json")
+
+	// Configuration errors
+	for _, env := range []map[string]string{
+		{"TLS_CLIENT_CA_FILE": filepath.Join(tmpDir, "ca.crt")},
+		{"TLS_CERT_FILE": filepath.Join(tmpDir, "server.crt")},
+		{"TLS_CERT_FILE": filepath.Join(tmpDir, "server.crt"), "TLS_KEY_FILE": filepath.Join(tmpDir, "server.key"), "TLS_MIN_VERSION": "1.1"},
+	} {
+		for key, value := range env {
+			t.Setenv(key, value)
+		}
+		if _, err := newServer(dataFile); err == nil {
+			t.Errorf("Expected an error for %v", env)
+		}
+		for key := range env {
+			os.Unsetenv(key)
+		}
+	}
+
+	t.Setenv("TLS_CERT_FILE", filepath.Join(tmpDir, "server.crt"))
+	t.Setenv("TLS_KEY_FILE", filepath.Join(tmpDir, "server.key"))
+	t.Setenv("TLS_CLIENT_CA_FILE", filepath.Join(tmpDir, "ca.crt"))
+	t.Setenv("TLS_CLIENT_ROLE_MAP", "automation=viewer")
+	t.Setenv("TLS_MIN_VERSION", "1.3")
+
+	srv, err := newServer(dataFile)
+	if err != nil {
+		t.Fatalf("Failed to setup server: %v", err)
+	}
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	go http.Serve(tls.NewListener(listener, srv.tls), srv.handler)
+	defer listener.Close()
+	url := "https://" + listener.Addr().String()
+
+	roots := x509.NewCertPool()
+	roots.AddCert(ca.Leaf)
+	tests := []struct {
+		name     string
+		config   *tls.Config
+		path     string
+		expected int
+	}{
+		{"Client certificate", &tls.Config{RootCAs: roots, Certificates: []tls.Certificate{client}}, "/api/v1/vlans", http.StatusOK},
+		{"Certificate role", &tls.Config{RootCAs: roots, Certificates: []tls.Certificate{client}}, "/api/v1/audit", http.StatusForbidden},
+		{"No client certificate", &tls.Config{RootCAs: roots}, "/api/v1/vlans", http.StatusUnauthorized},
+		{"Health without certificate", &tls.Config{RootCAs: roots}, "/health", http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			httpClient := &http.Client{Transport: &http.Transport{TLSClientConfig: tt.config}}
+			resp, err := httpClient.Get(url + tt.path)
+			if err != nil {
+				t.Fatalf("Failed to make request: %v", err)
+			}
+			resp.Body.Close()
+
+			if resp.StatusCode != tt.expected {
+				t.Errorf("Expected status %d, got %d", tt.expected, resp.StatusCode)
+			}
+		})
+	}
+
+	// Clients below the minimum version fail the handshake
+	old := &http.Client{Transport: &http.Transport{TLSClientConfig: &tls.Config{RootCAs: roots, MaxVersion: tls.VersionTLS12}}}
+	if resp, err := old.Get(url + "/health"); err == nil {
+		resp.Body.Close()
+		t.Error("Expected a TLS 1.2 client to be rejected")
+	}
+}
+
 func TestMainFunction(t *testing.T) {
 	// This test verifies that main() can be called without errors
 
@@ -342,3 +984,37 @@ func TestMainFunction(t *testing.T) {
 		})
 	}
 }
+
+func TestServerIPv6Lookup(t *testing.T) {
+	t.Setenv("LOG_LEVEL", "error")
+	handler, err := setupServer(filepath.Join(t.TempDir(), "ipv6_test_data.json"))
+	if err != nil {
+		t.Fatalf("Failed to setup server: %v", err)
+	}
+	ts := httptest.NewServer(handler)
+	defer ts.Close()
+
+	body := `{"name": "IPv6 servers", "vlan_id": 600, "subnet": "2001:db8:6::/64", "gateway": "2001:db8:6::1", "status": "active"}`
+	resp, err := http.Post(ts.URL+"/api/v1/vlans", "application/json", strings.NewReader(body))
+	if err != nil {
+		t.Fatalf("Failed to create VLAN: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusCreated {
+		t.Fatalf("Expected status %d, got %d", http.StatusCreated, resp.StatusCode)
+	}
+
+	resp, err = http.Get(ts.URL + "/api/v1/lookup/ip/2001:db8:6::abcd")
+	if err != nil {
+		t.Fatalf("Failed to look up address: %v", err)
+	}
+	defer resp.Body.Close()
+
+	var result models.IPLookupResult
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		t.Fatalf("Failed to decode lookup: %v", err)
+	}
+	if len(result.Matches) != 1 || result.Matches[0].VLAN.VlanID != 600 {
+		t.Errorf("Expected the IPv6 VLAN to match, got %+v", result.Matches)
+	}
+}
@@ -0,0 +1,99 @@
+// Package audit records security-relevant decisions, such as writes and denied
+// actions, as JSON lines and keeps the most recent entries in memory.
+package audit
+
+import (
+	"encoding/json"
+	"io"
+	"log"
+	"sync"
+	"time"
+)
+
+// Default number of entries kept in memory
+const DefaultSize = 1000
+
+// Outcomes of an authorization decision
+const (
+	DecisionAllowed = "allowed"
+	DecisionDenied  = "denied"
+)
+
+// One audited action
+type Entry struct {
+	Time time.Time `json:"time"`
+	// Caller and role; empty for anonymous callers
+	Subject  string `json:"subject,omitempty"`
+	KeyID    int    `json:"key_id,omitempty"`
+	Role     string `json:"role,omitempty"`
+	Action   string `json:"action"`
+	Method   string `json:"method"`
+	Path     string `json:"path"`
+	Decision string `json:"decision"`
+	Reason   string `json:"reason,omitempty"`
+}
+
+// Audit log writing to an optional sink and keeping a ring of recent entries.
+// The log is safe for concurrent use.
+type Log struct {
+	mu      sync.Mutex
+	out     io.Writer
+	entries []Entry
+	next    int
+	full    bool
+	now     func() time.Time
+}
+
+// Create a log keeping the given number of entries; out may be nil
+func NewLog(out io.Writer, size int) *Log {
+	if size <= 0 {
+		size = DefaultSize
+	}
+	return &Log{out: out, entries: make([]Entry, size), now: time.Now}
+}
+
+// Record an entry, stamping it with the current time when it has none
+func (l *Log) Record(entry Entry) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if entry.Time.IsZero() {
+		entry.Time = l.now().UTC()
+	}
+
+	l.entries[l.next] = entry
+	l.next = (l.next + 1) % len(l.entries)
+	l.full = l.full || l.next == 0
+
+	if l.out == nil {
+		return
+	}
+	line, err := json.Marshal(entry)
+	if err != nil {
+		log.Printf("Failed to encode audit entry: %v", err)
+		return
+	}
+	if _, err := l.out.Write(append(line, '\n')); err != nil {
+		log.Printf("Failed to write audit entry: %v", err)
+	}
+}
+
+// Up to limit recent entries, newest first, optionally only those with the given decision
+func (l *Log) Recent(limit int, decision string) []Entry {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	count := l.next
+	if l.full {
+		count = len(l.entries)
+	}
+
+	entries := []Entry{}
+	for i := 0; i < count && (limit <= 0 || len(entries) < limit); i++ {
+		entry := l.entries[(l.next-1-i+len(l.entries))%len(l.entries)]
+		if decision == "" || entry.Decision == decision {
+			entries = append(entries, entry)
+		}
+	}
+	return entries
+}
@@ -0,0 +1,65 @@
+package audit
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestLogRecent(t *testing.T) {
+	log := NewLog(nil, 3)
+
+	for _, action := range []string{"a", "b", "c", "d"} {
+		decision := DecisionAllowed
+		if action == "c" {
+			decision = DecisionDenied
+		}
+		log.Record(Entry{Action: action, Decision: decision})
+	}
+
+	var actions []string
+	for _, entry := range log.Recent(0, "") {
+		actions = append(actions, entry.Action)
+		if entry.Time.IsZero() {
+			t.Error("Expected entries to be stamped with the time")
+		}
+	}
+	if strings.Join(actions, "") != "dcb" {
+		t.Errorf("Expected the newest 3 entries newest first, got %v", actions)
+	}
+
+	if entries := log.Recent(1, ""); len(entries) != 1 || entries[0].Action != "d" {
+		t.Errorf("Expected limit to keep the newest entry, got %+v", entries)
+	}
+	if entries := log.Recent(0, DecisionDenied); len(entries) != 1 || entries[0].Action != "c" {
+		t.Errorf("Expected only the denied entry, got %+v", entries)
+	}
+}
+
+func TestLogWritesJSONLines(t *testing.T) {
+	var out bytes.Buffer
+	log := NewLog(&out, 10)
+
+	log.Record(Entry{KeyID: 2, Subject: "ci", Role: "viewer", Action: "vlan:delete", Method: "DELETE", Path: "/api/v1/vlans/1", Decision: DecisionDenied, Reason: "Role viewer may not perform vlan:delete"})
+	log.Record(Entry{Action: "vlan:update", Decision: DecisionAllowed})
+
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("Expected 2 lines, got %d: %s", len(lines), out.String())
+	}
+
+	var entry Entry
+	if err := json.Unmarshal([]byte(lines[0]), &entry); err != nil {
+		t.Fatalf("Failed to decode line: %v", err)
+	}
+	if entry.Subject != "ci" || entry.Decision != DecisionDenied || entry.Path != "/api/v1/vlans/1" {
+		t.Errorf("Unexpected entry %+v", entry)
+	}
+}
+
+func TestLogEmpty(t *testing.T) {
+	if entries := NewLog(nil, 0).Recent(10, ""); entries == nil || len(entries) != 0 {
+		t.Errorf("Expected an empty list, got %v", entries)
+	}
+}
@@ -0,0 +1,342 @@
+// Package auth authenticates API clients with API keys, JWTs or client
+// certificates. Keys are kept as SHA-256 hashes in a JSON file; a key is sent
+// either as an X-API-Key header or as an opaque bearer token in the
+// Authorization header.
+package auth
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"smit/server/api/models"
+)
+
+// Prefix of generated keys, so leaked keys are easy to search for
+const KeyPrefix = "smit_"
+
+// Number of leading key characters kept to recognise a key
+const displayPrefixLength = 12
+
+// Last-used times are only recorded, and the file rewritten, once per interval
+const lastUsedResolution = time.Minute
+
+var (
+	// No key matches the presented credential
+	ErrInvalidKey = errors.New("invalid API key")
+	// The key matched but is past its expiry
+	ErrKeyExpired = errors.New("API key has expired")
+	// The key matched but has been revoked
+	ErrKeyRevoked = errors.New("API key has been revoked")
+	// No key has the given ID
+	ErrKeyNotFound = errors.New("API key not found")
+)
+
+// Ways a caller can authenticate
+const (
+	MethodAPIKey      = "api_key"
+	MethodJWT         = "jwt"
+	MethodCertificate = "cert"
+)
+
+// Authenticated caller of a request
+type Identity struct {
+	// How the caller authenticated, one of the Method constants
+	Method string `json:"method"`
+	// API key name, subject of a JWT or common name of a client certificate
+	Subject string `json:"subject"`
+	// Zero for JWT and certificate callers
+	KeyID int                 `json:"key_id,omitempty"`
+	Role  string              `json:"role,omitempty"`
+	Scope *models.AccessScope `json:"scope,omitempty"`
+	// Limits of the API key, nil for the default limits
+	RateLimit *models.RateLimit `json:"rate_limit,omitempty"`
+}
+
+// Key under which per-caller state such as rate limit buckets and idempotency
+// keys is kept. API keys are told apart by ID; token and certificate callers by
+// method and subject, so a certificate cannot share the state of a JWT subject
+// with the same name.
+func (i *Identity) Key() string {
+	if i.KeyID != 0 {
+		return "key:" + strconv.Itoa(i.KeyID)
+	}
+	return i.Method + ":" + strconv.Quote(i.Subject)
+}
+
+type identityKey struct{}
+
+// Return a context carrying the identity
+func WithIdentity(ctx context.Context, identity *Identity) context.Context {
+	return context.WithValue(ctx, identityKey{}, identity)
+}
+
+// Identity of the request's caller, nil when the request was not authenticated
+func IdentityFromContext(ctx context.Context) *Identity {
+	identity, _ := ctx.Value(identityKey{}).(*Identity)
+	return identity
+}
+
+// Key as stored in the keys file
+type keyRecord struct {
+	models.APIKey
+	// Hex-encoded SHA-256 of the key
+	Hash string `json:"hash"`
+}
+
+// Store of API keys, persisted to a JSON file when a path is given.
+// The store is safe for concurrent use.
+type KeyStore struct {
+	mu       sync.Mutex
+	path     string
+	keys     []keyRecord
+	byHash   map[string]int
+	now      func() time.Time
+	generate func() (string, error)
+}
+
+// Load the keys file, starting with no keys when it does not exist yet.
+// An empty path keeps the keys in memory only.
+func LoadKeyStore(path string) (*KeyStore, error) {
+	s := &KeyStore{
+		path:     path,
+		byHash:   make(map[string]int),
+		now:      time.Now,
+		generate: generateKey,
+	}
+	if path == "" {
+		return s, nil
+	}
+
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return s, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read API keys file: %w", err)
+	}
+
+	if err := json.Unmarshal(data, &s.keys); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal API keys: %w", err)
+	}
+
+	ids := make(map[int]bool, len(s.keys))
+	for i, key := range s.keys {
+		hash := strings.ToLower(key.Hash)
+		if decoded, err := hex.DecodeString(hash); err != nil || len(decoded) != sha256.Size {
+			return nil, fmt.Errorf("API key %d: hash must be a hex-encoded SHA-256 digest", key.ID)
+		}
+		if key.ID < 1 || ids[key.ID] {
+			return nil, fmt.Errorf("API key %d: IDs must be positive and unique", key.ID)
+		}
+		if err := key.Scope.Validate(); err != nil {
+			return nil, fmt.Errorf("API key %d: %w", key.ID, err)
+		}
+		if err := key.RateLimit.Validate(); err != nil {
+			return nil, fmt.Errorf("API key %d: %w", key.ID, err)
+		}
+		if _, ok := s.byHash[hash]; ok {
+			return nil, fmt.Errorf("API key %d: hash is used by another key", key.ID)
+		}
+		ids[key.ID] = true
+		s.keys[i].Hash = hash
+		s.byHash[hash] = i
+	}
+
+	return s, nil
+}
+
+// Hash a key the way it is stored in the keys file
+func HashKey(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
+
+// Look up the key for a presented credential and record that it was used
+func (s *KeyStore) Authenticate(key string) (*Identity, error) {
+	hash := HashKey(key)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// Lookups are by hash, so timing reveals nothing about stored keys
+	i, ok := s.byHash[hash]
+	if !ok {
+		return nil, ErrInvalidKey
+	}
+
+	record := &s.keys[i]
+	now := s.now()
+	if record.Revoked() {
+		return nil, ErrKeyRevoked
+	}
+	if record.Expired(now) {
+		return nil, ErrKeyExpired
+	}
+
+	if record.LastUsedAt == nil || now.Sub(*record.LastUsedAt) >= lastUsedResolution {
+		usedAt := now.UTC()
+		record.LastUsedAt = &usedAt
+		// Failing to persist the last-used time must not fail the request
+		s.save()
+	}
+
+	return &Identity{Method: MethodAPIKey, Subject: record.Name, KeyID: record.ID, Role: record.Role, Scope: record.Scope, RateLimit: record.RateLimit}, nil
+}
+
+// All keys, including revoked and expired ones, ordered by ID
+func (s *KeyStore) List() []models.APIKey {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	keys := make([]models.APIKey, 0, len(s.keys))
+	for _, record := range s.keys {
+		keys = append(keys, record.APIKey)
+	}
+	sort.Slice(keys, func(i, j int) bool { return keys[i].ID < keys[j].ID })
+	return keys
+}
+
+// Key with the given ID
+func (s *KeyStore) Get(id int) (*models.APIKey, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, record := range s.keys {
+		if record.ID == id {
+			key := record.APIKey
+			return &key, nil
+		}
+	}
+	return nil, ErrKeyNotFound
+}
+
+// Generate and store a new key; the returned key is the only copy of it
+func (s *KeyStore) Create(input *models.APIKeyInput) (*models.CreatedAPIKey, error) {
+	key, err := s.generate()
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate API key: %w", err)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	maxID := 0
+	for _, record := range s.keys {
+		maxID = max(maxID, record.ID)
+	}
+
+	record := keyRecord{
+		APIKey: models.APIKey{
+			ID:        maxID + 1,
+			Name:      strings.TrimSpace(input.Name),
+			Role:      input.Role,
+			Scope:     input.Scope,
+			RateLimit: input.RateLimit,
+			Prefix:    key[:displayPrefixLength],
+			CreatedAt: s.now().UTC(),
+			ExpiresAt: input.ExpiresAt,
+		},
+		Hash: HashKey(key),
+	}
+
+	s.keys = append(s.keys, record)
+	s.byHash[record.Hash] = len(s.keys) - 1
+	if err := s.save(); err != nil {
+		s.keys = s.keys[:len(s.keys)-1]
+		delete(s.byHash, record.Hash)
+		return nil, err
+	}
+
+	return &models.CreatedAPIKey{APIKey: record.APIKey, Key: key}, nil
+}
+
+// Revoke a key; revoking a key twice keeps the first revocation time
+func (s *KeyStore) Revoke(id int) (*models.APIKey, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for i := range s.keys {
+		record := &s.keys[i]
+		if record.ID != id {
+			continue
+		}
+		if !record.Revoked() {
+			revokedAt := s.now().UTC()
+			record.RevokedAt = &revokedAt
+			if err := s.save(); err != nil {
+				record.RevokedAt = nil
+				return nil, err
+			}
+		}
+		key := record.APIKey
+		return &key, nil
+	}
+	return nil, ErrKeyNotFound
+}
+
+// Write the keys file atomically and durably, readable by the owner only: the
+// temporary file is synced before the rename and the directory after it. Callers
+// hold the lock.
+func (s *KeyStore) save() error {
+	if s.path == "" {
+		return nil
+	}
+
+	data, err := json.MarshalIndent(s.keys, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal API keys: %w", err)
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(s.path), ".keys-*")
+	if err != nil {
+		return fmt.Errorf("failed to write API keys file: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to write API keys file: %w", err)
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to write API keys file: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to write API keys file: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), s.path); err != nil {
+		return fmt.Errorf("failed to write API keys file: %w", err)
+	}
+
+	dir, err := os.Open(filepath.Dir(s.path))
+	if err != nil {
+		return fmt.Errorf("failed to write API keys file: %w", err)
+	}
+	defer dir.Close()
+	if err := dir.Sync(); err != nil {
+		return fmt.Errorf("failed to write API keys file: %w", err)
+	}
+	return nil
+}
+
+// Random key with 256 bits of entropy
+func generateKey() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return KeyPrefix + base64.RawURLEncoding.EncodeToString(b), nil
+}
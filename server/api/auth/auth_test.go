@@ -0,0 +1,199 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"smit/server/api/models"
+)
+
+// Store with a clock the test controls
+func newTestStore(t *testing.T, path string) (*KeyStore, *time.Time) {
+	t.Helper()
+	store, err := LoadKeyStore(path)
+	if err != nil {
+		t.Fatalf("Failed to load key store: %v", err)
+	}
+	now := time.Date(2024, 7, 15, 10, 0, 0, 0, time.UTC)
+	store.now = func() time.Time { return now }
+	return store, &now
+}
+
+func TestCreateAndAuthenticate(t *testing.T) {
+	store, _ := newTestStore(t, "")
+
+	created, err := store.Create(&models.APIKeyInput{Name: " ci "})
+	if err != nil {
+		t.Fatalf("Failed to create key: %v", err)
+	}
+	if !strings.HasPrefix(created.Key, KeyPrefix) || !strings.HasPrefix(created.Key, created.Prefix) {
+		t.Errorf("Unexpected key %q with prefix %q", created.Key, created.Prefix)
+	}
+	if created.ID != 1 || created.Name != "ci" {
+		t.Errorf("Unexpected key record %+v", created.APIKey)
+	}
+
+	identity, err := store.Authenticate(created.Key)
+	if err != nil {
+		t.Fatalf("Expected key to authenticate, got %v", err)
+	}
+	if identity.KeyID != 1 || identity.Subject != "ci" || identity.Method != MethodAPIKey {
+		t.Errorf("Unexpected identity %+v", identity)
+	}
+
+	if _, err := store.Authenticate(created.Key + "x"); !errors.Is(err, ErrInvalidKey) {
+		t.Errorf("Expected ErrInvalidKey, got %v", err)
+	}
+}
+
+func TestExpiryAndRevocation(t *testing.T) {
+	store, now := newTestStore(t, "")
+
+	expires := now.Add(time.Hour)
+	expiring, _ := store.Create(&models.APIKeyInput{Name: "temp", ExpiresAt: &expires})
+	revoked, _ := store.Create(&models.APIKeyInput{Name: "old"})
+
+	if _, err := store.Revoke(revoked.ID); err != nil {
+		t.Fatalf("Failed to revoke key: %v", err)
+	}
+	if _, err := store.Authenticate(revoked.Key); !errors.Is(err, ErrKeyRevoked) {
+		t.Errorf("Expected ErrKeyRevoked, got %v", err)
+	}
+	if _, err := store.Revoke(99); !errors.Is(err, ErrKeyNotFound) {
+		t.Errorf("Expected ErrKeyNotFound, got %v", err)
+	}
+
+	if _, err := store.Authenticate(expiring.Key); err != nil {
+		t.Errorf("Expected key to authenticate before expiry, got %v", err)
+	}
+	*now = now.Add(time.Hour)
+	if _, err := store.Authenticate(expiring.Key); !errors.Is(err, ErrKeyExpired) {
+		t.Errorf("Expected ErrKeyExpired, got %v", err)
+	}
+}
+
+func TestLastUsed(t *testing.T) {
+	store, now := newTestStore(t, "")
+	created, _ := store.Create(&models.APIKeyInput{Name: "ci"})
+
+	first := *now
+	store.Authenticate(created.Key)
+	*now = now.Add(30 * time.Second)
+	store.Authenticate(created.Key)
+
+	key, _ := store.Get(created.ID)
+	if key.LastUsedAt == nil || !key.LastUsedAt.Equal(first) {
+		t.Errorf("Expected last use to stay at %v within a minute, got %v", first, key.LastUsedAt)
+	}
+
+	*now = now.Add(time.Minute)
+	store.Authenticate(created.Key)
+	key, _ = store.Get(created.ID)
+	if !key.LastUsedAt.Equal(*now) {
+		t.Errorf("Expected last use at %v, got %v", *now, key.LastUsedAt)
+	}
+}
+
+func TestKeysFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "keys.json")
+
+	// Keys configured by hand only need an ID, a name and the hash
+	static := "static-key-0123456789"
+	config := `[{"id": 5, "name": "deploy", "rate_limit": {"write_per_minute": 10}, "hash": "` + strings.ToUpper(HashKey(static)) + `"}]`
+	if err := os.WriteFile(path, []byte(config), 0600); err != nil {
+		t.Fatalf("Failed to write keys file: %v", err)
+	}
+
+	store, _ := newTestStore(t, path)
+	identity, err := store.Authenticate(static)
+	if err != nil || identity.KeyID != 5 {
+		t.Fatalf("Expected static key to authenticate, got %v, %v", identity, err)
+	}
+	if identity.RateLimit == nil || identity.RateLimit.WritePerMinute != 10 {
+		t.Errorf("Expected the key's rate limit, got %+v", identity.RateLimit)
+	}
+
+	created, err := store.Create(&models.APIKeyInput{Name: "ci"})
+	if err != nil {
+		t.Fatalf("Failed to create key: %v", err)
+	}
+	if created.ID != 6 {
+		t.Errorf("Expected ID 6, got %d", created.ID)
+	}
+	store.Revoke(5)
+
+	data, _ := os.ReadFile(path)
+	if strings.Contains(string(data), created.Key) {
+		t.Error("Keys file must not contain the key itself")
+	}
+
+	reloaded, _ := newTestStore(t, path)
+	if _, err := reloaded.Authenticate(created.Key); err != nil {
+		t.Errorf("Expected created key to survive a reload, got %v", err)
+	}
+	if _, err := reloaded.Authenticate(static); !errors.Is(err, ErrKeyRevoked) {
+		t.Errorf("Expected revocation to survive a reload, got %v", err)
+	}
+}
+
+func TestLoadKeyStoreErrors(t *testing.T) {
+	hash := HashKey("key")
+	tests := []struct {
+		name   string
+		config string
+	}{
+		{"Invalid JSON", `[{`},
+		{"Invalid hash", `[{"id": 1, "name": "a", "hash": "abc"}]`},
+		{"Missing ID", `[{"name": "a", "hash": "` + hash + `"}]`},
+		{"Duplicate ID", `[{"id": 1, "hash": "` + hash + `"}, {"id": 1, "hash": "` + HashKey("other") + `"}]`},
+		{"Duplicate hash", `[{"id": 1, "hash": "` + hash + `"}, {"id": 2, "hash": "` + hash + `"}]`},
+		{"Negative rate limit", `[{"id": 1, "rate_limit": {"read_per_minute": -5}, "hash": "` + hash + `"}]`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "keys.json")
+			os.WriteFile(path, []byte(tt.config), 0600)
+
+			if _, err := LoadKeyStore(path); err == nil {
+				t.Error("Expected an error")
+			}
+		})
+	}
+}
+
+func TestIdentityContext(t *testing.T) {
+	if IdentityFromContext(context.Background()) != nil {
+		t.Error("Expected no identity on a bare context")
+	}
+
+	ctx := WithIdentity(context.Background(), &Identity{Subject: "ci", KeyID: 3})
+	if identity := IdentityFromContext(ctx); identity == nil || identity.KeyID != 3 {
+		t.Errorf("Unexpected identity %+v", identity)
+	}
+}
+
+func TestIdentityKey(t *testing.T) {
+	tests := []struct {
+		name     string
+		identity Identity
+		expected string
+	}{
+		{"API key", Identity{Method: MethodAPIKey, Subject: "ci", KeyID: 3}, "key:3"},
+		{"Token", Identity{Method: MethodJWT, Subject: "ci"}, `jwt:"ci"`},
+		{"Certificate", Identity{Method: MethodCertificate, Subject: "ci"}, `cert:"ci"`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if key := tt.identity.Key(); key != tt.expected {
+				t.Errorf("Expected %s, got %s", tt.expected, key)
+			}
+		})
+	}
+}
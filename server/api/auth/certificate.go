@@ -0,0 +1,30 @@
+package auth
+
+import "crypto/x509"
+
+// Identity of a verified client certificate. The subject is the certificate's
+// common name, or its full distinguished name when it has none. The role is the
+// first mapping whose value is the common name or one of the organizational
+// units; without mappings the first organizational unit is the role.
+func CertificateIdentity(cert *x509.Certificate, roleMap []RoleMapping) *Identity {
+	subject := cert.Subject.CommonName
+	if subject == "" {
+		subject = cert.Subject.String()
+	}
+
+	values := append([]string{cert.Subject.CommonName}, cert.Subject.OrganizationalUnit...)
+	identity := &Identity{Method: MethodCertificate, Subject: subject}
+	if len(roleMap) == 0 {
+		if len(cert.Subject.OrganizationalUnit) > 0 {
+			identity.Role = cert.Subject.OrganizationalUnit[0]
+		}
+		return identity
+	}
+	for _, mapping := range roleMap {
+		if contains(values, mapping.Value) {
+			identity.Role = mapping.Role
+			break
+		}
+	}
+	return identity
+}
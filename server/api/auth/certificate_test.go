@@ -0,0 +1,33 @@
+package auth
+
+import (
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"testing"
+)
+
+func TestCertificateIdentity(t *testing.T) {
+	roleMap, _ := ParseRoleMap("deploy-bot=admin,automation=operator")
+
+	tests := []struct {
+		name     string
+		subject  pkix.Name
+		roleMap  []RoleMapping
+		expected Identity
+	}{
+		{"Common name mapped", pkix.Name{CommonName: "deploy-bot", OrganizationalUnit: []string{"automation"}}, roleMap, Identity{Subject: "deploy-bot", Role: "admin"}},
+		{"Unit mapped", pkix.Name{CommonName: "backup-bot", OrganizationalUnit: []string{"automation"}}, roleMap, Identity{Subject: "backup-bot", Role: "operator"}},
+		{"Unmapped", pkix.Name{CommonName: "laptop", OrganizationalUnit: []string{"staff"}}, roleMap, Identity{Subject: "laptop"}},
+		{"Unit as role", pkix.Name{CommonName: "backup-bot", OrganizationalUnit: []string{"viewer"}}, nil, Identity{Subject: "backup-bot", Role: "viewer"}},
+		{"No common name", pkix.Name{Organization: []string{"Example"}}, nil, Identity{Subject: "O=Example"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			identity := CertificateIdentity(&x509.Certificate{Subject: tt.subject}, tt.roleMap)
+			if identity.Subject != tt.expected.Subject || identity.Role != tt.expected.Role || identity.KeyID != 0 || identity.Method != MethodCertificate {
+				t.Errorf("Expected %+v, got %+v", tt.expected, identity)
+			}
+		})
+	}
+}
@@ -0,0 +1,484 @@
+package auth
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"math/big"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Default tolerance for clock differences with the token issuer
+const DefaultClockSkew = time.Minute
+
+// Default claim holding the caller's roles or groups
+const DefaultRoleClaim = "roles"
+
+// The JWKS is reloaded for an unknown key ID at most once per interval
+const jwksRefreshInterval = time.Minute
+
+// Smallest accepted RSA modulus
+const minRSAKeyBits = 2048
+
+var (
+	// The token is malformed, badly signed or does not match the configuration
+	ErrInvalidToken = errors.New("invalid token")
+	// The token is past its expiry
+	ErrTokenExpired = errors.New("token has expired")
+)
+
+// Claim value granted a policy role
+type RoleMapping struct {
+	Value string
+	Role  string
+}
+
+// Parse a role map of the form "group=role,group=role"; earlier entries take precedence
+func ParseRoleMap(value string) ([]RoleMapping, error) {
+	var mappings []RoleMapping
+	for _, entry := range strings.Split(value, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		claim, role, ok := strings.Cut(entry, "=")
+		if !ok || claim == "" || role == "" {
+			return nil, fmt.Errorf("invalid role mapping %q, must be claim-value=role", entry)
+		}
+		mappings = append(mappings, RoleMapping{Value: claim, Role: role})
+	}
+	return mappings, nil
+}
+
+// Settings of JWT bearer validation
+type JWTConfig struct {
+	// JWKS file path or http(s) URL
+	JWKS     string
+	Issuer   string
+	Audience string
+	// Tolerance applied to exp, nbf and iat
+	ClockSkew time.Duration
+	// Claim with the caller's roles or groups; dots select nested claims
+	RoleClaim string
+	// Claim values mapped to roles; claim values are used as roles when empty
+	RoleMap []RoleMapping
+	// Client for JWKS URLs
+	Client *http.Client
+}
+
+// Validates RS256 and ES256 signed JWTs against keys from a JWKS.
+// The validator is safe for concurrent use.
+type JWTValidator struct {
+	config JWTConfig
+	now    func() time.Time
+
+	mu      sync.Mutex
+	keys    []signingKey
+	fetched time.Time
+	// Closed when the reload in progress finishes, nil while none is
+	reloading chan struct{}
+}
+
+// Create a validator and load its JWKS
+func NewJWTValidator(config JWTConfig) (*JWTValidator, error) {
+	if config.JWKS == "" {
+		return nil, fmt.Errorf("JWKS source is required")
+	}
+	if config.ClockSkew <= 0 {
+		config.ClockSkew = DefaultClockSkew
+	}
+	if config.RoleClaim == "" {
+		config.RoleClaim = DefaultRoleClaim
+	}
+	if config.Client == nil {
+		config.Client = &http.Client{Timeout: 10 * time.Second}
+	}
+
+	v := &JWTValidator{config: config, now: time.Now}
+	keys, err := v.loadJWKS()
+	if err != nil {
+		return nil, err
+	}
+	v.keys = keys
+	v.fetched = v.now()
+	return v, nil
+}
+
+// Check whether a credential has the shape of a JWT rather than an opaque key
+func LooksLikeJWT(token string) bool {
+	return strings.Count(token, ".") == 2
+}
+
+// Verify a token and return the identity of its subject
+func (v *JWTValidator) Validate(token string) (*Identity, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, fmt.Errorf("%w: malformed token", ErrInvalidToken)
+	}
+
+	var header struct {
+		Alg  string   `json:"alg"`
+		Kid  string   `json:"kid"`
+		Crit []string `json:"crit"`
+	}
+	if err := decodeSegment(parts[0], &header); err != nil {
+		return nil, fmt.Errorf("%w: malformed header", ErrInvalidToken)
+	}
+	if len(header.Crit) > 0 {
+		return nil, fmt.Errorf("%w: unsupported critical headers", ErrInvalidToken)
+	}
+
+	key, err := v.key(header.Kid, header.Alg)
+	if err != nil {
+		return nil, err
+	}
+
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, fmt.Errorf("%w: malformed signature", ErrInvalidToken)
+	}
+	if !verifySignature(header.Alg, key, parts[0]+"."+parts[1], signature) {
+		return nil, fmt.Errorf("%w: signature mismatch", ErrInvalidToken)
+	}
+
+	var claims map[string]interface{}
+	if err := decodeSegment(parts[1], &claims); err != nil {
+		return nil, fmt.Errorf("%w: malformed claims", ErrInvalidToken)
+	}
+	if err := v.checkClaims(claims); err != nil {
+		return nil, err
+	}
+
+	subject, _ := claims["sub"].(string)
+	return &Identity{Method: MethodJWT, Subject: subject, Role: v.role(claims)}, nil
+}
+
+// Check the registered claims against the configuration and the clock
+func (v *JWTValidator) checkClaims(claims map[string]interface{}) error {
+	now := v.now()
+	skew := v.config.ClockSkew
+
+	exp, ok := numericDate(claims["exp"])
+	if !ok {
+		return fmt.Errorf("%w: exp is required", ErrInvalidToken)
+	}
+	if now.After(exp.Add(skew)) {
+		return ErrTokenExpired
+	}
+	if nbf, ok := numericDate(claims["nbf"]); ok && now.Add(skew).Before(nbf) {
+		return fmt.Errorf("%w: token is not valid yet", ErrInvalidToken)
+	}
+	if iat, ok := numericDate(claims["iat"]); ok && now.Add(skew).Before(iat) {
+		return fmt.Errorf("%w: token was issued in the future", ErrInvalidToken)
+	}
+
+	if issuer, _ := claims["iss"].(string); v.config.Issuer != "" && issuer != v.config.Issuer {
+		return fmt.Errorf("%w: unexpected issuer", ErrInvalidToken)
+	}
+	if v.config.Audience != "" && !contains(stringValues(claims["aud"]), v.config.Audience) {
+		return fmt.Errorf("%w: unexpected audience", ErrInvalidToken)
+	}
+	if subject, _ := claims["sub"].(string); subject == "" {
+		return fmt.Errorf("%w: sub is required", ErrInvalidToken)
+	}
+	return nil
+}
+
+// Role granted by the role claim, empty when none matches
+func (v *JWTValidator) role(claims map[string]interface{}) string {
+	var value interface{} = claims
+	for _, name := range strings.Split(v.config.RoleClaim, ".") {
+		object, ok := value.(map[string]interface{})
+		if !ok {
+			return ""
+		}
+		value = object[name]
+	}
+
+	values := stringValues(value)
+	if len(v.config.RoleMap) == 0 {
+		if len(values) > 0 {
+			return values[0]
+		}
+		return ""
+	}
+	for _, mapping := range v.config.RoleMap {
+		if contains(values, mapping.Value) {
+			return mapping.Role
+		}
+	}
+	return ""
+}
+
+// Key for a token, reloading the JWKS once when the key ID is unknown
+func (v *JWTValidator) key(kid, alg string) (crypto.PublicKey, error) {
+	if alg != "RS256" && alg != "ES256" {
+		return nil, fmt.Errorf("%w: unsupported algorithm %q", ErrInvalidToken, alg)
+	}
+
+	v.mu.Lock()
+	key := v.lookup(kid, alg)
+	if key == nil {
+		if reloading := v.reloading; reloading != nil {
+			// Another request is reloading the JWKS, wait for its keys
+			v.mu.Unlock()
+			<-reloading
+			v.mu.Lock()
+			key = v.lookup(kid, alg)
+		} else if v.now().Sub(v.fetched) >= jwksRefreshInterval {
+			// Reload without the lock so tokens with known keys are not held up by the fetch
+			reloading := make(chan struct{})
+			v.reloading = reloading
+			v.fetched = v.now()
+			v.mu.Unlock()
+			keys, err := v.loadJWKS()
+			v.mu.Lock()
+			if err == nil {
+				v.keys = keys
+			}
+			v.reloading = nil
+			close(reloading)
+			key = v.lookup(kid, alg)
+		}
+	}
+	v.mu.Unlock()
+
+	if key == nil {
+		return nil, fmt.Errorf("%w: no key for kid %q", ErrInvalidToken, kid)
+	}
+	return key, nil
+}
+
+// Key of the algorithm's type with the token's key ID, or the only key of that type
+// when the token names none. Ambiguous matches find nothing. Callers hold the lock.
+func (v *JWTValidator) lookup(kid, alg string) crypto.PublicKey {
+	var found crypto.PublicKey
+	for _, k := range v.keys {
+		if (kid == "" || k.kid == kid) && keyMatches(k.key, alg) {
+			if found != nil {
+				return nil
+			}
+			found = k.key
+		}
+	}
+	return found
+}
+
+// Signature key from a JWKS with its key ID, which may be empty
+type signingKey struct {
+	kid string
+	key crypto.PublicKey
+}
+
+// JSON Web Key, only the members used for RSA and EC signature keys
+type jwk struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	Alg string `json:"alg"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+	Crv string `json:"crv"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+}
+
+// Read the JWKS from its file or URL and parse its signature keys. Keys that are
+// unsupported or invalid are skipped with a warning; it is an error when none is
+// left. Keys without an ID only verify tokens that name no key.
+func (v *JWTValidator) loadJWKS() ([]signingKey, error) {
+	var data []byte
+	var err error
+	if strings.HasPrefix(v.config.JWKS, "http://") || strings.HasPrefix(v.config.JWKS, "https://") {
+		data, err = v.fetchJWKS()
+	} else {
+		data, err = os.ReadFile(v.config.JWKS)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read JWKS: %w", err)
+	}
+
+	var set struct {
+		Keys []jwk `json:"keys"`
+	}
+	if err := json.Unmarshal(data, &set); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal JWKS: %w", err)
+	}
+
+	var keys []signingKey
+	for i, k := range set.Keys {
+		if k.Use != "" && k.Use != "sig" {
+			continue
+		}
+		key, err := k.publicKey()
+		if err != nil {
+			slog.Warn("Skipping unusable JWKS key", "index", i, "kid", k.Kid, "error", err)
+			continue
+		}
+
+		// Two keys of one type under one ID cannot be told apart, so tokens naming it are refused
+		for _, other := range keys {
+			if k.Kid != "" && other.kid == k.Kid && keyAlgorithm(other.key) == keyAlgorithm(key) {
+				slog.Warn("JWKS has several keys with the same kid, tokens naming it will be refused", "kid", k.Kid)
+				break
+			}
+		}
+		keys = append(keys, signingKey{kid: k.Kid, key: key})
+	}
+	if len(keys) == 0 {
+		return nil, fmt.Errorf("JWKS has no usable signature keys")
+	}
+	return keys, nil
+}
+
+// Download the JWKS
+func (v *JWTValidator) fetchJWKS() ([]byte, error) {
+	resp, err := v.config.Client.Get(v.config.JWKS)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status %d", resp.StatusCode)
+	}
+	return io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+}
+
+// Public key of an RSA or P-256 EC JWK
+func (k *jwk) publicKey() (crypto.PublicKey, error) {
+	switch k.Kty {
+	case "RSA":
+		if k.Alg != "" && k.Alg != "RS256" {
+			return nil, fmt.Errorf("unsupported RSA algorithm %q", k.Alg)
+		}
+		n, err := decodeBigInt(k.N)
+		if err != nil {
+			return nil, fmt.Errorf("invalid modulus: %w", err)
+		}
+		e, err := decodeBigInt(k.E)
+		if err != nil || !e.IsInt64() || e.Int64() < 3 || e.Int64() > 1<<31-1 {
+			return nil, fmt.Errorf("invalid exponent")
+		}
+		if n.BitLen() < minRSAKeyBits {
+			return nil, fmt.Errorf("RSA keys must have at least %d bits", minRSAKeyBits)
+		}
+		return &rsa.PublicKey{N: n, E: int(e.Int64())}, nil
+
+	case "EC":
+		if k.Crv != "P-256" || (k.Alg != "" && k.Alg != "ES256") {
+			return nil, fmt.Errorf("only P-256 EC keys are supported")
+		}
+		x, errX := decodeBigInt(k.X)
+		y, errY := decodeBigInt(k.Y)
+		if errX != nil || errY != nil || !elliptic.P256().IsOnCurve(x, y) {
+			return nil, fmt.Errorf("invalid EC point")
+		}
+		return &ecdsa.PublicKey{Curve: elliptic.P256(), X: x, Y: y}, nil
+
+	default:
+		return nil, fmt.Errorf("unsupported key type %q", k.Kty)
+	}
+}
+
+// Check whether a key can verify the algorithm
+func keyMatches(key crypto.PublicKey, alg string) bool {
+	return keyAlgorithm(key) == alg
+}
+
+// Signature algorithm a key verifies
+func keyAlgorithm(key crypto.PublicKey) string {
+	switch key.(type) {
+	case *rsa.PublicKey:
+		return "RS256"
+	case *ecdsa.PublicKey:
+		return "ES256"
+	}
+	return ""
+}
+
+// Verify a signature over the signing input
+func verifySignature(alg string, key crypto.PublicKey, input string, signature []byte) bool {
+	digest := sha256.Sum256([]byte(input))
+
+	switch alg {
+	case "RS256":
+		rsaKey, ok := key.(*rsa.PublicKey)
+		return ok && rsa.VerifyPKCS1v15(rsaKey, crypto.SHA256, digest[:], signature) == nil
+	case "ES256":
+		// JWS uses the fixed-size r || s encoding, not ASN.1
+		ecKey, ok := key.(*ecdsa.PublicKey)
+		if !ok || len(signature) != 64 {
+			return false
+		}
+		r := new(big.Int).SetBytes(signature[:32])
+		s := new(big.Int).SetBytes(signature[32:])
+		return ecdsa.Verify(ecKey, digest[:], r, s)
+	}
+	return false
+}
+
+// Decode a base64url JSON segment
+func decodeSegment(segment string, v interface{}) error {
+	data, err := base64.RawURLEncoding.DecodeString(segment)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, v)
+}
+
+// Decode a base64url big-endian integer
+func decodeBigInt(value string) (*big.Int, error) {
+	data, err := base64.RawURLEncoding.DecodeString(value)
+	if err != nil || len(data) == 0 {
+		return nil, fmt.Errorf("invalid base64url integer")
+	}
+	return new(big.Int).SetBytes(data), nil
+}
+
+// Time of a NumericDate claim
+func numericDate(value interface{}) (time.Time, bool) {
+	seconds, ok := value.(float64)
+	if !ok {
+		return time.Time{}, false
+	}
+	return time.Unix(0, int64(seconds*float64(time.Second))), true
+}
+
+// Claim that is either a string or an array of strings
+func stringValues(value interface{}) []string {
+	switch v := value.(type) {
+	case string:
+		return []string{v}
+	case []interface{}:
+		values := make([]string, 0, len(v))
+		for _, item := range v {
+			if s, ok := item.(string); ok {
+				values = append(values, s)
+			}
+		}
+		return values
+	}
+	return nil
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,360 @@
+package auth
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// Signing keys generated once for the package tests
+var (
+	testRSAKey, _ = rsa.GenerateKey(rand.Reader, 2048)
+	testECKey, _  = ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+)
+
+func encodeSegment(v interface{}) string {
+	data, _ := json.Marshal(v)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+func encodeBigInt(n *big.Int) string {
+	return base64.RawURLEncoding.EncodeToString(n.Bytes())
+}
+
+// Sign claims with the test key for the algorithm
+func signToken(t *testing.T, alg, kid string, claims map[string]interface{}) string {
+	t.Helper()
+	header := map[string]interface{}{"alg": alg, "typ": "JWT"}
+	if kid != "" {
+		header["kid"] = kid
+	}
+	input := encodeSegment(header) + "." + encodeSegment(claims)
+	digest := sha256.Sum256([]byte(input))
+
+	var signature []byte
+	switch alg {
+	case "RS256":
+		var err error
+		if signature, err = rsa.SignPKCS1v15(rand.Reader, testRSAKey, crypto.SHA256, digest[:]); err != nil {
+			t.Fatalf("Failed to sign token: %v", err)
+		}
+	case "ES256":
+		r, s, err := ecdsa.Sign(rand.Reader, testECKey, digest[:])
+		if err != nil {
+			t.Fatalf("Failed to sign token: %v", err)
+		}
+		signature = make([]byte, 64)
+		r.FillBytes(signature[:32])
+		s.FillBytes(signature[32:])
+	}
+	return input + "." + base64.RawURLEncoding.EncodeToString(signature)
+}
+
+// JWKS with the public halves of the test keys
+func testJWKS() []byte {
+	data, _ := json.Marshal(map[string]interface{}{"keys": []map[string]string{
+		{"kty": "RSA", "kid": "rsa-1", "use": "sig", "alg": "RS256", "n": encodeBigInt(testRSAKey.N), "e": encodeBigInt(big.NewInt(int64(testRSAKey.E)))},
+		{"kty": "EC", "kid": "ec-1", "crv": "P-256", "x": encodeBigInt(testECKey.X), "y": encodeBigInt(testECKey.Y)},
+		{"kty": "oct", "kid": "enc-1", "use": "enc"},
+	}})
+	return data
+}
+
+// Validator reading the test JWKS from a file, with a clock the test controls
+func newTestValidator(t *testing.T, config JWTConfig) (*JWTValidator, *time.Time) {
+	t.Helper()
+	if config.JWKS == "" {
+		config.JWKS = filepath.Join(t.TempDir(), "jwks.json")
+		if err := os.WriteFile(config.JWKS, testJWKS(), 0644); err != nil {
+			t.Fatalf("Failed to write JWKS: %v", err)
+		}
+	}
+	validator, err := NewJWTValidator(config)
+	if err != nil {
+		t.Fatalf("Failed to create validator: %v", err)
+	}
+	now := time.Date(2024, 7, 15, 10, 0, 0, 0, time.UTC)
+	validator.now = func() time.Time { return now }
+	validator.fetched = now
+	return validator, &now
+}
+
+func testClaims(now time.Time) map[string]interface{} {
+	return map[string]interface{}{
+		"iss":   "https://idp.example.com",
+		"aud":   []string{"other", "smit"},
+		"sub":   "alice",
+		"iat":   now.Unix(),
+		"exp":   now.Add(time.Hour).Unix(),
+		"roles": []string{"netops"},
+	}
+}
+
+func TestValidateToken(t *testing.T) {
+	validator, now := newTestValidator(t, JWTConfig{Issuer: "https://idp.example.com", Audience: "smit", ClockSkew: 30 * time.Second})
+
+	with := func(key string, value interface{}) map[string]interface{} {
+		claims := testClaims(*now)
+		if value == nil {
+			delete(claims, key)
+		} else {
+			claims[key] = value
+		}
+		return claims
+	}
+
+	tests := []struct {
+		name     string
+		alg      string
+		kid      string
+		claims   map[string]interface{}
+		expected error
+	}{
+		{"RS256", "RS256", "rsa-1", testClaims(*now), nil},
+		{"ES256", "ES256", "ec-1", testClaims(*now), nil},
+		{"No kid", "ES256", "", testClaims(*now), nil},
+		{"Audience string", "RS256", "rsa-1", with("aud", "smit"), nil},
+		{"Expired within skew", "RS256", "rsa-1", with("exp", now.Add(-20*time.Second).Unix()), nil},
+		{"Expired", "RS256", "rsa-1", with("exp", now.Add(-time.Minute).Unix()), ErrTokenExpired},
+		{"Missing exp", "RS256", "rsa-1", with("exp", nil), ErrInvalidToken},
+		{"Not yet valid", "RS256", "rsa-1", with("nbf", now.Add(time.Minute).Unix()), ErrInvalidToken},
+		{"Issued in the future", "RS256", "rsa-1", with("iat", now.Add(time.Minute).Unix()), ErrInvalidToken},
+		{"Wrong issuer", "RS256", "rsa-1", with("iss", "https://evil.example.com"), ErrInvalidToken},
+		{"Wrong audience", "RS256", "rsa-1", with("aud", "other"), ErrInvalidToken},
+		{"Missing subject", "RS256", "rsa-1", with("sub", nil), ErrInvalidToken},
+		{"Unknown kid", "RS256", "rsa-2", testClaims(*now), ErrInvalidToken},
+		{"Algorithm of another key", "RS256", "ec-1", testClaims(*now), ErrInvalidToken},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			identity, err := validator.Validate(signToken(t, tt.alg, tt.kid, tt.claims))
+			if !errors.Is(err, tt.expected) || (tt.expected == nil && err != nil) {
+				t.Fatalf("Expected %v, got %v", tt.expected, err)
+			}
+			if err == nil && (identity.Subject != "alice" || identity.Role != "netops" || identity.KeyID != 0 || identity.Method != MethodJWT) {
+				t.Errorf("Unexpected identity %+v", identity)
+			}
+		})
+	}
+}
+
+func TestValidateTokenTampering(t *testing.T) {
+	validator, now := newTestValidator(t, JWTConfig{Issuer: "https://idp.example.com", Audience: "smit"})
+	token := signToken(t, "RS256", "rsa-1", testClaims(*now))
+	parts := strings.Split(token, ".")
+
+	tests := []struct {
+		name  string
+		token string
+	}{
+		{"Changed claims", parts[0] + "." + encodeSegment(map[string]interface{}{"sub": "mallory", "exp": now.Add(time.Hour).Unix()}) + "." + parts[2]},
+		{"Algorithm none", encodeSegment(map[string]string{"alg": "none"}) + "." + parts[1] + "."},
+		{"Symmetric algorithm", encodeSegment(map[string]string{"alg": "HS256", "kid": "rsa-1"}) + "." + parts[1] + "." + parts[2]},
+		{"Critical header", encodeSegment(map[string]interface{}{"alg": "RS256", "kid": "rsa-1", "crit": []string{"exp"}}) + "." + parts[1] + "." + parts[2]},
+		{"Malformed", "a.b"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := validator.Validate(tt.token); !errors.Is(err, ErrInvalidToken) {
+				t.Errorf("Expected ErrInvalidToken, got %v", err)
+			}
+		})
+	}
+}
+
+func TestTokenRoles(t *testing.T) {
+	roleMap, err := ParseRoleMap("net-admins=admin, netops=operator")
+	if err != nil {
+		t.Fatalf("Failed to parse role map: %v", err)
+	}
+
+	tests := []struct {
+		name     string
+		config   JWTConfig
+		claims   map[string]interface{}
+		expected string
+	}{
+		{"Claim value", JWTConfig{}, map[string]interface{}{"roles": "viewer"}, "viewer"},
+		{"First mapping wins", JWTConfig{RoleMap: roleMap}, map[string]interface{}{"roles": []string{"netops", "net-admins"}}, "admin"},
+		{"Unmapped", JWTConfig{RoleMap: roleMap}, map[string]interface{}{"roles": []string{"finance"}}, ""},
+		{"Nested claim", JWTConfig{RoleClaim: "realm_access.roles", RoleMap: roleMap}, map[string]interface{}{"realm_access": map[string]interface{}{"roles": []string{"netops"}}}, "operator"},
+		{"Missing claim", JWTConfig{}, map[string]interface{}{}, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			validator, now := newTestValidator(t, tt.config)
+			claims := map[string]interface{}{"sub": "alice", "exp": now.Add(time.Hour).Unix()}
+			for key, value := range tt.claims {
+				claims[key] = value
+			}
+
+			identity, err := validator.Validate(signToken(t, "ES256", "ec-1", claims))
+			if err != nil {
+				t.Fatalf("Failed to validate token: %v", err)
+			}
+			if identity.Role != tt.expected {
+				t.Errorf("Expected role %q, got %q", tt.expected, identity.Role)
+			}
+		})
+	}
+
+	if _, err := ParseRoleMap("admins"); err == nil {
+		t.Error("Expected an error for a mapping without a role")
+	}
+}
+
+func TestJWKSFromURL(t *testing.T) {
+	jwks := testJWKS()
+	requests := 0
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		w.Write(jwks)
+	}))
+	defer ts.Close()
+
+	validator, now := newTestValidator(t, JWTConfig{JWKS: ts.URL})
+	if _, err := validator.Validate(signToken(t, "RS256", "rsa-1", testClaims(*now))); err != nil {
+		t.Fatalf("Failed to validate token: %v", err)
+	}
+
+	// Unknown key IDs reload the JWKS, but at most once a minute
+	validator.Validate(signToken(t, "RS256", "rotated", testClaims(*now)))
+	if requests != 1 {
+		t.Errorf("Expected no reload within a minute, got %d requests", requests)
+	}
+	*now = now.Add(time.Minute)
+	validator.Validate(signToken(t, "RS256", "rotated", testClaims(*now)))
+	validator.Validate(signToken(t, "RS256", "rotated", testClaims(*now)))
+	if requests != 2 {
+		t.Errorf("Expected one reload, got %d requests", requests)
+	}
+}
+
+func TestJWKSReloadWithoutLock(t *testing.T) {
+	jwks := testJWKS()
+	var block atomic.Bool
+	started := make(chan struct{})
+	release := make(chan struct{})
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if block.Load() {
+			started <- struct{}{}
+			<-release
+		}
+		w.Write(jwks)
+	}))
+	defer ts.Close()
+
+	validator, now := newTestValidator(t, JWTConfig{JWKS: ts.URL})
+	*now = now.Add(time.Minute)
+	block.Store(true)
+
+	token := signToken(t, "RS256", "rotated", testClaims(*now))
+	rotated := make(chan error, 1)
+	go func() {
+		_, err := validator.Validate(token)
+		rotated <- err
+	}()
+	<-started
+
+	// While the JWKS is being fetched, tokens with known keys still validate
+	if _, err := validator.Validate(signToken(t, "RS256", "rsa-1", testClaims(*now))); err != nil {
+		t.Errorf("Failed to validate token during reload: %v", err)
+	}
+
+	close(release)
+	if err := <-rotated; !errors.Is(err, ErrInvalidToken) {
+		t.Errorf("Expected the unknown key to stay invalid, got %v", err)
+	}
+}
+
+func TestLoadJWKSKeySelection(t *testing.T) {
+	weak, _ := rsa.GenerateKey(rand.Reader, 1024)
+	other, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	rsaKey := map[string]string{"kty": "RSA", "alg": "RS256", "n": encodeBigInt(testRSAKey.N), "e": encodeBigInt(big.NewInt(int64(testRSAKey.E)))}
+	ecKey := func(key *ecdsa.PrivateKey, kid string) map[string]string {
+		return map[string]string{"kty": "EC", "kid": kid, "crv": "P-256", "x": encodeBigInt(key.X), "y": encodeBigInt(key.Y)}
+	}
+	data, _ := json.Marshal(map[string]interface{}{"keys": []map[string]string{
+		// Unusable keys are skipped rather than failing the whole set
+		{"kty": "RSA", "kid": "weak", "n": encodeBigInt(weak.N), "e": "AQAB"},
+		{"kty": "OKP", "kid": "ed-1", "crv": "Ed25519", "x": "AQ"},
+		// Keys without an ID do not overwrite each other
+		rsaKey,
+		ecKey(testECKey, ""),
+		// One ID for two keys of the same type is ambiguous
+		ecKey(testECKey, "shared"),
+		ecKey(other, "shared"),
+	}})
+	path := filepath.Join(t.TempDir(), "jwks.json")
+	os.WriteFile(path, data, 0644)
+	validator, now := newTestValidator(t, JWTConfig{JWKS: path, Issuer: "https://idp.example.com", Audience: "smit"})
+
+	tests := []struct {
+		name     string
+		alg      string
+		kid      string
+		expected error
+	}{
+		{"RSA key without ID", "RS256", "", nil},
+		{"Skipped key", "RS256", "weak", ErrInvalidToken},
+		{"Duplicate ID", "ES256", "shared", ErrInvalidToken},
+		{"Several EC keys and no ID", "ES256", "", ErrInvalidToken},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := validator.Validate(signToken(t, tt.alg, tt.kid, testClaims(*now)))
+			if !errors.Is(err, tt.expected) || (tt.expected == nil && err != nil) {
+				t.Errorf("Expected %v, got %v", tt.expected, err)
+			}
+		})
+	}
+}
+
+func TestLoadJWKSErrors(t *testing.T) {
+	weak, _ := rsa.GenerateKey(rand.Reader, 1024)
+	tests := []struct {
+		name string
+		jwks string
+	}{
+		{"Invalid JSON", `{"keys": [`},
+		{"No keys", `{"keys": []}`},
+		{"Only unusable keys", `{"keys": [{"kty": "oct", "k": "AQ"}, {"kty": "EC", "crv": "P-384", "x": "AQ", "y": "AQ"}]}`},
+		{"Weak RSA key", `{"keys": [{"kty": "RSA", "n": "` + encodeBigInt(weak.N) + `", "e": "AQAB"}]}`},
+		{"Point off the curve", `{"keys": [{"kty": "EC", "crv": "P-256", "x": "AQ", "y": "AQ"}]}`},
+		{"Unsupported curve", `{"keys": [{"kty": "EC", "crv": "P-384", "x": "AQ", "y": "AQ"}]}`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "jwks.json")
+			os.WriteFile(path, []byte(tt.jwks), 0644)
+
+			if _, err := NewJWTValidator(JWTConfig{JWKS: path}); err == nil {
+				t.Error("Expected an error")
+			}
+		})
+	}
+
+	if _, err := NewJWTValidator(JWTConfig{JWKS: filepath.Join(t.TempDir(), "missing.json")}); err == nil {
+		t.Error("Expected an error for a missing file")
+	}
+}
@@ -0,0 +1,162 @@
+// Package certs serves TLS certificates that are reloaded when their files
+// change, so rotated certificates take effect without a restart.
+package certs
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"log/slog"
+	"os"
+	"sync"
+	"time"
+)
+
+// Default interval between checks of the certificate files
+const DefaultInterval = 30 * time.Second
+
+// Size and modification time of a file, to notice rotations
+type fileState struct {
+	size    int64
+	modTime time.Time
+}
+
+// Certificate and key pair kept in sync with its files. The reloader is safe for
+// concurrent use; GetCertificate is meant for tls.Config.
+type Reloader struct {
+	certFile string
+	keyFile  string
+	interval time.Duration
+
+	mu    sync.RWMutex
+	cert  *tls.Certificate
+	state [2]fileState
+	// Expiry of the served certificate, and the error of the latest check of the files
+	notAfter time.Time
+	err      error
+}
+
+// Load a certificate and key pair that is checked for changes every interval
+func NewReloader(certFile, keyFile string, interval time.Duration) (*Reloader, error) {
+	if interval <= 0 {
+		interval = DefaultInterval
+	}
+	r := &Reloader{certFile: certFile, keyFile: keyFile, interval: interval}
+	if _, err := r.reload(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// Current certificate, for tls.Config.GetCertificate
+func (r *Reloader) GetCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.cert, nil
+}
+
+// Check the files every interval until the context is cancelled
+func (r *Reloader) Run(ctx context.Context) {
+	ticker := time.NewTicker(r.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if reloaded, err := r.reload(); err != nil {
+				// Keep serving the old pair; a rotation may be half done
+				slog.Error("TLS certificate reload failed", "error", err)
+			} else if reloaded {
+				slog.Info("Reloaded TLS certificate", "file", r.certFile)
+			}
+		}
+	}
+}
+
+// Load the pair when either file changed since the last load, reporting whether it
+// did. The outcome is kept for the readiness check.
+func (r *Reloader) reload() (bool, error) {
+	reloaded, err := r.load()
+	r.mu.Lock()
+	r.err = err
+	r.mu.Unlock()
+	return reloaded, err
+}
+
+func (r *Reloader) load() (bool, error) {
+	var state [2]fileState
+	for i, path := range []string{r.certFile, r.keyFile} {
+		info, err := os.Stat(path)
+		if err != nil {
+			return false, fmt.Errorf("failed to read TLS file: %w", err)
+		}
+		state[i] = fileState{size: info.Size(), modTime: info.ModTime()}
+	}
+
+	r.mu.RLock()
+	unchanged := r.cert != nil && state == r.state
+	r.mu.RUnlock()
+	if unchanged {
+		return false, nil
+	}
+
+	cert, err := tls.LoadX509KeyPair(r.certFile, r.keyFile)
+	if err != nil {
+		return false, fmt.Errorf("failed to load TLS certificate: %w", err)
+	}
+	leaf, err := x509.ParseCertificate(cert.Certificate[0])
+	if err != nil {
+		return false, fmt.Errorf("failed to parse TLS certificate: %w", err)
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.cert = &cert
+	r.state = state
+	r.notAfter = leaf.NotAfter
+	return true, nil
+}
+
+// Report whether the served certificate is current, for the readiness probe.
+// The check fails while the latest reload failed or once the certificate has
+// expired, since clients would then see a stale or invalid certificate.
+func (r *Reloader) Check(ctx context.Context) (string, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	if r.err != nil {
+		return "", r.err
+	}
+	if time.Now().After(r.notAfter) {
+		return "", fmt.Errorf("TLS certificate expired at %s", r.notAfter.Format(time.RFC3339))
+	}
+	return "certificate valid until " + r.notAfter.Format(time.RFC3339), nil
+}
+
+// Load a PEM bundle of CA certificates
+func LoadCertPool(path string) (*x509.CertPool, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read CA bundle: %w", err)
+	}
+
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(data) {
+		return nil, fmt.Errorf("CA bundle %s has no PEM certificates", path)
+	}
+	return pool, nil
+}
+
+// Parse a TLS version such as "1.2" or "1.3"
+func ParseVersion(value string) (uint16, error) {
+	switch value {
+	case "1.2":
+		return tls.VersionTLS12, nil
+	case "1.3":
+		return tls.VersionTLS13, nil
+	}
+	return 0, fmt.Errorf("unsupported TLS version %q, must be 1.2 or 1.3", value)
+}
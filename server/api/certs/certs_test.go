@@ -0,0 +1,162 @@
+package certs
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// Write a self-signed certificate and its key, returning the certificate
+func writeCertificate(t *testing.T, certFile, keyFile, commonName string) *x509.Certificate {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("Failed to generate key: %v", err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      pkix.Name{CommonName: commonName},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		IsCA:         true,
+		KeyUsage:     x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		DNSNames:     []string{"localhost"},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatalf("Failed to create certificate: %v", err)
+	}
+	keyDER, _ := x509.MarshalECPrivateKey(key)
+
+	os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0644)
+	os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0600)
+	cert, _ := x509.ParseCertificate(der)
+	return cert
+}
+
+func servedName(t *testing.T, r *Reloader) string {
+	t.Helper()
+	cert, err := r.GetCertificate(&tls.ClientHelloInfo{})
+	if err != nil || cert == nil {
+		t.Fatalf("Expected a certificate, got %v", err)
+	}
+	leaf, _ := x509.ParseCertificate(cert.Certificate[0])
+	return leaf.Subject.CommonName
+}
+
+func TestReloader(t *testing.T) {
+	dir := t.TempDir()
+	certFile, keyFile := filepath.Join(dir, "tls.crt"), filepath.Join(dir, "tls.key")
+	writeCertificate(t, certFile, keyFile, "first")
+
+	reloader, err := NewReloader(certFile, keyFile, time.Minute)
+	if err != nil {
+		t.Fatalf("Failed to load certificate: %v", err)
+	}
+	if name := servedName(t, reloader); name != "first" {
+		t.Errorf("Expected first certificate, got %q", name)
+	}
+
+	if reloaded, err := reloader.reload(); reloaded || err != nil {
+		t.Errorf("Expected no reload of unchanged files, got %v, %v", reloaded, err)
+	}
+
+	// A half-done rotation keeps the old pair until the key follows
+	writeCertificate(t, certFile, filepath.Join(dir, "next.key"), "second")
+	if _, err := reloader.reload(); err == nil {
+		t.Error("Expected an error for a mismatched pair")
+	}
+	if name := servedName(t, reloader); name != "first" {
+		t.Errorf("Expected the old certificate after a failed reload, got %q", name)
+	}
+	if _, err := reloader.Check(context.Background()); err == nil {
+		t.Error("Expected the check to fail while the reload fails")
+	}
+
+	os.Rename(filepath.Join(dir, "next.key"), keyFile)
+	if reloaded, err := reloader.reload(); !reloaded || err != nil {
+		t.Fatalf("Expected a reload after rotation, got %v, %v", reloaded, err)
+	}
+	if name := servedName(t, reloader); name != "second" {
+		t.Errorf("Expected second certificate, got %q", name)
+	}
+	if detail, err := reloader.Check(context.Background()); err != nil || !strings.HasPrefix(detail, "certificate valid until ") {
+		t.Errorf("Expected the check to pass after the rotation, got %q, %v", detail, err)
+	}
+}
+
+func TestReloaderCheckExpired(t *testing.T) {
+	dir := t.TempDir()
+	certFile, keyFile := filepath.Join(dir, "tls.crt"), filepath.Join(dir, "tls.key")
+	writeCertificate(t, certFile, keyFile, "expiring")
+
+	reloader, err := NewReloader(certFile, keyFile, time.Minute)
+	if err != nil {
+		t.Fatalf("Failed to load certificate: %v", err)
+	}
+	reloader.notAfter = time.Now().Add(-time.Minute)
+	if _, err := reloader.Check(context.Background()); err == nil || !strings.Contains(err.Error(), "expired") {
+		t.Errorf("Expected an expired certificate to fail the check, got %v", err)
+	}
+}
+
+func TestNewReloaderErrors(t *testing.T) {
+	dir := t.TempDir()
+	if _, err := NewReloader(filepath.Join(dir, "missing.crt"), filepath.Join(dir, "missing.key"), 0); err == nil {
+		t.Error("Expected an error for missing files")
+	}
+
+	certFile, keyFile := filepath.Join(dir, "tls.crt"), filepath.Join(dir, "tls.key")
+	os.WriteFile(certFile, []byte("not a certificate"), 0644)
+	os.WriteFile(keyFile, []byte("not a key"), 0600)
+	if _, err := NewReloader(certFile, keyFile, 0); err == nil {
+		t.Error("Expected an error for invalid PEM")
+	}
+}
+
+func TestLoadCertPool(t *testing.T) {
+	dir := t.TempDir()
+	bundle := filepath.Join(dir, "ca.crt")
+	writeCertificate(t, bundle, filepath.Join(dir, "ca.key"), "ca")
+
+	if _, err := LoadCertPool(bundle); err != nil {
+		t.Errorf("Failed to load CA bundle: %v", err)
+	}
+
+	empty := filepath.Join(dir, "empty.crt")
+	os.WriteFile(empty, []byte("no certificates here"), 0644)
+	if _, err := LoadCertPool(empty); err == nil {
+		t.Error("Expected an error for a bundle without certificates")
+	}
+}
+
+func TestParseVersion(t *testing.T) {
+	tests := []struct {
+		value    string
+		expected uint16
+		wantErr  bool
+	}{
+		{"1.2", tls.VersionTLS12, false},
+		{"1.3", tls.VersionTLS13, false},
+		{"1.0", 0, true},
+		{"", 0, true},
+	}
+
+	for _, tt := range tests {
+		version, err := ParseVersion(tt.value)
+		if (err != nil) != tt.wantErr || version != tt.expected {
+			t.Errorf("ParseVersion(%q) = %d, %v", tt.value, version, err)
+		}
+	}
+}
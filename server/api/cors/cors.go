@@ -0,0 +1,152 @@
+// Package cors applies a configurable cross-origin resource sharing policy.
+// Only allowed origins get CORS headers, and preflight requests are answered
+// with the methods of the route they ask about.
+package cors
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Request headers browsers may send to the API
+var DefaultAllowedHeaders = []string{"Content-Type", "Authorization", "X-API-Key", "Idempotency-Key", "If-Match", "X-Request-ID", "traceparent"}
+
+// Response headers browsers may read by default
+var DefaultExposedHeaders = []string{"ETag", "Location", "Link", "Idempotent-Replayed", "X-Request-ID", "RateLimit-Limit", "RateLimit-Remaining", "RateLimit-Reset", "RateLimit-Policy", "Retry-After"}
+
+// Looks up the methods a path is served with
+type Routes interface {
+	// Methods of the route matching the path; ok is false when no route matches
+	Methods(path string) (methods []string, ok bool)
+}
+
+// Cross-origin policy
+type Policy struct {
+	// Allowed origins; "*" allows any origin and "https://*.example.com" any subdomain
+	AllowedOrigins []string
+	AllowedHeaders []string
+	ExposedHeaders []string
+	// Allow cookies and Authorization headers on cross-origin requests
+	AllowCredentials bool
+	// How long browsers may cache a preflight response; zero leaves it to the browser
+	MaxAge time.Duration
+}
+
+// Check the policy for settings browsers would reject or that would be unsafe
+func (p *Policy) Validate() error {
+	for _, origin := range p.AllowedOrigins {
+		if origin == "*" {
+			if p.AllowCredentials {
+				return fmt.Errorf("credentials cannot be allowed for every origin")
+			}
+			continue
+		}
+		scheme, host, ok := strings.Cut(origin, "://")
+		if !ok || scheme == "" || host == "" || strings.Contains(host, "/") {
+			return fmt.Errorf("invalid origin %q, must be scheme://host[:port]", origin)
+		}
+		if strings.Contains(host, "*") && (!strings.HasPrefix(host, "*.") || strings.Count(host, "*") > 1) {
+			return fmt.Errorf("invalid origin %q, a wildcard must be the leftmost label", origin)
+		}
+	}
+	if p.MaxAge < 0 {
+		return fmt.Errorf("max age must not be negative")
+	}
+	return nil
+}
+
+// Apply the policy in front of next. Preflight requests to known routes are
+// answered here; OPTIONS requests to unknown paths fall through to next.
+func (p *Policy) Handler(routes Routes, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// Unless every origin is allowed, the response depends on the origin, whether it
+		// is allowed or not, so caches must keep one per origin
+		if !p.allowsAnyOrigin() {
+			w.Header().Add("Vary", "Origin")
+		}
+
+		origin := r.Header.Get("Origin")
+		allowed := origin != "" && p.allowsOrigin(origin)
+		if allowed {
+			p.setOriginHeaders(w, origin)
+		}
+
+		methods, known := routes.Methods(r.URL.Path)
+		if r.Method != http.MethodOptions || !known {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		allow := append(append([]string{}, methods...), http.MethodOptions)
+		w.Header().Set("Allow", strings.Join(allow, ", "))
+		if allowed && r.Header.Get("Access-Control-Request-Method") != "" {
+			w.Header().Add("Vary", "Access-Control-Request-Method, Access-Control-Request-Headers")
+			w.Header().Set("Access-Control-Allow-Methods", strings.Join(methods, ", "))
+			if len(p.AllowedHeaders) > 0 {
+				w.Header().Set("Access-Control-Allow-Headers", strings.Join(p.AllowedHeaders, ", "))
+			}
+			if p.MaxAge > 0 {
+				w.Header().Set("Access-Control-Max-Age", strconv.Itoa(int(p.MaxAge.Seconds())))
+			}
+		}
+		w.WriteHeader(http.StatusOK)
+	})
+}
+
+// Headers of every response to an allowed origin
+func (p *Policy) setOriginHeaders(w http.ResponseWriter, origin string) {
+	if p.allowsAnyOrigin() {
+		w.Header().Set("Access-Control-Allow-Origin", "*")
+	} else {
+		w.Header().Set("Access-Control-Allow-Origin", origin)
+	}
+	if p.AllowCredentials {
+		w.Header().Set("Access-Control-Allow-Credentials", "true")
+	}
+	if len(p.ExposedHeaders) > 0 {
+		w.Header().Set("Access-Control-Expose-Headers", strings.Join(p.ExposedHeaders, ", "))
+	}
+}
+
+func (p *Policy) allowsAnyOrigin() bool {
+	for _, allowed := range p.AllowedOrigins {
+		if allowed == "*" {
+			return true
+		}
+	}
+	return false
+}
+
+// Check an Origin header against the allowed origins
+func (p *Policy) allowsOrigin(origin string) bool {
+	origin = strings.ToLower(origin)
+	for _, allowed := range p.AllowedOrigins {
+		allowed = strings.ToLower(allowed)
+		if allowed == "*" || allowed == origin {
+			return true
+		}
+
+		// https://*.example.com matches any subdomain, but not example.com itself
+		prefix, suffix, ok := strings.Cut(allowed, "*")
+		if !ok || !strings.HasPrefix(origin, prefix) || !strings.HasSuffix(origin, suffix) || len(origin) <= len(prefix)+len(suffix) {
+			continue
+		}
+		if isSubdomain(origin[len(prefix) : len(origin)-len(suffix)]) {
+			return true
+		}
+	}
+	return false
+}
+
+// Check that a wildcard matched only host name labels
+func isSubdomain(labels string) bool {
+	for _, c := range labels {
+		if !(c >= 'a' && c <= 'z' || c >= '0' && c <= '9' || c == '-' || c == '.') {
+			return false
+		}
+	}
+	return !strings.HasPrefix(labels, ".")
+}
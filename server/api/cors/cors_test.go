@@ -0,0 +1,166 @@
+package cors
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// Routes with fixed methods per path
+type staticRoutes map[string][]string
+
+func (s staticRoutes) Methods(path string) ([]string, bool) {
+	methods, ok := s[path]
+	return methods, ok
+}
+
+func serve(policy *Policy, method, path, origin string) *httptest.ResponseRecorder {
+	routes := staticRoutes{"/api/v1/vlans": {"GET", "POST"}}
+	handler := policy.Handler(routes, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusTeapot)
+	}))
+
+	req := httptest.NewRequest(method, path, nil)
+	if origin != "" {
+		req.Header.Set("Origin", origin)
+	}
+	if method == http.MethodOptions {
+		req.Header.Set("Access-Control-Request-Method", "POST")
+	}
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+	return w
+}
+
+func TestAllowsOrigin(t *testing.T) {
+	policy := &Policy{AllowedOrigins: []string{"https://app.example.com", "https://*.noc.example.com", "http://localhost:3000"}}
+
+	tests := []struct {
+		origin   string
+		expected bool
+	}{
+		{"https://app.example.com", true},
+		{"https://APP.example.com", true},
+		{"http://app.example.com", false},
+		{"https://app.example.com:8443", false},
+		{"https://tallinn.noc.example.com", true},
+		{"https://a.b.noc.example.com", true},
+		{"https://noc.example.com", false},
+		{"https://evil.com/.noc.example.com", false},
+		{"https://evilnoc.example.com", false},
+		{"http://localhost:3000", true},
+		{"null", false},
+	}
+
+	for _, tt := range tests {
+		if allowed := policy.allowsOrigin(tt.origin); allowed != tt.expected {
+			t.Errorf("allowsOrigin(%q) = %v, expected %v", tt.origin, allowed, tt.expected)
+		}
+	}
+}
+
+func TestHandler(t *testing.T) {
+	policy := &Policy{
+		AllowedOrigins:   []string{"https://app.example.com"},
+		AllowedHeaders:   DefaultAllowedHeaders,
+		ExposedHeaders:   DefaultExposedHeaders,
+		AllowCredentials: true,
+		MaxAge:           10 * time.Minute,
+	}
+
+	// Preflight from an allowed origin
+	w := serve(policy, "OPTIONS", "/api/v1/vlans", "https://app.example.com")
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status %d, got %d", http.StatusOK, w.Code)
+	}
+	for header, expected := range map[string]string{
+		"Access-Control-Allow-Origin":      "https://app.example.com",
+		"Access-Control-Allow-Credentials": "true",
+		"Access-Control-Allow-Methods":     "GET, POST",
+		"Access-Control-Allow-Headers":     strings.Join(DefaultAllowedHeaders, ", "),
+		"Access-Control-Max-Age":           "600",
+		"Allow":                            "GET, POST, OPTIONS",
+	} {
+		if value := w.Header().Get(header); value != expected {
+			t.Errorf("Expected %s %q, got %q", header, expected, value)
+		}
+	}
+	if vary := w.Header().Values("Vary"); len(vary) != 2 || vary[0] != "Origin" {
+		t.Errorf("Unexpected Vary %v", vary)
+	}
+
+	// Simple request from an allowed origin
+	w = serve(policy, "GET", "/api/v1/vlans", "https://app.example.com")
+	if w.Code != http.StatusTeapot || w.Header().Get("Access-Control-Expose-Headers") != strings.Join(DefaultExposedHeaders, ", ") {
+		t.Errorf("Unexpected response %d with headers %v", w.Code, w.Header())
+	}
+	if w.Header().Get("Access-Control-Allow-Methods") != "" {
+		t.Error("Expected methods only on preflight responses")
+	}
+
+	// Disallowed origins get no CORS headers at all, but the response still varies by origin
+	for _, method := range []string{"OPTIONS", "GET"} {
+		w = serve(policy, method, "/api/v1/vlans", "https://evil.example.com")
+		for header := range w.Header() {
+			if strings.HasPrefix(header, "Access-Control-") {
+				t.Errorf("%s: unexpected header %s", method, header)
+			}
+		}
+		if vary := w.Header().Values("Vary"); len(vary) != 1 || vary[0] != "Origin" {
+			t.Errorf("%s: expected Vary Origin, got %v", method, vary)
+		}
+	}
+
+	// So does a response to a request without an origin
+	if w = serve(policy, "GET", "/api/v1/vlans", ""); w.Header().Get("Vary") != "Origin" {
+		t.Errorf("Expected Vary Origin without an origin, got %v", w.Header().Values("Vary"))
+	}
+
+	// OPTIONS on an unknown path is left to the next handler
+	if w = serve(policy, "OPTIONS", "/api/v1/unknown", "https://app.example.com"); w.Code != http.StatusTeapot {
+		t.Errorf("Expected the next handler to answer, got %d", w.Code)
+	}
+}
+
+func TestAnyOrigin(t *testing.T) {
+	policy := &Policy{AllowedOrigins: []string{"*"}}
+
+	w := serve(policy, "GET", "/api/v1/vlans", "https://anywhere.example.org")
+	if origin := w.Header().Get("Access-Control-Allow-Origin"); origin != "*" {
+		t.Errorf("Expected wildcard origin, got %q", origin)
+	}
+	if w.Header().Get("Vary") != "" {
+		t.Error("Expected no Vary for a wildcard origin")
+	}
+
+	// Requests without an Origin are not cross-origin
+	if w = serve(policy, "GET", "/api/v1/vlans", ""); w.Header().Get("Access-Control-Allow-Origin") != "" {
+		t.Error("Expected no CORS headers without an Origin")
+	}
+}
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		policy  Policy
+		wantErr bool
+	}{
+		{"Valid", Policy{AllowedOrigins: []string{"https://app.example.com", "https://*.example.com:8443"}, AllowCredentials: true}, false},
+		{"Any origin", Policy{AllowedOrigins: []string{"*"}}, false},
+		{"Any origin with credentials", Policy{AllowedOrigins: []string{"*"}, AllowCredentials: true}, true},
+		{"Missing scheme", Policy{AllowedOrigins: []string{"app.example.com"}}, true},
+		{"Path", Policy{AllowedOrigins: []string{"https://app.example.com/ui"}}, true},
+		{"Inner wildcard", Policy{AllowedOrigins: []string{"https://app.*.example.com"}}, true},
+		{"Negative max age", Policy{MaxAge: -time.Second}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.policy.Validate(); (err != nil) != tt.wantErr {
+				t.Errorf("Expected error %v, got %v", tt.wantErr, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,160 @@
+// Package events publishes VLAN changes to subscribers and keeps a bounded log of
+// recent events, so a reconnecting subscriber can resume where it left off.
+package events
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"smit/server/api/models"
+	"smit/server/api/tracing"
+)
+
+// Default number of events kept for resumption
+const DefaultLogSize = 1000
+
+// Bits of an event ID left for events published by one process
+const epochShift = 20
+
+// Number of undelivered events a subscriber may fall behind before it is dropped
+const subscriberBuffer = 64
+
+// Event types
+const (
+	TypeCreated = "created"
+	TypeUpdated = "updated"
+	TypeDeleted = "deleted"
+)
+
+// Change to a VLAN. For deletions VLAN is the VLAN as it was before removal.
+type Event struct {
+	ID   uint64           `json:"id"`
+	Type string           `json:"type"`
+	Time time.Time        `json:"time"`
+	VLAN models.VLANModel `json:"vlan"`
+	// VLAN before an update, so filters can tell when a VLAN stops matching
+	Previous *models.VLANModel `json:"-"`
+	// Span of the request that made the change, so deliveries can join its trace
+	Trace tracing.SpanContext `json:"-"`
+}
+
+// Broker fans events out to subscribers and keeps the most recent ones in a ring buffer
+type Broker struct {
+	mu          sync.Mutex
+	log         []Event
+	next        int
+	count       int
+	lastID      uint64
+	subscribers map[*Subscription]struct{}
+}
+
+// Live feed of events for one subscriber
+type Subscription struct {
+	// Closed when the subscription is cancelled or the subscriber fell too far behind
+	C      <-chan Event
+	c      chan Event
+	broker *Broker
+}
+
+// Create a broker keeping the given number of events
+func NewBroker(logSize int) *Broker {
+	if logSize <= 0 {
+		logSize = DefaultLogSize
+	}
+
+	return &Broker{
+		log:         make([]Event, logSize),
+		lastID:      epoch(time.Now()),
+		subscribers: make(map[*Subscription]struct{}),
+	}
+}
+
+// First event ID of a broker started at the given time, minus one. IDs carry the
+// start time in their upper bits, so a later process always numbers above an
+// earlier one and a client resuming from an earlier process is reset rather than
+// silently matched against unrelated events. The result stays below 2^53, so IDs
+// survive JSON clients that parse numbers as doubles.
+func epoch(start time.Time) uint64 {
+	return uint64(start.Unix()) << epochShift
+}
+
+// Record an event and deliver it to every subscriber. The event carries the
+// trace of the context, if any.
+func (b *Broker) Publish(ctx context.Context, eventType string, vlan models.VLANModel, previous *models.VLANModel) Event {
+	trace, _ := tracing.SpanContextFromContext(ctx)
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.lastID++
+	event := Event{ID: b.lastID, Type: eventType, Time: time.Now(), VLAN: vlan, Previous: previous, Trace: trace}
+
+	b.log[b.next] = event
+	b.next = (b.next + 1) % len(b.log)
+	if b.count < len(b.log) {
+		b.count++
+	}
+
+	for sub := range b.subscribers {
+		select {
+		case sub.c <- event:
+		default:
+			// A subscriber that cannot keep up reconnects and resumes from the log
+			b.drop(sub)
+		}
+	}
+
+	return event
+}
+
+// Subscribe to events after the given ID, zero for new events only. The returned
+// backlog holds the logged events after lastID; complete is false when some of them
+// have already left the log, or lastID is unknown, and the subscriber has to resync.
+func (b *Broker) Subscribe(lastID uint64) (sub *Subscription, backlog []Event, complete bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	c := make(chan Event, subscriberBuffer)
+	sub = &Subscription{C: c, c: c, broker: b}
+	b.subscribers[sub] = struct{}{}
+
+	if lastID == 0 || lastID == b.lastID {
+		return sub, nil, true
+	}
+	if lastID > b.lastID {
+		return sub, nil, false
+	}
+
+	// Logged events, oldest first
+	oldest := b.lastID - uint64(b.count) + 1
+	for i := 0; i < b.count; i++ {
+		event := b.log[(b.next-b.count+i+len(b.log))%len(b.log)]
+		if event.ID > lastID {
+			backlog = append(backlog, event)
+		}
+	}
+	return sub, backlog, lastID+1 >= oldest
+}
+
+// Stop receiving events
+func (s *Subscription) Cancel() {
+	s.broker.mu.Lock()
+	defer s.broker.mu.Unlock()
+	s.broker.drop(s)
+}
+
+// ID of the most recent event
+func (b *Broker) LastID() uint64 {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.lastID
+}
+
+// Remove a subscriber; the caller holds the lock
+func (b *Broker) drop(sub *Subscription) {
+	if _, ok := b.subscribers[sub]; ok {
+		delete(b.subscribers, sub)
+		close(sub.c)
+	}
+}
@@ -0,0 +1,112 @@
+package events
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"smit/server/api/models"
+)
+
+func TestBrokerPublish(t *testing.T) {
+	broker := NewBroker(10)
+	base := broker.LastID()
+	sub, backlog, complete := broker.Subscribe(0)
+	defer sub.Cancel()
+
+	if len(backlog) != 0 || !complete {
+		t.Fatalf("Expected an empty, complete backlog, got %d events, complete %v", len(backlog), complete)
+	}
+
+	broker.Publish(context.Background(), TypeCreated, models.VLANModel{ID: 1, Name: "Prod"}, nil)
+
+	event := <-sub.C
+	if event.ID != base+1 || event.Type != TypeCreated || event.VLAN.Name != "Prod" {
+		t.Errorf("Unexpected event %+v", event)
+	}
+}
+
+func TestBrokerResume(t *testing.T) {
+	broker := NewBroker(3)
+	broker.lastID = 0
+	for i := 1; i <= 5; i++ {
+		broker.Publish(context.Background(), TypeUpdated, models.VLANModel{ID: i}, nil)
+	}
+
+	tests := []struct {
+		name     string
+		lastID   uint64
+		ids      []uint64
+		complete bool
+	}{
+		{"Within the log", 3, []uint64{4, 5}, true},
+		{"Just before the log", 2, []uint64{3, 4, 5}, true},
+		{"Evicted", 1, []uint64{3, 4, 5}, false},
+		{"Up to date", 5, nil, true},
+		{"Unknown", 9, nil, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sub, backlog, complete := broker.Subscribe(tt.lastID)
+			defer sub.Cancel()
+
+			if complete != tt.complete {
+				t.Errorf("Expected complete %v, got %v", tt.complete, complete)
+			}
+			if len(backlog) != len(tt.ids) {
+				t.Fatalf("Expected %d events, got %d", len(tt.ids), len(backlog))
+			}
+			for i, id := range tt.ids {
+				if backlog[i].ID != id {
+					t.Errorf("Expected event %d to have ID %d, got %d", i, id, backlog[i].ID)
+				}
+			}
+		})
+	}
+}
+
+func TestBrokerEpoch(t *testing.T) {
+	earlier := NewBroker(10)
+	earlier.lastID = epoch(time.Now().Add(-time.Hour))
+	for i := 0; i < 3; i++ {
+		earlier.Publish(context.Background(), TypeUpdated, models.VLANModel{ID: 1}, nil)
+	}
+
+	// A restarted broker numbers above the previous process and resets its clients
+	broker := NewBroker(10)
+	if broker.LastID() <= earlier.LastID() {
+		t.Fatalf("Expected IDs after a restart to exceed %d, got %d", earlier.LastID(), broker.LastID())
+	}
+	broker.Publish(context.Background(), TypeUpdated, models.VLANModel{ID: 1}, nil)
+
+	sub, backlog, complete := broker.Subscribe(earlier.LastID())
+	defer sub.Cancel()
+	if complete || len(backlog) != 1 {
+		t.Errorf("Expected a reset with the whole log for an ID from an earlier process, got %d events, complete %v", len(backlog), complete)
+	}
+
+	if latest := epoch(time.Date(2100, 1, 1, 0, 0, 0, 0, time.UTC)); latest >= 1<<53 {
+		t.Errorf("Expected IDs to stay exact as JSON numbers, got %d", latest)
+	}
+}
+
+func TestBrokerDropsSlowSubscriber(t *testing.T) {
+	broker := NewBroker(10)
+	sub, _, _ := broker.Subscribe(0)
+
+	for i := 0; i <= subscriberBuffer; i++ {
+		broker.Publish(context.Background(), TypeUpdated, models.VLANModel{ID: 1}, nil)
+	}
+
+	received := 0
+	for range sub.C {
+		received++
+	}
+	if received != subscriberBuffer {
+		t.Errorf("Expected %d buffered events before the channel closed, got %d", subscriberBuffer, received)
+	}
+
+	// Cancelling a dropped subscription is harmless
+	sub.Cancel()
+}
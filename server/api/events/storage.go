@@ -0,0 +1,99 @@
+package events
+
+import (
+	"context"
+	"sync"
+
+	"smit/server/api/models"
+	"smit/server/api/storage"
+)
+
+// Storage publishes an event for every VLAN write that succeeds on the wrapped storage.
+// Events of a copy bound to a request's context carry the request's trace.
+//
+// Each write, the read of the VLAN it replaces and its publication happen under one
+// lock shared by all copies, so events are published in the order the writes were
+// applied and Previous is the VLAN the write actually replaced. This holds as long
+// as every VLAN write goes through this storage.
+type Storage struct {
+	storage.Storage
+	broker *Broker
+	ctx    context.Context
+	mu     *sync.Mutex
+}
+
+// Wrap a storage so its writes are published to the broker
+func NewStorage(next storage.Storage, broker *Broker) *Storage {
+	return &Storage{Storage: next, broker: broker, ctx: context.Background(), mu: &sync.Mutex{}}
+}
+
+// Copy of the storage whose events carry the context's trace
+func (s *Storage) WithContext(ctx context.Context) storage.Storage {
+	bound := *s
+	bound.ctx = ctx
+	return &bound
+}
+
+// Create a VLAN and publish a created event
+func (s *Storage) Create(input *models.VLANInput) (*models.VLANModel, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	vlan, err := s.Storage.Create(input)
+	if err != nil {
+		return nil, err
+	}
+
+	s.broker.Publish(s.ctx, TypeCreated, *vlan, nil)
+	return vlan, nil
+}
+
+// Update a VLAN and publish an updated event
+func (s *Storage) Update(id int, input *models.VLANInput) (*models.VLANModel, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	previous, _ := s.Storage.GetByID(id)
+
+	vlan, err := s.Storage.Update(id, input)
+	if err != nil {
+		return nil, err
+	}
+
+	s.broker.Publish(s.ctx, TypeUpdated, *vlan, previous)
+	return vlan, nil
+}
+
+// Change a VLAN's status and publish an updated event
+func (s *Storage) UpdateStatus(id int, change models.StatusChange) (*models.VLANModel, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	previous, _ := s.Storage.GetByID(id)
+
+	vlan, err := s.Storage.UpdateStatus(id, change)
+	if err != nil {
+		return nil, err
+	}
+
+	s.broker.Publish(s.ctx, TypeUpdated, *vlan, previous)
+	return vlan, nil
+}
+
+// Delete a VLAN and publish a deleted event carrying the removed VLAN
+func (s *Storage) Delete(id int, ifMatch string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	vlan, err := s.Storage.GetByID(id)
+	if err != nil {
+		return err
+	}
+
+	if err := s.Storage.Delete(id, ifMatch); err != nil {
+		return err
+	}
+
+	s.broker.Publish(s.ctx, TypeDeleted, *vlan, nil)
+	return nil
+}
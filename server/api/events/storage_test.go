@@ -0,0 +1,133 @@
+package events
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"smit/server/api/models"
+	"smit/server/api/storage"
+	"smit/server/api/tracing"
+)
+
+func TestStoragePublishesWrites(t *testing.T) {
+	store, err := storage.NewJSONStorage(filepath.Join(t.TempDir(), "events_test.json"))
+	if err != nil {
+		t.Fatalf("Failed to create storage: %v", err)
+	}
+	broker := NewBroker(10)
+	vlans := NewStorage(store, broker)
+	sub, _, _ := broker.Subscribe(0)
+	defer sub.Cancel()
+
+	input := models.VLANInput{Name: "Prod", VlanID: 100, Subnet: "10.1.0.0/24", Gateway: "10.1.0.1", Status: "active"}
+	vlan, err := vlans.Create(&input)
+	if err != nil {
+		t.Fatalf("Failed to create VLAN: %v", err)
+	}
+
+	// Failed and dry-run writes publish nothing
+	vlans.Create(&input)
+	vlans.CheckCreate(&models.VLANInput{Name: "Dev", VlanID: 200, Subnet: "10.2.0.0/24", Gateway: "10.2.0.1", Status: "active"})
+
+	input.Status = "maintenance"
+	vlans.Update(vlan.ID, &input)
+	vlans.UpdateStatus(vlan.ID, models.StatusChange{To: "inactive", Reason: "Done"})
+	vlans.Delete(vlan.ID, "")
+	vlans.Delete(vlan.ID, "")
+
+	var published []Event
+	for len(sub.C) > 0 {
+		published = append(published, <-sub.C)
+	}
+
+	expected := []struct {
+		eventType string
+		status    string
+		previous  string
+	}{
+		{TypeCreated, "active", ""},
+		{TypeUpdated, "maintenance", "active"},
+		{TypeUpdated, "inactive", "maintenance"},
+		{TypeDeleted, "inactive", ""},
+	}
+	if len(published) != len(expected) {
+		t.Fatalf("Expected %d events, got %d", len(expected), len(published))
+	}
+	for i, want := range expected {
+		event := published[i]
+		if event.Type != want.eventType || event.VLAN.Status != want.status {
+			t.Errorf("Event %d: expected %s with status %s, got %s with status %s", i, want.eventType, want.status, event.Type, event.VLAN.Status)
+		}
+		if want.previous != "" && (event.Previous == nil || event.Previous.Status != want.previous) {
+			t.Errorf("Event %d: expected previous status %s, got %+v", i, want.previous, event.Previous)
+		}
+	}
+}
+
+func TestStoragePublishesInWriteOrder(t *testing.T) {
+	store, err := storage.NewJSONStorage(filepath.Join(t.TempDir(), "events_test.json"))
+	if err != nil {
+		t.Fatalf("Failed to create storage: %v", err)
+	}
+	broker := NewBroker(50)
+	vlans := NewStorage(store, broker)
+	vlan, err := vlans.Create(&models.VLANInput{Name: "Prod", VlanID: 100, Subnet: "10.1.0.0/24", Gateway: "10.1.0.1", Status: "active"})
+	if err != nil {
+		t.Fatalf("Failed to create VLAN: %v", err)
+	}
+	sub, _, _ := broker.Subscribe(broker.LastID())
+	defer sub.Cancel()
+
+	// Concurrent writers through request-bound copies
+	var wg sync.WaitGroup
+	for i := 0; i < 40; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			input := models.VLANInput{Name: fmt.Sprintf("Prod-%d", i), VlanID: 100, Subnet: "10.1.0.0/24", Gateway: "10.1.0.1", Status: "active"}
+			vlans.WithContext(context.Background()).Update(vlan.ID, &input)
+		}(i)
+	}
+	wg.Wait()
+
+	previous := *vlan
+	for len(sub.C) > 0 {
+		event := <-sub.C
+		if event.Previous == nil || event.Previous.Name != previous.Name {
+			t.Fatalf("Event %d: expected previous %s, got %+v", event.ID, previous.Name, event.Previous)
+		}
+		previous = event.VLAN
+	}
+	if current, _ := store.GetByID(vlan.ID); current.Name != previous.Name {
+		t.Errorf("Expected the last event to carry the stored VLAN %s, got %s", current.Name, previous.Name)
+	}
+}
+
+func TestStorageEventsCarryTrace(t *testing.T) {
+	store, err := storage.NewJSONStorage(filepath.Join(t.TempDir(), "events_test.json"))
+	if err != nil {
+		t.Fatalf("Failed to create storage: %v", err)
+	}
+	broker := NewBroker(10)
+	vlans := NewStorage(store, broker)
+	sub, _, _ := broker.Subscribe(0)
+	defer sub.Cancel()
+
+	parent, _ := tracing.ParseTraceparent("00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	ctx := tracing.ContextWithRemoteParent(context.Background(), parent)
+	vlans.Create(&models.VLANInput{Name: "Prod", VlanID: 100, Subnet: "10.1.0.0/24", Gateway: "10.1.0.1", Status: "active"})
+	vlans.WithContext(ctx).Create(&models.VLANInput{Name: "Dev", VlanID: 200, Subnet: "10.2.0.0/24", Gateway: "10.2.0.1", Status: "active"})
+
+	if len(sub.C) != 2 {
+		t.Fatalf("Expected 2 events, got %d", len(sub.C))
+	}
+	if event := <-sub.C; event.Trace.TraceID.IsValid() {
+		t.Errorf("Expected no trace outside a request, got %+v", event.Trace)
+	}
+	if event := <-sub.C; event.Trace != parent {
+		t.Errorf("Expected the event to carry the request's trace, got %+v", event.Trace)
+	}
+}
@@ -0,0 +1,129 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"smit/server/api/audit"
+	"smit/server/api/auth"
+	"smit/server/api/models"
+	"smit/server/api/rbac"
+)
+
+// Maximum number of audit entries returned at once
+const maxAuditLimit = 1000
+
+// Check that the caller's role may perform the action, sending 403 when it may not.
+// Every check passes when no access policy is configured.
+func (h *Handler) authorize(w http.ResponseWriter, r *http.Request, action string) bool {
+	return h.authorizeVLAN(w, r, action, nil, nil)
+}
+
+// Check a VLAN write against the caller's role and scope. The current VLAN is nil on
+// create and the input is nil on delete; on update the changed fields must be ones
+// the role may change, and both the old and the new VLAN must be inside the scope.
+func (h *Handler) authorizeVLAN(w http.ResponseWriter, r *http.Request, action string, current *models.VLANModel, input *models.VLANInput) bool {
+	if h.policy == nil {
+		return true
+	}
+
+	identity := auth.IdentityFromContext(r.Context())
+	reason := h.denialReason(identity, action, current, input)
+	h.recordDecision(r, identity, action, reason)
+	if reason == "" {
+		return true
+	}
+
+	h.sendProblem(w, r, problemForbidden, reason)
+	return false
+}
+
+// Why the policy denies the action, or empty when it is allowed
+func (h *Handler) denialReason(identity *auth.Identity, action string, current *models.VLANModel, input *models.VLANInput) string {
+	if identity == nil {
+		return "Request is not authenticated"
+	}
+	if !h.policy.HasRole(identity.Role) {
+		return "Caller has no valid role"
+	}
+	if !h.policy.Allows(identity.Role, action) {
+		return fmt.Sprintf("Role %s may not perform %s", identity.Role, action)
+	}
+
+	if current != nil && !identity.Scope.Allows(current.Site, current.VlanID) {
+		return fmt.Sprintf("VLAN %d is outside the scope of the caller", current.VlanID)
+	}
+	if input != nil && !identity.Scope.Allows(input.Site, input.VlanID) {
+		return fmt.Sprintf("VLAN %d is outside the scope of the caller", input.VlanID)
+	}
+	if current != nil && input != nil {
+		if denied := h.policy.DeniedFields(identity.Role, current.ChangedFields(input)); len(denied) > 0 {
+			return fmt.Sprintf("Role %s may not change %s", identity.Role, strings.Join(denied, ", "))
+		}
+	}
+	return ""
+}
+
+// Record denied actions and allowed writes in the audit log
+func (h *Handler) recordDecision(r *http.Request, identity *auth.Identity, action, reason string) {
+	if h.audit == nil || (reason == "" && strings.HasSuffix(action, ":read")) {
+		return
+	}
+
+	entry := audit.Entry{
+		Action:   action,
+		Method:   r.Method,
+		Path:     r.URL.Path,
+		Decision: audit.DecisionAllowed,
+		Reason:   reason,
+	}
+	if reason != "" {
+		entry.Decision = audit.DecisionDenied
+	}
+	if identity != nil {
+		entry.KeyID = identity.KeyID
+		entry.Subject = identity.Subject
+		entry.Role = identity.Role
+	}
+	h.audit.Record(entry)
+}
+
+// Handles GET /api/v1/audit
+func (h *Handler) GetAuditLog(w http.ResponseWriter, r *http.Request) {
+	r, span := h.startSpan(r, "GetAuditLog")
+	defer span.End()
+
+	if r.Method != http.MethodGet {
+		h.sendProblem(w, r, problemMethodNotAllowed, "Method not allowed")
+		return
+	}
+	if !h.authorize(w, r, rbac.ActionAuditRead) {
+		return
+	}
+	if h.audit == nil {
+		h.sendProblem(w, r, problemNotImplemented, "Audit log is not configured")
+		return
+	}
+
+	var errs models.ValidationErrors
+	limit := 100
+	if value := r.URL.Query().Get("limit"); value != "" {
+		parsed, err := strconv.Atoi(value)
+		if err != nil || parsed < 1 || parsed > maxAuditLimit {
+			errs.Add("limit", models.CodeOutOfRange, fmt.Sprintf("limit must be between 1 and %d", maxAuditLimit))
+		}
+		limit = parsed
+	}
+	decision := r.URL.Query().Get("decision")
+	if decision != "" && decision != audit.DecisionAllowed && decision != audit.DecisionDenied {
+		errs.Add("decision", models.CodeInvalidValue, "decision must be allowed or denied")
+	}
+	if err := errs.Err(); err != nil {
+		h.sendValidationError(w, r, err)
+		return
+	}
+
+	h.sendJSONResponse(w, http.StatusOK, h.audit.Recent(limit, decision))
+}
@@ -0,0 +1,217 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"smit/server/api/audit"
+	"smit/server/api/auth"
+	"smit/server/api/models"
+	"smit/server/api/rbac"
+)
+
+func newAccessHandler(t *testing.T) (*Handler, *audit.Log) {
+	t.Helper()
+	policy := &rbac.Policy{Roles: map[string]rbac.Role{
+		"viewer":   {Actions: []string{rbac.ActionVLANRead}},
+		"operator": {Actions: []string{rbac.ActionVLANRead, rbac.ActionVLANUpdate}, Fields: []string{"status", "labels"}},
+		"admin":    {Actions: []string{rbac.AllActions}},
+	}}
+	log := audit.NewLog(nil, 100)
+
+	mockStorage := NewMockStorage()
+	for _, input := range []models.VLANInput{
+		{Name: "Production", VlanID: 100, Subnet: "10.0.0.0/24", Gateway: "10.0.0.1", Status: "active", Site: "tallinn"},
+		{Name: "Lab", VlanID: 300, Subnet: "10.3.0.0/24", Gateway: "10.3.0.1", Status: "inactive", Site: "tartu"},
+	} {
+		mockStorage.Create(&input)
+	}
+
+	return NewHandler(mockStorage, WithAccessPolicy(policy), WithAuditLog(log)), log
+}
+
+// Request made by an API key with the given role and scope
+func requestAs(method, path, body, role string, scope *models.AccessScope) *http.Request {
+	req := httptest.NewRequest(method, path, strings.NewReader(body))
+	if role != "" {
+		identity := &auth.Identity{Subject: role + "-key", KeyID: 7, Role: role, Scope: scope}
+		req = req.WithContext(auth.WithIdentity(req.Context(), identity))
+	}
+	return req
+}
+
+func TestVLANAccess(t *testing.T) {
+	tallinn := &models.AccessScope{Sites: []string{"tallinn"}}
+	statusOnly := `{"name": "Production", "vlan_id": 100, "subnet": "10.0.0.0/24", "gateway": "10.0.0.1", "status": "maintenance", "site": "tallinn"}`
+	newSubnet := `{"name": "Production", "vlan_id": 100, "subnet": "10.0.9.0/24", "gateway": "10.0.9.1", "status": "active", "site": "tallinn"}`
+	create := `{"name": "New", "vlan_id": 200, "subnet": "10.2.0.0/24", "gateway": "10.2.0.1", "status": "active", "site": "tallinn"}`
+
+	tests := []struct {
+		name     string
+		method   string
+		path     string
+		body     string
+		role     string
+		scope    *models.AccessScope
+		expected int
+	}{
+		{"Viewer reads", "GET", "/api/v1/vlans", "", "viewer", nil, http.StatusOK},
+		{"Viewer reads by tag", "GET", "/api/v1/vlans/by-tag/100", "", "viewer", nil, http.StatusOK},
+		{"Viewer cannot create", "POST", "/api/v1/vlans", create, "viewer", nil, http.StatusForbidden},
+		{"Viewer cannot update", "PUT", "/api/v1/vlans/1", statusOnly, "viewer", nil, http.StatusForbidden},
+		{"Operator changes status", "PUT", "/api/v1/vlans/1", statusOnly, "operator", nil, http.StatusOK},
+		{"Operator patches status", "PATCH", "/api/v1/vlans/1", `{"status": "maintenance"}`, "operator", nil, http.StatusOK},
+		{"Operator transitions", "POST", "/api/v1/vlans/1/transitions", `{"status": "maintenance", "reason": "Upgrade"}`, "operator", nil, http.StatusOK},
+		{"Operator cannot change subnet", "PUT", "/api/v1/vlans/1", newSubnet, "operator", nil, http.StatusForbidden},
+		{"Operator cannot patch subnet", "PATCH", "/api/v1/vlans/1", `{"subnet": "10.0.9.0/24", "gateway": "10.0.9.1"}`, "operator", nil, http.StatusForbidden},
+		{"Operator cannot delete", "DELETE", "/api/v1/vlans/2", "", "operator", nil, http.StatusForbidden},
+		{"Scoped operator in scope", "PUT", "/api/v1/vlans/1", statusOnly, "operator", tallinn, http.StatusOK},
+		{"Scoped operator out of scope", "POST", "/api/v1/vlans/2/transitions", `{"status": "active", "reason": "Go live"}`, "operator", tallinn, http.StatusForbidden},
+		{"Admin changes subnet", "PUT", "/api/v1/vlans/1", newSubnet, "admin", nil, http.StatusOK},
+		{"Admin creates", "POST", "/api/v1/vlans", create, "admin", nil, http.StatusCreated},
+		{"Admin deletes", "DELETE", "/api/v1/vlans/2", "", "admin", nil, http.StatusNoContent},
+		{"Scoped admin cannot create elsewhere", "POST", "/api/v1/vlans", strings.Replace(create, "tallinn", "tartu", 1), "admin", tallinn, http.StatusForbidden},
+		{"Scoped admin cannot delete elsewhere", "DELETE", "/api/v1/vlans/2", "", "admin", tallinn, http.StatusForbidden},
+		{"Unknown role", "GET", "/api/v1/vlans", "", "auditor", nil, http.StatusForbidden},
+		{"No identity", "GET", "/api/v1/vlans", "", "", nil, http.StatusForbidden},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			handler, _ := newAccessHandler(t)
+
+			req := requestAs(tt.method, tt.path, tt.body, tt.role, tt.scope)
+			if tt.method == "PATCH" {
+				req.Header.Set("Content-Type", "application/merge-patch+json")
+			}
+			w := httptest.NewRecorder()
+			handler.VLANHandler(w, req)
+
+			if w.Code != tt.expected {
+				t.Fatalf("Expected status %d, got %d: %s", tt.expected, w.Code, w.Body.String())
+			}
+			if w.Code == http.StatusForbidden && !strings.Contains(w.Body.String(), DefaultProblemTypeBase+"forbidden") {
+				t.Errorf("Expected a forbidden problem, got %s", w.Body.String())
+			}
+		})
+	}
+}
+
+func TestAccessAudit(t *testing.T) {
+	handler, log := newAccessHandler(t)
+
+	newSubnet := `{"name": "Production", "vlan_id": 100, "subnet": "10.0.9.0/24", "gateway": "10.0.9.1", "status": "active", "site": "tallinn"}`
+	handler.VLANHandler(httptest.NewRecorder(), requestAs("PUT", "/api/v1/vlans/1", newSubnet, "operator", nil))
+	handler.VLANHandler(httptest.NewRecorder(), requestAs("GET", "/api/v1/vlans/1", "", "operator", nil))
+	handler.VLANHandler(httptest.NewRecorder(), requestAs("DELETE", "/api/v1/vlans/2", "", "admin", nil))
+
+	entries := log.Recent(0, "")
+	if len(entries) != 2 {
+		t.Fatalf("Expected the denial and the allowed write to be audited, got %+v", entries)
+	}
+	if entries[0].Decision != audit.DecisionAllowed || entries[0].Action != rbac.ActionVLANDelete {
+		t.Errorf("Unexpected newest entry %+v", entries[0])
+	}
+	denied := entries[1]
+	if denied.Decision != audit.DecisionDenied || denied.Role != "operator" || denied.KeyID != 7 || !strings.Contains(denied.Reason, "subnet") {
+		t.Errorf("Unexpected denial entry %+v", denied)
+	}
+
+	// Only admins read the audit log
+	w := httptest.NewRecorder()
+	handler.GetAuditLog(w, requestAs("GET", "/api/v1/audit?decision=denied", "", "viewer", nil))
+	if w.Code != http.StatusForbidden {
+		t.Errorf("Expected status %d for a viewer, got %d", http.StatusForbidden, w.Code)
+	}
+
+	w = httptest.NewRecorder()
+	handler.GetAuditLog(w, requestAs("GET", "/api/v1/audit?decision=denied", "", "admin", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status %d, got %d: %s", http.StatusOK, w.Code, w.Body.String())
+	}
+	var listed []audit.Entry
+	json.NewDecoder(w.Body).Decode(&listed)
+	// The viewer's attempt to read the log is a denial too
+	if len(listed) != 2 || listed[0].Action != rbac.ActionAuditRead {
+		t.Errorf("Unexpected audit entries %+v", listed)
+	}
+
+	w = httptest.NewRecorder()
+	handler.GetAuditLog(w, requestAs("GET", "/api/v1/audit?limit=0", "", "admin", nil))
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("Expected status %d for an invalid limit, got %d", http.StatusBadRequest, w.Code)
+	}
+}
+
+func TestCreateAPIKeyRole(t *testing.T) {
+	keys, _ := auth.LoadKeyStore("")
+	policy := &rbac.Policy{Roles: map[string]rbac.Role{"admin": {Actions: []string{rbac.AllActions}}}}
+	handler := NewHandler(NewMockStorage(), WithAPIKeys(keys), WithAccessPolicy(policy))
+
+	tests := []struct {
+		name     string
+		body     string
+		expected int
+	}{
+		{"Known role", `{"name": "ci", "role": "admin", "scope": {"sites": ["tallinn"]}}`, http.StatusCreated},
+		{"Unknown role", `{"name": "ci", "role": "root"}`, http.StatusBadRequest},
+		{"Missing role", `{"name": "ci"}`, http.StatusBadRequest},
+		{"Invalid scope", `{"name": "ci", "role": "admin", "scope": {"vlan_id_min": 200, "vlan_id_max": 100}}`, http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			handler.APIKeyHandler(w, requestAs("POST", "/api/v1/api-keys", tt.body, "admin", nil))
+			if w.Code != tt.expected {
+				t.Errorf("Expected status %d, got %d: %s", tt.expected, w.Code, w.Body.String())
+			}
+		})
+	}
+}
+
+func TestAllocateSubnetAccess(t *testing.T) {
+	policy := &rbac.Policy{Roles: map[string]rbac.Role{
+		"allocator": {Actions: []string{rbac.ActionPrefixAllocate}},
+		"builder":   {Actions: []string{rbac.ActionPrefixAllocate, rbac.ActionVLANCreate}},
+	}}
+	prefixes := &MockPrefixStorage{}
+	prefixes.CreatePrefix(&models.PrefixInput{Prefix: "10.20.0.0/16", Site: "tallinn"})
+	prefixes.CreatePrefix(&models.PrefixInput{Prefix: "10.30.0.0/16", Site: "tartu"})
+	tallinn := &models.AccessScope{Sites: []string{"tallinn"}}
+	vlan := `{"vlan": {"name": "New", "vlan_id": 200, "status": "active"}}`
+
+	tests := []struct {
+		name     string
+		path     string
+		body     string
+		role     string
+		scope    *models.AccessScope
+		expected int
+	}{
+		{"Allocate without a VLAN", "/api/v1/prefixes/1/allocate?length=24", "", "allocator", nil, http.StatusOK},
+		{"Create a VLAN without vlan:create", "/api/v1/prefixes/1/allocate?length=24", vlan, "allocator", nil, http.StatusForbidden},
+		{"Create a VLAN in scope", "/api/v1/prefixes/1/allocate?length=24", vlan, "builder", tallinn, http.StatusCreated},
+		{"Create a VLAN at a site out of scope", "/api/v1/prefixes/2/allocate?length=24", vlan, "builder", tallinn, http.StatusForbidden},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			vlans := NewMockStorage()
+			handler := NewHandler(vlans, WithPrefixStorage(prefixes), WithAccessPolicy(policy))
+
+			w := httptest.NewRecorder()
+			handler.PrefixHandler(w, requestAs("POST", tt.path, tt.body, tt.role, tt.scope))
+
+			if w.Code != tt.expected {
+				t.Fatalf("Expected status %d, got %d: %s", tt.expected, w.Code, w.Body.String())
+			}
+			if created, _ := vlans.GetAll(); w.Code == http.StatusForbidden && len(created) != 0 {
+				t.Errorf("Expected no VLAN to be created, got %+v", created)
+			}
+		})
+	}
+}
@@ -0,0 +1,277 @@
+package handlers
+
+import (
+	"crypto/x509"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"smit/server/api/auth"
+	"smit/server/api/models"
+	"smit/server/api/rbac"
+)
+
+// Request header carrying an API key
+const apiKeyHeader = "X-API-Key"
+
+// Paths that are reachable without credentials
+var publicPaths = map[string]bool{
+	"/health": true,
+	"/ready":  true,
+	"/live":   true,
+}
+
+// Require credentials on every request except the public paths. An API key is
+// sent as an X-API-Key header or as a bearer token; bearer tokens shaped like a
+// JWT are checked by the JWT validator instead. Requests without either may
+// present a verified client certificate. The caller's identity is added to the
+// request context. Requests pass through unchecked when no method is configured.
+// A client address that keeps failing authentication is refused with 429 before
+// its credentials are checked.
+func (h *Handler) Authenticate(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if (h.keys == nil && h.jwt == nil && !h.clientCerts) || publicPaths[r.URL.Path] {
+			next.ServeHTTP(w, r)
+			return
+		}
+		if !h.allowAuthentication(w, r) {
+			return
+		}
+
+		credential, ok := credentialFromRequest(r)
+		if !ok {
+			if cert := verifiedClientCertificate(r); h.clientCerts && cert != nil {
+				identity := auth.CertificateIdentity(cert, h.certRoles)
+				next.ServeHTTP(w, withIdentity(r, identity))
+				return
+			}
+			h.sendUnauthorized(w, r, "", "Missing API key, bearer token or client certificate")
+			return
+		}
+
+		identity, err := h.identify(credential)
+		if err != nil {
+			detail := "Invalid API key"
+			switch {
+			case errors.Is(err, auth.ErrKeyExpired), errors.Is(err, auth.ErrKeyRevoked), errors.Is(err, auth.ErrTokenExpired):
+				detail = err.Error()
+			case errors.Is(err, auth.ErrInvalidToken):
+				detail = "Invalid bearer token"
+			}
+			h.sendUnauthorized(w, r, "invalid_token", detail)
+			return
+		}
+
+		next.ServeHTTP(w, withIdentity(r, identity))
+	})
+}
+
+// Identity of a credential, validated as a JWT or looked up as an API key
+func (h *Handler) identify(credential string) (*auth.Identity, error) {
+	if h.jwt != nil && auth.LooksLikeJWT(credential) {
+		return h.jwt.Validate(credential)
+	}
+	if h.keys == nil {
+		return nil, auth.ErrInvalidKey
+	}
+	return h.keys.Authenticate(credential)
+}
+
+// Subject of the authenticated caller, empty when the request is anonymous
+func actor(r *http.Request) string {
+	if identity := auth.IdentityFromContext(r.Context()); identity != nil {
+		return identity.Subject
+	}
+	return ""
+}
+
+// Leaf of the client certificate chain the TLS handshake verified, nil without one
+func verifiedClientCertificate(r *http.Request) *x509.Certificate {
+	if r.TLS == nil || len(r.TLS.VerifiedChains) == 0 || len(r.TLS.VerifiedChains[0]) == 0 {
+		return nil
+	}
+	return r.TLS.VerifiedChains[0][0]
+}
+
+// Credential of a request from the X-API-Key header or an Authorization bearer token
+func credentialFromRequest(r *http.Request) (string, bool) {
+	if key := r.Header.Get(apiKeyHeader); key != "" {
+		return key, true
+	}
+
+	scheme, token, ok := strings.Cut(r.Header.Get("Authorization"), " ")
+	if !ok || !strings.EqualFold(scheme, "Bearer") {
+		return "", false
+	}
+	token = strings.TrimSpace(token)
+	return token, token != ""
+}
+
+// Send 401 with a WWW-Authenticate challenge (RFC 6750)
+func (h *Handler) sendUnauthorized(w http.ResponseWriter, r *http.Request, errorCode, detail string) {
+	challenge := `Bearer realm="smit"`
+	if errorCode != "" {
+		challenge += `, error="` + errorCode + `"`
+	}
+	w.Header().Set("WWW-Authenticate", challenge)
+	h.recordAuthFailure(r)
+	h.sendProblem(w, r, problemUnauthorized, detail)
+}
+
+// Handles GET /api/v1/api-keys
+func (h *Handler) GetAPIKeys(w http.ResponseWriter, r *http.Request) {
+	r, span := h.startSpan(r, "GetAPIKeys")
+	defer span.End()
+
+	if r.Method != http.MethodGet {
+		h.sendProblem(w, r, problemMethodNotAllowed, "Method not allowed")
+		return
+	}
+	if !h.authorize(w, r, rbac.ActionAPIKeyManage) {
+		return
+	}
+
+	h.sendJSONResponse(w, http.StatusOK, h.keys.List())
+}
+
+// Handles POST /api/v1/api-keys
+func (h *Handler) CreateAPIKey(w http.ResponseWriter, r *http.Request) {
+	r, span := h.startSpan(r, "CreateAPIKey")
+	defer span.End()
+
+	if r.Method != http.MethodPost {
+		h.sendProblem(w, r, problemMethodNotAllowed, "Method not allowed")
+		return
+	}
+	if !h.authorize(w, r, rbac.ActionAPIKeyManage) {
+		return
+	}
+
+	var input models.APIKeyInput
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		h.sendProblem(w, r, problemInvalidBody, "Invalid request body")
+		return
+	}
+
+	// Validate input
+	if err := input.Validate(time.Now()); err != nil {
+		h.sendValidationError(w, r, err)
+		return
+	}
+	if h.policy != nil && !h.policy.HasRole(input.Role) {
+		var errs models.ValidationErrors
+		errs.Add("role", models.CodeInvalidValue, "role must be one of: "+strings.Join(h.policy.RoleNames(), ", "))
+		h.sendValidationError(w, r, errs)
+		return
+	}
+
+	key, err := h.keys.Create(&input)
+	if err != nil {
+		h.sendProblem(w, r, problemInternal, "Failed to create API key")
+		return
+	}
+
+	h.sendJSONResponse(w, http.StatusCreated, key)
+}
+
+// Handles GET /api/v1/api-keys/{id}
+func (h *Handler) GetAPIKey(w http.ResponseWriter, r *http.Request) {
+	r, span := h.startSpan(r, "GetAPIKey")
+	defer span.End()
+
+	if r.Method != http.MethodGet {
+		h.sendProblem(w, r, problemMethodNotAllowed, "Method not allowed")
+		return
+	}
+	if !h.authorize(w, r, rbac.ActionAPIKeyManage) {
+		return
+	}
+
+	id, err := extractIDFromPath(r.URL.Path)
+	if err != nil {
+		h.sendProblem(w, r, problemInvalidID, err.Error())
+		return
+	}
+
+	key, err := h.keys.Get(id)
+	if err != nil {
+		h.sendProblem(w, r, problemAPIKeyNotFound, "API key not found")
+		return
+	}
+
+	h.sendJSONResponse(w, http.StatusOK, key)
+}
+
+// Handles DELETE /api/v1/api-keys/{id}
+func (h *Handler) RevokeAPIKey(w http.ResponseWriter, r *http.Request) {
+	r, span := h.startSpan(r, "RevokeAPIKey")
+	defer span.End()
+
+	if r.Method != http.MethodDelete {
+		h.sendProblem(w, r, problemMethodNotAllowed, "Method not allowed")
+		return
+	}
+	if !h.authorize(w, r, rbac.ActionAPIKeyManage) {
+		return
+	}
+
+	id, err := extractIDFromPath(r.URL.Path)
+	if err != nil {
+		h.sendProblem(w, r, problemInvalidID, err.Error())
+		return
+	}
+
+	if _, err := h.keys.Revoke(id); err != nil {
+		if errors.Is(err, auth.ErrKeyNotFound) {
+			h.sendProblem(w, r, problemAPIKeyNotFound, "API key not found")
+			return
+		}
+		h.sendProblem(w, r, problemInternal, "Failed to revoke API key")
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// Handler for API key endpoints
+func (h *Handler) APIKeyHandler(w http.ResponseWriter, r *http.Request) {
+	r, span := h.startSpan(r, "APIKeyHandler")
+	defer span.End()
+
+	if h.keys == nil {
+		h.sendProblem(w, r, problemNotImplemented, "Authentication is not configured")
+		return
+	}
+
+	path := r.URL.Path
+
+	// Handle /api/v1/api-keys
+	if path == "/api/v1/api-keys" {
+		switch r.Method {
+		case http.MethodGet:
+			h.GetAPIKeys(w, r)
+		case http.MethodPost:
+			h.CreateAPIKey(w, r)
+		default:
+			h.sendProblem(w, r, problemMethodNotAllowed, "Method not allowed")
+		}
+		return
+	}
+
+	// Handle /api/v1/api-keys/{id}
+	if strings.HasPrefix(path, "/api/v1/api-keys/") {
+		switch r.Method {
+		case http.MethodGet:
+			h.GetAPIKey(w, r)
+		case http.MethodDelete:
+			h.RevokeAPIKey(w, r)
+		default:
+			h.sendProblem(w, r, problemMethodNotAllowed, "Method not allowed")
+		}
+		return
+	}
+
+	h.sendProblem(w, r, problemEndpointNotFound, "Endpoint not found")
+}
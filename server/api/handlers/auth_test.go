@@ -0,0 +1,366 @@
+package handlers
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"smit/server/api/auth"
+	"smit/server/api/models"
+)
+
+func newAuthHandler(t *testing.T) (*Handler, string) {
+	t.Helper()
+	keys, err := auth.LoadKeyStore("")
+	if err != nil {
+		t.Fatalf("Failed to create key store: %v", err)
+	}
+	created, err := keys.Create(&models.APIKeyInput{Name: "admin"})
+	if err != nil {
+		t.Fatalf("Failed to create key: %v", err)
+	}
+	return NewHandler(NewMockStorage(), WithAPIKeys(keys)), created.Key
+}
+
+func TestAuthenticate(t *testing.T) {
+	handler, key := newAuthHandler(t)
+
+	var seen *auth.Identity
+	protected := handler.Authenticate(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		seen = auth.IdentityFromContext(r.Context())
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	tests := []struct {
+		name      string
+		path      string
+		header    string
+		value     string
+		expected  int
+		challenge string
+	}{
+		{"No credentials", "/api/v1/vlans", "", "", http.StatusUnauthorized, `Bearer realm="smit"`},
+		{"Health is public", "/health", "", "", http.StatusOK, ""},
+		{"API key header", "/api/v1/vlans", "X-API-Key", key, http.StatusOK, ""},
+		{"Bearer token", "/api/v1/vlans", "Authorization", "Bearer " + key, http.StatusOK, ""},
+		{"Lowercase scheme", "/api/v1/vlans", "Authorization", "bearer " + key, http.StatusOK, ""},
+		{"Wrong key", "/api/v1/vlans", "X-API-Key", "smit_nope", http.StatusUnauthorized, `Bearer realm="smit", error="invalid_token"`},
+		{"Basic scheme", "/api/v1/vlans", "Authorization", "Basic " + key, http.StatusUnauthorized, `Bearer realm="smit"`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			seen = nil
+			req := httptest.NewRequest("GET", tt.path, nil)
+			if tt.header != "" {
+				req.Header.Set(tt.header, tt.value)
+			}
+			w := httptest.NewRecorder()
+			protected.ServeHTTP(w, req)
+
+			if w.Code != tt.expected {
+				t.Fatalf("Expected status %d, got %d: %s", tt.expected, w.Code, w.Body.String())
+			}
+			if challenge := w.Header().Get("WWW-Authenticate"); challenge != tt.challenge {
+				t.Errorf("Expected WWW-Authenticate %q, got %q", tt.challenge, challenge)
+			}
+			if tt.header != "" && w.Code == http.StatusOK && (seen == nil || seen.Subject != "admin") {
+				t.Errorf("Expected identity in the request context, got %+v", seen)
+			}
+		})
+	}
+}
+
+func TestAuthenticateDisabled(t *testing.T) {
+	handler := NewHandler(NewMockStorage())
+	protected := handler.Authenticate(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	w := httptest.NewRecorder()
+	protected.ServeHTTP(w, httptest.NewRequest("GET", "/api/v1/vlans", nil))
+	if w.Code != http.StatusOK {
+		t.Errorf("Expected status %d without a key store, got %d", http.StatusOK, w.Code)
+	}
+}
+
+// Handler accepting API keys and ES256 tokens signed by the returned function
+func newJWTHandler(t *testing.T) (*Handler, string, func(claims map[string]interface{}) string) {
+	t.Helper()
+	handler, key := newAuthHandler(t)
+
+	signer, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("Failed to generate key: %v", err)
+	}
+	encode := func(v interface{}) string {
+		data, _ := json.Marshal(v)
+		return base64.RawURLEncoding.EncodeToString(data)
+	}
+	jwks, _ := json.Marshal(map[string]interface{}{"keys": []map[string]string{{
+		"kty": "EC", "crv": "P-256", "kid": "test",
+		"x": base64.RawURLEncoding.EncodeToString(signer.X.Bytes()),
+		"y": base64.RawURLEncoding.EncodeToString(signer.Y.Bytes()),
+	}}})
+	path := filepath.Join(t.TempDir(), "jwks.json")
+	os.WriteFile(path, jwks, 0644)
+
+	validator, err := auth.NewJWTValidator(auth.JWTConfig{JWKS: path, Issuer: "https://idp.example.com", Audience: "smit"})
+	if err != nil {
+		t.Fatalf("Failed to create validator: %v", err)
+	}
+	WithJWTValidator(validator)(handler)
+
+	sign := func(claims map[string]interface{}) string {
+		input := encode(map[string]string{"alg": "ES256", "kid": "test"}) + "." + encode(claims)
+		digest := sha256.Sum256([]byte(input))
+		r, s, _ := ecdsa.Sign(rand.Reader, signer, digest[:])
+		signature := make([]byte, 64)
+		r.FillBytes(signature[:32])
+		s.FillBytes(signature[32:])
+		return input + "." + base64.RawURLEncoding.EncodeToString(signature)
+	}
+	return handler, key, sign
+}
+
+func TestAuthenticateJWT(t *testing.T) {
+	handler, key, sign := newJWTHandler(t)
+
+	var seen *auth.Identity
+	protected := handler.Authenticate(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		seen = auth.IdentityFromContext(r.Context())
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	claims := func(exp time.Duration, aud string) map[string]interface{} {
+		return map[string]interface{}{
+			"iss": "https://idp.example.com", "aud": aud, "sub": "alice",
+			"exp": time.Now().Add(exp).Unix(), "roles": []string{"operator"},
+		}
+	}
+
+	tests := []struct {
+		name     string
+		token    string
+		expected int
+		subject  string
+		detail   string
+	}{
+		{"Valid token", sign(claims(time.Hour, "smit")), http.StatusOK, "alice", ""},
+		{"API key next to JWTs", key, http.StatusOK, "admin", ""},
+		{"Expired token", sign(claims(-time.Hour, "smit")), http.StatusUnauthorized, "", "token has expired"},
+		{"Wrong audience", sign(claims(time.Hour, "other")), http.StatusUnauthorized, "", "Invalid bearer token"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			seen = nil
+			req := httptest.NewRequest("GET", "/api/v1/vlans", nil)
+			req.Header.Set("Authorization", "Bearer "+tt.token)
+			w := httptest.NewRecorder()
+			protected.ServeHTTP(w, req)
+
+			if w.Code != tt.expected {
+				t.Fatalf("Expected status %d, got %d: %s", tt.expected, w.Code, w.Body.String())
+			}
+			if tt.subject != "" && (seen == nil || seen.Subject != tt.subject) {
+				t.Errorf("Expected subject %q in the request context, got %+v", tt.subject, seen)
+			}
+			if !strings.Contains(w.Body.String(), tt.detail) {
+				t.Errorf("Expected detail %q, got %s", tt.detail, w.Body.String())
+			}
+		})
+	}
+
+	// The role comes from the token
+	req := httptest.NewRequest("GET", "/api/v1/vlans", nil)
+	req.Header.Set("Authorization", "Bearer "+sign(claims(time.Hour, "smit")))
+	protected.ServeHTTP(httptest.NewRecorder(), req)
+	if seen == nil || seen.Role != "operator" || seen.KeyID != 0 {
+		t.Errorf("Unexpected identity %+v", seen)
+	}
+}
+
+func TestAuthenticateClientCertificate(t *testing.T) {
+	roleMap, _ := auth.ParseRoleMap("automation=operator")
+	handler := NewHandler(NewMockStorage(), WithClientCertificates(true, roleMap))
+
+	var seen *auth.Identity
+	protected := handler.Authenticate(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		seen = auth.IdentityFromContext(r.Context())
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	cert := &x509.Certificate{Subject: pkix.Name{CommonName: "deploy-bot", OrganizationalUnit: []string{"automation"}}}
+	tests := []struct {
+		name     string
+		state    *tls.ConnectionState
+		expected int
+	}{
+		{"Verified certificate", &tls.ConnectionState{VerifiedChains: [][]*x509.Certificate{{cert}}}, http.StatusOK},
+		{"Unverified certificate", &tls.ConnectionState{PeerCertificates: []*x509.Certificate{cert}}, http.StatusUnauthorized},
+		{"Plain HTTP", nil, http.StatusUnauthorized},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			seen = nil
+			req := httptest.NewRequest("GET", "/api/v1/vlans", nil)
+			req.TLS = tt.state
+			w := httptest.NewRecorder()
+			protected.ServeHTTP(w, req)
+
+			if w.Code != tt.expected {
+				t.Fatalf("Expected status %d, got %d: %s", tt.expected, w.Code, w.Body.String())
+			}
+			if w.Code == http.StatusOK && (seen == nil || seen.Subject != "deploy-bot" || seen.Role != "operator") {
+				t.Errorf("Unexpected identity %+v", seen)
+			}
+		})
+	}
+
+	// Without certificate authentication a verified certificate is not an identity
+	unprotected := NewHandler(NewMockStorage(), WithClientCertificates(false, nil)).Authenticate(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		seen = auth.IdentityFromContext(r.Context())
+	}))
+	req := httptest.NewRequest("GET", "/api/v1/vlans", nil)
+	req.TLS = &tls.ConnectionState{VerifiedChains: [][]*x509.Certificate{{cert}}}
+	seen = nil
+	unprotected.ServeHTTP(httptest.NewRecorder(), req)
+	if seen != nil {
+		t.Errorf("Expected no identity, got %+v", seen)
+	}
+}
+
+func TestOwnershipFields(t *testing.T) {
+	handler, _ := newAuthHandler(t)
+	as := func(method, path, body, subject string) *models.VLANModel {
+		t.Helper()
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		req = req.WithContext(auth.WithIdentity(req.Context(), &auth.Identity{Subject: subject}))
+		w := httptest.NewRecorder()
+		handler.VLANHandler(w, req)
+		if w.Code >= 300 {
+			t.Fatalf("%s %s: unexpected status %d: %s", method, path, w.Code, w.Body.String())
+		}
+		var vlan models.VLANModel
+		json.NewDecoder(w.Body).Decode(&vlan)
+		return &vlan
+	}
+
+	vlan := as("POST", "/api/v1/vlans", `{"name": "Lab", "vlan_id": 300, "subnet": "10.3.0.0/24", "gateway": "10.3.0.1", "status": "active"}`, "alice")
+	if vlan.CreatedBy != "alice" || vlan.UpdatedBy != "alice" {
+		t.Errorf("Expected alice to own the new VLAN, got %q/%q", vlan.CreatedBy, vlan.UpdatedBy)
+	}
+
+	vlan = as("PUT", "/api/v1/vlans/1", `{"name": "Lab 2", "vlan_id": 300, "subnet": "10.3.0.0/24", "gateway": "10.3.0.1", "status": "active"}`, "bob")
+	if vlan.CreatedBy != "alice" || vlan.UpdatedBy != "bob" {
+		t.Errorf("Expected bob as the last editor, got %q/%q", vlan.CreatedBy, vlan.UpdatedBy)
+	}
+
+	vlan = as("POST", "/api/v1/vlans/1/transitions", `{"status": "maintenance", "reason": "Upgrade"}`, "carol")
+	if vlan.UpdatedBy != "carol" || len(vlan.StatusHistory) != 1 || vlan.StatusHistory[0].ChangedBy != "carol" {
+		t.Errorf("Expected carol in the status history, got %q, %+v", vlan.UpdatedBy, vlan.StatusHistory)
+	}
+}
+
+func TestAPIKeyLifecycle(t *testing.T) {
+	handler, _ := newAuthHandler(t)
+
+	// Create
+	req := httptest.NewRequest("POST", "/api/v1/api-keys", strings.NewReader(`{"name": "ci", "expires_at": "2999-01-01T00:00:00Z"}`))
+	w := httptest.NewRecorder()
+	handler.APIKeyHandler(w, req)
+	if w.Code != http.StatusCreated {
+		t.Fatalf("Expected status %d, got %d: %s", http.StatusCreated, w.Code, w.Body.String())
+	}
+	var created models.CreatedAPIKey
+	json.NewDecoder(w.Body).Decode(&created)
+	if created.Key == "" || created.ID != 2 || created.ExpiresAt == nil {
+		t.Fatalf("Unexpected created key %+v", created)
+	}
+
+	// List never shows the key itself
+	w = httptest.NewRecorder()
+	handler.APIKeyHandler(w, httptest.NewRequest("GET", "/api/v1/api-keys", nil))
+	if w.Code != http.StatusOK || strings.Contains(w.Body.String(), created.Key) {
+		t.Fatalf("Unexpected list response %d: %s", w.Code, w.Body.String())
+	}
+	var keys []models.APIKey
+	json.NewDecoder(w.Body).Decode(&keys)
+	if len(keys) != 2 {
+		t.Errorf("Expected 2 keys, got %d", len(keys))
+	}
+
+	// Revoke
+	w = httptest.NewRecorder()
+	handler.APIKeyHandler(w, httptest.NewRequest("DELETE", "/api/v1/api-keys/2", nil))
+	if w.Code != http.StatusNoContent {
+		t.Fatalf("Expected status %d, got %d", http.StatusNoContent, w.Code)
+	}
+
+	w = httptest.NewRecorder()
+	handler.APIKeyHandler(w, httptest.NewRequest("GET", "/api/v1/api-keys/2", nil))
+	var revoked models.APIKey
+	json.NewDecoder(w.Body).Decode(&revoked)
+	if revoked.RevokedAt == nil {
+		t.Errorf("Expected revoked_at to be set, got %+v", revoked)
+	}
+
+	req = httptest.NewRequest("GET", "/api/v1/vlans", nil)
+	req.Header.Set("X-API-Key", created.Key)
+	w = httptest.NewRecorder()
+	handler.Authenticate(http.NotFoundHandler()).ServeHTTP(w, req)
+	if w.Code != http.StatusUnauthorized {
+		t.Errorf("Expected revoked key to get %d, got %d", http.StatusUnauthorized, w.Code)
+	}
+}
+
+func TestAPIKeyErrors(t *testing.T) {
+	handler, _ := newAuthHandler(t)
+
+	tests := []struct {
+		name     string
+		method   string
+		path     string
+		body     string
+		expected int
+	}{
+		{"Missing name", "POST", "/api/v1/api-keys", `{}`, http.StatusBadRequest},
+		{"Expiry in the past", "POST", "/api/v1/api-keys", `{"name": "ci", "expires_at": "2000-01-01T00:00:00Z"}`, http.StatusBadRequest},
+		{"Invalid body", "POST", "/api/v1/api-keys", `{"name": `, http.StatusBadRequest},
+		{"Unknown key", "GET", "/api/v1/api-keys/99", "", http.StatusNotFound},
+		{"Revoke unknown key", "DELETE", "/api/v1/api-keys/99", "", http.StatusNotFound},
+		{"Invalid ID", "DELETE", "/api/v1/api-keys/abc", "", http.StatusBadRequest},
+		{"Method not allowed", "PUT", "/api/v1/api-keys/1", "", http.StatusMethodNotAllowed},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			handler.APIKeyHandler(w, httptest.NewRequest(tt.method, tt.path, strings.NewReader(tt.body)))
+			if w.Code != tt.expected {
+				t.Errorf("Expected status %d, got %d: %s", tt.expected, w.Code, w.Body.String())
+			}
+		})
+	}
+
+	w := httptest.NewRecorder()
+	NewHandler(NewMockStorage()).APIKeyHandler(w, httptest.NewRequest("GET", "/api/v1/api-keys", nil))
+	if w.Code != http.StatusNotImplemented {
+		t.Errorf("Expected status %d without a key store, got %d", http.StatusNotImplemented, w.Code)
+	}
+}
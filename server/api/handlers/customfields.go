@@ -0,0 +1,24 @@
+package handlers
+
+import (
+	"net/http"
+
+	"smit/server/api/models"
+	"smit/server/api/rbac"
+)
+
+// Handles GET /api/v1/custom-fields
+func (h *Handler) GetCustomFields(w http.ResponseWriter, r *http.Request) {
+	r, span := h.startSpan(r, "GetCustomFields")
+	defer span.End()
+
+	if r.Method != http.MethodGet {
+		h.sendProblem(w, r, problemMethodNotAllowed, "Method not allowed")
+		return
+	}
+	if !h.authorize(w, r, rbac.ActionVLANRead) {
+		return
+	}
+
+	h.sendJSONResponse(w, http.StatusOK, models.CurrentCustomFields())
+}
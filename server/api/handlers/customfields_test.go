@@ -0,0 +1,49 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"smit/server/api/models"
+)
+
+func TestGetCustomFields(t *testing.T) {
+	previous := models.CurrentCustomFields()
+	defer models.SetCustomFields(previous)
+
+	models.SetCustomFields([]models.CustomFieldSchema{
+		{Name: "owner", Type: models.FieldTypeString, Required: true},
+	})
+	handler := NewHandler(NewMockStorage())
+
+	req := httptest.NewRequest("GET", "/api/v1/custom-fields", nil)
+	w := httptest.NewRecorder()
+
+	handler.GetCustomFields(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status %d, got %d", http.StatusOK, w.Code)
+	}
+
+	var schemas []models.CustomFieldSchema
+	if err := json.NewDecoder(w.Body).Decode(&schemas); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if len(schemas) != 1 || schemas[0].Name != "owner" || !schemas[0].Required {
+		t.Errorf("Unexpected schemas: %+v", schemas)
+	}
+
+	// Custom fields are validated on create
+	body := []byte(`{"name": "Test", "vlan_id": 100, "subnet": "10.0.0.0/24", "gateway": "10.0.0.1", "status": "active"}`)
+	req = httptest.NewRequest("POST", "/api/v1/vlans", bytes.NewReader(body))
+	w = httptest.NewRecorder()
+
+	handler.CreateVLAN(w, req)
+
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("Expected status %d, got %d", http.StatusBadRequest, w.Code)
+	}
+}
@@ -0,0 +1,102 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"smit/server/api/models"
+	"smit/server/api/storage"
+)
+
+func TestDryRun(t *testing.T) {
+	tests := []struct {
+		name     string
+		method   string
+		path     string
+		body     string
+		expected int
+		vlanName string
+	}{
+		{"Create", "POST", "/api/v1/vlans?dry_run=true", `{"name": "New", "vlan_id": 300, "subnet": "10.3.0.0/24", "gateway": "10.3.0.1", "status": "planned"}`, http.StatusOK, "New"},
+		{"Create conflict", "POST", "/api/v1/vlans?dry_run=true", `{"name": "New", "vlan_id": 100, "subnet": "10.3.0.0/24", "gateway": "10.3.0.1", "status": "planned"}`, http.StatusConflict, ""},
+		{"Create invalid", "POST", "/api/v1/vlans?dry_run=true", `{"name": "", "vlan_id": 300, "subnet": "10.3.0.0/24", "gateway": "10.3.0.1", "status": "planned"}`, http.StatusBadRequest, ""},
+		{"Update", "PUT", "/api/v1/vlans/1?dry_run=true", `{"name": "Renamed", "vlan_id": 100, "subnet": "10.1.0.0/24", "gateway": "10.1.0.1", "status": "active"}`, http.StatusOK, "Renamed"},
+		{"Update disallowed transition", "PUT", "/api/v1/vlans/1?dry_run=true", `{"name": "Renamed", "vlan_id": 100, "subnet": "10.1.0.0/24", "gateway": "10.1.0.1", "status": "planned"}`, http.StatusConflict, ""},
+		{"Update by tag", "PUT", "/api/v1/vlans/by-tag/100?dry_run=1", `{"name": "Renamed", "vlan_id": 100, "subnet": "10.1.0.0/24", "gateway": "10.1.0.1", "status": "active"}`, http.StatusOK, "Renamed"},
+		{"Patch", "PATCH", "/api/v1/vlans/1?dry_run=true", `{"name": "Patched"}`, http.StatusOK, "Patched"},
+		{"Delete", "DELETE", "/api/v1/vlans/2?dry_run=true", "", http.StatusOK, "Inactive"},
+		{"Delete not deletable", "DELETE", "/api/v1/vlans/1?dry_run=true", "", http.StatusConflict, ""},
+		{"Delete missing", "DELETE", "/api/v1/vlans/99?dry_run=true", "", http.StatusNotFound, ""},
+		{"Invalid flag", "POST", "/api/v1/vlans?dry_run=maybe", `{"name": "New", "vlan_id": 300, "subnet": "10.3.0.0/24", "gateway": "10.3.0.1", "status": "planned"}`, http.StatusBadRequest, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			storage := NewMockStorage()
+			handler := NewHandler(storage)
+			storage.Create(&models.VLANInput{Name: "Prod", VlanID: 100, Subnet: "10.1.0.0/24", Gateway: "10.1.0.1", Status: "active"})
+			storage.Create(&models.VLANInput{Name: "Inactive", VlanID: 200, Subnet: "10.2.0.0/24", Gateway: "10.2.0.1", Status: "inactive"})
+			before, _ := json.Marshal(storage.vlans)
+
+			req := httptest.NewRequest(tt.method, tt.path, strings.NewReader(tt.body))
+			req.Header.Set("Content-Type", "application/json")
+			if tt.method == "PATCH" {
+				req.Header.Set("Content-Type", "application/merge-patch+json")
+			}
+			w := httptest.NewRecorder()
+
+			handler.VLANHandler(w, req)
+
+			if w.Code != tt.expected {
+				t.Fatalf("Expected status %d, got %d: %s", tt.expected, w.Code, w.Body.String())
+			}
+			if after, _ := json.Marshal(storage.vlans); string(after) != string(before) {
+				t.Errorf("Expected dry run to leave storage unchanged, got %s", after)
+			}
+			if tt.vlanName == "" {
+				return
+			}
+
+			var vlan models.VLANModel
+			if err := json.NewDecoder(w.Body).Decode(&vlan); err != nil {
+				t.Fatalf("Failed to decode response: %v", err)
+			}
+			if vlan.Name != tt.vlanName {
+				t.Errorf("Expected VLAN %s, got %s", tt.vlanName, vlan.Name)
+			}
+		})
+	}
+}
+
+func TestDryRunSubnetOverlap(t *testing.T) {
+	store, err := storage.NewJSONStorage(filepath.Join(t.TempDir(), "overlap_test.json"), storage.WithOverlapCheck(true))
+	if err != nil {
+		t.Fatalf("Failed to create storage: %v", err)
+	}
+	store.Create(&models.VLANInput{Name: "Site", VlanID: 100, Subnet: "10.4.0.0/16", Gateway: "10.4.0.1", Status: "active"})
+	handler := NewHandler(store)
+
+	body := `{"name": "Servers", "vlan_id": 117, "subnet": "10.4.17.0/24", "gateway": "10.4.17.1", "status": "active"}`
+	for _, path := range []string{"/api/v1/vlans?dry_run=true", "/api/v1/vlans"} {
+		req := httptest.NewRequest("POST", path, strings.NewReader(body))
+		w := httptest.NewRecorder()
+
+		handler.VLANHandler(w, req)
+
+		if w.Code != http.StatusConflict {
+			t.Fatalf("Expected status %d for %s, got %d", http.StatusConflict, path, w.Code)
+		}
+
+		var problem models.Problem
+		if err := json.NewDecoder(w.Body).Decode(&problem); err != nil {
+			t.Fatalf("Failed to decode response: %v", err)
+		}
+		if problem.Type != DefaultProblemTypeBase+"subnet-overlap" || problem.ConflictingVLAN == nil || problem.ConflictingVLAN.VlanID != 100 {
+			t.Errorf("Expected subnet-overlap with VLAN 100, got %s %+v", problem.Type, problem.ConflictingVLAN)
+		}
+	}
+}
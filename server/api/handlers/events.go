@@ -0,0 +1,113 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"smit/server/api/events"
+	"smit/server/api/models"
+	"smit/server/api/rbac"
+)
+
+// Interval between keep-alive comments on an idle event stream
+var eventKeepAlive = 15 * time.Second
+
+// Handles GET /api/v1/events
+func (h *Handler) StreamEvents(w http.ResponseWriter, r *http.Request) {
+	r, span := h.startSpan(r, "StreamEvents")
+	defer span.End()
+
+	if r.Method != http.MethodGet {
+		h.sendProblem(w, r, problemMethodNotAllowed, "Method not allowed")
+		return
+	}
+	if !h.authorize(w, r, rbac.ActionVLANRead) {
+		return
+	}
+
+	if h.events == nil {
+		h.sendProblem(w, r, problemNotImplemented, "Event stream is not configured")
+		return
+	}
+
+	// The list endpoint's filters select the VLANs to stream
+	query, err := vlanQueryFromRequest(r)
+	if err != nil {
+		h.sendValidationError(w, r, err)
+		return
+	}
+
+	// EventSource sends Last-Event-ID on reconnect; last_event_id lets a first connection resume
+	lastID := r.Header.Get("Last-Event-ID")
+	if lastID == "" {
+		lastID = r.URL.Query().Get("last_event_id")
+	}
+	var after uint64
+	if lastID != "" {
+		after, err = strconv.ParseUint(lastID, 10, 64)
+		if err != nil {
+			h.sendProblem(w, r, problemInvalidParameter, "Last-Event-ID must be an event ID")
+			return
+		}
+	}
+
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		h.sendProblem(w, r, problemInternal, "Streaming is not supported")
+		return
+	}
+
+	sub, backlog, complete := h.events.Subscribe(after)
+	defer sub.Cancel()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+
+	if complete {
+		for _, event := range backlog {
+			writeEvent(w, &query, event)
+		}
+	} else {
+		// Events were missed, so the client has to reload the VLAN list
+		fmt.Fprintf(w, "id: %d\nevent: reset\ndata: {}\n\n", h.events.LastID())
+	}
+	flusher.Flush()
+
+	keepAlive := time.NewTicker(eventKeepAlive)
+	defer keepAlive.Stop()
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case event, ok := <-sub.C:
+			if !ok {
+				// Dropped for falling behind; the client reconnects with Last-Event-ID
+				return
+			}
+			writeEvent(w, &query, event)
+		case <-keepAlive.C:
+			fmt.Fprint(w, ": keep-alive\n\n")
+		}
+		flusher.Flush()
+	}
+}
+
+// Write an event if it passes the stream's filters. An update is sent when the VLAN
+// matches before or after it, so clients see VLANs leave a filtered view.
+func writeEvent(w http.ResponseWriter, query *models.VLANQuery, event events.Event) {
+	if !query.Matches(&event.VLAN) && (event.Previous == nil || !query.Matches(event.Previous)) {
+		return
+	}
+
+	data, err := json.Marshal(event.VLAN)
+	if err != nil {
+		return
+	}
+	fmt.Fprintf(w, "id: %d\nevent: %s\ndata: %s\n\n", event.ID, event.Type, data)
+}
@@ -0,0 +1,135 @@
+package handlers
+
+import (
+	"bufio"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+
+	"smit/server/api/events"
+	"smit/server/api/models"
+)
+
+// Stream events from a request whose client has already gone away, so only the backlog is written
+func streamBacklog(handler *Handler, path, lastEventID string) *httptest.ResponseRecorder {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	req := httptest.NewRequest("GET", path, nil).WithContext(ctx)
+	if lastEventID != "" {
+		req.Header.Set("Last-Event-ID", lastEventID)
+	}
+	w := httptest.NewRecorder()
+	handler.StreamEvents(w, req)
+	return w
+}
+
+func TestStreamEventsBacklog(t *testing.T) {
+	broker := events.NewBroker(10)
+	handler := NewHandler(NewMockStorage(), WithEventBroker(broker))
+
+	// Event IDs start above a per-process base
+	base := broker.LastID()
+	id := func(n uint64) string { return strconv.FormatUint(base+n, 10) }
+
+	active := models.VLANModel{ID: 1, Name: "Prod", VlanID: 100, Status: "active"}
+	maintenance := active
+	maintenance.Status = "maintenance"
+
+	broker.Publish(context.Background(), events.TypeCreated, models.VLANModel{ID: 9, Name: "Old", Status: "active"}, nil)
+	broker.Publish(context.Background(), events.TypeCreated, active, nil)
+	broker.Publish(context.Background(), events.TypeCreated, models.VLANModel{ID: 2, Name: "Lab", VlanID: 200, Status: "planned"}, nil)
+	broker.Publish(context.Background(), events.TypeUpdated, maintenance, &active)
+
+	tests := []struct {
+		name     string
+		path     string
+		lastID   string
+		expected int
+		events   []string
+	}{
+		{"All after the last ID", "/api/v1/events", id(1), http.StatusOK, []string{"id: " + id(2), "id: " + id(3), "id: " + id(4)}},
+		{"Status filter keeps VLANs leaving the view", "/api/v1/events?status=active", id(1), http.StatusOK, []string{"id: " + id(2), "id: " + id(4)}},
+		{"Query parameter resumption", "/api/v1/events?last_event_id=" + id(3), "", http.StatusOK, []string{"id: " + id(4)}},
+		{"New events only", "/api/v1/events", "", http.StatusOK, nil},
+		{"Unknown ID", "/api/v1/events", id(40), http.StatusOK, []string{"id: " + id(4), "event: reset"}},
+		{"Invalid ID", "/api/v1/events", "latest", http.StatusBadRequest, nil},
+		{"Invalid filter", "/api/v1/events?status=unknown", "", http.StatusBadRequest, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := streamBacklog(handler, tt.path, tt.lastID)
+
+			if w.Code != tt.expected {
+				t.Fatalf("Expected status %d, got %d", tt.expected, w.Code)
+			}
+			if w.Code != http.StatusOK {
+				return
+			}
+			if contentType := w.Header().Get("Content-Type"); contentType != "text/event-stream" {
+				t.Errorf("Expected Content-Type text/event-stream, got %s", contentType)
+			}
+
+			var got []string
+			for _, line := range strings.Split(w.Body.String(), "\n") {
+				if strings.HasPrefix(line, "id: ") || line == "event: reset" {
+					got = append(got, line)
+				}
+			}
+			if strings.Join(got, ",") != strings.Join(tt.events, ",") {
+				t.Errorf("Expected events %v, got %v", tt.events, got)
+			}
+		})
+	}
+}
+
+func TestStreamEventsLive(t *testing.T) {
+	broker := events.NewBroker(10)
+	base := broker.LastID()
+	storage := events.NewStorage(NewMockStorage(), broker)
+	handler := NewHandler(storage, WithEventBroker(broker))
+
+	server := httptest.NewServer(http.HandlerFunc(handler.StreamEvents))
+	defer server.Close()
+
+	resp, err := http.Get(server.URL + "/api/v1/events?label=env=prod")
+	if err != nil {
+		t.Fatalf("Failed to connect: %v", err)
+	}
+	defer resp.Body.Close()
+
+	// The subscription exists once the headers are sent
+	storage.Create(&models.VLANInput{Name: "Dev", VlanID: 200, Subnet: "10.2.0.0/24", Gateway: "10.2.0.1", Status: "active"})
+	storage.Create(&models.VLANInput{Name: "Prod", VlanID: 100, Subnet: "10.1.0.0/24", Gateway: "10.1.0.1", Status: "active",
+		Labels: map[string]string{"env": "prod"}})
+
+	reader := bufio.NewReader(resp.Body)
+	var lines []string
+	for len(lines) < 3 {
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			t.Fatalf("Failed to read event: %v", err)
+		}
+		lines = append(lines, strings.TrimSuffix(line, "\n"))
+	}
+
+	if lines[0] != "id: "+strconv.FormatUint(base+2, 10) || lines[1] != "event: created" || !strings.Contains(lines[2], `"name":"Prod"`) {
+		t.Errorf("Expected created event for Prod, got %q", lines)
+	}
+}
+
+func TestStreamEventsNotConfigured(t *testing.T) {
+	handler := NewHandler(NewMockStorage())
+
+	req := httptest.NewRequest("GET", "/api/v1/events", nil)
+	w := httptest.NewRecorder()
+	handler.StreamEvents(w, req)
+
+	if w.Code != http.StatusNotImplemented {
+		t.Errorf("Expected status %d, got %d", http.StatusNotImplemented, w.Code)
+	}
+}
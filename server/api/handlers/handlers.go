@@ -3,40 +3,238 @@ package handlers
 import (
 	"encoding/json"
 	"errors"
+	"fmt"
+	"log/slog"
 	"net/http"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 
+	"smit/server/api/audit"
+	"smit/server/api/auth"
+	"smit/server/api/events"
+	"smit/server/api/health"
+	"smit/server/api/idempotency"
+	"smit/server/api/ipam"
+	"smit/server/api/metrics"
 	"smit/server/api/models"
+	"smit/server/api/ratelimit"
+	"smit/server/api/rbac"
 	"smit/server/api/storage"
+	"smit/server/api/tracing"
+	"smit/server/api/webhooks"
 )
 
 const AppVersion = "1.0.0"
 
-// Handler holds the storage dependency
+// Default utilization percentage above which a VLAN is flagged
+const DefaultUtilizationThreshold = 80.0
+
+// Handler holds the storage dependency and handler settings
 type Handler struct {
-	storage storage.Storage
+	storage               storage.Storage
+	prefixes              storage.PrefixStorage
+	maintenance           storage.MaintenanceStorage
+	utilizationThreshold  float64
+	gatewayPolicy         string
+	legacyErrors          bool
+	problemTypeBase       string
+	idempotency           *idempotency.Store
+	events                *events.Broker
+	webhooks              storage.WebhookStorage
+	dispatcher            *webhooks.Dispatcher
+	keys                  *auth.KeyStore
+	jwt                   *auth.JWTValidator
+	clientCerts           bool
+	certRoles             []auth.RoleMapping
+	policy                *rbac.Policy
+	audit                 *audit.Log
+	limiter               *ratelimit.Limiter
+	rateLimits            models.RateLimit
+	trustForwarded        bool
+	authLimiter           *ratelimit.Limiter
+	authFailuresPerMinute int
+	logger                *slog.Logger
+	metrics               *metrics.Registry
+	tracer                *tracing.Tracer
+	readiness             *health.Registry
+	liveness              *health.Registry
+
+	// Serializes subnet allocation so concurrent allocations do not pick the same
+	// block; the storage's overlap check guards against other writes
+	allocMu sync.Mutex
+	// Subnet index for IP lookups, kept between requests
+	lookup lookupIndex
 }
 
-// Create a new handler instance
-func NewHandler(storage storage.Storage) *Handler {
-	return &Handler{
-		storage: storage,
+// Option configures optional handler settings
+type Option func(*Handler)
+
+// Set the utilization percentage above which VLANs are flagged
+func WithUtilizationThreshold(threshold float64) Option {
+	return func(h *Handler) {
+		h.utilizationThreshold = threshold
 	}
 }
 
-// Send error responses
-func (h *Handler) sendErrorResponse(w http.ResponseWriter, status int, message string) {
-	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(status)
+// Enable the parent prefix endpoints backed by the given storage
+func WithPrefixStorage(prefixes storage.PrefixStorage) Option {
+	return func(h *Handler) {
+		h.prefixes = prefixes
+	}
+}
 
-	response := models.ErrorResponse{
-		Error:     message,
-		Timestamp: time.Now(),
+// Enable the maintenance window endpoints backed by the given storage
+func WithMaintenanceStorage(maintenance storage.MaintenanceStorage) Option {
+	return func(h *Handler) {
+		h.maintenance = maintenance
+	}
+}
+
+// Set the default gateway policy for carved subnets
+func WithGatewayPolicy(policy string) Option {
+	return func(h *Handler) {
+		h.gatewayPolicy = policy
+	}
+}
+
+// Send errors in the legacy ErrorResponse shape instead of problem+json
+func WithLegacyErrors(legacy bool) Option {
+	return func(h *Handler) {
+		h.legacyErrors = legacy
 	}
+}
 
-	json.NewEncoder(w).Encode(response)
+// Prefix problem type slugs with the given base, such as https://docs.example.com/problems/
+func WithProblemTypeBase(base string) Option {
+	return func(h *Handler) {
+		h.problemTypeBase = base
+	}
+}
+
+// Replay responses to POST requests that carry an Idempotency-Key, using the given store
+func WithIdempotencyStore(store *idempotency.Store) Option {
+	return func(h *Handler) {
+		h.idempotency = store
+	}
+}
+
+// Enable the event stream fed by the given broker
+func WithEventBroker(broker *events.Broker) Option {
+	return func(h *Handler) {
+		h.events = broker
+	}
+}
+
+// Enable the webhook endpoints backed by the given storage and dispatcher
+func WithWebhooks(webhooks storage.WebhookStorage, dispatcher *webhooks.Dispatcher) Option {
+	return func(h *Handler) {
+		h.webhooks = webhooks
+		h.dispatcher = dispatcher
+	}
+}
+
+// Require API keys from the given store on every request but the health check
+func WithAPIKeys(keys *auth.KeyStore) Option {
+	return func(h *Handler) {
+		h.keys = keys
+	}
+}
+
+// Accept JWT bearer tokens checked by the given validator
+func WithJWTValidator(validator *auth.JWTValidator) Option {
+	return func(h *Handler) {
+		h.jwt = validator
+	}
+}
+
+// Accept verified TLS client certificates when enabled, mapping their subjects to roles
+func WithClientCertificates(enabled bool, roleMap []auth.RoleMapping) Option {
+	return func(h *Handler) {
+		h.clientCerts = enabled
+		h.certRoles = roleMap
+	}
+}
+
+// Limit each client's requests with the given limiter. The defaults apply to
+// callers whose API key sets no limits of its own; client addresses are taken
+// from X-Forwarded-For when trustForwarded is set.
+func WithRateLimits(limiter *ratelimit.Limiter, defaults models.RateLimit, trustForwarded bool) Option {
+	return func(h *Handler) {
+		h.limiter = limiter
+		h.rateLimits = defaults
+		h.trustForwarded = trustForwarded
+	}
+}
+
+// Refuse client addresses with more than perMinute failed authentications a
+// minute, counted in the given limiter; zero leaves failures unlimited. Addresses
+// follow the trustForwarded setting of WithRateLimits.
+func WithAuthFailureLimit(limiter *ratelimit.Limiter, perMinute int) Option {
+	return func(h *Handler) {
+		h.authLimiter = limiter
+		h.authFailuresPerMinute = perMinute
+	}
+}
+
+// Log every request to the given logger
+func WithLogger(logger *slog.Logger) Option {
+	return func(h *Handler) {
+		h.logger = logger
+	}
+}
+
+// Serve the given registry's metrics at /metrics
+func WithMetrics(registry *metrics.Registry) Option {
+	return func(h *Handler) {
+		h.metrics = registry
+	}
+}
+
+// Record a span for every handler and storage call with the given tracer
+func WithTracer(tracer *tracing.Tracer) Option {
+	return func(h *Handler) {
+		h.tracer = tracer
+	}
+}
+
+// Run the given checks on the readiness and liveness probes; nil checks always pass
+func WithProbes(readiness, liveness *health.Registry) Option {
+	return func(h *Handler) {
+		h.readiness = readiness
+		h.liveness = liveness
+	}
+}
+
+// Enforce the access policy on every handler
+func WithAccessPolicy(policy *rbac.Policy) Option {
+	return func(h *Handler) {
+		h.policy = policy
+	}
+}
+
+// Record denied actions and allowed writes in the given audit log
+func WithAuditLog(log *audit.Log) Option {
+	return func(h *Handler) {
+		h.audit = log
+	}
+}
+
+// Create a new handler instance
+func NewHandler(storage storage.Storage, opts ...Option) *Handler {
+	h := &Handler{
+		storage:              storage,
+		utilizationThreshold: DefaultUtilizationThreshold,
+		gatewayPolicy:        ipam.GatewayPolicyFirst,
+		problemTypeBase:      DefaultProblemTypeBase,
+	}
+
+	for _, opt := range opts {
+		opt(h)
+	}
+
+	return h
 }
 
 // Send JSON responses
@@ -44,9 +242,49 @@ func (h *Handler) sendJSONResponse(w http.ResponseWriter, status int, data inter
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(status)
 
+	// Headers are already sent, so an encoding failure can only be logged
 	if err := json.NewEncoder(w).Encode(data); err != nil {
-		h.sendErrorResponse(w, http.StatusInternalServerError, "Failed to encode response")
+		slog.Error("Failed to encode response", "error", err)
+	}
+}
+
+// Send a VLAN with its ETag, so a later write can be made conditional on it
+func (h *Handler) sendVLAN(w http.ResponseWriter, status int, vlan *models.VLANModel) {
+	w.Header().Set("ETag", vlan.ETag())
+	h.sendJSONResponse(w, status, vlan)
+}
+
+// Entity tag of the If-Match header; empty when the header is missing or matches any version
+func ifMatchFromRequest(r *http.Request) string {
+	value := strings.TrimSpace(r.Header.Get("If-Match"))
+	if value == "*" {
+		return ""
+	}
+	return value
+}
+
+// Check the request's If-Match against the VLAN as read, sending 412 when it
+// names another version
+func (h *Handler) matchVersion(w http.ResponseWriter, r *http.Request, current *models.VLANModel) bool {
+	if expected := ifMatchFromRequest(r); expected != "" && expected != current.ETag() {
+		h.sendProblem(w, r, problemPreconditionFailed, "VLAN has changed since it was read; fetch it again and retry")
+		return false
+	}
+	return true
+}
+
+// Read the dry_run query parameter; a dry run runs every check of a write without saving
+func dryRunFromRequest(r *http.Request) (bool, error) {
+	value := r.URL.Query().Get("dry_run")
+	if value == "" {
+		return false, nil
 	}
+
+	dryRun, err := strconv.ParseBool(value)
+	if err != nil {
+		return false, fmt.Errorf("dry_run must be true or false")
+	}
+	return dryRun, nil
 }
 
 // Extract ID from URL path
@@ -61,161 +299,383 @@ func extractIDFromPath(path string) (int, error) {
 		return 0, errors.New("invalid ID format")
 	}
 
-	if id < 1 || id > 4094 {
-		return 0, errors.New("ID must be between 1 and 4094")
+	if id < 1 {
+		return 0, errors.New("ID must be a positive integer")
 	}
 
 	return id, nil
 }
 
+// Extract the 802.1Q tag from a /api/v1/vlans/by-tag/{vlan_id} path
+func extractTagFromPath(path string) (int, error) {
+	parts := strings.Split(strings.TrimPrefix(path, "/"), "/")
+	if len(parts) < 5 {
+		return 0, errors.New("invalid path")
+	}
+
+	tag, err := strconv.Atoi(parts[4])
+	if err != nil {
+		return 0, errors.New("invalid VLAN ID format")
+	}
+
+	if tag < 1 || tag > 4094 {
+		return 0, errors.New("VLAN ID must be between 1 and 4094")
+	}
+
+	return tag, nil
+}
+
 // Handles GET /api/v1/vlans
 func (h *Handler) GetVLANs(w http.ResponseWriter, r *http.Request) {
+	r, span := h.startSpan(r, "GetVLANs")
+	defer span.End()
+
 	if r.Method != http.MethodGet {
-		h.sendErrorResponse(w, http.StatusMethodNotAllowed, "Method not allowed")
+		h.sendProblem(w, r, problemMethodNotAllowed, "Method not allowed")
+		return
+	}
+	if !h.authorize(w, r, rbac.ActionVLANRead) {
+		return
+	}
+
+	query, err := vlanQueryFromRequest(r)
+	if err != nil {
+		h.sendValidationError(w, r, err)
 		return
 	}
 
-	vlans, err := h.storage.GetAll()
+	page, err := h.vlanStore(r).Query(query)
 	if err != nil {
-		h.sendErrorResponse(w, http.StatusInternalServerError, "Failed to retrieve VLANs")
+		h.sendProblem(w, r, problemInternal, "Failed to retrieve VLANs")
 		return
 	}
 
-	h.sendJSONResponse(w, http.StatusOK, vlans)
+	// The body stays a plain list, the next page is linked in the header
+	if page.NextCursor != "" {
+		w.Header().Set("Link", nextLink(r, page.NextCursor))
+	}
+
+	h.sendJSONResponse(w, http.StatusOK, page.Items)
 }
 
 // Handles POST /api/v1/vlans
 func (h *Handler) CreateVLAN(w http.ResponseWriter, r *http.Request) {
+	r, span := h.startSpan(r, "CreateVLAN")
+	defer span.End()
+
 	if r.Method != http.MethodPost {
-		h.sendErrorResponse(w, http.StatusMethodNotAllowed, "Method not allowed")
+		h.sendProblem(w, r, problemMethodNotAllowed, "Method not allowed")
 		return
 	}
 
 	var input models.VLANInput
-	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
-		h.sendErrorResponse(w, http.StatusBadRequest, "Invalid request body")
+	if err := h.decodeBody(r, &input); err != nil {
+		h.sendProblem(w, r, problemInvalidBody, "Invalid request body")
+		return
+	}
+
+	dryRun, err := dryRunFromRequest(r)
+	if err != nil {
+		h.sendProblem(w, r, problemInvalidParameter, err.Error())
 		return
 	}
 
+	if !h.authorizeVLAN(w, r, rbac.ActionVLANCreate, nil, &input) {
+		return
+	}
+	input.Actor = actor(r)
+
 	// Validate input
-	if err := input.Validate(); err != nil {
-		h.sendErrorResponse(w, http.StatusBadRequest, err.Error())
+	if err := h.validateVLAN(r, &input); err != nil {
+		h.sendValidationError(w, r, err)
 		return
 	}
 
-	// Create VLAN
-	vlan, err := h.storage.Create(&input)
+	// Create VLAN, or only run the checks of a create on a dry run
+	create, status := h.vlanStore(r).Create, http.StatusCreated
+	if dryRun {
+		create, status = h.vlanStore(r).CheckCreate, http.StatusOK
+	}
+	vlan, err := create(&input)
 	if err != nil {
 		if errors.Is(err, storage.ErrVLANExists) {
-			h.sendErrorResponse(w, http.StatusConflict, "VLAN with this ID already exists")
+			h.sendVLANConflict(w, r, input.VlanID)
 			return
 		}
-		h.sendErrorResponse(w, http.StatusInternalServerError, "Failed to create VLAN")
+		if errors.Is(err, storage.ErrSubnetOverlap) {
+			h.sendOverlapConflict(w, r, err)
+			return
+		}
+		h.sendProblem(w, r, problemInternal, "Failed to create VLAN")
 		return
 	}
 
-	h.sendJSONResponse(w, http.StatusCreated, vlan)
+	if dryRun {
+		h.sendJSONResponse(w, status, vlan)
+		return
+	}
+	h.sendVLAN(w, status, vlan)
 }
 
 // Handles GET /api/v1/vlans/{id}
 func (h *Handler) GetVLAN(w http.ResponseWriter, r *http.Request) {
+	r, span := h.startSpan(r, "GetVLAN")
+	defer span.End()
+
 	if r.Method != http.MethodGet {
-		h.sendErrorResponse(w, http.StatusMethodNotAllowed, "Method not allowed")
+		h.sendProblem(w, r, problemMethodNotAllowed, "Method not allowed")
+		return
+	}
+	if !h.authorize(w, r, rbac.ActionVLANRead) {
 		return
 	}
 
 	id, err := extractIDFromPath(r.URL.Path)
 	if err != nil {
-		h.sendErrorResponse(w, http.StatusBadRequest, err.Error())
+		h.sendProblem(w, r, problemInvalidID, err.Error())
 		return
 	}
 
-	vlan, err := h.storage.GetByID(id)
+	vlan, err := h.vlanStore(r).GetByID(id)
 	if err != nil {
 		if errors.Is(err, storage.ErrVLANNotFound) {
-			h.sendErrorResponse(w, http.StatusNotFound, "VLAN not found")
+			h.sendProblem(w, r, problemVLANNotFound, "VLAN not found")
 			return
 		}
-		h.sendErrorResponse(w, http.StatusInternalServerError, "Failed to retrieve VLAN")
+		h.sendProblem(w, r, problemInternal, "Failed to retrieve VLAN")
 		return
 	}
 
-	h.sendJSONResponse(w, http.StatusOK, vlan)
+	h.sendVLAN(w, http.StatusOK, vlan)
 }
 
 // Handles PUT /api/v1/vlans/{id}
 func (h *Handler) UpdateVLAN(w http.ResponseWriter, r *http.Request) {
+	r, span := h.startSpan(r, "UpdateVLAN")
+	defer span.End()
+
 	if r.Method != http.MethodPut {
-		h.sendErrorResponse(w, http.StatusMethodNotAllowed, "Method not allowed")
+		h.sendProblem(w, r, problemMethodNotAllowed, "Method not allowed")
 		return
 	}
 
 	id, err := extractIDFromPath(r.URL.Path)
 	if err != nil {
-		h.sendErrorResponse(w, http.StatusBadRequest, err.Error())
+		h.sendProblem(w, r, problemInvalidID, err.Error())
 		return
 	}
 
+	h.updateVLAN(w, r, id)
+}
+
+// Update the VLAN with the given record ID from the request body
+func (h *Handler) updateVLAN(w http.ResponseWriter, r *http.Request, id int) {
 	var input models.VLANInput
-	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
-		h.sendErrorResponse(w, http.StatusBadRequest, "Invalid request body")
+	if err := h.decodeBody(r, &input); err != nil {
+		h.sendProblem(w, r, problemInvalidBody, "Invalid request body")
+		return
+	}
+	h.saveVLAN(w, r, id, &input)
+}
+
+// Validate and store the full new state of a VLAN
+func (h *Handler) saveVLAN(w http.ResponseWriter, r *http.Request, id int, input *models.VLANInput) {
+	dryRun, err := dryRunFromRequest(r)
+	if err != nil {
+		h.sendProblem(w, r, problemInvalidParameter, err.Error())
 		return
 	}
 
 	// Validate input
-	if err := input.Validate(); err != nil {
-		h.sendErrorResponse(w, http.StatusBadRequest, err.Error())
+	if err := h.validateVLAN(r, input); err != nil {
+		h.sendValidationError(w, r, err)
 		return
 	}
 
-	// Update VLAN
-	vlan, err := h.storage.Update(id, &input)
+	current, err := h.vlanStore(r).GetByID(id)
 	if err != nil {
 		if errors.Is(err, storage.ErrVLANNotFound) {
-			h.sendErrorResponse(w, http.StatusNotFound, "VLAN not found")
+			h.sendProblem(w, r, problemVLANNotFound, "VLAN not found")
+			return
+		}
+		h.sendProblem(w, r, problemInternal, "Failed to retrieve VLAN")
+		return
+	}
+	if !h.authorizeVLAN(w, r, rbac.ActionVLANUpdate, current, input) {
+		return
+	}
+	// The write applies only to the version it was authorized against, so a
+	// concurrent change cannot slip past the field and scope checks. A patch
+	// already names the version it was computed from.
+	if !h.matchVersion(w, r, current) {
+		return
+	}
+	if input.IfMatch == "" {
+		input.IfMatch = current.ETag()
+	}
+	input.Actor = actor(r)
+
+	// Update VLAN, or only run the checks of an update on a dry run. The storage
+	// enforces the status lifecycle against the status it holds.
+	update := h.vlanStore(r).Update
+	if dryRun {
+		update = h.vlanStore(r).CheckUpdate
+	}
+	vlan, err := update(id, input)
+	if err != nil {
+		if errors.Is(err, storage.ErrVLANNotFound) {
+			h.sendProblem(w, r, problemVLANNotFound, "VLAN not found")
 			return
 		}
 		if errors.Is(err, storage.ErrVLANExists) {
-			h.sendErrorResponse(w, http.StatusConflict, "VLAN with this ID already exists")
+			h.sendVLANConflict(w, r, input.VlanID)
+			return
+		}
+		if errors.Is(err, storage.ErrSubnetOverlap) {
+			h.sendOverlapConflict(w, r, err)
+			return
+		}
+		if errors.Is(err, storage.ErrInvalidTransition) {
+			h.sendProblem(w, r, problemInvalidTransition, err.Error())
 			return
 		}
-		h.sendErrorResponse(w, http.StatusInternalServerError, "Failed to update VLAN")
+		if errors.Is(err, storage.ErrVersionMismatch) {
+			h.sendProblem(w, r, problemPreconditionFailed, "VLAN has changed since it was read; fetch it again and retry")
+			return
+		}
+		h.sendProblem(w, r, problemInternal, "Failed to update VLAN")
 		return
 	}
 
-	h.sendJSONResponse(w, http.StatusOK, vlan)
+	if dryRun {
+		h.sendJSONResponse(w, http.StatusOK, vlan)
+		return
+	}
+	h.sendVLAN(w, http.StatusOK, vlan)
 }
 
 // Handles DELETE /api/v1/vlans/{id}
 func (h *Handler) DeleteVLAN(w http.ResponseWriter, r *http.Request) {
+	r, span := h.startSpan(r, "DeleteVLAN")
+	defer span.End()
+
 	if r.Method != http.MethodDelete {
-		h.sendErrorResponse(w, http.StatusMethodNotAllowed, "Method not allowed")
+		h.sendProblem(w, r, problemMethodNotAllowed, "Method not allowed")
 		return
 	}
 
 	id, err := extractIDFromPath(r.URL.Path)
 	if err != nil {
-		h.sendErrorResponse(w, http.StatusBadRequest, err.Error())
+		h.sendProblem(w, r, problemInvalidID, err.Error())
+		return
+	}
+
+	h.deleteVLAN(w, r, id)
+}
+
+// Delete the VLAN with the given record ID
+func (h *Handler) deleteVLAN(w http.ResponseWriter, r *http.Request, id int) {
+	dryRun, err := dryRunFromRequest(r)
+	if err != nil {
+		h.sendProblem(w, r, problemInvalidParameter, err.Error())
 		return
 	}
 
-	err = h.storage.Delete(id)
+	current, err := h.vlanStore(r).GetByID(id)
 	if err != nil {
 		if errors.Is(err, storage.ErrVLANNotFound) {
-			h.sendErrorResponse(w, http.StatusNotFound, "VLAN not found")
+			h.sendProblem(w, r, problemVLANNotFound, "VLAN not found")
 			return
 		}
-		h.sendErrorResponse(w, http.StatusInternalServerError, "Failed to delete VLAN")
+		h.sendProblem(w, r, problemInternal, "Failed to retrieve VLAN")
+		return
+	}
+	if !h.authorizeVLAN(w, r, rbac.ActionVLANDelete, current, nil) {
+		return
+	}
+	if !h.matchVersion(w, r, current) {
+		return
+	}
+	// A dry run answers with the VLAN that would be deleted. The storage enforces
+	// the status lifecycle against the status it holds, and deletes only the
+	// version that was authorized.
+	remove := h.vlanStore(r).Delete
+	if dryRun {
+		remove = h.vlanStore(r).CheckDelete
+	}
+	if err := remove(id, current.ETag()); err != nil {
+		if errors.Is(err, storage.ErrVLANNotFound) {
+			h.sendProblem(w, r, problemVLANNotFound, "VLAN not found")
+			return
+		}
+		if errors.Is(err, storage.ErrNotDeletable) {
+			h.sendProblem(w, r, problemNotDeletable, err.Error())
+			return
+		}
+		if errors.Is(err, storage.ErrVersionMismatch) {
+			h.sendProblem(w, r, problemPreconditionFailed, "VLAN has changed since it was read; fetch it again and retry")
+			return
+		}
+		h.sendProblem(w, r, problemInternal, "Failed to delete VLAN")
 		return
 	}
 
+	if dryRun {
+		h.sendJSONResponse(w, http.StatusOK, current)
+		return
+	}
 	w.WriteHeader(http.StatusNoContent)
 }
 
+// Handles GET, PUT, PATCH and DELETE /api/v1/vlans/by-tag/{vlan_id}
+func (h *Handler) VLANByTagHandler(w http.ResponseWriter, r *http.Request) {
+	r, span := h.startSpan(r, "VLANByTagHandler")
+	defer span.End()
+
+	switch r.Method {
+	case http.MethodGet, http.MethodPut, http.MethodPatch, http.MethodDelete:
+	default:
+		h.sendProblem(w, r, problemMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	tag, err := extractTagFromPath(r.URL.Path)
+	if err != nil {
+		h.sendProblem(w, r, problemInvalidID, err.Error())
+		return
+	}
+
+	vlan, err := h.vlanStore(r).GetByVlanID(tag)
+	if err != nil {
+		if errors.Is(err, storage.ErrVLANNotFound) {
+			h.sendProblem(w, r, problemVLANNotFound, "VLAN not found")
+			return
+		}
+		h.sendProblem(w, r, problemInternal, "Failed to retrieve VLAN")
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		if h.authorize(w, r, rbac.ActionVLANRead) {
+			h.sendVLAN(w, http.StatusOK, vlan)
+		}
+	case http.MethodPut:
+		h.updateVLAN(w, r, vlan.ID)
+	case http.MethodPatch:
+		h.patchVLAN(w, r, vlan.ID)
+	case http.MethodDelete:
+		h.deleteVLAN(w, r, vlan.ID)
+	}
+}
+
 // Handles GET /health
 func (h *Handler) HealthCheck(w http.ResponseWriter, r *http.Request) {
+	r, span := h.startSpan(r, "HealthCheck")
+	defer span.End()
+
 	if r.Method != http.MethodGet {
-		h.sendErrorResponse(w, http.StatusMethodNotAllowed, "Method not allowed")
+		h.sendProblem(w, r, problemMethodNotAllowed, "Method not allowed")
 		return
 	}
 
@@ -230,6 +690,9 @@ func (h *Handler) HealthCheck(w http.ResponseWriter, r *http.Request) {
 
 // Handler for VLAN endpoints
 func (h *Handler) VLANHandler(w http.ResponseWriter, r *http.Request) {
+	r, span := h.startSpan(r, "VLANHandler")
+	defer span.End()
+
 	path := r.URL.Path
 
 	// Handle /api/v1/vlans
@@ -240,11 +703,36 @@ func (h *Handler) VLANHandler(w http.ResponseWriter, r *http.Request) {
 		case http.MethodPost:
 			h.CreateVLAN(w, r)
 		default:
-			h.sendErrorResponse(w, http.StatusMethodNotAllowed, "Method not allowed")
+			h.sendProblem(w, r, problemMethodNotAllowed, "Method not allowed")
 		}
 		return
 	}
 
+	// Handle /api/v1/vlans/by-tag/{vlan_id}
+	if strings.HasPrefix(path, "/api/v1/vlans/by-tag/") {
+		h.VLANByTagHandler(w, r)
+		return
+	}
+
+	// Handle /api/v1/vlans/{id}/transitions
+	if strings.HasPrefix(path, "/api/v1/vlans/") && strings.HasSuffix(path, "/transitions") {
+		switch r.Method {
+		case http.MethodGet:
+			h.GetVLANTransitions(w, r)
+		case http.MethodPost:
+			h.TransitionVLAN(w, r)
+		default:
+			h.sendProblem(w, r, problemMethodNotAllowed, "Method not allowed")
+		}
+		return
+	}
+
+	// Handle /api/v1/vlans/{id}/utilization
+	if strings.HasPrefix(path, "/api/v1/vlans/") && strings.HasSuffix(path, "/utilization") {
+		h.GetVLANUtilization(w, r)
+		return
+	}
+
 	// Handle /api/v1/vlans/{id}
 	if strings.HasPrefix(path, "/api/v1/vlans/") {
 		switch r.Method {
@@ -252,13 +740,15 @@ func (h *Handler) VLANHandler(w http.ResponseWriter, r *http.Request) {
 			h.GetVLAN(w, r)
 		case http.MethodPut:
 			h.UpdateVLAN(w, r)
+		case http.MethodPatch:
+			h.PatchVLAN(w, r)
 		case http.MethodDelete:
 			h.DeleteVLAN(w, r)
 		default:
-			h.sendErrorResponse(w, http.StatusMethodNotAllowed, "Method not allowed")
+			h.sendProblem(w, r, problemMethodNotAllowed, "Method not allowed")
 		}
 		return
 	}
 
-	h.sendErrorResponse(w, http.StatusNotFound, "Endpoint not found")
+	h.sendProblem(w, r, problemEndpointNotFound, "Endpoint not found")
 }
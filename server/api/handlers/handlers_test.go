@@ -29,6 +29,10 @@ func (m *MockStorage) GetAll() ([]models.VLANModel, error) {
 	return m.vlans, nil
 }
 
+func (m *MockStorage) Query(query models.VLANQuery) (*models.VLANPage, error) {
+	return query.Apply(m.vlans)
+}
+
 func (m *MockStorage) GetByID(id int) (*models.VLANModel, error) {
 	for _, vlan := range m.vlans {
 		if vlan.ID == id {
@@ -38,6 +42,15 @@ func (m *MockStorage) GetByID(id int) (*models.VLANModel, error) {
 	return nil, storage.ErrVLANNotFound
 }
 
+func (m *MockStorage) GetByVlanID(vlanID int) (*models.VLANModel, error) {
+	for _, vlan := range m.vlans {
+		if vlan.VlanID == vlanID {
+			return &vlan, nil
+		}
+	}
+	return nil, storage.ErrVLANNotFound
+}
+
 func (m *MockStorage) Create(input *models.VLANInput) (*models.VLANModel, error) {
 	// Check if VLAN ID already exists
 	for _, vlan := range m.vlans {
@@ -47,14 +60,21 @@ func (m *MockStorage) Create(input *models.VLANInput) (*models.VLANModel, error)
 	}
 
 	vlan := models.VLANModel{
-		ID:        m.nextID,
-		Name:      input.Name,
-		VlanID:    input.VlanID,
-		Subnet:    input.Subnet,
-		Gateway:   input.Gateway,
-		Status:    input.Status,
-		CreatedAt: time.Now(),
-		UpdatedAt: time.Now(),
+		ID:           m.nextID,
+		Name:         input.Name,
+		VlanID:       input.VlanID,
+		Subnet:       input.Subnet,
+		Gateway:      input.Gateway,
+		Status:       input.Status,
+		Site:         input.Site,
+		Allocated:    input.Allocated,
+		Reserved:     input.Reserved,
+		Labels:       input.Labels,
+		CustomFields: input.CustomFields,
+		CreatedAt:    time.Now(),
+		UpdatedAt:    time.Now(),
+		CreatedBy:    input.Actor,
+		UpdatedBy:    input.Actor,
 	}
 
 	m.vlans = append(m.vlans, vlan)
@@ -66,6 +86,13 @@ func (m *MockStorage) Create(input *models.VLANInput) (*models.VLANModel, error)
 func (m *MockStorage) Update(id int, input *models.VLANInput) (*models.VLANModel, error) {
 	for i, vlan := range m.vlans {
 		if vlan.ID == id {
+			if input.IfMatch != "" && vlan.ETag() != input.IfMatch {
+				return nil, storage.ErrVersionMismatch
+			}
+			if !models.CurrentLifecycle().CanTransition(vlan.Status, input.Status) {
+				return nil, &storage.TransitionError{From: vlan.Status, To: input.Status}
+			}
+
 			// Check if new VLAN ID conflicts
 			if vlan.VlanID != input.VlanID {
 				for _, v := range m.vlans {
@@ -80,17 +107,47 @@ func (m *MockStorage) Update(id int, input *models.VLANInput) (*models.VLANModel
 			m.vlans[i].Subnet = input.Subnet
 			m.vlans[i].Gateway = input.Gateway
 			m.vlans[i].Status = input.Status
+			m.vlans[i].Site = input.Site
+			m.vlans[i].Allocated = input.Allocated
+			m.vlans[i].Reserved = input.Reserved
+			m.vlans[i].Labels = input.Labels
+			m.vlans[i].CustomFields = input.CustomFields
 			m.vlans[i].UpdatedAt = time.Now()
+			m.vlans[i].UpdatedBy = input.Actor
+
+			return &m.vlans[i], nil
+		}
+	}
+	return nil, storage.ErrVLANNotFound
+}
 
+func (m *MockStorage) UpdateStatus(id int, change models.StatusChange) (*models.VLANModel, error) {
+	for i, vlan := range m.vlans {
+		if vlan.ID == id {
+			if vlan.Status == change.To || !models.CurrentLifecycle().CanTransition(vlan.Status, change.To) {
+				return nil, &storage.TransitionError{From: vlan.Status, To: change.To}
+			}
+			change.From = vlan.Status
+			change.ChangedAt = time.Now()
+			m.vlans[i].Status = change.To
+			m.vlans[i].StatusHistory = append(m.vlans[i].StatusHistory, change)
+			m.vlans[i].UpdatedAt = time.Now()
+			m.vlans[i].UpdatedBy = change.ChangedBy
 			return &m.vlans[i], nil
 		}
 	}
 	return nil, storage.ErrVLANNotFound
 }
 
-func (m *MockStorage) Delete(id int) error {
+func (m *MockStorage) Delete(id int, ifMatch string) error {
 	for i, vlan := range m.vlans {
 		if vlan.ID == id {
+			if ifMatch != "" && vlan.ETag() != ifMatch {
+				return storage.ErrVersionMismatch
+			}
+			if !models.CurrentLifecycle().CanDelete(vlan.Status) {
+				return &storage.DeleteError{Status: vlan.Status}
+			}
 			m.vlans = append(m.vlans[:i], m.vlans[i+1:]...)
 			return nil
 		}
@@ -98,6 +155,23 @@ func (m *MockStorage) Delete(id int) error {
 	return storage.ErrVLANNotFound
 }
 
+// Dry runs apply the write to a copy, so they share the checks of the real write
+func (m *MockStorage) clone() *MockStorage {
+	return &MockStorage{vlans: append([]models.VLANModel(nil), m.vlans...), nextID: m.nextID}
+}
+
+func (m *MockStorage) CheckCreate(input *models.VLANInput) (*models.VLANModel, error) {
+	return m.clone().Create(input)
+}
+
+func (m *MockStorage) CheckUpdate(id int, input *models.VLANInput) (*models.VLANModel, error) {
+	return m.clone().Update(id, input)
+}
+
+func (m *MockStorage) CheckDelete(id int, ifMatch string) error {
+	return m.clone().Delete(id, ifMatch)
+}
+
 func TestHealthCheck(t *testing.T) {
 	handler := NewHandler(NewMockStorage())
 
@@ -152,6 +226,53 @@ func TestGetVLANs(t *testing.T) {
 	}
 }
 
+func TestGetVLANsByLabel(t *testing.T) {
+	storage := NewMockStorage()
+	handler := NewHandler(storage)
+
+	storage.Create(&models.VLANInput{Name: "Prod", VlanID: 100, Subnet: "10.0.0.0/24", Gateway: "10.0.0.1", Status: "active",
+		Labels: map[string]string{"env": "prod", "pci": ""}})
+	storage.Create(&models.VLANInput{Name: "Dev", VlanID: 200, Subnet: "10.0.1.0/24", Gateway: "10.0.1.1", Status: "active",
+		Labels: map[string]string{"env": "dev"}})
+
+	tests := []struct {
+		name     string
+		query    string
+		expected int
+		count    int
+	}{
+		{"Label value", "?label=env=prod", http.StatusOK, 1},
+		{"Label presence", "?label=env", http.StatusOK, 2},
+		{"Multiple labels", "?label=env=prod&label=pci", http.StatusOK, 1},
+		{"No match", "?label=env=qa", http.StatusOK, 0},
+		{"Invalid selector", "?label==prod", http.StatusBadRequest, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("GET", "/api/v1/vlans"+tt.query, nil)
+			w := httptest.NewRecorder()
+
+			handler.GetVLANs(w, req)
+
+			if w.Code != tt.expected {
+				t.Fatalf("Expected status %d, got %d", tt.expected, w.Code)
+			}
+			if w.Code != http.StatusOK {
+				return
+			}
+
+			var vlans []models.VLANModel
+			if err := json.NewDecoder(w.Body).Decode(&vlans); err != nil {
+				t.Fatalf("Failed to decode response: %v", err)
+			}
+			if len(vlans) != tt.count {
+				t.Errorf("Expected %d VLANs, got %d", tt.count, len(vlans))
+			}
+		})
+	}
+}
+
 func TestCreateVLAN(t *testing.T) {
 	handler := NewHandler(NewMockStorage())
 
@@ -351,7 +472,7 @@ func TestDeleteVLAN(t *testing.T) {
 		VlanID:  100,
 		Subnet:  "192.168.100.0/24",
 		Gateway: "192.168.100.1",
-		Status:  "active",
+		Status:  "inactive",
 	})
 
 	req := httptest.NewRequest("DELETE", "/api/v1/vlans/1", nil)
@@ -443,8 +564,10 @@ func TestExtractIDFromPath(t *testing.T) {
 		{"/api/v1/vlans/1", 1, false},
 		{"/api/v1/vlans/100", 100, false},
 		{"/api/v1/vlans/4094", 4094, false},
+		{"/api/v1/vlans/4095", 4095, false},
+		{"/api/v1/vlans/100000", 100000, false},
 		{"/api/v1/vlans/0", 0, true},
-		{"/api/v1/vlans/4095", 0, true},
+		{"/api/v1/vlans/-1", 0, true},
 		{"/api/v1/vlans/abc", 0, true},
 		{"/api/v1/", 0, true},
 		{"/api/v1/vlans/", 0, true},
@@ -464,6 +587,48 @@ func TestExtractIDFromPath(t *testing.T) {
 	}
 }
 
+func TestVLANByTagHandler(t *testing.T) {
+	storage := NewMockStorage()
+	handler := NewHandler(storage)
+
+	// Record IDs and tags deliberately differ
+	storage.nextID = 5000
+	storage.Create(&models.VLANInput{Name: "Development", VlanID: 200, Subnet: "192.168.200.0/24", Gateway: "192.168.200.1", Status: "active"})
+
+	update := `{"name": "Development Updated", "vlan_id": 200, "subnet": "192.168.200.0/24", "gateway": "192.168.200.1", "status": "inactive"}`
+
+	tests := []struct {
+		name     string
+		method   string
+		path     string
+		body     string
+		expected int
+	}{
+		{"Get by record ID above 4094", "GET", "/api/v1/vlans/5000", "", http.StatusOK},
+		{"Get by record ID using tag", "GET", "/api/v1/vlans/200", "", http.StatusNotFound},
+		{"Get by tag", "GET", "/api/v1/vlans/by-tag/200", "", http.StatusOK},
+		{"Get by missing tag", "GET", "/api/v1/vlans/by-tag/201", "", http.StatusNotFound},
+		{"Get by invalid tag", "GET", "/api/v1/vlans/by-tag/4095", "", http.StatusBadRequest},
+		{"Update by tag", "PUT", "/api/v1/vlans/by-tag/200", update, http.StatusOK},
+		{"Method not allowed", "POST", "/api/v1/vlans/by-tag/200", "", http.StatusMethodNotAllowed},
+		{"Delete by tag", "DELETE", "/api/v1/vlans/by-tag/200", "", http.StatusNoContent},
+		{"Delete by tag again", "DELETE", "/api/v1/vlans/by-tag/200", "", http.StatusNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, tt.path, bytes.NewReader([]byte(tt.body)))
+			w := httptest.NewRecorder()
+
+			handler.VLANHandler(w, req)
+
+			if w.Code != tt.expected {
+				t.Errorf("Expected status %d, got %d: %s", tt.expected, w.Code, w.Body.String())
+			}
+		})
+	}
+}
+
 func TestCreateVLANConflict(t *testing.T) {
 	storage := NewMockStorage()
 	handler := NewHandler(storage)
@@ -557,3 +722,29 @@ func TestInvalidJSONBody(t *testing.T) {
 		t.Errorf("Expected status %d for invalid JSON, got %d", http.StatusBadRequest, w.Code)
 	}
 }
+
+func TestCreateVLANValidationDetails(t *testing.T) {
+	handler := NewHandler(NewMockStorage())
+
+	body := []byte(`{"name": "", "vlan_id": 0, "subnet": "192.168.1.0/24", "gateway": "192.168.1.1", "status": "active"}`)
+	req := httptest.NewRequest("POST", "/api/v1/vlans", bytes.NewReader(body))
+	w := httptest.NewRecorder()
+
+	handler.CreateVLAN(w, req)
+
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("Expected status %d, got %d", http.StatusBadRequest, w.Code)
+	}
+
+	var problem models.Problem
+	if err := json.NewDecoder(w.Body).Decode(&problem); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+
+	if len(problem.Errors) != 2 {
+		t.Fatalf("Expected 2 field errors, got %d", len(problem.Errors))
+	}
+	if problem.Errors[0].Field != "name" || problem.Errors[1].Field != "vlan_id" {
+		t.Errorf("Unexpected field errors: %+v", problem.Errors)
+	}
+}
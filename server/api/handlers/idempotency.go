@@ -0,0 +1,117 @@
+package handlers
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"slices"
+
+	"smit/server/api/auth"
+	"smit/server/api/idempotency"
+	"smit/server/api/recorder"
+)
+
+// Request header carrying the client's idempotency key
+const idempotencyKeyHeader = "Idempotency-Key"
+
+// Maximum length of an idempotency key
+const maxIdempotencyKeyLength = 255
+
+// Largest request body read into memory, for fingerprinting or decoding
+const maxRequestBodySize = 1 << 20
+
+// Replay stored responses for POST requests that repeat an Idempotency-Key.
+// The first request with a key runs normally and its response is kept; retries with
+// the same key and payload get that response back, a different payload gets 422.
+// Server errors are not kept, so a retry after a 5xx runs the request again.
+// Bodies over maxRequestBodySize get 413 before a key is taken.
+func (h *Handler) Idempotent(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		key := r.Header.Get(idempotencyKeyHeader)
+		if h.idempotency == nil || r.Method != http.MethodPost || key == "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		if len(key) > maxIdempotencyKeyLength {
+			h.sendProblem(w, r, problemInvalidParameter, "Idempotency-Key must be at most 255 characters")
+			return
+		}
+
+		// Keys are scoped to the caller so clients cannot see each other's responses
+		if identity := auth.IdentityFromContext(r.Context()); identity != nil {
+			key = identity.Key() + ":" + key
+		}
+
+		body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxRequestBodySize))
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			h.sendProblem(w, r, problemBodyTooLarge, fmt.Sprintf("Request body must be at most %d bytes", maxRequestBodySize))
+			return
+		}
+		if err != nil {
+			h.sendProblem(w, r, problemInvalidBody, "Invalid request body")
+			return
+		}
+		r.Body = io.NopCloser(bytes.NewReader(body))
+
+		stored, err := h.idempotency.Begin(key, requestFingerprint(r, body))
+		switch {
+		case errors.Is(err, idempotency.ErrKeyReused):
+			h.sendProblem(w, r, problemIdempotencyKeyReused, "Idempotency-Key was already used for a different request")
+			return
+		case errors.Is(err, idempotency.ErrInProgress):
+			h.sendProblem(w, r, problemRequestInProgress, "A request with this Idempotency-Key is still being processed")
+			return
+		case stored != nil:
+			for name, values := range stored.Header {
+				w.Header()[name] = values
+			}
+			w.Header().Set("Idempotent-Replayed", "true")
+			w.WriteHeader(stored.Status)
+			w.Write(stored.Body)
+			return
+		}
+
+		// Headers set before the handler runs belong to this request, not the response
+		before := w.Header().Clone()
+		rec := &recorder.Recorder{ResponseWriter: w, Body: &bytes.Buffer{}}
+		defer func() {
+			if rec.Status == 0 || rec.Status >= http.StatusInternalServerError {
+				h.idempotency.Release(key)
+				return
+			}
+			h.idempotency.Complete(key, &idempotency.Response{
+				Status: rec.Status,
+				Header: handlerHeaders(before, w.Header()),
+				Body:   rec.Body.Bytes(),
+			})
+		}()
+
+		next.ServeHTTP(rec, r)
+	})
+}
+
+// Headers the handler set or changed. Middleware ahead of it sets per-request
+// headers such as X-Request-ID, RateLimit-* and CORS, which a replay must not repeat.
+func handlerHeaders(before, after http.Header) http.Header {
+	header := make(http.Header)
+	for name, values := range after {
+		if !slices.Equal(before[name], values) {
+			header[name] = slices.Clone(values)
+		}
+	}
+	return header
+}
+
+// Fingerprint of the parts of a request that must match on replay
+func requestFingerprint(r *http.Request, body []byte) string {
+	hash := sha256.New()
+	io.WriteString(hash, r.Method+" "+r.URL.RequestURI()+"\n")
+	hash.Write(body)
+	return hex.EncodeToString(hash.Sum(nil))
+}
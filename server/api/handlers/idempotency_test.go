@@ -0,0 +1,192 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"smit/server/api/auth"
+	"smit/server/api/idempotency"
+)
+
+func TestIdempotentCreateVLAN(t *testing.T) {
+	storage := NewMockStorage()
+	handler := NewHandler(storage, WithIdempotencyStore(idempotency.NewStore(time.Hour, 0)))
+	server := handler.Idempotent(http.HandlerFunc(handler.VLANHandler))
+
+	post := func(key, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("POST", "/api/v1/vlans", strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		if key != "" {
+			req.Header.Set("Idempotency-Key", key)
+		}
+		w := httptest.NewRecorder()
+		server.ServeHTTP(w, req)
+		return w
+	}
+
+	body := `{"name": "Provisioned", "vlan_id": 300, "subnet": "10.3.0.0/24", "gateway": "10.3.0.1", "status": "active"}`
+
+	first := post("run-42", body)
+	if first.Code != http.StatusCreated {
+		t.Fatalf("Expected status %d, got %d", http.StatusCreated, first.Code)
+	}
+
+	replay := post("run-42", body)
+	if replay.Code != http.StatusCreated {
+		t.Fatalf("Expected replayed status %d, got %d", http.StatusCreated, replay.Code)
+	}
+	if replay.Body.String() != first.Body.String() {
+		t.Errorf("Expected replayed body %s, got %s", first.Body.String(), replay.Body.String())
+	}
+	if replay.Header().Get("Idempotent-Replayed") != "true" {
+		t.Error("Expected Idempotent-Replayed header on replay")
+	}
+	if vlans, _ := storage.GetAll(); len(vlans) != 1 {
+		t.Errorf("Expected 1 VLAN after replay, got %d", len(vlans))
+	}
+
+	changed := strings.Replace(body, "Provisioned", "Changed", 1)
+	if w := post("run-42", changed); w.Code != http.StatusUnprocessableEntity {
+		t.Errorf("Expected status %d for a reused key, got %d", http.StatusUnprocessableEntity, w.Code)
+	}
+
+	// Without a key the retry runs again and conflicts
+	if w := post("", body); w.Code != http.StatusConflict {
+		t.Errorf("Expected status %d without a key, got %d", http.StatusConflict, w.Code)
+	}
+
+	if w := post(strings.Repeat("k", 256), body); w.Code != http.StatusBadRequest {
+		t.Errorf("Expected status %d for an overlong key, got %d", http.StatusBadRequest, w.Code)
+	}
+}
+
+func TestIdempotentScopedByCaller(t *testing.T) {
+	handler := NewHandler(NewMockStorage(), WithIdempotencyStore(idempotency.NewStore(time.Hour, 0)))
+	server := handler.Idempotent(http.HandlerFunc(handler.VLANHandler))
+
+	post := func(method, body string) int {
+		req := httptest.NewRequest("POST", "/api/v1/vlans", strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Idempotency-Key", "run-42")
+		req = req.WithContext(auth.WithIdentity(req.Context(), &auth.Identity{Method: method, Subject: "deploy-bot"}))
+		w := httptest.NewRecorder()
+		server.ServeHTTP(w, req)
+		return w.Code
+	}
+
+	if code := post(auth.MethodJWT, `{"name": "Token", "vlan_id": 300, "subnet": "10.3.0.0/24", "gateway": "10.3.0.1", "status": "active"}`); code != http.StatusCreated {
+		t.Fatalf("Expected status %d, got %d", http.StatusCreated, code)
+	}
+	// A certificate with the token's subject as its common name does not share its keys
+	if code := post(auth.MethodCertificate, `{"name": "Certificate", "vlan_id": 301, "subnet": "10.4.0.0/24", "gateway": "10.4.0.1", "status": "active"}`); code != http.StatusCreated {
+		t.Errorf("Expected status %d for the certificate caller, got %d", http.StatusCreated, code)
+	}
+}
+
+func TestIdempotentServerError(t *testing.T) {
+	handler := NewHandler(NewMockStorage(), WithIdempotencyStore(idempotency.NewStore(time.Hour, 0)))
+
+	calls := 0
+	server := handler.Idempotent(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		if calls == 1 {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		w.WriteHeader(http.StatusCreated)
+	}))
+
+	for _, expected := range []int{http.StatusInternalServerError, http.StatusCreated, http.StatusCreated} {
+		req := httptest.NewRequest("POST", "/api/v1/vlans", strings.NewReader(`{}`))
+		req.Header.Set("Idempotency-Key", "retry")
+		w := httptest.NewRecorder()
+		server.ServeHTTP(w, req)
+
+		if w.Code != expected {
+			t.Errorf("Expected status %d, got %d", expected, w.Code)
+		}
+	}
+	if calls != 2 {
+		t.Errorf("Expected the request to run again only after the server error, got %d calls", calls)
+	}
+}
+
+func TestIdempotentBodyTooLarge(t *testing.T) {
+	store := idempotency.NewStore(time.Hour, 0)
+	handler := NewHandler(NewMockStorage(), WithIdempotencyStore(store))
+
+	calls := 0
+	server := handler.Idempotent(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		w.WriteHeader(http.StatusCreated)
+	}))
+
+	send := func(body string) int {
+		req := httptest.NewRequest("POST", "/api/v1/vlans", strings.NewReader(body))
+		req.Header.Set("Idempotency-Key", "large")
+		w := httptest.NewRecorder()
+		server.ServeHTTP(w, req)
+		return w.Code
+	}
+
+	if code := send(strings.Repeat(" ", maxRequestBodySize+1)); code != http.StatusRequestEntityTooLarge {
+		t.Errorf("Expected status %d, got %d", http.StatusRequestEntityTooLarge, code)
+	}
+	// The rejected body takes no key, so a smaller retry runs
+	if code := send(`{}`); code != http.StatusCreated || calls != 1 {
+		t.Errorf("Expected the retry to run, got %d after %d calls", code, calls)
+	}
+}
+
+func TestIdempotentIgnoresOtherMethods(t *testing.T) {
+	handler := NewHandler(NewMockStorage(), WithIdempotencyStore(idempotency.NewStore(time.Hour, 0)))
+
+	calls := 0
+	server := handler.Idempotent(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+	}))
+
+	for i := 0; i < 2; i++ {
+		req := httptest.NewRequest("PUT", "/api/v1/vlans/1", strings.NewReader(`{}`))
+		req.Header.Set("Idempotency-Key", "put")
+		server.ServeHTTP(httptest.NewRecorder(), req)
+	}
+	if calls != 2 {
+		t.Errorf("Expected PUT to bypass idempotency, got %d calls", calls)
+	}
+}
+
+func TestIdempotentReplayHeaders(t *testing.T) {
+	handler := NewHandler(NewMockStorage(), WithIdempotencyStore(idempotency.NewStore(time.Hour, 0)))
+
+	// Middleware ahead of the store sets per-request headers before the handler runs
+	requests := 0
+	server := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		w.Header().Set("X-Request-ID", "request-"+strconv.Itoa(requests))
+		w.Header().Set("RateLimit-Remaining", strconv.Itoa(10-requests))
+		handler.Idempotent(http.HandlerFunc(handler.VLANHandler)).ServeHTTP(w, r)
+	})
+
+	body := `{"name": "Provisioned", "vlan_id": 300, "subnet": "10.3.0.0/24", "gateway": "10.3.0.1", "status": "active"}`
+	var responses []*httptest.ResponseRecorder
+	for i := 0; i < 2; i++ {
+		req := httptest.NewRequest("POST", "/api/v1/vlans", strings.NewReader(body))
+		req.Header.Set("Idempotency-Key", "run-42")
+		w := httptest.NewRecorder()
+		server.ServeHTTP(w, req)
+		responses = append(responses, w)
+	}
+
+	replay := responses[1]
+	if replay.Header().Get("X-Request-ID") != "request-2" || replay.Header().Get("RateLimit-Remaining") != "8" {
+		t.Errorf("Expected the retry's own per-request headers, got %v", replay.Header())
+	}
+	if etag := responses[0].Header().Get("ETag"); etag == "" || replay.Header().Get("ETag") != etag {
+		t.Errorf("Expected the handler's ETag to be replayed, got %q", replay.Header().Get("ETag"))
+	}
+}
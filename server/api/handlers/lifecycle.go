@@ -0,0 +1,127 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"smit/server/api/models"
+	"smit/server/api/rbac"
+	"smit/server/api/storage"
+)
+
+// Handles GET /api/v1/lifecycle
+func (h *Handler) GetLifecycle(w http.ResponseWriter, r *http.Request) {
+	r, span := h.startSpan(r, "GetLifecycle")
+	defer span.End()
+
+	if r.Method != http.MethodGet {
+		h.sendProblem(w, r, problemMethodNotAllowed, "Method not allowed")
+		return
+	}
+	if !h.authorize(w, r, rbac.ActionVLANRead) {
+		return
+	}
+
+	h.sendJSONResponse(w, http.StatusOK, models.CurrentLifecycle())
+}
+
+// Handles GET /api/v1/vlans/{id}/transitions
+func (h *Handler) GetVLANTransitions(w http.ResponseWriter, r *http.Request) {
+	r, span := h.startSpan(r, "GetVLANTransitions")
+	defer span.End()
+
+	if r.Method != http.MethodGet {
+		h.sendProblem(w, r, problemMethodNotAllowed, "Method not allowed")
+		return
+	}
+	if !h.authorize(w, r, rbac.ActionVLANRead) {
+		return
+	}
+
+	id, err := extractIDFromPath(r.URL.Path)
+	if err != nil {
+		h.sendProblem(w, r, problemInvalidID, err.Error())
+		return
+	}
+
+	vlan, err := h.vlanStore(r).GetByID(id)
+	if err != nil {
+		if errors.Is(err, storage.ErrVLANNotFound) {
+			h.sendProblem(w, r, problemVLANNotFound, "VLAN not found")
+			return
+		}
+		h.sendProblem(w, r, problemInternal, "Failed to retrieve VLAN")
+		return
+	}
+
+	history := vlan.StatusHistory
+	if history == nil {
+		history = []models.StatusChange{}
+	}
+
+	h.sendJSONResponse(w, http.StatusOK, history)
+}
+
+// Handles POST /api/v1/vlans/{id}/transitions
+func (h *Handler) TransitionVLAN(w http.ResponseWriter, r *http.Request) {
+	r, span := h.startSpan(r, "TransitionVLAN")
+	defer span.End()
+
+	if r.Method != http.MethodPost {
+		h.sendProblem(w, r, problemMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	id, err := extractIDFromPath(r.URL.Path)
+	if err != nil {
+		h.sendProblem(w, r, problemInvalidID, err.Error())
+		return
+	}
+
+	var input models.TransitionInput
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		h.sendProblem(w, r, problemInvalidBody, "Invalid request body")
+		return
+	}
+
+	// Validate input
+	if err := input.Validate(); err != nil {
+		h.sendValidationError(w, r, err)
+		return
+	}
+
+	current, err := h.vlanStore(r).GetByID(id)
+	if err != nil {
+		if errors.Is(err, storage.ErrVLANNotFound) {
+			h.sendProblem(w, r, problemVLANNotFound, "VLAN not found")
+			return
+		}
+		h.sendProblem(w, r, problemInternal, "Failed to retrieve VLAN")
+		return
+	}
+
+	// A transition is an update of the status field only
+	change := current.Input()
+	change.Status = input.Status
+	if !h.authorizeVLAN(w, r, rbac.ActionVLANUpdate, current, &change) {
+		return
+	}
+
+	// The storage checks the transition against the status it holds
+	vlan, err := h.vlanStore(r).UpdateStatus(id, models.StatusChange{To: input.Status, Reason: input.Reason, ChangedBy: actor(r)})
+	if err != nil {
+		if errors.Is(err, storage.ErrVLANNotFound) {
+			h.sendProblem(w, r, problemVLANNotFound, "VLAN not found")
+			return
+		}
+		if errors.Is(err, storage.ErrInvalidTransition) {
+			h.sendProblem(w, r, problemInvalidTransition, err.Error())
+			return
+		}
+		h.sendProblem(w, r, problemInternal, "Failed to update VLAN status")
+		return
+	}
+
+	h.sendVLAN(w, http.StatusOK, vlan)
+}
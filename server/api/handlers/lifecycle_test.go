@@ -0,0 +1,164 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"smit/server/api/models"
+)
+
+func TestTransitionVLAN(t *testing.T) {
+	storage := NewMockStorage()
+	handler := NewHandler(storage)
+
+	storage.Create(&models.VLANInput{
+		Name:    "Test VLAN",
+		VlanID:  100,
+		Subnet:  "192.168.100.0/24",
+		Gateway: "192.168.100.1",
+		Status:  "active",
+	})
+
+	body := []byte(`{"status": "maintenance", "reason": "Switch firmware upgrade"}`)
+	req := httptest.NewRequest("POST", "/api/v1/vlans/1/transitions", bytes.NewReader(body))
+	w := httptest.NewRecorder()
+
+	handler.VLANHandler(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status %d, got %d: %s", http.StatusOK, w.Code, w.Body.String())
+	}
+
+	// The change is recorded with its reason
+	req = httptest.NewRequest("GET", "/api/v1/vlans/1/transitions", nil)
+	w = httptest.NewRecorder()
+
+	handler.VLANHandler(w, req)
+
+	var history []models.StatusChange
+	if err := json.NewDecoder(w.Body).Decode(&history); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if len(history) != 1 {
+		t.Fatalf("Expected 1 status change, got %d", len(history))
+	}
+	if history[0].From != "active" || history[0].To != "maintenance" || history[0].Reason != "Switch firmware upgrade" {
+		t.Errorf("Unexpected status change: %+v", history[0])
+	}
+}
+
+func TestTransitionVLANErrors(t *testing.T) {
+	storage := NewMockStorage()
+	handler := NewHandler(storage)
+
+	storage.Create(&models.VLANInput{
+		Name:    "Test VLAN",
+		VlanID:  100,
+		Subnet:  "192.168.100.0/24",
+		Gateway: "192.168.100.1",
+		Status:  "active",
+	})
+
+	tests := []struct {
+		name     string
+		path     string
+		body     string
+		expected int
+	}{
+		{"Disallowed transition", "/api/v1/vlans/1/transitions", `{"status": "planned", "reason": "Replan"}`, http.StatusConflict},
+		{"Same status", "/api/v1/vlans/1/transitions", `{"status": "active", "reason": "No-op"}`, http.StatusConflict},
+		{"Missing reason", "/api/v1/vlans/1/transitions", `{"status": "maintenance"}`, http.StatusBadRequest},
+		{"Unknown status", "/api/v1/vlans/1/transitions", `{"status": "gone", "reason": "Test"}`, http.StatusBadRequest},
+		{"Not found", "/api/v1/vlans/999/transitions", `{"status": "maintenance", "reason": "Test"}`, http.StatusNotFound},
+		{"Invalid body", "/api/v1/vlans/1/transitions", `invalid`, http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("POST", tt.path, bytes.NewReader([]byte(tt.body)))
+			w := httptest.NewRecorder()
+
+			handler.VLANHandler(w, req)
+
+			if w.Code != tt.expected {
+				t.Errorf("Expected status %d, got %d: %s", tt.expected, w.Code, w.Body.String())
+			}
+		})
+	}
+}
+
+func TestUpdateVLANDisallowedTransition(t *testing.T) {
+	storage := NewMockStorage()
+	handler := NewHandler(storage)
+
+	storage.Create(&models.VLANInput{
+		Name:    "Test VLAN",
+		VlanID:  100,
+		Subnet:  "192.168.100.0/24",
+		Gateway: "192.168.100.1",
+		Status:  "decommissioning",
+	})
+
+	update := models.VLANInput{
+		Name:    "Test VLAN",
+		VlanID:  100,
+		Subnet:  "192.168.100.0/24",
+		Gateway: "192.168.100.1",
+		Status:  "active",
+	}
+
+	body, _ := json.Marshal(update)
+	req := httptest.NewRequest("PUT", "/api/v1/vlans/1", bytes.NewReader(body))
+	w := httptest.NewRecorder()
+
+	handler.UpdateVLAN(w, req)
+
+	if w.Code != http.StatusConflict {
+		t.Errorf("Expected status %d, got %d", http.StatusConflict, w.Code)
+	}
+}
+
+func TestDeleteActiveVLAN(t *testing.T) {
+	storage := NewMockStorage()
+	handler := NewHandler(storage)
+
+	storage.Create(&models.VLANInput{
+		Name:    "Test VLAN",
+		VlanID:  100,
+		Subnet:  "192.168.100.0/24",
+		Gateway: "192.168.100.1",
+		Status:  "active",
+	})
+
+	req := httptest.NewRequest("DELETE", "/api/v1/vlans/1", nil)
+	w := httptest.NewRecorder()
+
+	handler.DeleteVLAN(w, req)
+
+	if w.Code != http.StatusConflict {
+		t.Errorf("Expected status %d, got %d", http.StatusConflict, w.Code)
+	}
+	if _, err := storage.GetByID(1); err != nil {
+		t.Error("Active VLAN was deleted")
+	}
+}
+
+func TestGetLifecycle(t *testing.T) {
+	handler := NewHandler(NewMockStorage())
+
+	req := httptest.NewRequest("GET", "/api/v1/lifecycle", nil)
+	w := httptest.NewRecorder()
+
+	handler.GetLifecycle(w, req)
+
+	var lifecycle models.Lifecycle
+	if err := json.NewDecoder(w.Body).Decode(&lifecycle); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if !lifecycle.IsValidState("decommissioning") {
+		t.Errorf("Expected default lifecycle, got %+v", lifecycle)
+	}
+}
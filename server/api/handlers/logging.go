@@ -0,0 +1,121 @@
+package handlers
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"smit/server/api/auth"
+	"smit/server/api/recorder"
+	"smit/server/api/tracing"
+)
+
+// Request and response header carrying the request ID
+const requestIDHeader = "X-Request-ID"
+
+// Longest request ID accepted from a client
+const maxRequestIDLength = 128
+
+type requestLogKey struct{}
+
+// What the request log records beyond the request itself; filled in as the
+// request passes through the middleware
+type requestLog struct {
+	id       string
+	identity *auth.Identity
+}
+
+// Give every request an ID and log it once it completes. The client's X-Request-ID
+// is kept when it is reasonable, so IDs can be traced through proxies; otherwise a
+// new one is generated. The ID is echoed in the X-Request-ID response header and
+// in error bodies, and requests inside a trace also log the trace ID. Health checks
+// are logged at debug level so probes do not flood the log. Nothing is logged
+// without a logger, but IDs are still assigned.
+func (h *Handler) LogRequests(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+
+		entry := &requestLog{id: r.Header.Get(requestIDHeader)}
+		if !validRequestID(entry.id) {
+			entry.id = newRequestID()
+		}
+		w.Header().Set(requestIDHeader, entry.id)
+		span := tracing.SpanFromContext(r.Context())
+		span.SetAttributes(tracing.String("http.request.id", entry.id))
+
+		rec := recorder.New(w)
+		next.ServeHTTP(rec, r.WithContext(context.WithValue(r.Context(), requestLogKey{}, entry)))
+
+		if h.logger == nil {
+			return
+		}
+
+		status := rec.StatusCode()
+		level := slog.LevelInfo
+		switch {
+		case status >= http.StatusInternalServerError:
+			level = slog.LevelError
+		case publicPaths[r.URL.Path]:
+			level = slog.LevelDebug
+		}
+
+		attrs := []slog.Attr{
+			slog.String("request_id", entry.id),
+			slog.String("method", r.Method),
+			slog.String("path", r.URL.Path),
+			slog.Int("status", status),
+			slog.Float64("latency_ms", float64(time.Since(start).Microseconds())/1000),
+			slog.Int64("bytes", rec.Bytes),
+			slog.String("remote", h.clientAddress(r)),
+		}
+		if span != nil {
+			attrs = append(attrs, slog.String("trace_id", span.Context().TraceID.String()))
+		}
+		if entry.identity != nil {
+			attrs = append(attrs, slog.String("subject", entry.identity.Subject))
+			if entry.identity.KeyID != 0 {
+				attrs = append(attrs, slog.Int("key_id", entry.identity.KeyID))
+			}
+		}
+		h.logger.LogAttrs(r.Context(), level, "request", attrs...)
+	})
+}
+
+// ID of the request, empty outside the logging middleware
+func requestID(r *http.Request) string {
+	if entry, ok := r.Context().Value(requestLogKey{}).(*requestLog); ok {
+		return entry.id
+	}
+	return ""
+}
+
+// Request carrying the caller's identity, which is also noted for the request log
+func withIdentity(r *http.Request, identity *auth.Identity) *http.Request {
+	if entry, ok := r.Context().Value(requestLogKey{}).(*requestLog); ok {
+		entry.identity = identity
+	}
+	return r.WithContext(auth.WithIdentity(r.Context(), identity))
+}
+
+// Check a client-supplied request ID: printable ASCII without spaces, of bounded length
+func validRequestID(id string) bool {
+	if id == "" || len(id) > maxRequestIDLength {
+		return false
+	}
+	for i := 0; i < len(id); i++ {
+		if id[i] <= ' ' || id[i] > '~' {
+			return false
+		}
+	}
+	return true
+}
+
+// Random 128-bit request ID
+func newRequestID() string {
+	b := make([]byte, 16)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
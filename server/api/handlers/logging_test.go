@@ -0,0 +1,130 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"smit/server/api/auth"
+	"smit/server/api/models"
+)
+
+func TestLogRequests(t *testing.T) {
+	keys, _ := auth.LoadKeyStore("")
+	created, _ := keys.Create(&models.APIKeyInput{Name: "ci"})
+
+	var out bytes.Buffer
+	logger := slog.New(slog.NewJSONHandler(&out, &slog.HandlerOptions{Level: slog.LevelInfo}))
+	handler := NewHandler(NewMockStorage(), WithAPIKeys(keys), WithLogger(logger))
+	server := handler.LogRequests(handler.Authenticate(http.HandlerFunc(handler.VLANHandler)))
+
+	send := func(path, key, requestID string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", path, nil)
+		req.RemoteAddr = "192.0.2.10:40000"
+		if key != "" {
+			req.Header.Set("X-API-Key", key)
+		}
+		if requestID != "" {
+			req.Header.Set("X-Request-ID", requestID)
+		}
+		w := httptest.NewRecorder()
+		server.ServeHTTP(w, req)
+		return w
+	}
+
+	// The client's ID is kept and the caller is logged
+	w := send("/api/v1/vlans", created.Key, "trace-0001")
+	if w.Code != http.StatusOK || w.Header().Get("X-Request-ID") != "trace-0001" {
+		t.Fatalf("Expected status 200 with the client's request ID, got %d and %q", w.Code, w.Header().Get("X-Request-ID"))
+	}
+
+	var entry map[string]interface{}
+	if err := json.Unmarshal(out.Bytes(), &entry); err != nil {
+		t.Fatalf("Expected one JSON log line, got %q", out.String())
+	}
+	for field, expected := range map[string]interface{}{
+		"msg":        "request",
+		"level":      "INFO",
+		"request_id": "trace-0001",
+		"method":     "GET",
+		"path":       "/api/v1/vlans",
+		"status":     float64(200),
+		"bytes":      float64(w.Body.Len()),
+		"remote":     "192.0.2.10",
+		"subject":    "ci",
+		"key_id":     float64(created.ID),
+	} {
+		if entry[field] != expected {
+			t.Errorf("Expected %s %v, got %v", field, expected, entry[field])
+		}
+	}
+	if _, ok := entry["latency_ms"]; !ok {
+		t.Error("Expected latency_ms in the log line")
+	}
+
+	// Errors carry a generated ID in the header and the body
+	out.Reset()
+	w = send("/api/v1/vlans", "", "bad id with spaces")
+	id := w.Header().Get("X-Request-ID")
+	if len(id) != 32 {
+		t.Fatalf("Expected a generated request ID, got %q", id)
+	}
+	var problem models.Problem
+	json.NewDecoder(w.Body).Decode(&problem)
+	if problem.RequestID != id {
+		t.Errorf("Expected request_id %q in the problem, got %q", id, problem.RequestID)
+	}
+	if !strings.Contains(out.String(), `"status":401`) || strings.Contains(out.String(), "subject") {
+		t.Errorf("Expected an anonymous 401 to be logged, got %s", out.String())
+	}
+
+	// Health checks are only logged at debug level
+	out.Reset()
+	if w := send("/health", "", ""); w.Header().Get("X-Request-ID") == "" {
+		t.Error("Expected a request ID on health checks")
+	}
+	if out.Len() != 0 {
+		t.Errorf("Expected no info log for a health check, got %s", out.String())
+	}
+}
+
+func TestLegacyErrorRequestID(t *testing.T) {
+	handler := NewHandler(NewMockStorage(), WithLegacyErrors(true))
+	server := handler.LogRequests(http.HandlerFunc(handler.VLANHandler))
+
+	req := httptest.NewRequest("GET", "/api/v1/vlans/999", nil)
+	req.Header.Set("X-Request-ID", "trace-0002")
+	w := httptest.NewRecorder()
+	server.ServeHTTP(w, req)
+
+	var response models.ErrorResponse
+	json.NewDecoder(w.Body).Decode(&response)
+	if w.Code != http.StatusNotFound || response.RequestID != "trace-0002" {
+		t.Errorf("Expected a 404 with request_id trace-0002, got %d and %+v", w.Code, response)
+	}
+}
+
+func TestValidRequestID(t *testing.T) {
+	tests := []struct {
+		id       string
+		expected bool
+	}{
+		{"3f2b9c1e-7d4a-4e0b-9a61-2c5d8e7f1a34", true},
+		{"trace:0001/retry", true},
+		{"", false},
+		{"has space", false},
+		{"line\nbreak", false},
+		{"ünicode", false},
+		{strings.Repeat("a", 129), false},
+	}
+
+	for _, tt := range tests {
+		if valid := validRequestID(tt.id); valid != tt.expected {
+			t.Errorf("validRequestID(%q) = %v, expected %v", tt.id, valid, tt.expected)
+		}
+	}
+}
@@ -0,0 +1,83 @@
+package handlers
+
+import (
+	"net/http"
+	"net/netip"
+	"strings"
+	"sync"
+
+	"smit/server/api/ipam"
+	"smit/server/api/models"
+	"smit/server/api/rbac"
+)
+
+// Handles GET /api/v1/lookup/ip/{address}
+func (h *Handler) LookupIP(w http.ResponseWriter, r *http.Request) {
+	r, span := h.startSpan(r, "LookupIP")
+	defer span.End()
+
+	if r.Method != http.MethodGet {
+		h.sendProblem(w, r, problemMethodNotAllowed, "Method not allowed")
+		return
+	}
+	if !h.authorize(w, r, rbac.ActionVLANRead) {
+		return
+	}
+
+	value := strings.TrimPrefix(r.URL.Path, "/api/v1/lookup/ip/")
+	addr, err := netip.ParseAddr(value)
+	if err != nil {
+		h.sendProblem(w, r, problemInvalidParameter, "address must be an IPv4 or IPv6 address")
+		return
+	}
+	addr = addr.WithZone("").Unmap()
+
+	index, err := h.vlanIndex(r)
+	if err != nil {
+		h.sendProblem(w, r, problemInternal, "Failed to retrieve VLANs")
+		return
+	}
+
+	result := models.IPLookupResult{
+		Address: addr.String(),
+		Matches: ipam.LookupIP(index, addr),
+	}
+
+	h.sendJSONResponse(w, http.StatusOK, result)
+}
+
+// Subnet index of the VLANs and the last event it reflects
+type lookupIndex struct {
+	mu      sync.Mutex
+	version uint64
+	index   *ipam.PrefixTrie[models.VLANModel]
+}
+
+// Index of the VLAN subnets. With an event broker the index is kept and rebuilt
+// only after a VLAN write; without one it is built for every lookup.
+func (h *Handler) vlanIndex(r *http.Request) (*ipam.PrefixTrie[models.VLANModel], error) {
+	if h.events == nil {
+		vlans, err := h.vlanStore(r).GetAll()
+		if err != nil {
+			return nil, err
+		}
+		return ipam.VLANIndex(vlans), nil
+	}
+
+	h.lookup.mu.Lock()
+	defer h.lookup.mu.Unlock()
+
+	// Read the version before the VLANs, so a write in between triggers another rebuild
+	version := h.events.LastID()
+	if h.lookup.index != nil && h.lookup.version == version {
+		return h.lookup.index, nil
+	}
+
+	vlans, err := h.vlanStore(r).GetAll()
+	if err != nil {
+		return nil, err
+	}
+	h.lookup.index = ipam.VLANIndex(vlans)
+	h.lookup.version = version
+	return h.lookup.index, nil
+}
@@ -0,0 +1,123 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"smit/server/api/events"
+	"smit/server/api/models"
+)
+
+func TestLookupIP(t *testing.T) {
+	storage := NewMockStorage()
+	handler := NewHandler(storage)
+
+	storage.Create(&models.VLANInput{Name: "Site", VlanID: 100, Subnet: "10.4.0.0/16", Gateway: "10.4.0.1", Status: "active"})
+	storage.Create(&models.VLANInput{Name: "Servers", VlanID: 117, Subnet: "10.4.17.0/24", Gateway: "10.4.17.1", Status: "active"})
+	storage.Create(&models.VLANInput{Name: "IPv6", VlanID: 200, Subnet: "2001:db8:4::/48", Gateway: "10.4.17.1", Status: "active"})
+
+	tests := []struct {
+		name     string
+		path     string
+		expected int
+		vlans    []string
+	}{
+		{"Most specific first", "/api/v1/lookup/ip/10.4.17.23", http.StatusOK, []string{"Servers", "Site"}},
+		{"Single match", "/api/v1/lookup/ip/10.4.200.1", http.StatusOK, []string{"Site"}},
+		{"No match", "/api/v1/lookup/ip/192.0.2.1", http.StatusOK, nil},
+		{"IPv6", "/api/v1/lookup/ip/2001:db8:4::10", http.StatusOK, []string{"IPv6"}},
+		{"Invalid address", "/api/v1/lookup/ip/10.4.17", http.StatusBadRequest, nil},
+		{"Missing address", "/api/v1/lookup/ip/", http.StatusBadRequest, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("GET", tt.path, nil)
+			w := httptest.NewRecorder()
+
+			handler.LookupIP(w, req)
+
+			if w.Code != tt.expected {
+				t.Fatalf("Expected status %d, got %d", tt.expected, w.Code)
+			}
+			if w.Code != http.StatusOK {
+				return
+			}
+
+			var result models.IPLookupResult
+			if err := json.NewDecoder(w.Body).Decode(&result); err != nil {
+				t.Fatalf("Failed to decode response: %v", err)
+			}
+			if len(result.Matches) != len(tt.vlans) {
+				t.Fatalf("Expected %d matches, got %d", len(tt.vlans), len(result.Matches))
+			}
+			for i, name := range tt.vlans {
+				if result.Matches[i].VLAN.Name != name {
+					t.Errorf("Expected match %d to be %s, got %s", i, name, result.Matches[i].VLAN.Name)
+				}
+			}
+		})
+	}
+
+	req := httptest.NewRequest("POST", "/api/v1/lookup/ip/10.4.17.23", nil)
+	w := httptest.NewRecorder()
+	handler.LookupIP(w, req)
+	if w.Code != http.StatusMethodNotAllowed {
+		t.Errorf("Expected status %d, got %d", http.StatusMethodNotAllowed, w.Code)
+	}
+}
+
+func TestLookupIPIndex(t *testing.T) {
+	mock := NewMockStorage()
+	broker := events.NewBroker(10)
+	handler := NewHandler(events.NewStorage(mock, broker), WithEventBroker(broker))
+
+	lookup := func(address string) int {
+		w := httptest.NewRecorder()
+		handler.LookupIP(w, httptest.NewRequest("GET", "/api/v1/lookup/ip/"+address, nil))
+		var result models.IPLookupResult
+		json.NewDecoder(w.Body).Decode(&result)
+		return len(result.Matches)
+	}
+
+	if n := lookup("10.4.17.23"); n != 0 {
+		t.Fatalf("Expected no matches, got %d", n)
+	}
+
+	// A write through the storage publishes an event, which rebuilds the index
+	handler.storage.Create(&models.VLANInput{Name: "Servers", VlanID: 117, Subnet: "10.4.17.0/24", Gateway: "10.4.17.1", Status: "active"})
+	if n := lookup("10.4.17.23"); n != 1 {
+		t.Fatalf("Expected the new VLAN to match, got %d matches", n)
+	}
+
+	// Without an event the kept index is used
+	mock.Create(&models.VLANInput{Name: "Site", VlanID: 100, Subnet: "10.4.0.0/16", Gateway: "10.4.0.1", Status: "active"})
+	if n := lookup("10.4.17.23"); n != 1 {
+		t.Errorf("Expected the kept index to be used, got %d matches", n)
+	}
+}
+
+func TestLookupIPv6VLAN(t *testing.T) {
+	handler := NewHandler(NewMockStorage())
+
+	body := `{"name": "IPv6 servers", "vlan_id": 600, "subnet": "2001:db8:6::/64", "gateway": "2001:db8:6::1", "status": "active"}`
+	w := httptest.NewRecorder()
+	handler.CreateVLAN(w, httptest.NewRequest("POST", "/api/v1/vlans", strings.NewReader(body)))
+	if w.Code != http.StatusCreated {
+		t.Fatalf("Expected status %d, got %d: %s", http.StatusCreated, w.Code, w.Body)
+	}
+
+	w = httptest.NewRecorder()
+	handler.LookupIP(w, httptest.NewRequest("GET", "/api/v1/lookup/ip/2001:db8:6::abcd", nil))
+
+	var result models.IPLookupResult
+	if err := json.NewDecoder(w.Body).Decode(&result); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if len(result.Matches) != 1 || result.Matches[0].VLAN.Name != "IPv6 servers" {
+		t.Errorf("Expected the IPv6 VLAN to match, got %+v", result.Matches)
+	}
+}
@@ -0,0 +1,239 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"smit/server/api/models"
+	"smit/server/api/rbac"
+	"smit/server/api/storage"
+)
+
+// Default look-ahead of the upcoming maintenance listing
+const defaultMaintenanceHorizonDays = 7
+
+// Handles GET /api/v1/maintenance
+func (h *Handler) GetUpcomingMaintenance(w http.ResponseWriter, r *http.Request) {
+	r, span := h.startSpan(r, "GetUpcomingMaintenance")
+	defer span.End()
+
+	if r.Method != http.MethodGet {
+		h.sendProblem(w, r, problemMethodNotAllowed, "Method not allowed")
+		return
+	}
+	if !h.authorize(w, r, rbac.ActionMaintenanceRead) {
+		return
+	}
+
+	days := defaultMaintenanceHorizonDays
+	if value := r.URL.Query().Get("days"); value != "" {
+		parsed, err := strconv.Atoi(value)
+		if err != nil || parsed < 1 || parsed > 366 {
+			h.sendProblem(w, r, problemInvalidParameter, "days must be an integer between 1 and 366")
+			return
+		}
+		days = parsed
+	}
+
+	windows, err := h.maintenance.GetAllMaintenanceWindows()
+	if err != nil {
+		h.sendProblem(w, r, problemInternal, "Failed to retrieve maintenance windows")
+		return
+	}
+
+	horizon := time.Duration(days) * 24 * time.Hour
+	h.sendJSONResponse(w, http.StatusOK, models.UpcomingMaintenance(windows, time.Now(), horizon))
+}
+
+// Handles GET /api/v1/maintenance/windows
+func (h *Handler) GetMaintenanceWindows(w http.ResponseWriter, r *http.Request) {
+	r, span := h.startSpan(r, "GetMaintenanceWindows")
+	defer span.End()
+
+	if r.Method != http.MethodGet {
+		h.sendProblem(w, r, problemMethodNotAllowed, "Method not allowed")
+		return
+	}
+	if !h.authorize(w, r, rbac.ActionMaintenanceRead) {
+		return
+	}
+
+	windows, err := h.maintenance.GetAllMaintenanceWindows()
+	if err != nil {
+		h.sendProblem(w, r, problemInternal, "Failed to retrieve maintenance windows")
+		return
+	}
+
+	h.sendJSONResponse(w, http.StatusOK, windows)
+}
+
+// Handles POST /api/v1/maintenance
+func (h *Handler) CreateMaintenanceWindow(w http.ResponseWriter, r *http.Request) {
+	r, span := h.startSpan(r, "CreateMaintenanceWindow")
+	defer span.End()
+
+	if r.Method != http.MethodPost {
+		h.sendProblem(w, r, problemMethodNotAllowed, "Method not allowed")
+		return
+	}
+	if !h.authorize(w, r, rbac.ActionMaintenanceWrite) {
+		return
+	}
+
+	var input models.MaintenanceWindowInput
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		h.sendProblem(w, r, problemInvalidBody, "Invalid request body")
+		return
+	}
+
+	// Validate input
+	if err := input.Validate(); err != nil {
+		h.sendValidationError(w, r, err)
+		return
+	}
+
+	// Every attached VLAN must exist
+	var errs models.ValidationErrors
+	for _, id := range input.VLANRecordIDs {
+		if _, err := h.vlanStore(r).GetByID(id); err != nil {
+			if errors.Is(err, storage.ErrVLANNotFound) {
+				errs.Add("vlan_record_ids", models.CodeInvalidValue, fmt.Sprintf("VLAN record %d does not exist", id))
+				continue
+			}
+			h.sendProblem(w, r, problemInternal, "Failed to retrieve VLAN")
+			return
+		}
+	}
+	if err := errs.Err(); err != nil {
+		h.sendValidationError(w, r, err)
+		return
+	}
+
+	window, err := h.maintenance.CreateMaintenanceWindow(&input)
+	if err != nil {
+		h.sendProblem(w, r, problemInternal, "Failed to create maintenance window")
+		return
+	}
+
+	h.sendJSONResponse(w, http.StatusCreated, window)
+}
+
+// Handles GET /api/v1/maintenance/{id}
+func (h *Handler) GetMaintenanceWindow(w http.ResponseWriter, r *http.Request) {
+	r, span := h.startSpan(r, "GetMaintenanceWindow")
+	defer span.End()
+
+	if r.Method != http.MethodGet {
+		h.sendProblem(w, r, problemMethodNotAllowed, "Method not allowed")
+		return
+	}
+	if !h.authorize(w, r, rbac.ActionMaintenanceRead) {
+		return
+	}
+
+	id, err := extractIDFromPath(r.URL.Path)
+	if err != nil {
+		h.sendProblem(w, r, problemInvalidID, err.Error())
+		return
+	}
+
+	window, err := h.maintenance.GetMaintenanceWindowByID(id)
+	if err != nil {
+		if errors.Is(err, storage.ErrMaintenanceWindowNotFound) {
+			h.sendProblem(w, r, problemMaintenanceNotFound, "Maintenance window not found")
+			return
+		}
+		h.sendProblem(w, r, problemInternal, "Failed to retrieve maintenance window")
+		return
+	}
+
+	h.sendJSONResponse(w, http.StatusOK, window)
+}
+
+// Handles DELETE /api/v1/maintenance/{id}
+func (h *Handler) DeleteMaintenanceWindow(w http.ResponseWriter, r *http.Request) {
+	r, span := h.startSpan(r, "DeleteMaintenanceWindow")
+	defer span.End()
+
+	if r.Method != http.MethodDelete {
+		h.sendProblem(w, r, problemMethodNotAllowed, "Method not allowed")
+		return
+	}
+	if !h.authorize(w, r, rbac.ActionMaintenanceWrite) {
+		return
+	}
+
+	id, err := extractIDFromPath(r.URL.Path)
+	if err != nil {
+		h.sendProblem(w, r, problemInvalidID, err.Error())
+		return
+	}
+
+	// Deleting a window in progress would leave its VLANs in maintenance
+	if err := h.maintenance.DeleteMaintenanceWindow(id); err != nil {
+		if errors.Is(err, storage.ErrMaintenanceWindowNotFound) {
+			h.sendProblem(w, r, problemMaintenanceNotFound, "Maintenance window not found")
+			return
+		}
+		if errors.Is(err, storage.ErrMaintenanceInProgress) {
+			h.sendProblem(w, r, problemMaintenanceInProgress, "Maintenance window is in progress, delete it after it ends")
+			return
+		}
+		h.sendProblem(w, r, problemInternal, "Failed to delete maintenance window")
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// Handler for maintenance endpoints
+func (h *Handler) MaintenanceHandler(w http.ResponseWriter, r *http.Request) {
+	r, span := h.startSpan(r, "MaintenanceHandler")
+	defer span.End()
+
+	if h.maintenance == nil {
+		h.sendProblem(w, r, problemNotImplemented, "Maintenance storage is not configured")
+		return
+	}
+
+	path := r.URL.Path
+
+	// Handle /api/v1/maintenance
+	if path == "/api/v1/maintenance" {
+		switch r.Method {
+		case http.MethodGet:
+			h.GetUpcomingMaintenance(w, r)
+		case http.MethodPost:
+			h.CreateMaintenanceWindow(w, r)
+		default:
+			h.sendProblem(w, r, problemMethodNotAllowed, "Method not allowed")
+		}
+		return
+	}
+
+	// Handle /api/v1/maintenance/windows
+	if path == "/api/v1/maintenance/windows" {
+		h.GetMaintenanceWindows(w, r)
+		return
+	}
+
+	// Handle /api/v1/maintenance/{id}
+	if strings.HasPrefix(path, "/api/v1/maintenance/") {
+		switch r.Method {
+		case http.MethodGet:
+			h.GetMaintenanceWindow(w, r)
+		case http.MethodDelete:
+			h.DeleteMaintenanceWindow(w, r)
+		default:
+			h.sendProblem(w, r, problemMethodNotAllowed, "Method not allowed")
+		}
+		return
+	}
+
+	h.sendProblem(w, r, problemEndpointNotFound, "Endpoint not found")
+}
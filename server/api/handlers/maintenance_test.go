@@ -0,0 +1,178 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"smit/server/api/models"
+	"smit/server/api/storage"
+)
+
+// MockMaintenanceStorage implements the storage.MaintenanceStorage interface for testing
+type MockMaintenanceStorage struct {
+	windows []models.MaintenanceWindow
+}
+
+func (m *MockMaintenanceStorage) GetAllMaintenanceWindows() ([]models.MaintenanceWindow, error) {
+	return m.windows, nil
+}
+
+func (m *MockMaintenanceStorage) GetMaintenanceWindowByID(id int) (*models.MaintenanceWindow, error) {
+	for _, window := range m.windows {
+		if window.ID == id {
+			return &window, nil
+		}
+	}
+	return nil, storage.ErrMaintenanceWindowNotFound
+}
+
+func (m *MockMaintenanceStorage) CreateMaintenanceWindow(input *models.MaintenanceWindowInput) (*models.MaintenanceWindow, error) {
+	window := models.MaintenanceWindow{
+		ID:            len(m.windows) + 1,
+		Name:          input.Name,
+		VLANRecordIDs: input.VLANRecordIDs,
+		Start:         input.Start,
+		End:           input.End,
+		Timezone:      input.Timezone,
+		Recurrence:    input.Recurrence,
+		Until:         input.Until,
+		CreatedAt:     time.Now(),
+		UpdatedAt:     time.Now(),
+	}
+	m.windows = append(m.windows, window)
+	return &window, nil
+}
+
+func (m *MockMaintenanceStorage) SetMaintenanceApplied(id int, applied map[int]string) error {
+	for i, window := range m.windows {
+		if window.ID == id {
+			m.windows[i].Applied = applied
+			return nil
+		}
+	}
+	return storage.ErrMaintenanceWindowNotFound
+}
+
+func (m *MockMaintenanceStorage) DeleteMaintenanceWindow(id int) error {
+	for i, window := range m.windows {
+		if window.ID == id {
+			if len(window.Applied) > 0 {
+				return storage.ErrMaintenanceInProgress
+			}
+			m.windows = append(m.windows[:i], m.windows[i+1:]...)
+			return nil
+		}
+	}
+	return storage.ErrMaintenanceWindowNotFound
+}
+
+func TestMaintenanceHandler(t *testing.T) {
+	vlans := NewMockStorage()
+	vlans.Create(&models.VLANInput{Name: "VLAN", VlanID: 100, Subnet: "10.0.0.0/24", Gateway: "10.0.0.1", Status: "active"})
+	windows := &MockMaintenanceStorage{}
+	handler := NewHandler(vlans, WithMaintenanceStorage(windows))
+
+	start := time.Now().UTC().Add(time.Hour)
+	body := func(vlanID int) string {
+		return `{"name": "Upgrade", "vlan_record_ids": [` + strconv.Itoa(vlanID) + `], "timezone": "UTC", ` +
+			`"start": "` + start.Format(models.MaintenanceTimeLayout) + `", ` +
+			`"end": "` + start.Add(2*time.Hour).Format(models.MaintenanceTimeLayout) + `"}`
+	}
+
+	tests := []struct {
+		name     string
+		method   string
+		path     string
+		body     string
+		expected int
+	}{
+		{"Create window", "POST", "/api/v1/maintenance", body(1), http.StatusCreated},
+		{"Create window for missing VLAN", "POST", "/api/v1/maintenance", body(99), http.StatusBadRequest},
+		{"Create invalid window", "POST", "/api/v1/maintenance", `{"name": "Upgrade"}`, http.StatusBadRequest},
+		{"Upcoming maintenance", "GET", "/api/v1/maintenance", "", http.StatusOK},
+		{"Upcoming with invalid days", "GET", "/api/v1/maintenance?days=0", "", http.StatusBadRequest},
+		{"List windows", "GET", "/api/v1/maintenance/windows", "", http.StatusOK},
+		{"Get window", "GET", "/api/v1/maintenance/1", "", http.StatusOK},
+		{"Get missing window", "GET", "/api/v1/maintenance/2", "", http.StatusNotFound},
+		{"Method not allowed", "PUT", "/api/v1/maintenance/1", "", http.StatusMethodNotAllowed},
+		{"Delete window", "DELETE", "/api/v1/maintenance/1", "", http.StatusNoContent},
+		{"Delete missing window", "DELETE", "/api/v1/maintenance/1", "", http.StatusNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, tt.path, bytes.NewReader([]byte(tt.body)))
+			w := httptest.NewRecorder()
+
+			handler.MaintenanceHandler(w, req)
+
+			if w.Code != tt.expected {
+				t.Errorf("Expected status %d, got %d: %s", tt.expected, w.Code, w.Body.String())
+			}
+		})
+	}
+}
+
+func TestGetUpcomingMaintenance(t *testing.T) {
+	now := time.Now().UTC()
+	windows := &MockMaintenanceStorage{windows: []models.MaintenanceWindow{
+		{ID: 1, Name: "Running", VLANRecordIDs: []int{1}, Timezone: "UTC",
+			Start: now.Add(-time.Hour).Format(models.MaintenanceTimeLayout),
+			End:   now.Add(time.Hour).Format(models.MaintenanceTimeLayout)},
+		{ID: 2, Name: "Far away", VLANRecordIDs: []int{1}, Timezone: "UTC",
+			Start: now.Add(30 * 24 * time.Hour).Format(models.MaintenanceTimeLayout),
+			End:   now.Add(31 * 24 * time.Hour).Format(models.MaintenanceTimeLayout)},
+	}}
+	handler := NewHandler(NewMockStorage(), WithMaintenanceStorage(windows))
+
+	req := httptest.NewRequest("GET", "/api/v1/maintenance", nil)
+	w := httptest.NewRecorder()
+
+	handler.MaintenanceHandler(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status %d, got %d", http.StatusOK, w.Code)
+	}
+
+	var occurrences []models.MaintenanceOccurrence
+	if err := json.NewDecoder(w.Body).Decode(&occurrences); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if len(occurrences) != 1 || occurrences[0].WindowID != 1 || !occurrences[0].Active {
+		t.Errorf("Expected only the running window, got %+v", occurrences)
+	}
+}
+
+func TestDeleteMaintenanceWindowInProgress(t *testing.T) {
+	windows := &MockMaintenanceStorage{windows: []models.MaintenanceWindow{
+		{ID: 1, Name: "Running", VLANRecordIDs: []int{1}, Applied: map[int]string{1: "active"}},
+	}}
+	handler := NewHandler(NewMockStorage(), WithMaintenanceStorage(windows))
+
+	req := httptest.NewRequest("DELETE", "/api/v1/maintenance/1", nil)
+	w := httptest.NewRecorder()
+
+	handler.MaintenanceHandler(w, req)
+
+	if w.Code != http.StatusConflict {
+		t.Errorf("Expected status %d, got %d", http.StatusConflict, w.Code)
+	}
+}
+
+func TestMaintenanceHandlerNotConfigured(t *testing.T) {
+	handler := NewHandler(NewMockStorage())
+
+	req := httptest.NewRequest("GET", "/api/v1/maintenance", nil)
+	w := httptest.NewRecorder()
+
+	handler.MaintenanceHandler(w, req)
+
+	if w.Code != http.StatusNotImplemented {
+		t.Errorf("Expected status %d, got %d", http.StatusNotImplemented, w.Code)
+	}
+}
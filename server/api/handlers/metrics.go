@@ -0,0 +1,27 @@
+package handlers
+
+import (
+	"net/http"
+
+	"smit/server/api/rbac"
+)
+
+// Handles GET /metrics
+func (h *Handler) GetMetrics(w http.ResponseWriter, r *http.Request) {
+	r, span := h.startSpan(r, "GetMetrics")
+	defer span.End()
+
+	if r.Method != http.MethodGet {
+		h.sendProblem(w, r, problemMethodNotAllowed, "Method not allowed")
+		return
+	}
+	if !h.authorize(w, r, rbac.ActionMetricsRead) {
+		return
+	}
+	if h.metrics == nil {
+		h.sendProblem(w, r, problemNotImplemented, "Metrics are not configured")
+		return
+	}
+
+	h.metrics.ServeHTTP(w, r)
+}
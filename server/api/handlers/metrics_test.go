@@ -0,0 +1,49 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"smit/server/api/metrics"
+	"smit/server/api/rbac"
+)
+
+func TestGetMetrics(t *testing.T) {
+	registry := metrics.NewRegistry()
+	registry.NewCounter("smit_test_total", "Test.").Inc()
+
+	policy := &rbac.Policy{Roles: map[string]rbac.Role{
+		"viewer":     {Actions: []string{rbac.ActionVLANRead}},
+		"prometheus": {Actions: []string{rbac.ActionMetricsRead}},
+	}}
+	handler := NewHandler(NewMockStorage(), WithMetrics(registry), WithAccessPolicy(policy))
+
+	w := httptest.NewRecorder()
+	handler.GetMetrics(w, requestAs("GET", "/metrics", "", "prometheus", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status %d, got %d", http.StatusOK, w.Code)
+	}
+	if w.Header().Get("Content-Type") != metrics.ContentType || !strings.Contains(w.Body.String(), "smit_test_total 1") {
+		t.Errorf("Unexpected response %q: %s", w.Header().Get("Content-Type"), w.Body.String())
+	}
+
+	w = httptest.NewRecorder()
+	handler.GetMetrics(w, requestAs("GET", "/metrics", "", "viewer", nil))
+	if w.Code != http.StatusForbidden {
+		t.Errorf("Expected status %d without metrics:read, got %d", http.StatusForbidden, w.Code)
+	}
+
+	w = httptest.NewRecorder()
+	handler.GetMetrics(w, requestAs("POST", "/metrics", "", "prometheus", nil))
+	if w.Code != http.StatusMethodNotAllowed {
+		t.Errorf("Expected status %d, got %d", http.StatusMethodNotAllowed, w.Code)
+	}
+
+	w = httptest.NewRecorder()
+	NewHandler(NewMockStorage()).GetMetrics(w, httptest.NewRequest("GET", "/metrics", nil))
+	if w.Code != http.StatusNotImplemented {
+		t.Errorf("Expected status %d without a registry, got %d", http.StatusNotImplemented, w.Code)
+	}
+}
@@ -0,0 +1,132 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"mime"
+	"net/http"
+	"reflect"
+	"strings"
+
+	"smit/server/api/models"
+	"smit/server/api/patch"
+	"smit/server/api/storage"
+)
+
+// Fields of the VLAN representation that a patch cannot change
+var readOnlyVLANFields = []string{"id", "created_at", "updated_at", "created_by", "updated_by", "status_history"}
+
+// Patch formats accepted by PATCH, advertised in Accept-Patch
+var acceptPatch = strings.Join([]string{patch.MergePatchType, patch.JSONPatchType}, ", ")
+
+// Handles PATCH /api/v1/vlans/{id}
+func (h *Handler) PatchVLAN(w http.ResponseWriter, r *http.Request) {
+	r, span := h.startSpan(r, "PatchVLAN")
+	defer span.End()
+
+	if r.Method != http.MethodPatch {
+		h.sendProblem(w, r, problemMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	id, err := extractIDFromPath(r.URL.Path)
+	if err != nil {
+		h.sendProblem(w, r, problemInvalidID, err.Error())
+		return
+	}
+
+	h.patchVLAN(w, r, id)
+}
+
+// Apply the request's patch document to the VLAN with the given record ID
+func (h *Handler) patchVLAN(w http.ResponseWriter, r *http.Request, id int) {
+	var apply func(doc, patch []byte) ([]byte, error)
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	switch mediaType {
+	case patch.MergePatchType:
+		apply = patch.MergePatch
+	case patch.JSONPatchType:
+		apply = patch.JSONPatch
+	default:
+		w.Header().Set("Accept-Patch", acceptPatch)
+		h.sendProblem(w, r, problemUnsupportedMediaType, "Content-Type must be one of: "+acceptPatch)
+		return
+	}
+
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		h.sendProblem(w, r, problemInvalidBody, "Invalid request body")
+		return
+	}
+
+	current, err := h.vlanStore(r).GetByID(id)
+	if err != nil {
+		if errors.Is(err, storage.ErrVLANNotFound) {
+			h.sendProblem(w, r, problemVLANNotFound, "VLAN not found")
+			return
+		}
+		h.sendProblem(w, r, problemInternal, "Failed to retrieve VLAN")
+		return
+	}
+	if !h.matchVersion(w, r, current) {
+		return
+	}
+	etag := current.ETag()
+
+	// Patches apply to the VLAN as GET returns it
+	doc, err := json.Marshal(current)
+	if err != nil {
+		h.sendProblem(w, r, problemInternal, "Failed to encode VLAN")
+		return
+	}
+
+	patched, err := apply(doc, body)
+	if err != nil {
+		if errors.Is(err, patch.ErrPatchFailed) {
+			h.sendProblem(w, r, problemPatchFailed, err.Error())
+			return
+		}
+		h.sendProblem(w, r, problemInvalidBody, err.Error())
+		return
+	}
+
+	if err := checkReadOnly(doc, patched); err != nil {
+		h.sendValidationError(w, r, err)
+		return
+	}
+
+	var vlan models.VLANModel
+	decoder := json.NewDecoder(bytes.NewReader(patched))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&vlan); err != nil {
+		h.sendProblem(w, r, problemInvalidBody, "Patched VLAN is invalid: "+err.Error())
+		return
+	}
+
+	// The merged result goes through the same validation and lifecycle checks as PUT,
+	// and is only saved over the version it was computed from
+	input := vlan.Input()
+	input.IfMatch = etag
+	h.saveVLAN(w, r, id, &input)
+}
+
+// Reject patches that change read-only fields
+func checkReadOnly(original, patched []byte) error {
+	var before, after map[string]interface{}
+	if err := json.Unmarshal(original, &before); err != nil {
+		return err
+	}
+	if err := json.Unmarshal(patched, &after); err != nil {
+		return errors.New("patched document must be a JSON object")
+	}
+
+	var errs models.ValidationErrors
+	for _, field := range readOnlyVLANFields {
+		if !reflect.DeepEqual(before[field], after[field]) {
+			errs.Add(field, models.CodeInvalidValue, field+" is read-only")
+		}
+	}
+	return errs.Err()
+}
@@ -0,0 +1,209 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"smit/server/api/models"
+	"smit/server/api/patch"
+)
+
+func TestPatchVLAN(t *testing.T) {
+	tests := []struct {
+		name        string
+		path        string
+		contentType string
+		body        string
+		expected    int
+		check       func(t *testing.T, vlan models.VLANModel)
+	}{
+		{"Merge patch", "/api/v1/vlans/1", patch.MergePatchType, `{"status": "maintenance", "labels": {"env": "prod"}}`, http.StatusOK,
+			func(t *testing.T, vlan models.VLANModel) {
+				if vlan.Status != "maintenance" || vlan.Labels["env"] != "prod" {
+					t.Errorf("Expected status maintenance with label env=prod, got %s %v", vlan.Status, vlan.Labels)
+				}
+				if vlan.Name != "Test VLAN" {
+					t.Errorf("Expected untouched name to be kept, got %s", vlan.Name)
+				}
+			}},
+		{"Merge patch removes field", "/api/v1/vlans/1", patch.MergePatchType, `{"site": null}`, http.StatusOK,
+			func(t *testing.T, vlan models.VLANModel) {
+				if vlan.Site != "" {
+					t.Errorf("Expected site to be removed, got %q", vlan.Site)
+				}
+			}},
+		{"JSON patch", "/api/v1/vlans/1", patch.JSONPatchType + "; charset=utf-8",
+			`[{"op": "test", "path": "/name", "value": "Test VLAN"}, {"op": "replace", "path": "/name", "value": "Renamed"}]`, http.StatusOK,
+			func(t *testing.T, vlan models.VLANModel) {
+				if vlan.Name != "Renamed" {
+					t.Errorf("Expected name Renamed, got %s", vlan.Name)
+				}
+			}},
+		{"By tag", "/api/v1/vlans/by-tag/100", patch.MergePatchType, `{"site": "ams1"}`, http.StatusOK,
+			func(t *testing.T, vlan models.VLANModel) {
+				if vlan.Site != "ams1" {
+					t.Errorf("Expected site ams1, got %s", vlan.Site)
+				}
+			}},
+		{"Plain JSON", "/api/v1/vlans/1", "application/json", `{"name": "Renamed"}`, http.StatusUnsupportedMediaType, nil},
+		{"Failed test", "/api/v1/vlans/1", patch.JSONPatchType, `[{"op": "test", "path": "/name", "value": "Other"}]`, http.StatusUnprocessableEntity, nil},
+		{"Malformed patch", "/api/v1/vlans/1", patch.JSONPatchType, `{"op": "replace"}`, http.StatusBadRequest, nil},
+		{"Read-only field", "/api/v1/vlans/1", patch.MergePatchType, `{"id": 7}`, http.StatusBadRequest, nil},
+		{"Read-only author", "/api/v1/vlans/1", patch.MergePatchType, `{"updated_by": "someone-else"}`, http.StatusBadRequest, nil},
+		{"Unknown field", "/api/v1/vlans/1", patch.MergePatchType, `{"colour": "blue"}`, http.StatusBadRequest, nil},
+		{"Invalid result", "/api/v1/vlans/1", patch.MergePatchType, `{"vlan_id": 5000}`, http.StatusBadRequest, nil},
+		{"Disallowed transition", "/api/v1/vlans/1", patch.MergePatchType, `{"status": "planned"}`, http.StatusConflict, nil},
+		{"Not found", "/api/v1/vlans/99", patch.MergePatchType, `{"name": "Renamed"}`, http.StatusNotFound, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			storage := NewMockStorage()
+			handler := NewHandler(storage)
+			storage.Create(&models.VLANInput{
+				Name:    "Test VLAN",
+				VlanID:  100,
+				Subnet:  "192.168.100.0/24",
+				Gateway: "192.168.100.1",
+				Status:  "active",
+				Site:    "fra1",
+			})
+
+			req := httptest.NewRequest("PATCH", tt.path, strings.NewReader(tt.body))
+			req.Header.Set("Content-Type", tt.contentType)
+			w := httptest.NewRecorder()
+
+			handler.VLANHandler(w, req)
+
+			if w.Code != tt.expected {
+				t.Fatalf("Expected status %d, got %d: %s", tt.expected, w.Code, w.Body.String())
+			}
+			if w.Code == http.StatusUnsupportedMediaType && w.Header().Get("Accept-Patch") == "" {
+				t.Error("Expected Accept-Patch header")
+			}
+			if tt.check == nil {
+				return
+			}
+
+			var vlan models.VLANModel
+			if err := json.NewDecoder(w.Body).Decode(&vlan); err != nil {
+				t.Fatalf("Failed to decode response: %v", err)
+			}
+			tt.check(t, vlan)
+		})
+	}
+}
+
+// Storage that hands out a VLAN as it was before a write the caller has not seen
+type staleReadStorage struct {
+	*MockStorage
+	stale models.VLANModel
+}
+
+func (s *staleReadStorage) GetByID(id int) (*models.VLANModel, error) {
+	return &s.stale, nil
+}
+
+func TestPatchVLANIfMatch(t *testing.T) {
+	storage := NewMockStorage()
+	handler := NewHandler(storage)
+	storage.Create(&models.VLANInput{Name: "Test VLAN", VlanID: 100, Subnet: "192.168.100.0/24", Gateway: "192.168.100.1", Status: "active"})
+
+	request := func(method, body, ifMatch string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, "/api/v1/vlans/1", strings.NewReader(body))
+		req.Header.Set("Content-Type", patch.MergePatchType)
+		if ifMatch != "" {
+			req.Header.Set("If-Match", ifMatch)
+		}
+		w := httptest.NewRecorder()
+		handler.VLANHandler(w, req)
+		return w
+	}
+
+	etag := request("GET", "", "").Header().Get("ETag")
+	if etag == "" {
+		t.Fatal("Expected an ETag on GET")
+	}
+
+	w := request("PATCH", `{"name": "Renamed"}`, etag)
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status %d, got %d: %s", http.StatusOK, w.Code, w.Body)
+	}
+	updated := w.Header().Get("ETag")
+	if updated == "" || updated == etag {
+		t.Errorf("Expected a new ETag after the patch, got %q", updated)
+	}
+	if got := request("GET", "", "").Header().Get("ETag"); got != updated {
+		t.Errorf("Expected GET to return the ETag of the patch response, got %q and %q", got, updated)
+	}
+
+	// The old ETag no longer matches
+	w = request("PATCH", `{"name": "Again"}`, etag)
+	if w.Code != http.StatusPreconditionFailed || !strings.Contains(w.Body.String(), DefaultProblemTypeBase+"precondition-failed") {
+		t.Errorf("Expected a precondition-failed problem, got %d: %s", w.Code, w.Body)
+	}
+	if w := request("PUT", `{"name": "Put", "vlan_id": 100, "subnet": "192.168.100.0/24", "gateway": "192.168.100.1", "status": "active"}`, etag); w.Code != http.StatusPreconditionFailed {
+		t.Errorf("Expected status %d for PUT, got %d", http.StatusPreconditionFailed, w.Code)
+	}
+	if w := request("PATCH", `{"name": "Any"}`, "*"); w.Code != http.StatusOK {
+		t.Errorf("Expected If-Match * to match, got %d", w.Code)
+	}
+
+	// A patch computed from a VLAN that changed before the save is not applied over the change
+	current, _ := storage.GetByID(1)
+	stale := *current
+	stale.Name = "Before"
+	handler = NewHandler(&staleReadStorage{MockStorage: storage, stale: stale})
+	if w := request("PATCH", `{"site": "fra1"}`, ""); w.Code != http.StatusPreconditionFailed {
+		t.Errorf("Expected status %d for a patch of a stale read, got %d", http.StatusPreconditionFailed, w.Code)
+	}
+	if vlan, _ := storage.GetByID(1); vlan.Name != "Any" || vlan.Site != "" {
+		t.Errorf("Expected the VLAN to be unchanged, got %+v", vlan)
+	}
+}
+
+func TestWritesOfStaleRead(t *testing.T) {
+	storage := NewMockStorage()
+	storage.Create(&models.VLANInput{Name: "Test VLAN", VlanID: 100, Subnet: "192.168.100.0/24", Gateway: "192.168.100.1", Status: "inactive"})
+	current, _ := storage.GetByID(1)
+	stale := *current
+	stale.Site = "fra1"
+
+	// Writes are authorized against the VLAN as read, so they only apply to that version
+	// even when the client sends no If-Match
+	handler := NewHandler(&staleReadStorage{MockStorage: storage, stale: stale})
+	for _, tt := range []struct{ method, body string }{
+		{"PUT", `{"name": "Put", "vlan_id": 100, "subnet": "192.168.100.0/24", "gateway": "192.168.100.1", "status": "inactive"}`},
+		{"DELETE", ""},
+	} {
+		w := httptest.NewRecorder()
+		handler.VLANHandler(w, httptest.NewRequest(tt.method, "/api/v1/vlans/1", strings.NewReader(tt.body)))
+		if w.Code != http.StatusPreconditionFailed {
+			t.Errorf("%s: expected status %d, got %d: %s", tt.method, http.StatusPreconditionFailed, w.Code, w.Body)
+		}
+	}
+	if vlan, err := storage.GetByID(1); err != nil || vlan.Name != "Test VLAN" {
+		t.Errorf("Expected the VLAN to be unchanged, got %+v, %v", vlan, err)
+	}
+
+	// A delete with an If-Match of another version is refused
+	handler = NewHandler(storage)
+	req := httptest.NewRequest("DELETE", "/api/v1/vlans/1", nil)
+	req.Header.Set("If-Match", stale.ETag())
+	w := httptest.NewRecorder()
+	handler.VLANHandler(w, req)
+	if w.Code != http.StatusPreconditionFailed {
+		t.Errorf("Expected status %d, got %d", http.StatusPreconditionFailed, w.Code)
+	}
+
+	req = httptest.NewRequest("DELETE", "/api/v1/vlans/1", nil)
+	req.Header.Set("If-Match", current.ETag())
+	w = httptest.NewRecorder()
+	handler.VLANHandler(w, req)
+	if w.Code != http.StatusNoContent {
+		t.Errorf("Expected status %d, got %d", http.StatusNoContent, w.Code)
+	}
+}
@@ -0,0 +1,316 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"smit/server/api/ipam"
+	"smit/server/api/models"
+	"smit/server/api/rbac"
+	"smit/server/api/storage"
+)
+
+// Handles GET /api/v1/prefixes
+func (h *Handler) GetPrefixes(w http.ResponseWriter, r *http.Request) {
+	r, span := h.startSpan(r, "GetPrefixes")
+	defer span.End()
+
+	if r.Method != http.MethodGet {
+		h.sendProblem(w, r, problemMethodNotAllowed, "Method not allowed")
+		return
+	}
+	if !h.authorize(w, r, rbac.ActionPrefixRead) {
+		return
+	}
+
+	prefixes, err := h.prefixes.GetAllPrefixes()
+	if err != nil {
+		h.sendProblem(w, r, problemInternal, "Failed to retrieve prefixes")
+		return
+	}
+
+	h.sendJSONResponse(w, http.StatusOK, prefixes)
+}
+
+// Handles POST /api/v1/prefixes
+func (h *Handler) CreatePrefix(w http.ResponseWriter, r *http.Request) {
+	r, span := h.startSpan(r, "CreatePrefix")
+	defer span.End()
+
+	if r.Method != http.MethodPost {
+		h.sendProblem(w, r, problemMethodNotAllowed, "Method not allowed")
+		return
+	}
+	if !h.authorize(w, r, rbac.ActionPrefixWrite) {
+		return
+	}
+
+	var input models.PrefixInput
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		h.sendProblem(w, r, problemInvalidBody, "Invalid request body")
+		return
+	}
+
+	// Validate input
+	if err := input.Validate(); err != nil {
+		h.sendValidationError(w, r, err)
+		return
+	}
+
+	prefix, err := h.prefixes.CreatePrefix(&input)
+	if err != nil {
+		if errors.Is(err, storage.ErrPrefixExists) {
+			h.sendProblem(w, r, problemPrefixExists, "Prefix already exists")
+			return
+		}
+		h.sendProblem(w, r, problemInternal, "Failed to create prefix")
+		return
+	}
+
+	h.sendJSONResponse(w, http.StatusCreated, prefix)
+}
+
+// Handles GET /api/v1/prefixes/{id}
+func (h *Handler) GetPrefix(w http.ResponseWriter, r *http.Request) {
+	r, span := h.startSpan(r, "GetPrefix")
+	defer span.End()
+
+	if r.Method != http.MethodGet {
+		h.sendProblem(w, r, problemMethodNotAllowed, "Method not allowed")
+		return
+	}
+	if !h.authorize(w, r, rbac.ActionPrefixRead) {
+		return
+	}
+
+	id, err := extractIDFromPath(r.URL.Path)
+	if err != nil {
+		h.sendProblem(w, r, problemInvalidID, err.Error())
+		return
+	}
+
+	prefix, err := h.prefixes.GetPrefixByID(id)
+	if err != nil {
+		if errors.Is(err, storage.ErrPrefixNotFound) {
+			h.sendProblem(w, r, problemPrefixNotFound, "Prefix not found")
+			return
+		}
+		h.sendProblem(w, r, problemInternal, "Failed to retrieve prefix")
+		return
+	}
+
+	h.sendJSONResponse(w, http.StatusOK, prefix)
+}
+
+// Handles DELETE /api/v1/prefixes/{id}
+func (h *Handler) DeletePrefix(w http.ResponseWriter, r *http.Request) {
+	r, span := h.startSpan(r, "DeletePrefix")
+	defer span.End()
+
+	if r.Method != http.MethodDelete {
+		h.sendProblem(w, r, problemMethodNotAllowed, "Method not allowed")
+		return
+	}
+	if !h.authorize(w, r, rbac.ActionPrefixWrite) {
+		return
+	}
+
+	id, err := extractIDFromPath(r.URL.Path)
+	if err != nil {
+		h.sendProblem(w, r, problemInvalidID, err.Error())
+		return
+	}
+
+	if err := h.prefixes.DeletePrefix(id); err != nil {
+		if errors.Is(err, storage.ErrPrefixNotFound) {
+			h.sendProblem(w, r, problemPrefixNotFound, "Prefix not found")
+			return
+		}
+		h.sendProblem(w, r, problemInternal, "Failed to delete prefix")
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// Handles POST /api/v1/prefixes/{id}/allocate?length={length}
+func (h *Handler) AllocateSubnet(w http.ResponseWriter, r *http.Request) {
+	r, span := h.startSpan(r, "AllocateSubnet")
+	defer span.End()
+
+	if r.Method != http.MethodPost {
+		h.sendProblem(w, r, problemMethodNotAllowed, "Method not allowed")
+		return
+	}
+	if !h.authorize(w, r, rbac.ActionPrefixAllocate) {
+		return
+	}
+
+	id, err := extractIDFromPath(r.URL.Path)
+	if err != nil {
+		h.sendProblem(w, r, problemInvalidID, err.Error())
+		return
+	}
+
+	length, err := strconv.Atoi(r.URL.Query().Get("length"))
+	if err != nil {
+		h.sendProblem(w, r, problemInvalidParameter, "length query parameter must be an integer")
+		return
+	}
+
+	// The body is optional; without a VLAN only the free block is returned
+	var req models.AllocationRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil && !errors.Is(err, io.EOF) {
+		h.sendProblem(w, r, problemInvalidBody, "Invalid request body")
+		return
+	}
+
+	policy := req.GatewayPolicy
+	if policy == "" {
+		policy = h.gatewayPolicy
+	}
+
+	parent, err := h.prefixes.GetPrefixByID(id)
+	if err != nil {
+		if errors.Is(err, storage.ErrPrefixNotFound) {
+			h.sendProblem(w, r, problemPrefixNotFound, "Prefix not found")
+			return
+		}
+		h.sendProblem(w, r, problemInternal, "Failed to retrieve prefix")
+		return
+	}
+
+	h.allocMu.Lock()
+	defer h.allocMu.Unlock()
+
+	vlans, err := h.vlanStore(r).GetAll()
+	if err != nil {
+		h.sendProblem(w, r, problemInternal, "Failed to retrieve VLANs")
+		return
+	}
+
+	used := make([]string, 0, len(vlans))
+	for _, vlan := range vlans {
+		used = append(used, vlan.Subnet)
+	}
+
+	subnet, err := ipam.NextFreeSubnet(parent.Prefix, length, used)
+	if err != nil {
+		if errors.Is(err, ipam.ErrInvalidLength) {
+			h.sendProblem(w, r, problemInvalidParameter, err.Error())
+			return
+		}
+		if errors.Is(err, ipam.ErrNoFreeSubnet) {
+			h.sendProblem(w, r, problemPrefixExhausted, "No free subnet of the requested length in prefix")
+			return
+		}
+		h.sendProblem(w, r, problemInternal, "Failed to allocate subnet")
+		return
+	}
+
+	gateway, err := ipam.GatewayForPolicy(subnet, policy)
+	if err != nil {
+		h.sendProblem(w, r, problemInvalidParameter, err.Error())
+		return
+	}
+
+	response := models.AllocationResponse{
+		Prefix:  parent.Prefix,
+		Subnet:  subnet.String(),
+		Gateway: gateway,
+	}
+
+	if req.VLAN == nil {
+		h.sendJSONResponse(w, http.StatusOK, response)
+		return
+	}
+
+	// Create the VLAN with the carved subnet and policy gateway
+	input := *req.VLAN
+	input.Subnet = response.Subnet
+	input.Gateway = response.Gateway
+	if input.Site == "" {
+		input.Site = parent.Site
+	}
+	// Creating the VLAN needs the same permission and scope as creating it directly
+	if !h.authorizeVLAN(w, r, rbac.ActionVLANCreate, nil, &input) {
+		return
+	}
+	input.Actor = actor(r)
+	// The storage checks the block again under its write lock, so a VLAN created
+	// since the free block was found cannot end up sharing it
+	input.RejectOverlap = true
+
+	if err := input.Validate(); err != nil {
+		h.sendValidationError(w, r, err)
+		return
+	}
+
+	vlan, err := h.vlanStore(r).Create(&input)
+	if err != nil {
+		if errors.Is(err, storage.ErrVLANExists) {
+			h.sendVLANConflict(w, r, input.VlanID)
+			return
+		}
+		if errors.Is(err, storage.ErrSubnetOverlap) {
+			h.sendOverlapConflict(w, r, err)
+			return
+		}
+		h.sendProblem(w, r, problemInternal, "Failed to create VLAN")
+		return
+	}
+
+	response.VLAN = vlan
+	h.sendJSONResponse(w, http.StatusCreated, response)
+}
+
+// Handler for prefix endpoints
+func (h *Handler) PrefixHandler(w http.ResponseWriter, r *http.Request) {
+	r, span := h.startSpan(r, "PrefixHandler")
+	defer span.End()
+
+	if h.prefixes == nil {
+		h.sendProblem(w, r, problemNotImplemented, "Prefix storage is not configured")
+		return
+	}
+
+	path := r.URL.Path
+
+	// Handle /api/v1/prefixes
+	if path == "/api/v1/prefixes" {
+		switch r.Method {
+		case http.MethodGet:
+			h.GetPrefixes(w, r)
+		case http.MethodPost:
+			h.CreatePrefix(w, r)
+		default:
+			h.sendProblem(w, r, problemMethodNotAllowed, "Method not allowed")
+		}
+		return
+	}
+
+	// Handle /api/v1/prefixes/{id}/allocate
+	if strings.HasPrefix(path, "/api/v1/prefixes/") && strings.HasSuffix(path, "/allocate") {
+		h.AllocateSubnet(w, r)
+		return
+	}
+
+	// Handle /api/v1/prefixes/{id}
+	if strings.HasPrefix(path, "/api/v1/prefixes/") {
+		switch r.Method {
+		case http.MethodGet:
+			h.GetPrefix(w, r)
+		case http.MethodDelete:
+			h.DeletePrefix(w, r)
+		default:
+			h.sendProblem(w, r, problemMethodNotAllowed, "Method not allowed")
+		}
+		return
+	}
+
+	h.sendProblem(w, r, problemEndpointNotFound, "Endpoint not found")
+}
@@ -0,0 +1,263 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"smit/server/api/models"
+	"smit/server/api/storage"
+)
+
+// MockPrefixStorage implements the storage.PrefixStorage interface for testing
+type MockPrefixStorage struct {
+	prefixes []models.PrefixModel
+}
+
+func (m *MockPrefixStorage) GetAllPrefixes() ([]models.PrefixModel, error) {
+	return m.prefixes, nil
+}
+
+func (m *MockPrefixStorage) GetPrefixByID(id int) (*models.PrefixModel, error) {
+	for _, prefix := range m.prefixes {
+		if prefix.ID == id {
+			return &prefix, nil
+		}
+	}
+	return nil, storage.ErrPrefixNotFound
+}
+
+func (m *MockPrefixStorage) CreatePrefix(input *models.PrefixInput) (*models.PrefixModel, error) {
+	for _, prefix := range m.prefixes {
+		if prefix.Prefix == input.Prefix {
+			return nil, storage.ErrPrefixExists
+		}
+	}
+
+	prefix := models.PrefixModel{
+		ID:        len(m.prefixes) + 1,
+		Prefix:    input.Prefix,
+		Site:      input.Site,
+		CreatedAt: time.Now(),
+		UpdatedAt: time.Now(),
+	}
+	m.prefixes = append(m.prefixes, prefix)
+	return &prefix, nil
+}
+
+func (m *MockPrefixStorage) DeletePrefix(id int) error {
+	for i, prefix := range m.prefixes {
+		if prefix.ID == id {
+			m.prefixes = append(m.prefixes[:i], m.prefixes[i+1:]...)
+			return nil
+		}
+	}
+	return storage.ErrPrefixNotFound
+}
+
+func TestPrefixHandler(t *testing.T) {
+	handler := NewHandler(NewMockStorage(), WithPrefixStorage(&MockPrefixStorage{}))
+
+	tests := []struct {
+		name     string
+		method   string
+		path     string
+		body     string
+		expected int
+	}{
+		{"Create prefix", "POST", "/api/v1/prefixes", `{"prefix": "10.20.0.0/16"}`, http.StatusCreated},
+		{"Create duplicate prefix", "POST", "/api/v1/prefixes", `{"prefix": "10.20.0.0/16"}`, http.StatusConflict},
+		{"Create host prefix", "POST", "/api/v1/prefixes", `{"prefix": "10.20.1.1/16"}`, http.StatusBadRequest},
+		{"List prefixes", "GET", "/api/v1/prefixes", "", http.StatusOK},
+		{"Get prefix", "GET", "/api/v1/prefixes/1", "", http.StatusOK},
+		{"Get missing prefix", "GET", "/api/v1/prefixes/2", "", http.StatusNotFound},
+		{"Method not allowed", "PUT", "/api/v1/prefixes/1", "", http.StatusMethodNotAllowed},
+		{"Delete prefix", "DELETE", "/api/v1/prefixes/1", "", http.StatusNoContent},
+		{"Delete missing prefix", "DELETE", "/api/v1/prefixes/1", "", http.StatusNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, tt.path, bytes.NewReader([]byte(tt.body)))
+			w := httptest.NewRecorder()
+
+			handler.PrefixHandler(w, req)
+
+			if w.Code != tt.expected {
+				t.Errorf("Expected status %d, got %d: %s", tt.expected, w.Code, w.Body.String())
+			}
+		})
+	}
+}
+
+func TestPrefixHandlerNotConfigured(t *testing.T) {
+	handler := NewHandler(NewMockStorage())
+
+	req := httptest.NewRequest("GET", "/api/v1/prefixes", nil)
+	w := httptest.NewRecorder()
+
+	handler.PrefixHandler(w, req)
+
+	if w.Code != http.StatusNotImplemented {
+		t.Errorf("Expected status %d, got %d", http.StatusNotImplemented, w.Code)
+	}
+}
+
+func TestAllocateSubnet(t *testing.T) {
+	vlans := NewMockStorage()
+	prefixes := &MockPrefixStorage{}
+	handler := NewHandler(vlans, WithPrefixStorage(prefixes))
+
+	prefixes.CreatePrefix(&models.PrefixInput{Prefix: "10.20.0.0/16", Site: "tallinn"})
+	vlans.Create(&models.VLANInput{
+		Name:    "Existing",
+		VlanID:  100,
+		Subnet:  "10.20.0.0/24",
+		Gateway: "10.20.0.1",
+		Status:  "active",
+	})
+
+	// Allocation without a VLAN only returns the free block
+	req := httptest.NewRequest("POST", "/api/v1/prefixes/1/allocate?length=24", nil)
+	w := httptest.NewRecorder()
+	handler.PrefixHandler(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status %d, got %d: %s", http.StatusOK, w.Code, w.Body.String())
+	}
+
+	var allocation models.AllocationResponse
+	if err := json.NewDecoder(w.Body).Decode(&allocation); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if allocation.Subnet != "10.20.1.0/24" || allocation.Gateway != "10.20.1.1" {
+		t.Errorf("Unexpected allocation: %+v", allocation)
+	}
+
+	// Allocation with a VLAN creates it with the carved subnet
+	body := `{"vlan": {"name": "New", "vlan_id": 200, "status": "active"}, "gateway_policy": "last"}`
+	req = httptest.NewRequest("POST", "/api/v1/prefixes/1/allocate?length=24", bytes.NewReader([]byte(body)))
+	w = httptest.NewRecorder()
+	handler.PrefixHandler(w, req)
+
+	if w.Code != http.StatusCreated {
+		t.Fatalf("Expected status %d, got %d: %s", http.StatusCreated, w.Code, w.Body.String())
+	}
+
+	allocation = models.AllocationResponse{}
+	json.NewDecoder(w.Body).Decode(&allocation)
+	if allocation.VLAN == nil {
+		t.Fatal("Expected VLAN in response")
+	}
+	if allocation.VLAN.Subnet != "10.20.1.0/24" || allocation.VLAN.Gateway != "10.20.1.254" || allocation.VLAN.Site != "tallinn" {
+		t.Errorf("Unexpected VLAN: %+v", allocation.VLAN)
+	}
+
+	// The next allocation skips the newly created VLAN
+	req = httptest.NewRequest("POST", "/api/v1/prefixes/1/allocate?length=24", nil)
+	w = httptest.NewRecorder()
+	handler.PrefixHandler(w, req)
+
+	allocation = models.AllocationResponse{}
+	json.NewDecoder(w.Body).Decode(&allocation)
+	if allocation.Subnet != "10.20.2.0/24" {
+		t.Errorf("Expected 10.20.2.0/24, got %s", allocation.Subnet)
+	}
+}
+
+func TestAllocateSubnetErrors(t *testing.T) {
+	prefixes := &MockPrefixStorage{}
+	prefixes.CreatePrefix(&models.PrefixInput{Prefix: "10.20.0.0/24"})
+	vlans := NewMockStorage()
+	vlans.Create(&models.VLANInput{Name: "Full", VlanID: 100, Subnet: "10.20.0.0/24", Gateway: "10.20.0.1", Status: "active"})
+	handler := NewHandler(vlans, WithPrefixStorage(prefixes))
+
+	tests := []struct {
+		name     string
+		path     string
+		body     string
+		expected int
+	}{
+		{"Missing length", "/api/v1/prefixes/1/allocate", "", http.StatusBadRequest},
+		{"Invalid length", "/api/v1/prefixes/1/allocate?length=8", "", http.StatusBadRequest},
+		{"Prefix not found", "/api/v1/prefixes/2/allocate?length=26", "", http.StatusNotFound},
+		{"Prefix exhausted", "/api/v1/prefixes/1/allocate?length=26", "", http.StatusConflict},
+		{"Invalid body", "/api/v1/prefixes/1/allocate?length=26", "invalid json", http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("POST", tt.path, bytes.NewReader([]byte(tt.body)))
+			w := httptest.NewRecorder()
+
+			handler.PrefixHandler(w, req)
+
+			if w.Code != tt.expected {
+				t.Errorf("Expected status %d, got %d: %s", tt.expected, w.Code, w.Body.String())
+			}
+		})
+	}
+}
+
+// Storage whose listing misses writes made after the allocator looked, as when a
+// POST lands between the allocator's scan and its create
+type staleStorage struct {
+	storage.Storage
+	listed []models.VLANModel
+}
+
+func (s *staleStorage) GetAll() ([]models.VLANModel, error) {
+	return s.listed, nil
+}
+
+func TestAllocateSubnetRace(t *testing.T) {
+	store, err := storage.NewJSONStorage(filepath.Join(t.TempDir(), "vlans.json"))
+	if err != nil {
+		t.Fatalf("Failed to create storage: %v", err)
+	}
+	prefixes := &MockPrefixStorage{}
+	prefixes.CreatePrefix(&models.PrefixInput{Prefix: "10.20.0.0/16"})
+	handler := NewHandler(&staleStorage{Storage: store}, WithPrefixStorage(prefixes))
+
+	// Overlaps are allowed for ordinary writes, and this one takes the first block
+	if _, err := store.Create(&models.VLANInput{Name: "Manual", VlanID: 100, Subnet: "10.20.0.0/23", Gateway: "10.20.0.1", Status: "active"}); err != nil {
+		t.Fatalf("Failed to create VLAN: %v", err)
+	}
+
+	body := `{"vlan": {"name": "Allocated", "vlan_id": 200, "status": "active"}}`
+	w := httptest.NewRecorder()
+	handler.PrefixHandler(w, httptest.NewRequest("POST", "/api/v1/prefixes/1/allocate?length=24", strings.NewReader(body)))
+
+	if w.Code != http.StatusConflict {
+		t.Fatalf("Expected status %d, got %d: %s", http.StatusConflict, w.Code, w.Body)
+	}
+	var problem models.Problem
+	json.NewDecoder(w.Body).Decode(&problem)
+	if problem.ConflictingVLAN == nil || problem.ConflictingVLAN.VlanID != 100 {
+		t.Errorf("Expected VLAN 100 to be named as the conflict, got %+v", problem.ConflictingVLAN)
+	}
+}
+
+func TestAllocateSubnetIPv6(t *testing.T) {
+	prefixes := &MockPrefixStorage{}
+	prefixes.CreatePrefix(&models.PrefixInput{Prefix: "2001:db8:20::/48"})
+	handler := NewHandler(NewMockStorage(), WithPrefixStorage(prefixes))
+
+	body := `{"vlan": {"name": "IPv6", "vlan_id": 200, "status": "active"}}`
+	w := httptest.NewRecorder()
+	handler.PrefixHandler(w, httptest.NewRequest("POST", "/api/v1/prefixes/1/allocate?length=64", strings.NewReader(body)))
+
+	if w.Code != http.StatusCreated {
+		t.Fatalf("Expected status %d, got %d: %s", http.StatusCreated, w.Code, w.Body)
+	}
+	var allocation models.AllocationResponse
+	json.NewDecoder(w.Body).Decode(&allocation)
+	if allocation.VLAN == nil || allocation.VLAN.Subnet != "2001:db8:20::/64" || allocation.VLAN.Gateway != "2001:db8:20::1" {
+		t.Errorf("Unexpected VLAN: %+v", allocation.VLAN)
+	}
+}
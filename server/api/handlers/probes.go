@@ -0,0 +1,54 @@
+package handlers
+
+import (
+	"net/http"
+	"time"
+
+	"smit/server/api/health"
+	"smit/server/api/models"
+)
+
+// Handles GET /ready
+func (h *Handler) Readiness(w http.ResponseWriter, r *http.Request) {
+	r, span := h.startSpan(r, "Readiness")
+	defer span.End()
+
+	h.probe(w, r, h.readiness)
+}
+
+// Handles GET /live
+func (h *Handler) Liveness(w http.ResponseWriter, r *http.Request) {
+	r, span := h.startSpan(r, "Liveness")
+	defer span.End()
+
+	h.probe(w, r, h.liveness)
+}
+
+// Run a probe's checks, answering 503 when any of them fails. A probe without
+// checks passes as long as the server answers.
+func (h *Handler) probe(w http.ResponseWriter, r *http.Request, checks *health.Registry) {
+	if r.Method != http.MethodGet {
+		h.sendProblem(w, r, problemMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	response := models.ProbeResponse{
+		Status:    health.StatusPass,
+		Timestamp: time.Now(),
+		Version:   AppVersion,
+		Checks:    map[string]models.CheckResult{},
+	}
+	status := http.StatusOK
+	if checks != nil {
+		results, passed := checks.Run(r.Context())
+		response.Checks = results
+		if !passed {
+			response.Status = health.StatusFail
+			status = http.StatusServiceUnavailable
+		}
+	}
+
+	// Probes must see the current state, never a cached answer
+	w.Header().Set("Cache-Control", "no-store")
+	h.sendJSONResponse(w, status, response)
+}
@@ -0,0 +1,82 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"smit/server/api/auth"
+	"smit/server/api/health"
+	"smit/server/api/models"
+)
+
+func TestProbes(t *testing.T) {
+	healthy := true
+	readiness := health.NewRegistry(0)
+	readiness.Register("storage", health.CheckerFunc(func(ctx context.Context) (string, error) {
+		if !healthy {
+			return "", errors.New("failed to unmarshal data")
+		}
+		return "2 VLANs", nil
+	}))
+
+	keys, _ := auth.LoadKeyStore("")
+	keys.Create(&models.APIKeyInput{Name: "ci"})
+	handler := NewHandler(NewMockStorage(), WithProbes(readiness, health.NewRegistry(0)), WithAPIKeys(keys))
+	server := handler.Authenticate(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/ready" {
+			handler.Readiness(w, r)
+			return
+		}
+		handler.Liveness(w, r)
+	}))
+
+	send := func(method, path string) (*httptest.ResponseRecorder, models.ProbeResponse) {
+		w := httptest.NewRecorder()
+		server.ServeHTTP(w, httptest.NewRequest(method, path, nil))
+		var response models.ProbeResponse
+		json.NewDecoder(w.Body).Decode(&response)
+		return w, response
+	}
+
+	// Probes need no credentials
+	w, response := send("GET", "/ready")
+	if w.Code != http.StatusOK || response.Status != health.StatusPass || response.Version != AppVersion {
+		t.Fatalf("Expected a passing probe, got %d %+v", w.Code, response)
+	}
+	if check := response.Checks["storage"]; check.Status != health.StatusPass || check.Detail != "2 VLANs" {
+		t.Errorf("Unexpected storage check %+v", check)
+	}
+	if w.Header().Get("Cache-Control") != "no-store" {
+		t.Error("Expected probe responses not to be cached")
+	}
+
+	healthy = false
+	w, response = send("GET", "/ready")
+	if w.Code != http.StatusServiceUnavailable || response.Status != health.StatusFail {
+		t.Fatalf("Expected a failing probe, got %d %+v", w.Code, response)
+	}
+	if check := response.Checks["storage"]; check.Status != health.StatusFail || check.Error != "failed to unmarshal data" {
+		t.Errorf("Unexpected storage check %+v", check)
+	}
+
+	// Liveness does not depend on the data
+	w, response = send("GET", "/live")
+	if w.Code != http.StatusOK || response.Status != health.StatusPass || len(response.Checks) != 0 {
+		t.Errorf("Expected liveness to pass without checks, got %d %+v", w.Code, response)
+	}
+
+	if w, _ := send("POST", "/ready"); w.Code != http.StatusMethodNotAllowed {
+		t.Errorf("Expected status %d, got %d", http.StatusMethodNotAllowed, w.Code)
+	}
+
+	// Without configured checks the probes pass
+	w = httptest.NewRecorder()
+	NewHandler(NewMockStorage()).Readiness(w, httptest.NewRequest("GET", "/ready", nil))
+	if w.Code != http.StatusOK {
+		t.Errorf("Expected status %d without checks, got %d", http.StatusOK, w.Code)
+	}
+}
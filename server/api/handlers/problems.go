@@ -0,0 +1,148 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"time"
+
+	"smit/server/api/models"
+	"smit/server/api/storage"
+	"smit/server/api/tracing"
+)
+
+// Default prefix of problem type URIs, a relative reference resolved against the API's own URL
+const DefaultProblemTypeBase = "/problems/"
+
+// Content type of RFC 7807 error responses
+const problemContentType = "application/problem+json"
+
+// Stable problem type with its default title and HTTP status
+type problemType struct {
+	slug   string
+	title  string
+	status int
+}
+
+// Problem types returned by the API
+var (
+	problemValidation            = problemType{"validation-error", "Validation failed", http.StatusBadRequest}
+	problemInvalidBody           = problemType{"invalid-body", "Invalid request body", http.StatusBadRequest}
+	problemInvalidID             = problemType{"invalid-id", "Invalid ID", http.StatusBadRequest}
+	problemInvalidParameter      = problemType{"invalid-parameter", "Invalid parameter", http.StatusBadRequest}
+	problemUnauthorized          = problemType{"unauthorized", "Authentication required", http.StatusUnauthorized}
+	problemForbidden             = problemType{"forbidden", "Forbidden", http.StatusForbidden}
+	problemVLANNotFound          = problemType{"vlan-not-found", "VLAN not found", http.StatusNotFound}
+	problemPrefixNotFound        = problemType{"prefix-not-found", "Prefix not found", http.StatusNotFound}
+	problemMaintenanceNotFound   = problemType{"maintenance-not-found", "Maintenance window not found", http.StatusNotFound}
+	problemWebhookNotFound       = problemType{"webhook-not-found", "Webhook not found", http.StatusNotFound}
+	problemAPIKeyNotFound        = problemType{"api-key-not-found", "API key not found", http.StatusNotFound}
+	problemEndpointNotFound      = problemType{"endpoint-not-found", "Endpoint not found", http.StatusNotFound}
+	problemMethodNotAllowed      = problemType{"method-not-allowed", "Method not allowed", http.StatusMethodNotAllowed}
+	problemVLANExists            = problemType{"vlan-exists", "VLAN already exists", http.StatusConflict}
+	problemInvalidTransition     = problemType{"invalid-transition", "Status transition not allowed", http.StatusConflict}
+	problemNotDeletable          = problemType{"vlan-not-deletable", "VLAN cannot be deleted", http.StatusConflict}
+	problemMaintenanceInProgress = problemType{"maintenance-in-progress", "Maintenance window in progress", http.StatusConflict}
+	problemSubnetOverlap         = problemType{"subnet-overlap", "Subnet overlaps another VLAN", http.StatusConflict}
+	problemPrefixExists          = problemType{"prefix-exists", "Prefix already exists", http.StatusConflict}
+	problemPrefixExhausted       = problemType{"prefix-exhausted", "Prefix exhausted", http.StatusConflict}
+	problemInvalidSubnet         = problemType{"invalid-subnet", "Invalid stored subnet", http.StatusUnprocessableEntity}
+	problemIdempotencyKeyReused  = problemType{"idempotency-key-reused", "Idempotency key reused", http.StatusUnprocessableEntity}
+	problemRequestInProgress     = problemType{"request-in-progress", "Request in progress", http.StatusConflict}
+	problemPatchFailed           = problemType{"patch-failed", "Patch cannot be applied", http.StatusUnprocessableEntity}
+	problemPreconditionFailed    = problemType{"precondition-failed", "VLAN has changed", http.StatusPreconditionFailed}
+	problemBodyTooLarge          = problemType{"body-too-large", "Request body too large", http.StatusRequestEntityTooLarge}
+	problemUnsupportedMediaType  = problemType{"unsupported-media-type", "Unsupported media type", http.StatusUnsupportedMediaType}
+	problemRateLimited           = problemType{"rate-limited", "Too many requests", http.StatusTooManyRequests}
+	problemInternal              = problemType{"internal-error", "Internal server error", http.StatusInternalServerError}
+	problemNotImplemented        = problemType{"not-implemented", "Not implemented", http.StatusNotImplemented}
+)
+
+// Build a problem of the given type for a request
+func (h *Handler) newProblem(r *http.Request, pt problemType, detail string) models.Problem {
+	span := tracing.SpanFromContext(r.Context())
+	span.SetAttributes(tracing.String("problem.type", pt.slug))
+	if pt.status >= http.StatusInternalServerError {
+		span.SetError(detail)
+	}
+
+	return models.Problem{
+		Type:      h.problemTypeBase + pt.slug,
+		Title:     pt.title,
+		Status:    pt.status,
+		Detail:    detail,
+		Instance:  r.URL.Path,
+		RequestID: requestID(r),
+		Timestamp: time.Now(),
+	}
+}
+
+// Send a problem of the given type
+func (h *Handler) sendProblem(w http.ResponseWriter, r *http.Request, pt problemType, detail string) {
+	h.writeProblem(w, h.newProblem(r, pt, detail))
+}
+
+// Write a problem as problem+json, or as ErrorResponse when legacy errors are enabled
+func (h *Handler) writeProblem(w http.ResponseWriter, problem models.Problem) {
+	if h.legacyErrors {
+		message := problem.Detail
+		if message == "" {
+			message = problem.Title
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(problem.Status)
+		json.NewEncoder(w).Encode(models.ErrorResponse{
+			Error:     message,
+			Details:   problem.Errors,
+			RequestID: problem.RequestID,
+			Timestamp: problem.Timestamp,
+		})
+		return
+	}
+
+	w.Header().Set("Content-Type", problemContentType)
+	w.WriteHeader(problem.Status)
+	json.NewEncoder(w).Encode(problem)
+}
+
+// Send validation errors with every field violation
+func (h *Handler) sendValidationError(w http.ResponseWriter, r *http.Request, err error) {
+	var validationErrs models.ValidationErrors
+	if !errors.As(err, &validationErrs) {
+		h.sendProblem(w, r, problemInvalidParameter, err.Error())
+		return
+	}
+
+	problem := h.newProblem(r, problemValidation, err.Error())
+	problem.Errors = validationErrs
+	h.writeProblem(w, problem)
+}
+
+// Send a conflict for a subnet that overlaps another VLAN, including that VLAN
+func (h *Handler) sendOverlapConflict(w http.ResponseWriter, r *http.Request, err error) {
+	problem := h.newProblem(r, problemSubnetOverlap, err.Error())
+
+	var overlap *storage.OverlapError
+	if errors.As(err, &overlap) {
+		problem.ConflictingVLAN = &overlap.VLAN
+	}
+
+	h.writeProblem(w, problem)
+}
+
+// Send a conflict for a duplicate VLAN tag, including the VLAN holding it
+func (h *Handler) sendVLANConflict(w http.ResponseWriter, r *http.Request, vlanID int) {
+	problem := h.newProblem(r, problemVLANExists, "VLAN with this ID already exists")
+
+	if vlans, err := h.vlanStore(r).GetAll(); err == nil {
+		for _, vlan := range vlans {
+			if vlan.VlanID == vlanID {
+				problem.ConflictingVLAN = &vlan
+				break
+			}
+		}
+	}
+
+	h.writeProblem(w, problem)
+}
@@ -0,0 +1,110 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"smit/server/api/models"
+)
+
+func TestProblemResponse(t *testing.T) {
+	handler := NewHandler(NewMockStorage())
+
+	req := httptest.NewRequest("GET", "/api/v1/vlans/999", nil)
+	w := httptest.NewRecorder()
+
+	handler.GetVLAN(w, req)
+
+	if ct := w.Header().Get("Content-Type"); ct != "application/problem+json" {
+		t.Errorf("Expected problem+json content type, got %s", ct)
+	}
+
+	var problem models.Problem
+	if err := json.NewDecoder(w.Body).Decode(&problem); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+
+	if problem.Type != DefaultProblemTypeBase+"vlan-not-found" {
+		t.Errorf("Unexpected type %s", problem.Type)
+	}
+	if problem.Status != http.StatusNotFound || problem.Title == "" {
+		t.Errorf("Unexpected status or title: %+v", problem)
+	}
+	if problem.Instance != "/api/v1/vlans/999" {
+		t.Errorf("Expected instance /api/v1/vlans/999, got %s", problem.Instance)
+	}
+}
+
+func TestProblemTypeBase(t *testing.T) {
+	handler := NewHandler(NewMockStorage(), WithProblemTypeBase("https://docs.example.com/smit/problems/"))
+
+	w := httptest.NewRecorder()
+	handler.GetVLAN(w, httptest.NewRequest("GET", "/api/v1/vlans/999", nil))
+
+	var problem models.Problem
+	json.NewDecoder(w.Body).Decode(&problem)
+	if problem.Type != "https://docs.example.com/smit/problems/vlan-not-found" {
+		t.Errorf("Expected the configured base in the type, got %s", problem.Type)
+	}
+}
+
+func TestProblemConflictingVLAN(t *testing.T) {
+	storage := NewMockStorage()
+	handler := NewHandler(storage)
+
+	input := models.VLANInput{
+		Name:    "First VLAN",
+		VlanID:  300,
+		Subnet:  "192.168.30.0/24",
+		Gateway: "192.168.30.1",
+		Status:  "active",
+	}
+	storage.Create(&input)
+
+	body, _ := json.Marshal(input)
+	req := httptest.NewRequest("POST", "/api/v1/vlans", bytes.NewReader(body))
+	w := httptest.NewRecorder()
+
+	handler.CreateVLAN(w, req)
+
+	var problem models.Problem
+	if err := json.NewDecoder(w.Body).Decode(&problem); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+
+	if problem.Type != DefaultProblemTypeBase+"vlan-exists" {
+		t.Errorf("Unexpected type %s", problem.Type)
+	}
+	if problem.ConflictingVLAN == nil || problem.ConflictingVLAN.ID != 1 {
+		t.Errorf("Expected conflicting VLAN 1, got %+v", problem.ConflictingVLAN)
+	}
+}
+
+func TestLegacyErrors(t *testing.T) {
+	handler := NewHandler(NewMockStorage(), WithLegacyErrors(true))
+
+	body := []byte(`{"name": "", "vlan_id": 100, "subnet": "192.168.1.0/24", "gateway": "192.168.1.1", "status": "active"}`)
+	req := httptest.NewRequest("POST", "/api/v1/vlans", bytes.NewReader(body))
+	w := httptest.NewRecorder()
+
+	handler.CreateVLAN(w, req)
+
+	if ct := w.Header().Get("Content-Type"); ct != "application/json" {
+		t.Errorf("Expected application/json content type, got %s", ct)
+	}
+
+	var response models.ErrorResponse
+	if err := json.NewDecoder(w.Body).Decode(&response); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+
+	if response.Error != "name must be between 1 and 255 characters" {
+		t.Errorf("Unexpected error message %q", response.Error)
+	}
+	if len(response.Details) != 1 || response.Details[0].Field != "name" {
+		t.Errorf("Unexpected details: %+v", response.Details)
+	}
+}
@@ -0,0 +1,110 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"net/netip"
+	"strconv"
+	"strings"
+
+	"smit/server/api/models"
+)
+
+// Build a VLAN query from the list endpoint's query parameters, collecting every violation
+func vlanQueryFromRequest(r *http.Request) (models.VLANQuery, error) {
+	params := r.URL.Query()
+	var query models.VLANQuery
+	var errs models.ValidationErrors
+
+	// status=active,maintenance or repeated status parameters
+	for _, value := range params["status"] {
+		for _, status := range strings.Split(value, ",") {
+			if status != "" {
+				query.Status = append(query.Status, status)
+			}
+		}
+	}
+
+	query.Name = params.Get("name")
+
+	// vlan_id=100 or vlan_id=100-199, either end of a range may be left open
+	if value := params.Get("vlan_id"); value != "" {
+		min, max, err := parseVlanIDRange(value)
+		if err != nil {
+			errs.Add("vlan_id", models.CodeInvalidFormat, err.Error())
+		}
+		query.VlanIDMin, query.VlanIDMax = min, max
+	}
+
+	if value := params.Get("subnet_within"); value != "" {
+		prefix, err := netip.ParsePrefix(value)
+		if err != nil {
+			errs.Add("subnet_within", models.CodeInvalidFormat, "subnet_within must be in CIDR notation")
+		}
+		query.SubnetWithin = prefix.Masked()
+	}
+	if value := params.Get("subnet_contains"); value != "" {
+		addr, err := netip.ParseAddr(value)
+		if err != nil {
+			errs.Add("subnet_contains", models.CodeInvalidFormat, "subnet_contains must be an IP address")
+		}
+		query.SubnetContains = addr
+	}
+
+	selector, err := models.ParseLabelSelector(params["label"])
+	if err != nil {
+		errs.Add("label", models.CodeInvalidFormat, err.Error())
+	}
+	query.Labels = selector
+
+	// sort=name ascending, sort=-name descending
+	if value := params.Get("sort"); value != "" {
+		query.Sort = strings.TrimPrefix(value, "-")
+		query.Descending = strings.HasPrefix(value, "-")
+	}
+
+	if value := params.Get("limit"); value != "" {
+		limit, err := strconv.Atoi(value)
+		if err != nil || limit < 1 {
+			limit = -1
+		}
+		query.Limit = limit
+	}
+	query.Cursor = params.Get("cursor")
+
+	// Merge with the checks that do not depend on parsing
+	if err := query.Validate(); err != nil {
+		errs = append(errs, err.(models.ValidationErrors)...)
+	}
+
+	return query, errs.Err()
+}
+
+// Parse a single VLAN ID or an inclusive range of them
+func parseVlanIDRange(value string) (int, int, error) {
+	start, end, isRange := strings.Cut(value, "-")
+	if !isRange {
+		end = start
+	}
+
+	var bounds [2]int
+	for i, part := range []string{start, end} {
+		if part == "" && isRange {
+			continue
+		}
+		id, err := strconv.Atoi(part)
+		if err != nil || id < 1 {
+			return 0, 0, fmt.Errorf("vlan_id must be a VLAN ID or a range such as 100-199")
+		}
+		bounds[i] = id
+	}
+
+	return bounds[0], bounds[1], nil
+}
+
+// Link header value pointing at the next page of the current request
+func nextLink(r *http.Request, cursor string) string {
+	params := r.URL.Query()
+	params.Set("cursor", cursor)
+	return fmt.Sprintf(`<%s?%s>; rel="next"`, r.URL.Path, params.Encode())
+}
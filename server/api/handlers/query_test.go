@@ -0,0 +1,120 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"smit/server/api/models"
+)
+
+func TestGetVLANsQuery(t *testing.T) {
+	storage := NewMockStorage()
+	handler := NewHandler(storage)
+
+	storage.Create(&models.VLANInput{Name: "Production", VlanID: 100, Subnet: "10.0.0.0/24", Gateway: "10.0.0.1", Status: "active"})
+	storage.Create(&models.VLANInput{Name: "Development", VlanID: 200, Subnet: "10.1.0.0/24", Gateway: "10.1.0.1", Status: "active"})
+	storage.Create(&models.VLANInput{Name: "Guest", VlanID: 300, Subnet: "192.168.0.0/24", Gateway: "192.168.0.1", Status: "inactive"})
+
+	tests := []struct {
+		name     string
+		query    string
+		expected int
+		names    []string
+	}{
+		{"Status", "?status=inactive", http.StatusOK, []string{"Guest"}},
+		{"Status list", "?status=active,inactive&sort=-vlan_id", http.StatusOK, []string{"Guest", "Development", "Production"}},
+		{"Name substring", "?name=DEV", http.StatusOK, []string{"Development"}},
+		{"VLAN ID range", "?vlan_id=150-300", http.StatusOK, []string{"Development", "Guest"}},
+		{"Open VLAN ID range", "?vlan_id=-200", http.StatusOK, []string{"Production", "Development"}},
+		{"Single VLAN ID", "?vlan_id=300", http.StatusOK, []string{"Guest"}},
+		{"Subnet within", "?subnet_within=10.0.0.0/8&sort=name", http.StatusOK, []string{"Development", "Production"}},
+		{"Subnet contains", "?subnet_contains=192.168.0.20", http.StatusOK, []string{"Guest"}},
+		{"Invalid status", "?status=gone", http.StatusBadRequest, nil},
+		{"Invalid VLAN ID range", "?vlan_id=a-b", http.StatusBadRequest, nil},
+		{"Invalid subnet", "?subnet_within=10.0.0.0", http.StatusBadRequest, nil},
+		{"Invalid sort", "?sort=color", http.StatusBadRequest, nil},
+		{"Invalid limit", "?limit=0", http.StatusBadRequest, nil},
+		{"Invalid cursor", "?cursor=abc", http.StatusBadRequest, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("GET", "/api/v1/vlans"+tt.query, nil)
+			w := httptest.NewRecorder()
+
+			handler.GetVLANs(w, req)
+
+			if w.Code != tt.expected {
+				t.Fatalf("Expected status %d, got %d: %s", tt.expected, w.Code, w.Body.String())
+			}
+			if w.Code != http.StatusOK {
+				return
+			}
+
+			var vlans []models.VLANModel
+			if err := json.NewDecoder(w.Body).Decode(&vlans); err != nil {
+				t.Fatalf("Failed to decode response: %v", err)
+			}
+			if len(vlans) != len(tt.names) {
+				t.Fatalf("Expected %d VLANs, got %d", len(tt.names), len(vlans))
+			}
+			for i, name := range tt.names {
+				if vlans[i].Name != name {
+					t.Errorf("Expected VLAN %d to be %s, got %s", i, name, vlans[i].Name)
+				}
+			}
+		})
+	}
+}
+
+func TestGetVLANsPagination(t *testing.T) {
+	storage := NewMockStorage()
+	handler := NewHandler(storage)
+
+	for i, name := range []string{"Alpha", "Bravo", "Charlie"} {
+		storage.Create(&models.VLANInput{Name: name, VlanID: 100 + i, Subnet: "10.0.0.0/24", Gateway: "10.0.0.1", Status: "active"})
+	}
+
+	var names []string
+	path := "/api/v1/vlans?sort=-name&limit=2"
+	for path != "" {
+		req := httptest.NewRequest("GET", path, nil)
+		w := httptest.NewRecorder()
+
+		handler.GetVLANs(w, req)
+
+		if w.Code != http.StatusOK {
+			t.Fatalf("Expected status %d, got %d", http.StatusOK, w.Code)
+		}
+
+		var vlans []models.VLANModel
+		if err := json.NewDecoder(w.Body).Decode(&vlans); err != nil {
+			t.Fatalf("Failed to decode response: %v", err)
+		}
+		for _, vlan := range vlans {
+			names = append(names, vlan.Name)
+		}
+
+		path = ""
+		if link := w.Header().Get("Link"); link != "" {
+			if !strings.HasSuffix(link, `>; rel="next"`) {
+				t.Fatalf("Unexpected Link header: %s", link)
+			}
+			path = strings.TrimSuffix(strings.TrimPrefix(link, "<"), `>; rel="next"`)
+
+			// The next link keeps the other parameters
+			next, _ := url.Parse(path)
+			if next.Query().Get("sort") != "-name" || next.Query().Get("limit") != "2" {
+				t.Errorf("Next link lost parameters: %s", path)
+			}
+		}
+	}
+
+	if strings.Join(names, ",") != "Charlie,Bravo,Alpha" {
+		t.Errorf("Unexpected pages: %v", names)
+	}
+}
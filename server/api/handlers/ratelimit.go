@@ -0,0 +1,130 @@
+package handlers
+
+import (
+	"fmt"
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"smit/server/api/auth"
+	"smit/server/api/ratelimit"
+)
+
+// Period the per-minute limits refill over
+const rateLimitPeriod = time.Minute
+
+// Throttle clients that send requests faster than their limits. Clients are told
+// apart by API key, by token or certificate subject, and otherwise by address;
+// reads and writes count against separate limits. Every limited response carries
+// RateLimit headers, and requests over the limit get 429 with Retry-After.
+func (h *Handler) RateLimit(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if h.limiter == nil || publicPaths[r.URL.Path] {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		// Limits set on the caller's API key override the defaults
+		limits := h.rateLimits
+		if identity := auth.IdentityFromContext(r.Context()); identity != nil && identity.RateLimit != nil {
+			if identity.RateLimit.ReadPerMinute > 0 {
+				limits.ReadPerMinute = identity.RateLimit.ReadPerMinute
+			}
+			if identity.RateLimit.WritePerMinute > 0 {
+				limits.WritePerMinute = identity.RateLimit.WritePerMinute
+			}
+		}
+
+		class, perMinute := "write", limits.WritePerMinute
+		switch r.Method {
+		case http.MethodGet, http.MethodHead, http.MethodOptions:
+			class, perMinute = "read", limits.ReadPerMinute
+		}
+		if perMinute <= 0 {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		result := h.limiter.Allow(h.rateLimitClient(r)+" "+class, ratelimit.Limit{Requests: perMinute, Period: rateLimitPeriod})
+		w.Header().Set("RateLimit-Limit", strconv.Itoa(result.Limit))
+		w.Header().Set("RateLimit-Remaining", strconv.Itoa(result.Remaining))
+		w.Header().Set("RateLimit-Reset", strconv.Itoa(ceilSeconds(result.Reset)))
+		w.Header().Set("RateLimit-Policy", fmt.Sprintf("%d;w=%d", result.Limit, int(rateLimitPeriod.Seconds())))
+
+		if !result.Allowed {
+			w.Header().Set("Retry-After", strconv.Itoa(max(1, ceilSeconds(result.RetryAfter))))
+			h.sendProblem(w, r, problemRateLimited, fmt.Sprintf("Rate limit of %d %s requests per minute exceeded", perMinute, class))
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// Refuse a client address that has used up its failed authentications, with 429
+// and Retry-After. Only failures take tokens, so clients that authenticate are
+// never held back here.
+func (h *Handler) allowAuthentication(w http.ResponseWriter, r *http.Request) bool {
+	if h.authLimiter == nil {
+		return true
+	}
+
+	result := h.authLimiter.Peek(h.authFailureKey(r), h.authFailureLimit())
+	if result.Allowed {
+		return true
+	}
+
+	w.Header().Set("Retry-After", strconv.Itoa(max(1, ceilSeconds(result.RetryAfter))))
+	h.sendProblem(w, r, problemRateLimited, "Too many failed authentication attempts from this address")
+	return false
+}
+
+// Count a failed authentication against the client's address
+func (h *Handler) recordAuthFailure(r *http.Request) {
+	if h.authLimiter != nil {
+		h.authLimiter.Allow(h.authFailureKey(r), h.authFailureLimit())
+	}
+}
+
+func (h *Handler) authFailureKey(r *http.Request) string {
+	return "auth-failure ip:" + h.clientAddress(r)
+}
+
+func (h *Handler) authFailureLimit() ratelimit.Limit {
+	return ratelimit.Limit{Requests: h.authFailuresPerMinute, Period: rateLimitPeriod}
+}
+
+// Key the caller's requests are counted under
+func (h *Handler) rateLimitClient(r *http.Request) string {
+	if identity := auth.IdentityFromContext(r.Context()); identity != nil {
+		return identity.Key()
+	}
+	return "ip:" + h.clientAddress(r)
+}
+
+// Address of the client; behind a trusted proxy, the last X-Forwarded-For
+// entry, which the proxy itself appended
+func (h *Handler) clientAddress(r *http.Request) string {
+	if h.trustForwarded {
+		if forwarded := r.Header.Values("X-Forwarded-For"); len(forwarded) > 0 {
+			entries := strings.Split(forwarded[len(forwarded)-1], ",")
+			if addr := strings.TrimSpace(entries[len(entries)-1]); addr != "" {
+				return addr
+			}
+		}
+	}
+
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+// Whole seconds, rounded up
+func ceilSeconds(d time.Duration) int {
+	return int(math.Ceil(d.Seconds()))
+}
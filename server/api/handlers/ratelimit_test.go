@@ -0,0 +1,186 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+
+	"smit/server/api/auth"
+	"smit/server/api/models"
+	"smit/server/api/ratelimit"
+)
+
+func TestRateLimit(t *testing.T) {
+	keys, _ := auth.LoadKeyStore("")
+	limited, _ := keys.Create(&models.APIKeyInput{Name: "script"})
+	generous, _ := keys.Create(&models.APIKeyInput{Name: "sync", RateLimit: &models.RateLimit{WritePerMinute: 5}})
+
+	handler := NewHandler(NewMockStorage(), WithAPIKeys(keys),
+		WithRateLimits(ratelimit.NewLimiter(), models.RateLimit{ReadPerMinute: 3, WritePerMinute: 1}, false))
+	server := handler.Authenticate(handler.RateLimit(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	})))
+
+	send := func(method, path, key string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, nil)
+		if key != "" {
+			req.Header.Set("X-API-Key", key)
+		}
+		w := httptest.NewRecorder()
+		server.ServeHTTP(w, req)
+		return w
+	}
+
+	for i := 2; i >= 0; i-- {
+		w := send("GET", "/api/v1/vlans", limited.Key)
+		if w.Code != http.StatusNoContent {
+			t.Fatalf("Expected status %d, got %d", http.StatusNoContent, w.Code)
+		}
+		if w.Header().Get("RateLimit-Limit") != "3" || w.Header().Get("RateLimit-Remaining") != strconv.Itoa(i) {
+			t.Errorf("Unexpected headers %v", w.Header())
+		}
+	}
+
+	w := send("GET", "/api/v1/vlans", limited.Key)
+	if w.Code != http.StatusTooManyRequests {
+		t.Fatalf("Expected status %d, got %d", http.StatusTooManyRequests, w.Code)
+	}
+	if w.Header().Get("Retry-After") != "20" || w.Header().Get("RateLimit-Policy") != "3;w=60" {
+		t.Errorf("Unexpected headers %v", w.Header())
+	}
+	if !strings.Contains(w.Body.String(), "rate-limited") {
+		t.Errorf("Expected a rate-limited problem, got %s", w.Body.String())
+	}
+
+	// Writes have their own limit
+	if w := send("POST", "/api/v1/vlans", limited.Key); w.Code != http.StatusNoContent {
+		t.Errorf("Expected a write to be allowed, got %d", w.Code)
+	}
+	if w := send("POST", "/api/v1/vlans", limited.Key); w.Code != http.StatusTooManyRequests {
+		t.Errorf("Expected the second write to be limited, got %d", w.Code)
+	}
+
+	// A key's own limits replace the defaults
+	for i := 0; i < 5; i++ {
+		if w := send("DELETE", "/api/v1/vlans/1", generous.Key); w.Code != http.StatusNoContent {
+			t.Fatalf("Expected write %d within the key's limit, got %d", i+1, w.Code)
+		}
+	}
+
+	// The health check is never limited
+	for i := 0; i < 5; i++ {
+		if w := send("GET", "/health", ""); w.Code != http.StatusNoContent || w.Header().Get("RateLimit-Limit") != "" {
+			t.Fatalf("Expected the health check to pass unlimited, got %d", w.Code)
+		}
+	}
+}
+
+func TestRateLimitByMethod(t *testing.T) {
+	handler := NewHandler(NewMockStorage(), WithRateLimits(ratelimit.NewLimiter(), models.RateLimit{WritePerMinute: 1}, false))
+	server := handler.RateLimit(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	}))
+
+	send := func(method string) int {
+		req := httptest.NewRequest("POST", "/api/v1/vlans", nil)
+		req = req.WithContext(auth.WithIdentity(req.Context(), &auth.Identity{Method: method, Subject: "deploy-bot"}))
+		w := httptest.NewRecorder()
+		server.ServeHTTP(w, req)
+		return w.Code
+	}
+
+	if code := send(auth.MethodJWT); code != http.StatusNoContent {
+		t.Fatalf("Expected status %d, got %d", http.StatusNoContent, code)
+	}
+	// A certificate with the token's subject as its common name has its own bucket
+	if code := send(auth.MethodCertificate); code != http.StatusNoContent {
+		t.Errorf("Expected the certificate caller to be allowed, got %d", code)
+	}
+	if code := send(auth.MethodJWT); code != http.StatusTooManyRequests {
+		t.Errorf("Expected the token caller to be limited, got %d", code)
+	}
+}
+
+func TestRateLimitByAddress(t *testing.T) {
+	handler := NewHandler(NewMockStorage(), WithRateLimits(ratelimit.NewLimiter(), models.RateLimit{WritePerMinute: 1}, true))
+	server := handler.RateLimit(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	}))
+
+	send := func(remote, forwarded string) int {
+		req := httptest.NewRequest("POST", "/api/v1/vlans", nil)
+		req.RemoteAddr = remote
+		if forwarded != "" {
+			req.Header.Set("X-Forwarded-For", forwarded)
+		}
+		w := httptest.NewRecorder()
+		server.ServeHTTP(w, req)
+		return w.Code
+	}
+
+	if code := send("10.0.0.1:5000", "198.51.100.7"); code != http.StatusNoContent {
+		t.Fatalf("Expected status %d, got %d", http.StatusNoContent, code)
+	}
+	// The proxy's own entry counts, not the ones the client sent
+	if code := send("10.0.0.1:5001", "203.0.113.9, 198.51.100.7"); code != http.StatusTooManyRequests {
+		t.Errorf("Expected the same forwarded client to be limited, got %d", code)
+	}
+	if code := send("10.0.0.1:5002", "198.51.100.8"); code != http.StatusNoContent {
+		t.Errorf("Expected another client to be allowed, got %d", code)
+	}
+
+	// Reads are unlimited when no read limit is set
+	req := httptest.NewRequest("GET", "/api/v1/vlans", nil)
+	w := httptest.NewRecorder()
+	server.ServeHTTP(w, req)
+	if w.Code != http.StatusNoContent || w.Header().Get("RateLimit-Limit") != "" {
+		t.Errorf("Expected unlimited reads, got %d with %v", w.Code, w.Header())
+	}
+}
+
+func TestRateLimitAuthFailures(t *testing.T) {
+	keys, _ := auth.LoadKeyStore("")
+	valid, _ := keys.Create(&models.APIKeyInput{Name: "script"})
+
+	handler := NewHandler(NewMockStorage(), WithAPIKeys(keys), WithAuthFailureLimit(ratelimit.NewLimiter(), 2))
+	server := handler.Authenticate(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	}))
+
+	send := func(remote, key string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", "/api/v1/vlans", nil)
+		req.RemoteAddr = remote
+		if key != "" {
+			req.Header.Set("X-API-Key", key)
+		}
+		w := httptest.NewRecorder()
+		server.ServeHTTP(w, req)
+		return w
+	}
+
+	// Successful requests take nothing from the failure allowance
+	for i := 0; i < 3; i++ {
+		if w := send("192.0.2.1:5000", valid.Key); w.Code != http.StatusNoContent {
+			t.Fatalf("Expected status %d, got %d", http.StatusNoContent, w.Code)
+		}
+	}
+
+	// Missing and invalid credentials both count
+	if w := send("192.0.2.1:5000", ""); w.Code != http.StatusUnauthorized {
+		t.Fatalf("Expected status %d, got %d", http.StatusUnauthorized, w.Code)
+	}
+	if w := send("192.0.2.1:5000", "smit_guess"); w.Code != http.StatusUnauthorized {
+		t.Fatalf("Expected status %d, got %d", http.StatusUnauthorized, w.Code)
+	}
+
+	// The address is refused before its credentials are checked, even valid ones
+	w := send("192.0.2.1:5001", valid.Key)
+	if w.Code != http.StatusTooManyRequests || w.Header().Get("Retry-After") != "30" {
+		t.Errorf("Expected 429 with Retry-After 30, got %d with %q", w.Code, w.Header().Get("Retry-After"))
+	}
+	if w := send("192.0.2.2:5000", valid.Key); w.Code != http.StatusNoContent {
+		t.Errorf("Expected another address to be allowed, got %d", w.Code)
+	}
+}
@@ -0,0 +1,47 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+
+	"smit/server/api/models"
+	"smit/server/api/storage"
+	"smit/server/api/tracing"
+)
+
+// Storage that can record its calls as children of a request's span
+type contextStorage interface {
+	WithContext(ctx context.Context) storage.Storage
+}
+
+// Start a span for a handler, returning the request that carries it
+func (h *Handler) startSpan(r *http.Request, name string) (*http.Request, *tracing.Span) {
+	ctx, span := h.tracer.Start(r.Context(), name)
+	if span == nil {
+		return r, nil
+	}
+	return r.WithContext(ctx), span
+}
+
+// VLAN storage for the request, traced when the storage supports it
+func (h *Handler) vlanStore(r *http.Request) storage.Storage {
+	if traced, ok := h.storage.(contextStorage); ok {
+		return traced.WithContext(r.Context())
+	}
+	return h.storage
+}
+
+// Decode a JSON request body in a span of its own, so slow uploads show up in traces
+func (h *Handler) decodeBody(r *http.Request, v interface{}) error {
+	_, span := h.tracer.Start(r.Context(), "DecodeBody")
+	defer span.End()
+	return json.NewDecoder(r.Body).Decode(v)
+}
+
+// Validate VLAN input in a span of its own
+func (h *Handler) validateVLAN(r *http.Request, input *models.VLANInput) error {
+	_, span := h.tracer.Start(r.Context(), "ValidateVLAN")
+	defer span.End()
+	return input.Validate()
+}
@@ -0,0 +1,126 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"smit/server/api/tracing"
+)
+
+// Span fields of an OTLP/JSON export the tests look at
+type exportedSpan struct {
+	TraceID      string `json:"traceId"`
+	SpanID       string `json:"spanId"`
+	ParentSpanID string `json:"parentSpanId"`
+	Name         string `json:"name"`
+	Attributes   []struct {
+		Key   string `json:"key"`
+		Value struct {
+			StringValue string `json:"stringValue"`
+		} `json:"value"`
+	} `json:"attributes"`
+}
+
+// Export the tracer's spans and return them by name
+func exportSpans(t *testing.T, tracer *tracing.Tracer, out *bytes.Buffer) map[string]exportedSpan {
+	t.Helper()
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	tracer.Run(ctx)
+
+	spans := make(map[string]exportedSpan)
+	for _, line := range strings.Split(strings.TrimSpace(out.String()), "\n") {
+		var request struct {
+			ResourceSpans []struct {
+				ScopeSpans []struct {
+					Spans []exportedSpan `json:"spans"`
+				} `json:"scopeSpans"`
+			} `json:"resourceSpans"`
+		}
+		if err := json.Unmarshal([]byte(line), &request); err != nil {
+			t.Fatalf("Failed to decode export %q: %v", line, err)
+		}
+		for _, resource := range request.ResourceSpans {
+			for _, scope := range resource.ScopeSpans {
+				for _, span := range scope.Spans {
+					spans[span.Name] = span
+				}
+			}
+		}
+	}
+	out.Reset()
+	return spans
+}
+
+// Routes naming every path after itself
+type pathRoutes struct{}
+
+func (pathRoutes) Route(path string) (string, bool) { return path, true }
+
+func TestTracing(t *testing.T) {
+	var out, logs bytes.Buffer
+	tracer := tracing.New(tracing.NewWriterExporter(&out), tracing.Options{SampleRatio: 1})
+	logger := slog.New(slog.NewJSONHandler(&logs, nil))
+	handler := NewHandler(tracing.NewStorage(NewMockStorage(), "mock", tracer), WithTracer(tracer), WithLogger(logger))
+	server := tracer.Handler(pathRoutes{}, handler.LogRequests(http.HandlerFunc(handler.VLANHandler)))
+
+	body := `{"name":"Office","vlan_id":100,"subnet":"10.1.0.0/24","gateway":"10.1.0.1","status":"active"}`
+	req := httptest.NewRequest("POST", "/api/v1/vlans", strings.NewReader(body))
+	req.Header.Set("traceparent", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	w := httptest.NewRecorder()
+	server.ServeHTTP(w, req)
+	if w.Code != http.StatusCreated {
+		t.Fatalf("Expected status %d, got %d: %s", http.StatusCreated, w.Code, w.Body.String())
+	}
+
+	// Each step of the create is a child of the one that called it
+	spans := exportSpans(t, tracer, &out)
+	for child, parent := range map[string]string{
+		"VLANHandler":    "POST /api/v1/vlans",
+		"CreateVLAN":     "VLANHandler",
+		"DecodeBody":     "CreateVLAN",
+		"ValidateVLAN":   "CreateVLAN",
+		"storage.Create": "CreateVLAN",
+	} {
+		span, ok := spans[child]
+		if !ok {
+			t.Errorf("Expected a %s span, got %v", child, spans)
+			continue
+		}
+		if span.ParentSpanID != spans[parent].SpanID {
+			t.Errorf("Expected %s to be a child of %s", child, parent)
+		}
+		if span.TraceID != "4bf92f3577b34da6a3ce929d0e0e4736" {
+			t.Errorf("Expected %s to continue the caller's trace, got %s", child, span.TraceID)
+		}
+	}
+
+	var entry map[string]interface{}
+	json.Unmarshal(logs.Bytes(), &entry)
+	if entry["trace_id"] != "4bf92f3577b34da6a3ce929d0e0e4736" {
+		t.Errorf("Expected the trace ID in the request log, got %v", entry["trace_id"])
+	}
+
+	// Problems are recorded on the span of the handler that sent them
+	w = httptest.NewRecorder()
+	server.ServeHTTP(w, httptest.NewRequest("POST", "/api/v1/vlans", strings.NewReader("{")))
+	spans = exportSpans(t, tracer, &out)
+	problemType := ""
+	for _, attribute := range spans["CreateVLAN"].Attributes {
+		if attribute.Key == "problem.type" {
+			problemType = attribute.Value.StringValue
+		}
+	}
+	if problemType != problemInvalidBody.slug {
+		t.Errorf("Expected problem.type %q on the span, got %q", problemInvalidBody.slug, problemType)
+	}
+	if _, ok := spans["storage.Create"]; ok {
+		t.Error("Expected no storage call for an invalid body")
+	}
+}
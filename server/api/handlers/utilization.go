@@ -0,0 +1,98 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"smit/server/api/ipam"
+	"smit/server/api/rbac"
+	"smit/server/api/storage"
+)
+
+// Read the utilization threshold from the query string, falling back to the configured one
+func (h *Handler) thresholdFromQuery(r *http.Request) (float64, error) {
+	value := r.URL.Query().Get("threshold")
+	if value == "" {
+		return h.utilizationThreshold, nil
+	}
+
+	threshold, err := strconv.ParseFloat(value, 64)
+	if err != nil || threshold < 0 || threshold > 100 {
+		return 0, errors.New("threshold must be a number between 0 and 100")
+	}
+
+	return threshold, nil
+}
+
+// Handles GET /api/v1/vlans/{id}/utilization
+func (h *Handler) GetVLANUtilization(w http.ResponseWriter, r *http.Request) {
+	r, span := h.startSpan(r, "GetVLANUtilization")
+	defer span.End()
+
+	if r.Method != http.MethodGet {
+		h.sendProblem(w, r, problemMethodNotAllowed, "Method not allowed")
+		return
+	}
+	if !h.authorize(w, r, rbac.ActionVLANRead) {
+		return
+	}
+
+	id, err := extractIDFromPath(r.URL.Path)
+	if err != nil {
+		h.sendProblem(w, r, problemInvalidID, err.Error())
+		return
+	}
+
+	threshold, err := h.thresholdFromQuery(r)
+	if err != nil {
+		h.sendProblem(w, r, problemInvalidParameter, err.Error())
+		return
+	}
+
+	vlan, err := h.vlanStore(r).GetByID(id)
+	if err != nil {
+		if errors.Is(err, storage.ErrVLANNotFound) {
+			h.sendProblem(w, r, problemVLANNotFound, "VLAN not found")
+			return
+		}
+		h.sendProblem(w, r, problemInternal, "Failed to retrieve VLAN")
+		return
+	}
+
+	utilization := ipam.VLANUtilization(*vlan, threshold)
+	if utilization.Error != "" {
+		h.sendProblem(w, r, problemInvalidSubnet, utilization.Error)
+		return
+	}
+
+	h.sendJSONResponse(w, http.StatusOK, utilization)
+}
+
+// Handles GET /api/v1/reports/utilization
+func (h *Handler) UtilizationReport(w http.ResponseWriter, r *http.Request) {
+	r, span := h.startSpan(r, "UtilizationReport")
+	defer span.End()
+
+	if r.Method != http.MethodGet {
+		h.sendProblem(w, r, problemMethodNotAllowed, "Method not allowed")
+		return
+	}
+	if !h.authorize(w, r, rbac.ActionVLANRead) {
+		return
+	}
+
+	threshold, err := h.thresholdFromQuery(r)
+	if err != nil {
+		h.sendProblem(w, r, problemInvalidParameter, err.Error())
+		return
+	}
+
+	vlans, err := h.vlanStore(r).GetAll()
+	if err != nil {
+		h.sendProblem(w, r, problemInternal, "Failed to retrieve VLANs")
+		return
+	}
+
+	h.sendJSONResponse(w, http.StatusOK, ipam.UtilizationReport(vlans, threshold))
+}
@@ -0,0 +1,133 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"smit/server/api/models"
+)
+
+func TestGetVLANUtilization(t *testing.T) {
+	storage := NewMockStorage()
+	handler := NewHandler(storage, WithUtilizationThreshold(50))
+
+	storage.Create(&models.VLANInput{
+		Name:      "Test VLAN",
+		VlanID:    100,
+		Subnet:    "192.168.100.0/24",
+		Gateway:   "192.168.100.1",
+		Status:    "active",
+		Allocated: 127,
+	})
+
+	req := httptest.NewRequest("GET", "/api/v1/vlans/1/utilization", nil)
+	w := httptest.NewRecorder()
+
+	handler.VLANHandler(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status %d, got %d", http.StatusOK, w.Code)
+	}
+
+	var result models.VLANUtilization
+	if err := json.NewDecoder(w.Body).Decode(&result); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+
+	if result.Usable != 254 || result.Free != 126 {
+		t.Errorf("Unexpected counts: %+v", result)
+	}
+	if !result.OverThreshold {
+		t.Error("Expected VLAN to be over the configured threshold")
+	}
+
+	// Query parameter overrides the configured threshold
+	req = httptest.NewRequest("GET", "/api/v1/vlans/1/utilization?threshold=90", nil)
+	w = httptest.NewRecorder()
+	handler.VLANHandler(w, req)
+
+	result = models.VLANUtilization{}
+	json.NewDecoder(w.Body).Decode(&result)
+	if result.OverThreshold {
+		t.Error("Expected VLAN to be under the requested threshold")
+	}
+}
+
+func TestGetVLANUtilizationErrors(t *testing.T) {
+	handler := NewHandler(NewMockStorage())
+
+	tests := []struct {
+		name     string
+		method   string
+		path     string
+		expected int
+	}{
+		{"Not found", "GET", "/api/v1/vlans/999/utilization", http.StatusNotFound},
+		{"Invalid threshold", "GET", "/api/v1/vlans/1/utilization?threshold=abc", http.StatusBadRequest},
+		{"Method not allowed", "POST", "/api/v1/vlans/1/utilization", http.StatusMethodNotAllowed},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, tt.path, nil)
+			w := httptest.NewRecorder()
+
+			handler.VLANHandler(w, req)
+
+			if w.Code != tt.expected {
+				t.Errorf("Expected status %d, got %d", tt.expected, w.Code)
+			}
+		})
+	}
+}
+
+func TestUtilizationReport(t *testing.T) {
+	storage := NewMockStorage()
+	handler := NewHandler(storage)
+
+	storage.Create(&models.VLANInput{
+		Name:    "VLAN 1",
+		VlanID:  100,
+		Subnet:  "10.0.1.0/24",
+		Gateway: "10.0.1.1",
+		Status:  "active",
+		Site:    "tallinn",
+	})
+	storage.Create(&models.VLANInput{
+		Name:    "VLAN 2",
+		VlanID:  200,
+		Subnet:  "10.0.2.0/24",
+		Gateway: "10.0.2.1",
+		Status:  "inactive",
+		Site:    "tallinn",
+	})
+
+	req := httptest.NewRequest("GET", "/api/v1/reports/utilization", nil)
+	w := httptest.NewRecorder()
+
+	handler.UtilizationReport(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status %d, got %d", http.StatusOK, w.Code)
+	}
+
+	var report models.UtilizationReport
+	if err := json.NewDecoder(w.Body).Decode(&report); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+
+	if report.Threshold != DefaultUtilizationThreshold {
+		t.Errorf("Expected threshold %v, got %v", DefaultUtilizationThreshold, report.Threshold)
+	}
+	if len(report.VLANs) != 2 {
+		t.Errorf("Expected 2 VLANs, got %d", len(report.VLANs))
+	}
+	if report.Summary.BySite["tallinn"].VLANs != 2 {
+		t.Errorf("Expected 2 VLANs in site tallinn, got %d", report.Summary.BySite["tallinn"].VLANs)
+	}
+	if report.Summary.ByStatus["inactive"].VLANs != 1 {
+		t.Errorf("Expected 1 inactive VLAN, got %d", report.Summary.ByStatus["inactive"].VLANs)
+	}
+}
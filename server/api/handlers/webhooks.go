@@ -0,0 +1,276 @@
+package handlers
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+
+	"smit/server/api/models"
+	"smit/server/api/rbac"
+	"smit/server/api/storage"
+)
+
+// Handles GET /api/v1/webhooks
+func (h *Handler) GetWebhooks(w http.ResponseWriter, r *http.Request) {
+	r, span := h.startSpan(r, "GetWebhooks")
+	defer span.End()
+
+	if r.Method != http.MethodGet {
+		h.sendProblem(w, r, problemMethodNotAllowed, "Method not allowed")
+		return
+	}
+	if !h.authorize(w, r, rbac.ActionWebhookRead) {
+		return
+	}
+
+	webhooks, err := h.webhooks.GetAllWebhooks()
+	if err != nil {
+		h.sendProblem(w, r, problemInternal, "Failed to retrieve webhooks")
+		return
+	}
+
+	// Secrets are only shown when a webhook is created
+	redacted := make([]models.Webhook, 0, len(webhooks))
+	for _, webhook := range webhooks {
+		redacted = append(redacted, webhook.Redacted())
+	}
+
+	h.sendJSONResponse(w, http.StatusOK, redacted)
+}
+
+// Handles POST /api/v1/webhooks
+func (h *Handler) CreateWebhook(w http.ResponseWriter, r *http.Request) {
+	r, span := h.startSpan(r, "CreateWebhook")
+	defer span.End()
+
+	if r.Method != http.MethodPost {
+		h.sendProblem(w, r, problemMethodNotAllowed, "Method not allowed")
+		return
+	}
+	if !h.authorize(w, r, rbac.ActionWebhookWrite) {
+		return
+	}
+
+	var input models.WebhookInput
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		h.sendProblem(w, r, problemInvalidBody, "Invalid request body")
+		return
+	}
+
+	// Validate input
+	if err := input.Validate(); err != nil {
+		h.sendValidationError(w, r, err)
+		return
+	}
+	if h.dispatcher != nil {
+		if err := h.dispatcher.CheckURL(input.URL); err != nil {
+			var errs models.ValidationErrors
+			errs.Add("url", models.CodeInvalidValue, "url must not point to a loopback, private or link-local address")
+			h.sendValidationError(w, r, errs)
+			return
+		}
+	}
+
+	if input.Secret == "" {
+		secret := make([]byte, 32)
+		if _, err := rand.Read(secret); err != nil {
+			h.sendProblem(w, r, problemInternal, "Failed to generate webhook secret")
+			return
+		}
+		input.Secret = hex.EncodeToString(secret)
+	}
+
+	webhook, err := h.webhooks.CreateWebhook(&input)
+	if err != nil {
+		h.sendProblem(w, r, problemInternal, "Failed to create webhook")
+		return
+	}
+
+	h.sendJSONResponse(w, http.StatusCreated, webhook)
+}
+
+// Handles GET /api/v1/webhooks/{id}
+func (h *Handler) GetWebhook(w http.ResponseWriter, r *http.Request) {
+	r, span := h.startSpan(r, "GetWebhook")
+	defer span.End()
+
+	if r.Method != http.MethodGet {
+		h.sendProblem(w, r, problemMethodNotAllowed, "Method not allowed")
+		return
+	}
+	if !h.authorize(w, r, rbac.ActionWebhookRead) {
+		return
+	}
+
+	webhook, ok := h.webhookFromPath(w, r)
+	if !ok {
+		return
+	}
+
+	h.sendJSONResponse(w, http.StatusOK, webhook.Redacted())
+}
+
+// Handles DELETE /api/v1/webhooks/{id}
+func (h *Handler) DeleteWebhook(w http.ResponseWriter, r *http.Request) {
+	r, span := h.startSpan(r, "DeleteWebhook")
+	defer span.End()
+
+	if r.Method != http.MethodDelete {
+		h.sendProblem(w, r, problemMethodNotAllowed, "Method not allowed")
+		return
+	}
+	if !h.authorize(w, r, rbac.ActionWebhookWrite) {
+		return
+	}
+
+	id, err := extractIDFromPath(r.URL.Path)
+	if err != nil {
+		h.sendProblem(w, r, problemInvalidID, err.Error())
+		return
+	}
+
+	if err := h.webhooks.DeleteWebhook(id); err != nil {
+		if errors.Is(err, storage.ErrWebhookNotFound) {
+			h.sendProblem(w, r, problemWebhookNotFound, "Webhook not found")
+			return
+		}
+		h.sendProblem(w, r, problemInternal, "Failed to delete webhook")
+		return
+	}
+
+	if h.dispatcher != nil {
+		h.dispatcher.Forget(id)
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// Handles GET /api/v1/webhooks/{id}/deliveries
+func (h *Handler) GetWebhookDeliveries(w http.ResponseWriter, r *http.Request) {
+	r, span := h.startSpan(r, "GetWebhookDeliveries")
+	defer span.End()
+
+	if r.Method != http.MethodGet {
+		h.sendProblem(w, r, problemMethodNotAllowed, "Method not allowed")
+		return
+	}
+	if !h.authorize(w, r, rbac.ActionWebhookRead) {
+		return
+	}
+
+	webhook, ok := h.webhookFromPath(w, r)
+	if !ok {
+		return
+	}
+
+	deliveries := []models.WebhookDelivery{}
+	if h.dispatcher != nil {
+		deliveries = h.dispatcher.Deliveries(webhook.ID)
+	}
+	h.sendJSONResponse(w, http.StatusOK, deliveries)
+}
+
+// Handles GET /api/v1/webhooks/dead-letters and /api/v1/webhooks/{id}/dead-letters
+func (h *Handler) GetWebhookDeadLetters(w http.ResponseWriter, r *http.Request) {
+	r, span := h.startSpan(r, "GetWebhookDeadLetters")
+	defer span.End()
+
+	if r.Method != http.MethodGet {
+		h.sendProblem(w, r, problemMethodNotAllowed, "Method not allowed")
+		return
+	}
+	if !h.authorize(w, r, rbac.ActionWebhookRead) {
+		return
+	}
+
+	webhookID := 0
+	if r.URL.Path != "/api/v1/webhooks/dead-letters" {
+		webhook, ok := h.webhookFromPath(w, r)
+		if !ok {
+			return
+		}
+		webhookID = webhook.ID
+	}
+
+	dead := []models.WebhookDelivery{}
+	if h.dispatcher != nil {
+		dead = h.dispatcher.DeadLetters(webhookID)
+	}
+	h.sendJSONResponse(w, http.StatusOK, dead)
+}
+
+// Load the webhook named by the request path, sending the error response if there is none
+func (h *Handler) webhookFromPath(w http.ResponseWriter, r *http.Request) (*models.Webhook, bool) {
+	id, err := extractIDFromPath(r.URL.Path)
+	if err != nil {
+		h.sendProblem(w, r, problemInvalidID, err.Error())
+		return nil, false
+	}
+
+	webhook, err := h.webhooks.GetWebhookByID(id)
+	if err != nil {
+		if errors.Is(err, storage.ErrWebhookNotFound) {
+			h.sendProblem(w, r, problemWebhookNotFound, "Webhook not found")
+			return nil, false
+		}
+		h.sendProblem(w, r, problemInternal, "Failed to retrieve webhook")
+		return nil, false
+	}
+
+	return webhook, true
+}
+
+// Handler for webhook endpoints
+func (h *Handler) WebhookHandler(w http.ResponseWriter, r *http.Request) {
+	r, span := h.startSpan(r, "WebhookHandler")
+	defer span.End()
+
+	if h.webhooks == nil {
+		h.sendProblem(w, r, problemNotImplemented, "Webhook storage is not configured")
+		return
+	}
+
+	path := r.URL.Path
+
+	// Handle /api/v1/webhooks
+	if path == "/api/v1/webhooks" {
+		switch r.Method {
+		case http.MethodGet:
+			h.GetWebhooks(w, r)
+		case http.MethodPost:
+			h.CreateWebhook(w, r)
+		default:
+			h.sendProblem(w, r, problemMethodNotAllowed, "Method not allowed")
+		}
+		return
+	}
+
+	// Handle /api/v1/webhooks/dead-letters and /api/v1/webhooks/{id}/dead-letters
+	if strings.HasSuffix(path, "/dead-letters") {
+		h.GetWebhookDeadLetters(w, r)
+		return
+	}
+
+	// Handle /api/v1/webhooks/{id}/deliveries
+	if strings.HasPrefix(path, "/api/v1/webhooks/") && strings.HasSuffix(path, "/deliveries") {
+		h.GetWebhookDeliveries(w, r)
+		return
+	}
+
+	// Handle /api/v1/webhooks/{id}
+	if strings.HasPrefix(path, "/api/v1/webhooks/") {
+		switch r.Method {
+		case http.MethodGet:
+			h.GetWebhook(w, r)
+		case http.MethodDelete:
+			h.DeleteWebhook(w, r)
+		default:
+			h.sendProblem(w, r, problemMethodNotAllowed, "Method not allowed")
+		}
+		return
+	}
+
+	h.sendProblem(w, r, problemEndpointNotFound, "Endpoint not found")
+}
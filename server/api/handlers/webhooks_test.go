@@ -0,0 +1,122 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"smit/server/api/events"
+	"smit/server/api/models"
+	"smit/server/api/storage"
+	"smit/server/api/webhooks"
+)
+
+func newWebhookHandler(t *testing.T) *Handler {
+	t.Helper()
+	store, err := storage.NewJSONStorage(filepath.Join(t.TempDir(), "webhooks_test.json"))
+	if err != nil {
+		t.Fatalf("Failed to create storage: %v", err)
+	}
+	dispatcher := webhooks.New(store, events.NewBroker(10), webhooks.Options{})
+	return NewHandler(NewMockStorage(), WithWebhooks(store, dispatcher))
+}
+
+func TestCreateWebhook(t *testing.T) {
+	tests := []struct {
+		name     string
+		body     string
+		expected int
+		secret   string
+	}{
+		{"Given secret", `{"url": "https://cmdb.example.com/hooks", "events": ["created"], "secret": "0123456789abcdef"}`, http.StatusCreated, "0123456789abcdef"},
+		{"Generated secret", `{"url": "https://cmdb.example.com/hooks"}`, http.StatusCreated, ""},
+		{"Invalid URL", `{"url": "cmdb.example.com/hooks"}`, http.StatusBadRequest, ""},
+		{"Internal address", `{"url": "http://169.254.169.254/latest/meta-data"}`, http.StatusBadRequest, ""},
+		{"Unknown event", `{"url": "https://cmdb.example.com/hooks", "events": ["renamed"]}`, http.StatusBadRequest, ""},
+		{"Invalid body", `{"url": `, http.StatusBadRequest, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			handler := newWebhookHandler(t)
+
+			req := httptest.NewRequest("POST", "/api/v1/webhooks", strings.NewReader(tt.body))
+			w := httptest.NewRecorder()
+			handler.WebhookHandler(w, req)
+
+			if w.Code != tt.expected {
+				t.Fatalf("Expected status %d, got %d: %s", tt.expected, w.Code, w.Body.String())
+			}
+			if w.Code != http.StatusCreated {
+				return
+			}
+
+			var webhook models.Webhook
+			if err := json.NewDecoder(w.Body).Decode(&webhook); err != nil {
+				t.Fatalf("Failed to decode response: %v", err)
+			}
+			if tt.secret != "" && webhook.Secret != tt.secret {
+				t.Errorf("Expected secret %s, got %s", tt.secret, webhook.Secret)
+			}
+			if len(webhook.Secret) < models.MinWebhookSecretLength {
+				t.Errorf("Expected the secret to be returned on create, got %q", webhook.Secret)
+			}
+		})
+	}
+}
+
+func TestWebhookEndpoints(t *testing.T) {
+	handler := newWebhookHandler(t)
+
+	req := httptest.NewRequest("POST", "/api/v1/webhooks", strings.NewReader(`{"url": "https://cmdb.example.com/hooks"}`))
+	handler.WebhookHandler(httptest.NewRecorder(), req)
+
+	tests := []struct {
+		name     string
+		method   string
+		path     string
+		expected int
+	}{
+		{"List", "GET", "/api/v1/webhooks", http.StatusOK},
+		{"Get", "GET", "/api/v1/webhooks/1", http.StatusOK},
+		{"Deliveries", "GET", "/api/v1/webhooks/1/deliveries", http.StatusOK},
+		{"Dead letters", "GET", "/api/v1/webhooks/1/dead-letters", http.StatusOK},
+		{"All dead letters", "GET", "/api/v1/webhooks/dead-letters", http.StatusOK},
+		{"Missing webhook", "GET", "/api/v1/webhooks/9", http.StatusNotFound},
+		{"Deliveries of missing webhook", "GET", "/api/v1/webhooks/9/deliveries", http.StatusNotFound},
+		{"Invalid ID", "GET", "/api/v1/webhooks/abc", http.StatusBadRequest},
+		{"Unsupported method", "PUT", "/api/v1/webhooks/1", http.StatusMethodNotAllowed},
+		{"Delete", "DELETE", "/api/v1/webhooks/1", http.StatusNoContent},
+		{"Delete again", "DELETE", "/api/v1/webhooks/1", http.StatusNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, tt.path, nil)
+			w := httptest.NewRecorder()
+			handler.WebhookHandler(w, req)
+
+			if w.Code != tt.expected {
+				t.Fatalf("Expected status %d, got %d: %s", tt.expected, w.Code, w.Body.String())
+			}
+			if tt.method == "GET" && w.Code == http.StatusOK && strings.Contains(w.Body.String(), `"secret"`) {
+				t.Errorf("Expected secret to be redacted, got %s", w.Body.String())
+			}
+		})
+	}
+}
+
+func TestWebhooksNotConfigured(t *testing.T) {
+	handler := NewHandler(NewMockStorage())
+
+	req := httptest.NewRequest("GET", "/api/v1/webhooks", nil)
+	w := httptest.NewRecorder()
+	handler.WebhookHandler(w, req)
+
+	if w.Code != http.StatusNotImplemented {
+		t.Errorf("Expected status %d, got %d", http.StatusNotImplemented, w.Code)
+	}
+}
@@ -0,0 +1,165 @@
+// Package health runs the checks behind the readiness and liveness probes.
+// Checks are pluggable: anything that implements Checker can be registered
+// under a name, and every check runs on each probe with its own deadline.
+package health
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"smit/server/api/models"
+	"smit/server/api/storage"
+)
+
+// Default time a single check may take, below the probes' timeouts
+const DefaultTimeout = 2 * time.Second
+
+// Check results
+const (
+	StatusPass = "pass"
+	StatusFail = "fail"
+)
+
+// Verifies one dependency of the server. The detail describes a passing check;
+// checks should give up when the context is done.
+type Checker interface {
+	Check(ctx context.Context) (detail string, err error)
+}
+
+// Function used as a Checker
+type CheckerFunc func(ctx context.Context) (string, error)
+
+func (f CheckerFunc) Check(ctx context.Context) (string, error) {
+	return f(ctx)
+}
+
+type namedChecker struct {
+	name    string
+	checker Checker
+}
+
+// Named checks run together. The registry is safe for concurrent use.
+type Registry struct {
+	timeout  time.Duration
+	mu       sync.Mutex
+	checkers []namedChecker
+}
+
+// Create an empty registry whose checks each get the given timeout
+func NewRegistry(timeout time.Duration) *Registry {
+	if timeout <= 0 {
+		timeout = DefaultTimeout
+	}
+	return &Registry{timeout: timeout}
+}
+
+// Add a check; registering a name twice is a programming error, like a duplicate route
+func (r *Registry) Register(name string, checker Checker) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, c := range r.checkers {
+		if c.name == name {
+			panic("health: duplicate check " + name)
+		}
+	}
+	r.checkers = append(r.checkers, namedChecker{name, checker})
+}
+
+// Run every check concurrently and report whether all of them passed
+func (r *Registry) Run(ctx context.Context) (map[string]models.CheckResult, bool) {
+	r.mu.Lock()
+	checkers := append([]namedChecker(nil), r.checkers...)
+	r.mu.Unlock()
+
+	results := make([]models.CheckResult, len(checkers))
+	var wg sync.WaitGroup
+	for i, c := range checkers {
+		wg.Add(1)
+		go func(i int, checker Checker) {
+			defer wg.Done()
+			results[i] = r.run(ctx, checker)
+		}(i, c.checker)
+	}
+	wg.Wait()
+
+	report := make(map[string]models.CheckResult, len(checkers))
+	passed := true
+	for i, c := range checkers {
+		report[c.name] = results[i]
+		passed = passed && results[i].Status == StatusPass
+	}
+	return report, passed
+}
+
+// Run one check, failing it when it outlives its deadline
+func (r *Registry) run(ctx context.Context, checker Checker) models.CheckResult {
+	ctx, cancel := context.WithTimeout(ctx, r.timeout)
+	defer cancel()
+
+	type outcome struct {
+		detail string
+		err    error
+	}
+	start := time.Now()
+	// Buffered so a check that ignores its context does not leak a blocked goroutine
+	done := make(chan outcome, 1)
+	go func() {
+		detail, err := checker.Check(ctx)
+		done <- outcome{detail, err}
+	}()
+
+	var o outcome
+	select {
+	case o = <-done:
+	case <-ctx.Done():
+		o.err = fmt.Errorf("timed out after %s", r.timeout)
+	}
+
+	result := models.CheckResult{
+		Status:     StatusPass,
+		Detail:     o.detail,
+		DurationMs: float64(time.Since(start).Microseconds()) / 1000,
+	}
+	if o.err != nil {
+		result.Status = StatusFail
+		result.Detail = ""
+		result.Error = o.err.Error()
+	}
+	return result
+}
+
+// Check that the VLAN data can be read and decoded through the storage
+func StorageChecker(store storage.Storage) Checker {
+	return CheckerFunc(func(ctx context.Context) (string, error) {
+		vlans, err := store.GetAll()
+		if err != nil {
+			return "", err
+		}
+		return fmt.Sprintf("%d VLANs", len(vlans)), nil
+	})
+}
+
+// Check that a file can be created in the directory, as saving the data file requires
+func WritableChecker(dir string) Checker {
+	return CheckerFunc(func(ctx context.Context) (string, error) {
+		file, err := os.CreateTemp(dir, ".ready-*")
+		if err != nil {
+			return "", fmt.Errorf("directory is not writable: %w", err)
+		}
+		name := file.Name()
+		defer os.Remove(name)
+
+		if _, err := file.Write([]byte("ok")); err != nil {
+			file.Close()
+			return "", fmt.Errorf("directory is not writable: %w", err)
+		}
+		if err := file.Close(); err != nil {
+			return "", fmt.Errorf("directory is not writable: %w", err)
+		}
+		return dir + " is writable", nil
+	})
+}
@@ -0,0 +1,101 @@
+package health
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"smit/server/api/models"
+	"smit/server/api/storage"
+)
+
+func TestRegistry(t *testing.T) {
+	registry := NewRegistry(50 * time.Millisecond)
+	registry.Register("ok", CheckerFunc(func(ctx context.Context) (string, error) {
+		return "fine", nil
+	}))
+
+	results, passed := registry.Run(context.Background())
+	if !passed || results["ok"].Status != StatusPass || results["ok"].Detail != "fine" {
+		t.Fatalf("Expected a passing check, got %+v", results)
+	}
+
+	registry.Register("broken", CheckerFunc(func(ctx context.Context) (string, error) {
+		return "ignored", errors.New("disk on fire")
+	}))
+	registry.Register("stuck", CheckerFunc(func(ctx context.Context) (string, error) {
+		time.Sleep(time.Second)
+		return "", nil
+	}))
+
+	start := time.Now()
+	results, passed = registry.Run(context.Background())
+	if passed {
+		t.Fatal("Expected the run to fail")
+	}
+	if time.Since(start) > 500*time.Millisecond {
+		t.Error("Expected a stuck check to be abandoned at its timeout")
+	}
+	if results["ok"].Status != StatusPass {
+		t.Errorf("Expected the other checks to be unaffected, got %+v", results["ok"])
+	}
+	if r := results["broken"]; r.Status != StatusFail || r.Error != "disk on fire" || r.Detail != "" {
+		t.Errorf("Unexpected result %+v", r)
+	}
+	if r := results["stuck"]; r.Status != StatusFail || r.Error != "timed out after 50ms" {
+		t.Errorf("Unexpected result %+v", r)
+	}
+}
+
+func TestRegistryDuplicate(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Error("Expected a duplicate check to panic")
+		}
+	}()
+	registry := NewRegistry(0)
+	registry.Register("storage", CheckerFunc(func(ctx context.Context) (string, error) { return "", nil }))
+	registry.Register("storage", CheckerFunc(func(ctx context.Context) (string, error) { return "", nil }))
+}
+
+func TestStorageChecker(t *testing.T) {
+	dataFile := filepath.Join(t.TempDir(), "data.json")
+	store, err := storage.NewJSONStorage(dataFile)
+	if err != nil {
+		t.Fatalf("Failed to create storage: %v", err)
+	}
+	store.Create(&models.VLANInput{Name: "Office", VlanID: 100, Subnet: "10.1.0.0/24", Gateway: "10.1.0.1", Status: "active"})
+
+	checker := StorageChecker(store)
+	if detail, err := checker.Check(context.Background()); err != nil || detail != "1 VLANs" {
+		t.Errorf("Expected a passing check, got %q, %v", detail, err)
+	}
+
+	os.WriteFile(dataFile, []byte("{not json"), 0644)
+	if _, err := checker.Check(context.Background()); err == nil {
+		t.Error("Expected a corrupt data file to fail the check")
+	}
+
+	os.Remove(dataFile)
+	if _, err := checker.Check(context.Background()); err == nil {
+		t.Error("Expected a missing data file to fail the check")
+	}
+}
+
+func TestWritableChecker(t *testing.T) {
+	dir := t.TempDir()
+	if _, err := WritableChecker(dir).Check(context.Background()); err != nil {
+		t.Errorf("Expected a writable directory to pass, got %v", err)
+	}
+	entries, _ := os.ReadDir(dir)
+	if len(entries) != 0 {
+		t.Errorf("Expected the probe file to be removed, found %d entries", len(entries))
+	}
+
+	if _, err := WritableChecker(filepath.Join(dir, "missing")).Check(context.Background()); err == nil {
+		t.Error("Expected a missing directory to fail")
+	}
+}
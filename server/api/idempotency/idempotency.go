@@ -0,0 +1,154 @@
+// Package idempotency remembers responses to requests sent with an Idempotency-Key,
+// so a retried request gets the original response instead of being executed twice.
+package idempotency
+
+import (
+	"container/list"
+	"errors"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// Default time a key and its response are kept
+const DefaultTTL = 24 * time.Hour
+
+// Default number of keys kept before the oldest are evicted
+const DefaultMaxEntries = 10000
+
+var (
+	// The key was already used for a request with a different fingerprint
+	ErrKeyReused = errors.New("idempotency key reused with a different request")
+	// The first request with the key has not finished yet
+	ErrInProgress = errors.New("request with this idempotency key is in progress")
+)
+
+// Stored response of a completed request
+type Response struct {
+	Status int
+	Header http.Header
+	Body   []byte
+}
+
+type entry struct {
+	key         string
+	fingerprint string
+	// Nil while the first request is in progress
+	response *Response
+	expires  time.Time
+}
+
+// In-memory store of idempotency keys and their responses. Entries expire after
+// the TTL, and past the maximum entry count the oldest completed entries are
+// evicted first. The store is safe for concurrent use.
+type Store struct {
+	mu      sync.Mutex
+	entries map[string]*list.Element
+	// Entries ordered by expiry, oldest first; with a fixed TTL that is the order of last use
+	order      *list.List
+	ttl        time.Duration
+	maxEntries int
+	now        func() time.Time
+}
+
+// Create a new store keeping up to maxEntries keys for the given TTL
+func NewStore(ttl time.Duration, maxEntries int) *Store {
+	if ttl <= 0 {
+		ttl = DefaultTTL
+	}
+	if maxEntries <= 0 {
+		maxEntries = DefaultMaxEntries
+	}
+
+	return &Store{
+		entries:    make(map[string]*list.Element),
+		order:      list.New(),
+		ttl:        ttl,
+		maxEntries: maxEntries,
+		now:        time.Now,
+	}
+}
+
+// Claim a key for a request. A nil response means the caller owns the key and must
+// call Complete or Release; otherwise the stored response of the first request is returned.
+func (s *Store) Begin(key, fingerprint string) (*Response, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := s.now()
+	s.sweep(now)
+
+	if element, ok := s.entries[key]; ok {
+		e := element.Value.(*entry)
+		if e.fingerprint != fingerprint {
+			return nil, ErrKeyReused
+		}
+		if e.response == nil {
+			return nil, ErrInProgress
+		}
+		return e.response, nil
+	}
+
+	for len(s.entries) >= s.maxEntries {
+		s.evict()
+	}
+	s.entries[key] = s.order.PushBack(&entry{key: key, fingerprint: fingerprint, expires: now.Add(s.ttl)})
+	return nil, nil
+}
+
+// Store the response of a claimed key; replays return it until the key expires
+func (s *Store) Complete(key string, response *Response) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if element, ok := s.entries[key]; ok {
+		e := element.Value.(*entry)
+		e.response = response
+		e.expires = s.now().Add(s.ttl)
+		s.order.MoveToBack(element)
+	}
+}
+
+// Give up a claimed key without storing a response, so the request can be retried
+func (s *Store) Release(key string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if element, ok := s.entries[key]; ok && element.Value.(*entry).response == nil {
+		s.remove(element)
+	}
+}
+
+// Number of keys held
+func (s *Store) Len() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.entries)
+}
+
+// Drop expired entries from the front of the expiry order
+func (s *Store) sweep(now time.Time) {
+	for element := s.order.Front(); element != nil; element = s.order.Front() {
+		if now.Before(element.Value.(*entry).expires) {
+			return
+		}
+		s.remove(element)
+	}
+}
+
+// Drop the oldest completed entry. Requests still in progress are only evicted
+// when nothing else is left, since their retries would run again.
+func (s *Store) evict() {
+	for element := s.order.Front(); element != nil; element = element.Next() {
+		if element.Value.(*entry).response != nil {
+			s.remove(element)
+			return
+		}
+	}
+	s.remove(s.order.Front())
+}
+
+func (s *Store) remove(element *list.Element) {
+	s.order.Remove(element)
+	delete(s.entries, element.Value.(*entry).key)
+}
@@ -0,0 +1,105 @@
+package idempotency
+
+import (
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+)
+
+// Store with a clock the test controls
+func newTestStore(ttl time.Duration) (*Store, *time.Time) {
+	now := time.Date(2024, 7, 15, 10, 0, 0, 0, time.UTC)
+	store := NewStore(ttl, 0)
+	store.now = func() time.Time { return now }
+	return store, &now
+}
+
+func TestStoreReplay(t *testing.T) {
+	store, _ := newTestStore(time.Hour)
+
+	if response, err := store.Begin("key-1", "fp-a"); response != nil || err != nil {
+		t.Fatalf("Expected first request to claim the key, got %v, %v", response, err)
+	}
+	if _, err := store.Begin("key-1", "fp-a"); !errors.Is(err, ErrInProgress) {
+		t.Errorf("Expected ErrInProgress while the first request runs, got %v", err)
+	}
+
+	store.Complete("key-1", &Response{Status: http.StatusCreated, Body: []byte(`{"id":1}`)})
+
+	response, err := store.Begin("key-1", "fp-a")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if response == nil || response.Status != http.StatusCreated || string(response.Body) != `{"id":1}` {
+		t.Errorf("Expected stored response, got %+v", response)
+	}
+
+	if _, err := store.Begin("key-1", "fp-b"); !errors.Is(err, ErrKeyReused) {
+		t.Errorf("Expected ErrKeyReused for a different fingerprint, got %v", err)
+	}
+}
+
+func TestStoreRelease(t *testing.T) {
+	store, _ := newTestStore(time.Hour)
+
+	store.Begin("key-1", "fp-a")
+	store.Release("key-1")
+
+	if response, err := store.Begin("key-1", "fp-b"); response != nil || err != nil {
+		t.Errorf("Expected a released key to be claimable again, got %v, %v", response, err)
+	}
+}
+
+func TestStoreExpiry(t *testing.T) {
+	store, now := newTestStore(time.Hour)
+
+	store.Begin("key-1", "fp-a")
+	store.Complete("key-1", &Response{Status: http.StatusCreated})
+
+	*now = now.Add(59 * time.Minute)
+	if response, _ := store.Begin("key-1", "fp-a"); response == nil {
+		t.Fatal("Expected response to be kept within the TTL")
+	}
+
+	*now = now.Add(2 * time.Minute)
+	if response, err := store.Begin("key-1", "fp-b"); response != nil || err != nil {
+		t.Errorf("Expected an expired key to be claimable again, got %v, %v", response, err)
+	}
+	if store.Len() != 1 {
+		t.Errorf("Expected expired entries to be swept, got %d entries", store.Len())
+	}
+}
+
+func TestStoreEviction(t *testing.T) {
+	store, now := newTestStore(time.Hour)
+	store.maxEntries = 3
+
+	store.Begin("in-progress", "fp")
+	for _, key := range []string{"key-1", "key-2"} {
+		*now = now.Add(time.Minute)
+		store.Begin(key, "fp")
+		store.Complete(key, &Response{Status: http.StatusCreated})
+	}
+
+	// The oldest completed key makes room; the request still in progress keeps its claim
+	store.Begin("key-3", "fp")
+	if store.Len() != 3 {
+		t.Fatalf("Expected 3 entries, got %d", store.Len())
+	}
+	if response, err := store.Begin("key-1", "fp-other"); response != nil || err != nil {
+		t.Errorf("Expected key-1 to have been evicted, got %v, %v", response, err)
+	}
+	if _, err := store.Begin("in-progress", "fp"); !errors.Is(err, ErrInProgress) {
+		t.Errorf("Expected the request in progress to be kept, got %v", err)
+	}
+
+	// With only requests in progress left, the oldest of them goes
+	store, _ = newTestStore(time.Hour)
+	store.maxEntries = 1
+	store.Begin("first", "fp")
+	store.Begin("second", "fp")
+	if _, err := store.Begin("first", "fp"); err != nil {
+		t.Errorf("Expected the first claim to have been evicted, got %v", err)
+	}
+}
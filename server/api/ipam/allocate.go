@@ -0,0 +1,111 @@
+package ipam
+
+import (
+	"errors"
+	"fmt"
+	"net/netip"
+)
+
+// Gateway placement policies for carved subnets
+const (
+	GatewayPolicyFirst = "first"
+	GatewayPolicyLast  = "last"
+)
+
+var (
+	ErrInvalidLength = errors.New("invalid prefix length")
+	ErrNoFreeSubnet  = errors.New("no free subnet of the requested length")
+)
+
+// Find the first block of the given length inside parent that does not overlap any of the used subnets.
+// Used subnets that cannot be parsed are ignored.
+func NextFreeSubnet(parent string, length int, used []string) (netip.Prefix, error) {
+	p, err := netip.ParsePrefix(parent)
+	if err != nil {
+		return netip.Prefix{}, fmt.Errorf("invalid parent prefix %q: %w", parent, err)
+	}
+	p = p.Masked()
+
+	if length < p.Bits() || length > p.Addr().BitLen() {
+		return netip.Prefix{}, fmt.Errorf("%w: must be between %d and %d", ErrInvalidLength, p.Bits(), p.Addr().BitLen())
+	}
+
+	// Only subnets overlapping the parent can block a candidate
+	var blocking []netip.Prefix
+	for _, subnet := range used {
+		u, err := netip.ParsePrefix(subnet)
+		if err != nil {
+			continue
+		}
+		u = u.Masked()
+		if u.Overlaps(p) {
+			blocking = append(blocking, u)
+		}
+	}
+
+	candidate := netip.PrefixFrom(p.Addr(), length)
+	for p.Contains(candidate.Addr()) {
+		overlap, found := firstOverlap(candidate, blocking)
+		if !found {
+			return candidate, nil
+		}
+
+		// Skip past whichever of the two blocks is larger
+		skip := candidate
+		if overlap.Bits() < candidate.Bits() {
+			skip = overlap
+		}
+
+		next := lastAddr(skip).Next()
+		if !next.IsValid() {
+			break
+		}
+		candidate = netip.PrefixFrom(next, length)
+	}
+
+	return netip.Prefix{}, ErrNoFreeSubnet
+}
+
+// Pick the gateway address of a subnet according to the policy
+func GatewayForPolicy(subnet netip.Prefix, policy string) (string, error) {
+	subnet = subnet.Masked()
+	// IPv4 subnets larger than /31 have network and broadcast addresses
+	hasBroadcast := subnet.Addr().Is4() && subnet.Bits() <= 30
+
+	switch policy {
+	case "", GatewayPolicyFirst:
+		addr := subnet.Addr()
+		if hasBroadcast || subnet.Addr().Is6() && subnet.Bits() < 128 {
+			addr = addr.Next()
+		}
+		return addr.String(), nil
+	case GatewayPolicyLast:
+		addr := lastAddr(subnet)
+		if hasBroadcast {
+			addr = addr.Prev()
+		}
+		return addr.String(), nil
+	default:
+		return "", fmt.Errorf("gateway_policy must be one of: %s, %s", GatewayPolicyFirst, GatewayPolicyLast)
+	}
+}
+
+// Find the first blocking subnet overlapping the candidate
+func firstOverlap(candidate netip.Prefix, blocking []netip.Prefix) (netip.Prefix, bool) {
+	for _, u := range blocking {
+		if u.Overlaps(candidate) {
+			return u, true
+		}
+	}
+	return netip.Prefix{}, false
+}
+
+// Last address of a prefix
+func lastAddr(p netip.Prefix) netip.Addr {
+	bytes := p.Masked().Addr().AsSlice()
+	for bit := p.Bits(); bit < len(bytes)*8; bit++ {
+		bytes[bit/8] |= 0x80 >> uint(bit%8)
+	}
+	addr, _ := netip.AddrFromSlice(bytes)
+	return addr
+}
@@ -0,0 +1,64 @@
+package ipam
+
+import (
+	"fmt"
+	"math"
+	"net"
+)
+
+// Address capacity of a subnet
+type Capacity struct {
+	Total  uint64
+	Usable uint64
+}
+
+// Calculate the address capacity of a CIDR subnet.
+// Counts saturate at math.MaxUint64 for very large IPv6 prefixes.
+func SubnetCapacity(cidr string) (Capacity, error) {
+	_, ipNet, err := net.ParseCIDR(cidr)
+	if err != nil {
+		return Capacity{}, fmt.Errorf("invalid subnet %q: %w", cidr, err)
+	}
+
+	ones, bits := ipNet.Mask.Size()
+	hostBits := bits - ones
+
+	var total uint64
+	if hostBits >= 64 {
+		total = math.MaxUint64
+	} else {
+		total = uint64(1) << uint(hostBits)
+	}
+
+	usable := total
+	// IPv4 subnets lose the network and broadcast addresses,
+	// except for point-to-point /31 and host /32 prefixes (RFC 3021)
+	if bits == 32 && hostBits >= 2 {
+		usable = total - 2
+	}
+
+	return Capacity{Total: total, Usable: usable}, nil
+}
+
+// Check whether an IP address is inside a CIDR subnet
+func SubnetContains(cidr, ip string) bool {
+	_, ipNet, err := net.ParseCIDR(cidr)
+	if err != nil {
+		return false
+	}
+
+	parsedIP := net.ParseIP(ip)
+	if parsedIP == nil {
+		return false
+	}
+
+	return ipNet.Contains(parsedIP)
+}
+
+// Add two counts, saturating at math.MaxUint64
+func SaturatingAdd(a, b uint64) uint64 {
+	if a > math.MaxUint64-b {
+		return math.MaxUint64
+	}
+	return a + b
+}
@@ -0,0 +1,283 @@
+package ipam
+
+import (
+	"errors"
+	"math"
+	"net/netip"
+	"testing"
+
+	"smit/server/api/models"
+)
+
+func TestSubnetCapacity(t *testing.T) {
+	tests := []struct {
+		cidr       string
+		wantTotal  uint64
+		wantUsable uint64
+		wantErr    bool
+	}{
+		{"192.168.1.0/24", 256, 254, false},
+		{"10.0.0.0/8", 16777216, 16777214, false},
+		{"192.168.1.0/30", 4, 2, false},
+		{"192.168.1.0/31", 2, 2, false},
+		{"192.168.1.1/32", 1, 1, false},
+		{"2001:db8::/120", 256, 256, false},
+		{"2001:db8::/64", math.MaxUint64, math.MaxUint64, false},
+		{"invalid", 0, 0, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.cidr, func(t *testing.T) {
+			capacity, err := SubnetCapacity(tt.cidr)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("SubnetCapacity() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if capacity.Total != tt.wantTotal || capacity.Usable != tt.wantUsable {
+				t.Errorf("SubnetCapacity() = %+v, want total %d usable %d", capacity, tt.wantTotal, tt.wantUsable)
+			}
+		})
+	}
+}
+
+func TestVLANUtilization(t *testing.T) {
+	vlan := models.VLANModel{
+		ID:        1,
+		VlanID:    100,
+		Subnet:    "192.168.1.0/24",
+		Gateway:   "192.168.1.1",
+		Status:    "active",
+		Allocated: 200,
+		Reserved:  9,
+	}
+
+	u := VLANUtilization(vlan, 80)
+	if u.Usable != 254 || u.Allocated != 200 || u.Reserved != 10 || u.Free != 44 {
+		t.Errorf("Unexpected counts: %+v", u)
+	}
+	if u.UtilizationPercent != 82.68 {
+		t.Errorf("Expected 82.68%%, got %v", u.UtilizationPercent)
+	}
+	if !u.OverThreshold {
+		t.Error("Expected VLAN to be over threshold")
+	}
+
+	// Gateway outside the subnet is not counted as reserved
+	vlan.Gateway = "10.0.0.1"
+	vlan.Allocated = 0
+	vlan.Reserved = 0
+	u = VLANUtilization(vlan, 80)
+	if u.Reserved != 0 || u.Free != 254 || u.OverThreshold {
+		t.Errorf("Unexpected counts: %+v", u)
+	}
+
+	// Allocations beyond capacity do not underflow
+	vlan.Allocated = 1000
+	u = VLANUtilization(vlan, 80)
+	if u.Free != 0 {
+		t.Errorf("Expected 0 free addresses, got %d", u.Free)
+	}
+
+	// Only utilization above the threshold is flagged, not utilization equal to it
+	vlan = models.VLANModel{Subnet: "10.0.0.0/30", Gateway: "10.0.0.1"}
+	if u = VLANUtilization(vlan, 50); u.UtilizationPercent != 50 || u.OverThreshold {
+		t.Errorf("Expected 50%% not to be over a threshold of 50, got %+v", u)
+	}
+	if u = VLANUtilization(vlan, 49.9); !u.OverThreshold {
+		t.Errorf("Expected 50%% to be over a threshold of 49.9, got %+v", u)
+	}
+}
+
+func TestUtilizationReport(t *testing.T) {
+	vlans := []models.VLANModel{
+		{ID: 1, Subnet: "10.0.1.0/24", Gateway: "10.0.1.1", Status: "active", Site: "tallinn", Allocated: 250},
+		{ID: 2, Subnet: "10.0.2.0/24", Gateway: "10.0.2.1", Status: "active", Site: "tartu"},
+		{ID: 3, Subnet: "10.0.3.0/25", Gateway: "10.0.3.1", Status: "maintenance"},
+		{ID: 4, Subnet: "not-a-subnet", Gateway: "10.0.4.1", Status: "active"},
+	}
+
+	report := UtilizationReport(vlans, 90)
+
+	if len(report.VLANs) != 4 {
+		t.Fatalf("Expected 4 VLAN entries, got %d", len(report.VLANs))
+	}
+	if report.VLANs[3].Error == "" {
+		t.Error("Expected error for invalid subnet")
+	}
+
+	total := report.Summary.Total
+	if total.VLANs != 3 || total.Usable != 254+254+126 || total.OverThreshold != 1 {
+		t.Errorf("Unexpected totals: %+v", total)
+	}
+
+	if got := report.Summary.ByStatus["active"].VLANs; got != 2 {
+		t.Errorf("Expected 2 active VLANs, got %d", got)
+	}
+	if got := report.Summary.BySite[UnassignedSite].VLANs; got != 1 {
+		t.Errorf("Expected 1 unassigned VLAN, got %d", got)
+	}
+	if got := report.Summary.BySite["tallinn"].OverThreshold; got != 1 {
+		t.Errorf("Expected 1 VLAN over threshold in tallinn, got %d", got)
+	}
+}
+
+func TestNextFreeSubnet(t *testing.T) {
+	tests := []struct {
+		name    string
+		parent  string
+		length  int
+		used    []string
+		want    string
+		wantErr error
+	}{
+		{"Empty parent", "10.20.0.0/16", 24, nil, "10.20.0.0/24", nil},
+		{"Skips used blocks", "10.20.0.0/16", 24, []string{"10.20.0.0/24", "10.20.1.0/24"}, "10.20.2.0/24", nil},
+		{"Skips larger used block", "10.20.0.0/16", 24, []string{"10.20.0.0/22"}, "10.20.4.0/24", nil},
+		{"Skips block with smaller subnet inside", "10.20.0.0/16", 24, []string{"10.20.0.128/25"}, "10.20.1.0/24", nil},
+		{"Fills gaps", "10.20.0.0/16", 24, []string{"10.20.0.0/24", "10.20.2.0/24"}, "10.20.1.0/24", nil},
+		{"Ignores subnets outside parent", "10.20.0.0/16", 24, []string{"10.30.0.0/24", "invalid"}, "10.20.0.0/24", nil},
+		{"Parent fully used", "10.20.0.0/23", 24, []string{"10.20.0.0/24", "10.20.1.0/24"}, "", ErrNoFreeSubnet},
+		{"Parent covered by larger subnet", "10.20.0.0/24", 26, []string{"10.20.0.0/16"}, "", ErrNoFreeSubnet},
+		{"Top of address space", "255.255.255.0/24", 25, []string{"255.255.255.0/25"}, "255.255.255.128/25", nil},
+		{"Length shorter than parent", "10.20.0.0/16", 8, nil, "", ErrInvalidLength},
+		{"Length too long", "10.20.0.0/16", 33, nil, "", ErrInvalidLength},
+		{"IPv6", "2001:db8::/48", 64, []string{"2001:db8::/64"}, "2001:db8:0:1::/64", nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := NextFreeSubnet(tt.parent, tt.length, tt.used)
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("NextFreeSubnet() error = %v, want %v", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("NextFreeSubnet() unexpected error: %v", err)
+			}
+			if got.String() != tt.want {
+				t.Errorf("NextFreeSubnet() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestGatewayForPolicy(t *testing.T) {
+	tests := []struct {
+		subnet  string
+		policy  string
+		want    string
+		wantErr bool
+	}{
+		{"10.20.3.0/24", GatewayPolicyFirst, "10.20.3.1", false},
+		{"10.20.3.0/24", "", "10.20.3.1", false},
+		{"10.20.3.0/24", GatewayPolicyLast, "10.20.3.254", false},
+		{"10.20.3.0/31", GatewayPolicyFirst, "10.20.3.0", false},
+		{"10.20.3.0/31", GatewayPolicyLast, "10.20.3.1", false},
+		{"2001:db8::/64", GatewayPolicyFirst, "2001:db8::1", false},
+		{"10.20.3.0/24", "middle", "", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.subnet+" "+tt.policy, func(t *testing.T) {
+			got, err := GatewayForPolicy(netip.MustParsePrefix(tt.subnet), tt.policy)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("GatewayForPolicy() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("GatewayForPolicy() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPrefixTrie(t *testing.T) {
+	trie := NewPrefixTrie[string]()
+	for _, entry := range []struct{ prefix, value string }{
+		{"10.0.0.0/8", "aggregate"},
+		{"10.4.0.0/16", "site"},
+		{"10.4.17.0/24", "vlan"},
+		{"10.4.17.0/24", "duplicate"},
+		{"10.4.17.23/32", "host"},
+		{"192.168.0.0/24", "other"},
+		{"2001:db8::/32", "v6 aggregate"},
+		{"2001:db8:4:17::/64", "v6 vlan"},
+		{"0.0.0.0/0", "default"},
+	} {
+		trie.Insert(netip.MustParsePrefix(entry.prefix), entry.value)
+	}
+
+	if trie.Len() != 9 {
+		t.Errorf("Expected 9 values, got %d", trie.Len())
+	}
+
+	tests := []struct {
+		addr     string
+		expected []string
+	}{
+		{"10.4.17.23", []string{"10.4.17.23/32", "10.4.17.0/24", "10.4.0.0/16", "10.0.0.0/8", "0.0.0.0/0"}},
+		{"10.4.18.1", []string{"10.4.0.0/16", "10.0.0.0/8", "0.0.0.0/0"}},
+		{"172.16.0.1", []string{"0.0.0.0/0"}},
+		{"::ffff:192.168.0.9", []string{"192.168.0.0/24", "0.0.0.0/0"}},
+		{"2001:db8:4:17::1", []string{"2001:db8:4:17::/64", "2001:db8::/32"}},
+		{"2001:db9::1", nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.addr, func(t *testing.T) {
+			matches := trie.Lookup(netip.MustParseAddr(tt.addr))
+			if len(matches) != len(tt.expected) {
+				t.Fatalf("Expected %d matches, got %d: %v", len(tt.expected), len(matches), matches)
+			}
+			for i, prefix := range tt.expected {
+				if matches[i].Prefix.String() != prefix {
+					t.Errorf("Expected match %d to be %s, got %s", i, prefix, matches[i].Prefix)
+				}
+			}
+		})
+	}
+
+	// Duplicate prefixes keep every value
+	matches := trie.Lookup(netip.MustParseAddr("10.4.17.1"))
+	if len(matches[0].Values) != 2 {
+		t.Errorf("Expected 2 values for duplicate prefix, got %v", matches[0].Values)
+	}
+}
+
+func TestLookupIP(t *testing.T) {
+	vlans := []models.VLANModel{
+		{ID: 1, Name: "Aggregate", Subnet: "10.4.0.0/16"},
+		{ID: 2, Name: "Servers", Subnet: "10.4.17.0/24"},
+		{ID: 3, Name: "Broken", Subnet: "invalid"},
+		{ID: 4, Name: "Other", Subnet: "10.5.0.0/24"},
+	}
+
+	matches := LookupIP(VLANIndex(vlans), netip.MustParseAddr("10.4.17.23"))
+	if len(matches) != 2 {
+		t.Fatalf("Expected 2 matches, got %d", len(matches))
+	}
+	if matches[0].VLAN.ID != 2 || matches[0].PrefixLength != 24 || matches[0].Subnet != "10.4.17.0/24" {
+		t.Errorf("Unexpected first match: %+v", matches[0])
+	}
+	if matches[1].VLAN.ID != 1 {
+		t.Errorf("Unexpected second match: %+v", matches[1])
+	}
+
+	if matches := LookupIP(VLANIndex(vlans), netip.MustParseAddr("192.0.2.1")); len(matches) != 0 {
+		t.Errorf("Expected no matches, got %v", matches)
+	}
+}
+
+func BenchmarkPrefixTrieLookup(b *testing.B) {
+	trie := NewPrefixTrie[int]()
+	for i := 0; i < 10000; i++ {
+		prefix := netip.PrefixFrom(netip.AddrFrom4([4]byte{10, byte(i >> 8), byte(i), 0}), 24)
+		trie.Insert(prefix, i)
+	}
+	addr := netip.MustParseAddr("10.20.30.40")
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		trie.Lookup(addr)
+	}
+}
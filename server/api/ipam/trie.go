@@ -0,0 +1,128 @@
+package ipam
+
+import (
+	"net/netip"
+
+	"smit/server/api/models"
+)
+
+// Binary prefix trie mapping prefixes to values, with separate roots for IPv4 and IPv6.
+// A lookup walks at most one node per address bit, regardless of how many prefixes are stored.
+type PrefixTrie[V any] struct {
+	v4   *trieNode[V]
+	v6   *trieNode[V]
+	size int
+}
+
+type trieNode[V any] struct {
+	children [2]*trieNode[V]
+	prefix   netip.Prefix
+	values   []V
+}
+
+// Prefix that contains a looked-up address and the values stored under it
+type PrefixMatch[V any] struct {
+	Prefix netip.Prefix
+	Values []V
+}
+
+// Create an empty prefix trie
+func NewPrefixTrie[V any]() *PrefixTrie[V] {
+	return &PrefixTrie[V]{v4: &trieNode[V]{}, v6: &trieNode[V]{}}
+}
+
+// Store a value under a prefix; host bits are ignored and duplicate prefixes keep every value
+func (t *PrefixTrie[V]) Insert(prefix netip.Prefix, value V) {
+	prefix = prefix.Masked()
+	addr := prefix.Addr()
+
+	node := t.root(addr)
+	for i := 0; i < prefix.Bits(); i++ {
+		bit := addrBit(addr, i)
+		if node.children[bit] == nil {
+			node.children[bit] = &trieNode[V]{}
+		}
+		node = node.children[bit]
+	}
+
+	node.prefix = prefix
+	node.values = append(node.values, value)
+	t.size++
+}
+
+// Number of stored values
+func (t *PrefixTrie[V]) Len() int {
+	return t.size
+}
+
+// All prefixes containing the address, longest prefix first
+func (t *PrefixTrie[V]) Lookup(addr netip.Addr) []PrefixMatch[V] {
+	addr = addr.WithZone("").Unmap()
+
+	var matches []PrefixMatch[V]
+	node := t.root(addr)
+	for i := 0; node != nil; i++ {
+		if len(node.values) > 0 {
+			matches = append(matches, PrefixMatch[V]{Prefix: node.prefix, Values: node.values})
+		}
+		if i == addr.BitLen() {
+			break
+		}
+		node = node.children[addrBit(addr, i)]
+	}
+
+	// The walk goes from short to long prefixes
+	for i, j := 0, len(matches)-1; i < j; i, j = i+1, j-1 {
+		matches[i], matches[j] = matches[j], matches[i]
+	}
+	return matches
+}
+
+// Root of the address family
+func (t *PrefixTrie[V]) root(addr netip.Addr) *trieNode[V] {
+	if addr.Is4() {
+		return t.v4
+	}
+	return t.v6
+}
+
+// Bit i of an address, counting from the most significant bit
+func addrBit(addr netip.Addr, i int) int {
+	var b []byte
+	if addr.Is4() {
+		a := addr.As4()
+		b = a[:]
+	} else {
+		a := addr.As16()
+		b = a[:]
+	}
+	return int(b[i/8]>>(7-i%8)) & 1
+}
+
+// Index VLANs by subnet; VLANs with unparsable subnets are left out
+func VLANIndex(vlans []models.VLANModel) *PrefixTrie[models.VLANModel] {
+	trie := NewPrefixTrie[models.VLANModel]()
+	for _, vlan := range vlans {
+		prefix, err := netip.ParsePrefix(vlan.Subnet)
+		if err != nil {
+			continue
+		}
+		trie.Insert(prefix, vlan)
+	}
+	return trie
+}
+
+// VLANs whose subnet contains the address, ranked by longest-prefix match
+func LookupIP(index *PrefixTrie[models.VLANModel], addr netip.Addr) []models.IPLookupMatch {
+	matches := []models.IPLookupMatch{}
+	for _, match := range index.Lookup(addr) {
+		for _, vlan := range match.Values {
+			matches = append(matches, models.IPLookupMatch{
+				Subnet:       match.Prefix.String(),
+				PrefixLength: match.Prefix.Bits(),
+				VLAN:         vlan,
+			})
+		}
+	}
+	return matches
+}
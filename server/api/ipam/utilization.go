@@ -0,0 +1,107 @@
+package ipam
+
+import (
+	"math"
+	"time"
+
+	"smit/server/api/models"
+)
+
+// Site name used in summaries for VLANs without a site
+const UnassignedSite = "unassigned"
+
+// Compute address utilization for a VLAN.
+// The gateway counts as reserved when it lies inside the subnet. Only utilization
+// above the threshold, not equal to it, is flagged.
+func VLANUtilization(vlan models.VLANModel, threshold float64) models.VLANUtilization {
+	result := models.VLANUtilization{
+		ID:     vlan.ID,
+		VlanID: vlan.VlanID,
+		Name:   vlan.Name,
+		Subnet: vlan.Subnet,
+		Status: vlan.Status,
+		Site:   vlan.Site,
+	}
+
+	capacity, err := SubnetCapacity(vlan.Subnet)
+	if err != nil {
+		result.Error = err.Error()
+		return result
+	}
+
+	reserved := uint64(max(vlan.Reserved, 0))
+	if SubnetContains(vlan.Subnet, vlan.Gateway) {
+		reserved++
+	}
+	allocated := uint64(max(vlan.Allocated, 0))
+
+	result.Total = capacity.Total
+	result.Usable = capacity.Usable
+	result.Allocated = allocated
+	result.Reserved = reserved
+
+	used := SaturatingAdd(allocated, reserved)
+	if used < capacity.Usable {
+		result.Free = capacity.Usable - used
+	}
+	result.UtilizationPercent = percent(used, capacity.Usable)
+	result.OverThreshold = result.UtilizationPercent > threshold
+
+	return result
+}
+
+// Build a utilization report for a set of VLANs.
+// VLANs with an unparsable subnet are listed but left out of the summary.
+func UtilizationReport(vlans []models.VLANModel, threshold float64) models.UtilizationReport {
+	report := models.UtilizationReport{
+		Threshold:   threshold,
+		GeneratedAt: time.Now(),
+		VLANs:       make([]models.VLANUtilization, 0, len(vlans)),
+		Summary: models.UtilizationSummary{
+			ByStatus: map[string]models.UtilizationTotals{},
+			BySite:   map[string]models.UtilizationTotals{},
+		},
+	}
+
+	for _, vlan := range vlans {
+		u := VLANUtilization(vlan, threshold)
+		report.VLANs = append(report.VLANs, u)
+		if u.Error != "" {
+			continue
+		}
+
+		site := u.Site
+		if site == "" {
+			site = UnassignedSite
+		}
+
+		report.Summary.Total = addTotals(report.Summary.Total, u)
+		report.Summary.ByStatus[u.Status] = addTotals(report.Summary.ByStatus[u.Status], u)
+		report.Summary.BySite[site] = addTotals(report.Summary.BySite[site], u)
+	}
+
+	return report
+}
+
+// Add a VLAN's counts to running totals
+func addTotals(t models.UtilizationTotals, u models.VLANUtilization) models.UtilizationTotals {
+	t.VLANs++
+	t.Usable = SaturatingAdd(t.Usable, u.Usable)
+	t.Allocated = SaturatingAdd(t.Allocated, u.Allocated)
+	t.Reserved = SaturatingAdd(t.Reserved, u.Reserved)
+	t.Free = SaturatingAdd(t.Free, u.Free)
+	if u.OverThreshold {
+		t.OverThreshold++
+	}
+	t.UtilizationPercent = percent(SaturatingAdd(t.Allocated, t.Reserved), t.Usable)
+	return t
+}
+
+// Percentage of used addresses, rounded to two decimals
+func percent(used, usable uint64) float64 {
+	if usable == 0 {
+		return 0
+	}
+	p := float64(used) / float64(usable) * 100
+	return math.Round(p*100) / 100
+}
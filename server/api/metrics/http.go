@@ -0,0 +1,65 @@
+package metrics
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"smit/server/api/recorder"
+)
+
+// Route label of requests to paths that match no route, so scanners cannot
+// create a series per path
+const unmatchedRoute = "unmatched"
+
+// Methods kept as a label value; anything else is counted as OTHER
+var knownMethods = map[string]bool{
+	http.MethodGet: true, http.MethodHead: true, http.MethodPost: true, http.MethodPut: true,
+	http.MethodPatch: true, http.MethodDelete: true, http.MethodOptions: true,
+}
+
+// Looks up the route template a path is served by
+type Routes interface {
+	// Template of the route matching the path; ok is false when no route matches
+	Route(path string) (template string, ok bool)
+}
+
+// Request counts and latencies by method, route template and status
+type HTTPMetrics struct {
+	requests *Counter
+	duration *Histogram
+}
+
+// Register the HTTP request metrics
+func NewHTTPMetrics(reg *Registry) *HTTPMetrics {
+	return &HTTPMetrics{
+		requests: reg.NewCounter("smit_http_requests_total",
+			"HTTP requests by method, route and status.", "method", "route", "status"),
+		duration: reg.NewHistogram("smit_http_request_duration_seconds",
+			"HTTP request latency by method, route and status.", DefaultBuckets, "method", "route", "status"),
+	}
+}
+
+// Count and time every request passing to next. Requests are labelled with the
+// template of their route, such as /api/v1/vlans/{id}, rather than the path.
+func (m *HTTPMetrics) Handler(routes Routes, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		rec := recorder.New(w)
+		next.ServeHTTP(rec, r)
+
+		route, ok := routes.Route(r.URL.Path)
+		if !ok {
+			route = unmatchedRoute
+		}
+		method := r.Method
+		if !knownMethods[method] {
+			method = "OTHER"
+		}
+		status := rec.StatusCode()
+
+		labels := []string{method, route, strconv.Itoa(status)}
+		m.requests.Inc(labels...)
+		m.duration.Observe(time.Since(start).Seconds(), labels...)
+	})
+}
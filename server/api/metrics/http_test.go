@@ -0,0 +1,62 @@
+package metrics
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// Routes with fixed templates per path
+type staticRoutes map[string]string
+
+func (s staticRoutes) Route(path string) (string, bool) {
+	template, ok := s[path]
+	return template, ok
+}
+
+func TestHTTPMetrics(t *testing.T) {
+	reg := NewRegistry()
+	routes := staticRoutes{"/api/v1/vlans/7": "/api/v1/vlans/{id}", "/api/v1/vlans/8": "/api/v1/vlans/{id}"}
+	handler := NewHTTPMetrics(reg).Handler(routes, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/api/v1/vlans/8" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.Write([]byte("{}"))
+	}))
+
+	for _, req := range []struct{ method, path string }{
+		{"GET", "/api/v1/vlans/7"},
+		{"GET", "/api/v1/vlans/7"},
+		{"GET", "/api/v1/vlans/8"},
+		{"GET", "/wp-login.php"},
+		{"BREW", "/api/v1/vlans/7"},
+	} {
+		handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(req.method, req.path, nil))
+	}
+
+	var out bytes.Buffer
+	reg.WriteText(&out)
+	for _, expected := range []string{
+		`smit_http_requests_total{method="GET",route="/api/v1/vlans/{id}",status="200"} 2`,
+		`smit_http_requests_total{method="GET",route="/api/v1/vlans/{id}",status="404"} 1`,
+		`smit_http_requests_total{method="GET",route="unmatched",status="200"} 1`,
+		`smit_http_requests_total{method="OTHER",route="/api/v1/vlans/{id}",status="200"} 1`,
+		`smit_http_request_duration_seconds_count{method="GET",route="/api/v1/vlans/{id}",status="200"} 2`,
+	} {
+		if !strings.Contains(out.String(), expected) {
+			t.Errorf("Expected %q in:\n%s", expected, out.String())
+		}
+	}
+}
+
+func TestHTTPMetricsFlush(t *testing.T) {
+	handler := NewHTTPMetrics(NewRegistry()).Handler(staticRoutes{}, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if _, ok := w.(http.Flusher); !ok {
+			t.Error("Expected the response writer to support flushing")
+		}
+	}))
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/api/v1/events", nil))
+}
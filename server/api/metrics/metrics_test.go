@@ -0,0 +1,100 @@
+package metrics
+
+import (
+	"bytes"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestWriteText(t *testing.T) {
+	reg := NewRegistry()
+	requests := reg.NewCounter("test_requests_total", "Requests by method.", "method")
+	latency := reg.NewHistogram("test_latency_seconds", "Latency.", []float64{0.1, 1}, "route")
+	reg.NewGaugeFunc("test_items", "Items by kind.", []string{"kind"}, func() []Sample {
+		return []Sample{{Labels: []string{"b"}, Value: 2}, {Labels: []string{"a"}, Value: 1.5}}
+	})
+	reg.NewGaugeFunc("test_up", "Whether the test is up.", nil, func() []Sample {
+		return []Sample{{Value: 1}}
+	})
+
+	requests.Inc("POST")
+	requests.Inc("GET")
+	requests.Add(2, "GET")
+	latency.Observe(0.05, "/a")
+	latency.Observe(0.5, "/a")
+	latency.Observe(3, "/a")
+
+	var out bytes.Buffer
+	reg.WriteText(&out)
+
+	expected := `# HELP test_requests_total Requests by method.
+# TYPE test_requests_total counter
+test_requests_total{method="GET"} 3
+test_requests_total{method="POST"} 1
+# HELP test_latency_seconds Latency.
+# TYPE test_latency_seconds histogram
+test_latency_seconds_bucket{route="/a",le="0.1"} 1
+test_latency_seconds_bucket{route="/a",le="1"} 2
+test_latency_seconds_bucket{route="/a",le="+Inf"} 3
+test_latency_seconds_sum{route="/a"} 3.55
+test_latency_seconds_count{route="/a"} 3
+# HELP test_items Items by kind.
+# TYPE test_items gauge
+test_items{kind="a"} 1.5
+test_items{kind="b"} 2
+# HELP test_up Whether the test is up.
+# TYPE test_up gauge
+test_up 1
+`
+	if out.String() != expected {
+		t.Errorf("Unexpected exposition:\n%s", out.String())
+	}
+}
+
+func TestEscaping(t *testing.T) {
+	reg := NewRegistry()
+	reg.NewCounter("test_total", "Help with \\ and\nnewline.", "value").Inc("a \"quoted\" \\ value\n")
+
+	var out bytes.Buffer
+	reg.WriteText(&out)
+
+	for _, expected := range []string{
+		`# HELP test_total Help with \\ and\nnewline.`,
+		`test_total{value="a \"quoted\" \\ value\n"} 1`,
+	} {
+		if !strings.Contains(out.String(), expected) {
+			t.Errorf("Expected %q in:\n%s", expected, out.String())
+		}
+	}
+}
+
+func TestRegistryPanics(t *testing.T) {
+	reg := NewRegistry()
+	counter := reg.NewCounter("test_total", "Test.", "a", "b")
+
+	for name, f := range map[string]func(){
+		"duplicate name":     func() { reg.NewCounter("test_total", "Again.") },
+		"wrong label values": func() { counter.Inc("only-one") },
+	} {
+		t.Run(name, func(t *testing.T) {
+			defer func() {
+				if recover() == nil {
+					t.Error("Expected a panic")
+				}
+			}()
+			f()
+		})
+	}
+}
+
+func TestServeHTTP(t *testing.T) {
+	reg := NewRegistry()
+	reg.NewCounter("test_total", "Test.").Inc()
+
+	w := httptest.NewRecorder()
+	reg.ServeHTTP(w, httptest.NewRequest("GET", "/metrics", nil))
+	if w.Header().Get("Content-Type") != ContentType || !strings.Contains(w.Body.String(), "test_total 1\n") {
+		t.Errorf("Unexpected response %q: %s", w.Header().Get("Content-Type"), w.Body.String())
+	}
+}
@@ -0,0 +1,149 @@
+package metrics
+
+import (
+	"time"
+
+	"smit/server/api/models"
+	"smit/server/api/storage"
+)
+
+// Storage times every call to the wrapped storage and counts the calls that fail
+type Storage struct {
+	storage.Storage
+	backend  string
+	duration *Histogram
+	errors   *Counter
+}
+
+// Wrap a storage, labelling its metrics with the backend name
+func NewStorage(next storage.Storage, backend string, reg *Registry) *Storage {
+	return &Storage{
+		Storage: next,
+		backend: backend,
+		duration: reg.NewHistogram("smit_storage_operation_duration_seconds",
+			"Storage operation latency by backend and method.", DefaultBuckets, "backend", "method"),
+		errors: reg.NewCounter("smit_storage_errors_total",
+			"Storage operations that returned an error, including not found and conflicts, by backend and method.", "backend", "method"),
+	}
+}
+
+// Record one call of a storage method
+func (s *Storage) observe(method string, start time.Time, err error) {
+	s.duration.Observe(time.Since(start).Seconds(), s.backend, method)
+	if err != nil {
+		s.errors.Inc(s.backend, method)
+	}
+}
+
+func (s *Storage) GetAll() ([]models.VLANModel, error) {
+	start := time.Now()
+	vlans, err := s.Storage.GetAll()
+	s.observe("GetAll", start, err)
+	return vlans, err
+}
+
+func (s *Storage) Query(query models.VLANQuery) (*models.VLANPage, error) {
+	start := time.Now()
+	page, err := s.Storage.Query(query)
+	s.observe("Query", start, err)
+	return page, err
+}
+
+func (s *Storage) GetByID(id int) (*models.VLANModel, error) {
+	start := time.Now()
+	vlan, err := s.Storage.GetByID(id)
+	s.observe("GetByID", start, err)
+	return vlan, err
+}
+
+func (s *Storage) GetByVlanID(vlanID int) (*models.VLANModel, error) {
+	start := time.Now()
+	vlan, err := s.Storage.GetByVlanID(vlanID)
+	s.observe("GetByVlanID", start, err)
+	return vlan, err
+}
+
+func (s *Storage) Create(input *models.VLANInput) (*models.VLANModel, error) {
+	start := time.Now()
+	vlan, err := s.Storage.Create(input)
+	s.observe("Create", start, err)
+	return vlan, err
+}
+
+func (s *Storage) Update(id int, input *models.VLANInput) (*models.VLANModel, error) {
+	start := time.Now()
+	vlan, err := s.Storage.Update(id, input)
+	s.observe("Update", start, err)
+	return vlan, err
+}
+
+func (s *Storage) UpdateStatus(id int, change models.StatusChange) (*models.VLANModel, error) {
+	start := time.Now()
+	vlan, err := s.Storage.UpdateStatus(id, change)
+	s.observe("UpdateStatus", start, err)
+	return vlan, err
+}
+
+func (s *Storage) Delete(id int, ifMatch string) error {
+	start := time.Now()
+	err := s.Storage.Delete(id, ifMatch)
+	s.observe("Delete", start, err)
+	return err
+}
+
+func (s *Storage) CheckCreate(input *models.VLANInput) (*models.VLANModel, error) {
+	start := time.Now()
+	vlan, err := s.Storage.CheckCreate(input)
+	s.observe("CheckCreate", start, err)
+	return vlan, err
+}
+
+func (s *Storage) CheckUpdate(id int, input *models.VLANInput) (*models.VLANModel, error) {
+	start := time.Now()
+	vlan, err := s.Storage.CheckUpdate(id, input)
+	s.observe("CheckUpdate", start, err)
+	return vlan, err
+}
+
+func (s *Storage) CheckDelete(id int, ifMatch string) error {
+	start := time.Now()
+	err := s.Storage.CheckDelete(id, ifMatch)
+	s.observe("CheckDelete", start, err)
+	return err
+}
+
+// Register gauges describing the VLAN data: VLANs by status, and the size and
+// last successful save of the data file
+func RegisterDataGauges(reg *Registry, store *storage.JSONStorage) {
+	reg.NewGaugeFunc("smit_vlans", "VLANs by status.", []string{"status"}, func() []Sample {
+		vlans, err := store.GetAll()
+		if err != nil {
+			return nil
+		}
+		counts := make(map[string]int)
+		for _, vlan := range vlans {
+			counts[vlan.Status]++
+		}
+		samples := make([]Sample, 0, len(counts))
+		for status, count := range counts {
+			samples = append(samples, Sample{Labels: []string{status}, Value: float64(count)})
+		}
+		return samples
+	})
+
+	reg.NewGaugeFunc("smit_data_file_size_bytes", "Size of the data file.", nil, func() []Sample {
+		size, err := store.FileSize()
+		if err != nil {
+			return nil
+		}
+		return []Sample{{Value: float64(size)}}
+	})
+
+	reg.NewGaugeFunc("smit_data_last_save_timestamp_seconds", "Unix time of the last successful save of the data file, absent before the first save.", nil, func() []Sample {
+		saved := store.LastSaved()
+		if saved.IsZero() {
+			return nil
+		}
+		return []Sample{{Value: float64(saved.UnixNano()) / 1e9}}
+	})
+}
@@ -0,0 +1,52 @@
+package metrics
+
+import (
+	"bytes"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"smit/server/api/models"
+	"smit/server/api/storage"
+)
+
+func TestStorage(t *testing.T) {
+	store, err := storage.NewJSONStorage(filepath.Join(t.TempDir(), "data.json"))
+	if err != nil {
+		t.Fatalf("Failed to create storage: %v", err)
+	}
+
+	reg := NewRegistry()
+	RegisterDataGauges(reg, store)
+	instrumented := NewStorage(store, "json", reg)
+
+	for _, input := range []models.VLANInput{
+		{Name: "Office", VlanID: 100, Subnet: "10.1.0.0/24", Gateway: "10.1.0.1", Status: "active"},
+		{Name: "Lab", VlanID: 200, Subnet: "10.2.0.0/24", Gateway: "10.2.0.1", Status: "active"},
+		{Name: "Spare", VlanID: 300, Subnet: "10.3.0.0/24", Gateway: "10.3.0.1", Status: "reserved"},
+	} {
+		if _, err := instrumented.Create(&input); err != nil {
+			t.Fatalf("Failed to create VLAN: %v", err)
+		}
+	}
+	instrumented.GetByID(999)
+
+	var out bytes.Buffer
+	reg.WriteText(&out)
+	for _, expected := range []string{
+		`smit_storage_operation_duration_seconds_count{backend="json",method="Create"} 3`,
+		`smit_storage_operation_duration_seconds_count{backend="json",method="GetByID"} 1`,
+		`smit_storage_errors_total{backend="json",method="GetByID"} 1`,
+		`smit_vlans{status="active"} 2`,
+		`smit_vlans{status="reserved"} 1`,
+		"smit_data_file_size_bytes ",
+		"smit_data_last_save_timestamp_seconds ",
+	} {
+		if !strings.Contains(out.String(), expected) {
+			t.Errorf("Expected %q in:\n%s", expected, out.String())
+		}
+	}
+	if strings.Contains(out.String(), `smit_storage_errors_total{backend="json",method="Create"}`) {
+		t.Error("Expected no errors for successful creates")
+	}
+}
@@ -0,0 +1,88 @@
+package models
+
+import (
+	"fmt"
+	"reflect"
+)
+
+// Editable VLAN fields, by their JSON names
+var VLANFields = []string{"name", "vlan_id", "subnet", "gateway", "status", "site", "allocated", "reserved", "labels", "custom_fields"}
+
+// Restricts which VLANs a caller may change. Zero values place no restriction.
+type AccessScope struct {
+	Sites     []string `json:"sites,omitempty"`
+	VlanIDMin int      `json:"vlan_id_min,omitempty"`
+	VlanIDMax int      `json:"vlan_id_max,omitempty"`
+}
+
+// Check whether a VLAN with the given site and tag is inside the scope
+func (s *AccessScope) Allows(site string, vlanID int) bool {
+	if s == nil {
+		return true
+	}
+	if len(s.Sites) > 0 && !contains(s.Sites, site) {
+		return false
+	}
+	if s.VlanIDMin > 0 && vlanID < s.VlanIDMin {
+		return false
+	}
+	if s.VlanIDMax > 0 && vlanID > s.VlanIDMax {
+		return false
+	}
+	return true
+}
+
+// Validate the scope, collecting every violation
+func (s *AccessScope) Validate() error {
+	var errs ValidationErrors
+	if s == nil {
+		return nil
+	}
+
+	for _, bound := range []struct {
+		field string
+		value int
+	}{{"scope.vlan_id_min", s.VlanIDMin}, {"scope.vlan_id_max", s.VlanIDMax}} {
+		if bound.value < 0 || bound.value > 4094 {
+			errs.Add(bound.field, CodeOutOfRange, fmt.Sprintf("%s must be between 1 and 4094", bound.field))
+		}
+	}
+	if s.VlanIDMin > 0 && s.VlanIDMax > 0 && s.VlanIDMin > s.VlanIDMax {
+		errs.Add("scope.vlan_id_max", CodeOutOfRange, "scope.vlan_id_max must not be below scope.vlan_id_min")
+	}
+
+	return errs.Err()
+}
+
+// Names of the fields the input would change on the VLAN
+func (v *VLANModel) ChangedFields(input *VLANInput) []string {
+	current := v.Input()
+	values := []struct {
+		field      string
+		old, value interface{}
+	}{
+		{"name", current.Name, input.Name},
+		{"vlan_id", current.VlanID, input.VlanID},
+		{"subnet", current.Subnet, input.Subnet},
+		{"gateway", current.Gateway, input.Gateway},
+		{"status", current.Status, input.Status},
+		{"site", current.Site, input.Site},
+		{"allocated", current.Allocated, input.Allocated},
+		{"reserved", current.Reserved, input.Reserved},
+	}
+
+	var changed []string
+	for _, value := range values {
+		if value.old != value.value {
+			changed = append(changed, value.field)
+		}
+	}
+	// An empty map and a missing one are the same
+	if (len(current.Labels) > 0 || len(input.Labels) > 0) && !reflect.DeepEqual(current.Labels, input.Labels) {
+		changed = append(changed, "labels")
+	}
+	if (len(current.CustomFields) > 0 || len(input.CustomFields) > 0) && !reflect.DeepEqual(current.CustomFields, input.CustomFields) {
+		changed = append(changed, "custom_fields")
+	}
+	return changed
+}
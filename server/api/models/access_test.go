@@ -0,0 +1,65 @@
+package models
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestAccessScopeAllows(t *testing.T) {
+	scope := &AccessScope{Sites: []string{"tallinn"}, VlanIDMin: 100, VlanIDMax: 199}
+
+	tests := []struct {
+		site    string
+		vlanID  int
+		allowed bool
+	}{
+		{"tallinn", 100, true},
+		{"tallinn", 199, true},
+		{"tallinn", 200, false},
+		{"tallinn", 99, false},
+		{"tartu", 150, false},
+		{"", 150, false},
+	}
+	for _, tt := range tests {
+		if allowed := scope.Allows(tt.site, tt.vlanID); allowed != tt.allowed {
+			t.Errorf("Allows(%q, %d) = %v, expected %v", tt.site, tt.vlanID, allowed, tt.allowed)
+		}
+	}
+
+	var unscoped *AccessScope
+	if !unscoped.Allows("anywhere", 4094) {
+		t.Error("Expected a nil scope to allow everything")
+	}
+}
+
+func TestAccessScopeValidate(t *testing.T) {
+	if err := (&AccessScope{VlanIDMin: 100, VlanIDMax: 199}).Validate(); err != nil {
+		t.Errorf("Expected no error, got %v", err)
+	}
+	if err := (&AccessScope{VlanIDMin: 200, VlanIDMax: 100}).Validate(); err == nil {
+		t.Error("Expected an error for an inverted range")
+	}
+	if err := (&AccessScope{VlanIDMax: 5000}).Validate(); err == nil {
+		t.Error("Expected an error for an out-of-range tag")
+	}
+}
+
+func TestChangedFields(t *testing.T) {
+	vlan := VLANModel{
+		Name: "Production", VlanID: 100, Subnet: "10.0.0.0/24", Gateway: "10.0.0.1", Status: "active",
+		Labels: map[string]string{"env": "prod"},
+	}
+
+	input := vlan.Input()
+	if changed := vlan.ChangedFields(&input); changed != nil {
+		t.Errorf("Expected no changes, got %v", changed)
+	}
+
+	input.Status = "maintenance"
+	input.Subnet = "10.0.1.0/24"
+	input.Labels = map[string]string{"env": "staging"}
+	input.CustomFields = map[string]interface{}{}
+	if changed := vlan.ChangedFields(&input); !reflect.DeepEqual(changed, []string{"subnet", "status", "labels"}) {
+		t.Errorf("Unexpected changed fields %v", changed)
+	}
+}
@@ -0,0 +1,105 @@
+package models
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Highest per-minute rate a key may be given
+const MaxRatePerMinute = 1000000
+
+// API key that authenticates clients; the key itself is never stored, only its hash
+type APIKey struct {
+	ID   int    `json:"id"`
+	Name string `json:"name"`
+	// Leading characters of the key, so it can be recognised without being revealed
+	Prefix string `json:"prefix"`
+	// Role granted by the access policy, and the VLANs the key may change
+	Role       string       `json:"role,omitempty"`
+	Scope      *AccessScope `json:"scope,omitempty"`
+	RateLimit  *RateLimit   `json:"rate_limit,omitempty"`
+	CreatedAt  time.Time    `json:"created_at"`
+	ExpiresAt  *time.Time   `json:"expires_at,omitempty"`
+	LastUsedAt *time.Time   `json:"last_used_at,omitempty"`
+	RevokedAt  *time.Time   `json:"revoked_at,omitempty"`
+}
+
+// Structure for creating an API key
+type APIKeyInput struct {
+	Name  string       `json:"name"`
+	Role  string       `json:"role,omitempty"`
+	Scope *AccessScope `json:"scope,omitempty"`
+	// Uses the server's default limits when omitted
+	RateLimit *RateLimit `json:"rate_limit,omitempty"`
+	// Never expires when omitted
+	ExpiresAt *time.Time `json:"expires_at,omitempty"`
+}
+
+// Requests per minute a client may send. Zero values fall back to the default
+// limits.
+type RateLimit struct {
+	ReadPerMinute  int `json:"read_per_minute,omitempty"`
+	WritePerMinute int `json:"write_per_minute,omitempty"`
+}
+
+// Newly created API key together with the key itself, which is only shown once
+type CreatedAPIKey struct {
+	APIKey
+	Key string `json:"key"`
+}
+
+// Check whether the key has expired at the given time
+func (k *APIKey) Expired(now time.Time) bool {
+	return k.ExpiresAt != nil && !now.Before(*k.ExpiresAt)
+}
+
+// Check whether the key has been revoked
+func (k *APIKey) Revoked() bool {
+	return k.RevokedAt != nil
+}
+
+// Validate API key input against the current time, collecting every violation
+func (k *APIKeyInput) Validate(now time.Time) error {
+	var errs ValidationErrors
+
+	name := strings.TrimSpace(k.Name)
+	if name == "" {
+		errs.Add("name", CodeRequired, "name is required")
+	} else if len(name) > 100 {
+		errs.Add("name", CodeTooLong, "name must be at most 100 characters")
+	}
+
+	if err := k.Scope.Validate(); err != nil {
+		errs = append(errs, err.(ValidationErrors)...)
+	}
+
+	if err := k.RateLimit.Validate(); err != nil {
+		errs = append(errs, err.(ValidationErrors)...)
+	}
+
+	if k.ExpiresAt != nil && !k.ExpiresAt.After(now) {
+		errs.Add("expires_at", CodeOutOfRange, "expires_at must be in the future")
+	}
+
+	return errs.Err()
+}
+
+// Validate the limits, collecting every violation
+func (l *RateLimit) Validate() error {
+	var errs ValidationErrors
+	if l == nil {
+		return nil
+	}
+
+	for _, rate := range []struct {
+		field string
+		value int
+	}{{"rate_limit.read_per_minute", l.ReadPerMinute}, {"rate_limit.write_per_minute", l.WritePerMinute}} {
+		if rate.value < 0 || rate.value > MaxRatePerMinute {
+			errs.Add(rate.field, CodeOutOfRange, fmt.Sprintf("%s must be between 1 and %d", rate.field, MaxRatePerMinute))
+		}
+	}
+
+	return errs.Err()
+}
@@ -0,0 +1,64 @@
+package models
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestAPIKeyInputValidate(t *testing.T) {
+	now := time.Date(2024, 7, 15, 10, 0, 0, 0, time.UTC)
+	future := now.Add(time.Hour)
+
+	tests := []struct {
+		name   string
+		input  APIKeyInput
+		fields []string
+	}{
+		{"Valid", APIKeyInput{Name: "ci"}, nil},
+		{"Valid with expiry", APIKeyInput{Name: "ci", ExpiresAt: &future}, nil},
+		{"Missing name", APIKeyInput{Name: "  "}, []string{"name"}},
+		{"Long name", APIKeyInput{Name: strings.Repeat("a", 101)}, []string{"name"}},
+		{"Expiry not in the future", APIKeyInput{Name: "ci", ExpiresAt: &now}, []string{"expires_at"}},
+		{"Valid rate limit", APIKeyInput{Name: "ci", RateLimit: &RateLimit{ReadPerMinute: 600}}, nil},
+		{"Negative rate limit", APIKeyInput{Name: "ci", RateLimit: &RateLimit{ReadPerMinute: -1, WritePerMinute: MaxRatePerMinute + 1}}, []string{"rate_limit.read_per_minute", "rate_limit.write_per_minute"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.input.Validate(now)
+			if len(tt.fields) == 0 {
+				if err != nil {
+					t.Errorf("Expected no error, got %v", err)
+				}
+				return
+			}
+
+			errs, ok := err.(ValidationErrors)
+			if !ok || len(errs) != len(tt.fields) {
+				t.Fatalf("Expected %d violations, got %v", len(tt.fields), err)
+			}
+			for i, field := range tt.fields {
+				if errs[i].Field != field {
+					t.Errorf("Expected violation on %s, got %s", field, errs[i].Field)
+				}
+			}
+		})
+	}
+}
+
+func TestAPIKeyState(t *testing.T) {
+	now := time.Date(2024, 7, 15, 10, 0, 0, 0, time.UTC)
+	key := APIKey{ExpiresAt: &now}
+
+	if key.Expired(now.Add(-time.Second)) || !key.Expired(now) {
+		t.Error("Expected the key to expire exactly at expires_at")
+	}
+	if key.Revoked() {
+		t.Error("Expected the key not to be revoked")
+	}
+	key.RevokedAt = &now
+	if !key.Revoked() {
+		t.Error("Expected the key to be revoked")
+	}
+}
@@ -0,0 +1,154 @@
+package models
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"net"
+	"os"
+	"strings"
+	"sync/atomic"
+)
+
+// Custom field types
+const (
+	FieldTypeString = "string"
+	FieldTypeInt    = "int"
+	FieldTypeEnum   = "enum"
+	FieldTypeIP     = "ip"
+	FieldTypeCIDR   = "cidr"
+)
+
+// Admin-defined custom field that VLANs may or must carry
+type CustomFieldSchema struct {
+	Name        string   `json:"name"`
+	Type        string   `json:"type"`
+	Required    bool     `json:"required,omitempty"`
+	Values      []string `json:"values,omitempty"`
+	Description string   `json:"description,omitempty"`
+}
+
+// Custom field schemas used by validation, replaced at startup from configuration
+var currentCustomFields atomic.Pointer[[]CustomFieldSchema]
+
+func init() {
+	currentCustomFields.Store(&[]CustomFieldSchema{})
+}
+
+// Load custom field schemas from a JSON file
+func LoadCustomFields(path string) ([]CustomFieldSchema, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read custom fields file: %w", err)
+	}
+
+	var schemas []CustomFieldSchema
+	if err := json.Unmarshal(data, &schemas); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal custom fields: %w", err)
+	}
+
+	seen := make(map[string]bool, len(schemas))
+	for _, schema := range schemas {
+		if err := schema.Validate(); err != nil {
+			return nil, err
+		}
+		if seen[schema.Name] {
+			return nil, fmt.Errorf("custom field %q is defined more than once", schema.Name)
+		}
+		seen[schema.Name] = true
+	}
+
+	return schemas, nil
+}
+
+// Set the custom field schemas used by validation
+func SetCustomFields(schemas []CustomFieldSchema) {
+	currentCustomFields.Store(&schemas)
+}
+
+// Get the custom field schemas used by validation
+func CurrentCustomFields() []CustomFieldSchema {
+	return *currentCustomFields.Load()
+}
+
+// Check that a schema is well formed
+func (s *CustomFieldSchema) Validate() error {
+	if !labelKeyRegex.MatchString(s.Name) {
+		return fmt.Errorf("custom field name %q is invalid", s.Name)
+	}
+
+	switch s.Type {
+	case FieldTypeString, FieldTypeInt, FieldTypeIP, FieldTypeCIDR:
+		if len(s.Values) > 0 {
+			return fmt.Errorf("custom field %q: values are only allowed on enum fields", s.Name)
+		}
+	case FieldTypeEnum:
+		if len(s.Values) == 0 {
+			return fmt.Errorf("custom field %q: enum fields must list their values", s.Name)
+		}
+	default:
+		return fmt.Errorf("custom field %q has unknown type %q", s.Name, s.Type)
+	}
+
+	return nil
+}
+
+// Check a value against the schema, returning the validation message on mismatch
+func (s *CustomFieldSchema) check(value interface{}) string {
+	str, isString := value.(string)
+
+	switch s.Type {
+	case FieldTypeString:
+		if !isString {
+			return "must be a string"
+		}
+		if len(str) > 1024 {
+			return "must be at most 1024 characters"
+		}
+	case FieldTypeInt:
+		number, ok := value.(float64)
+		if !ok || number != math.Trunc(number) {
+			return "must be an integer"
+		}
+	case FieldTypeEnum:
+		if !isString || !contains(s.Values, str) {
+			return "must be one of: " + strings.Join(s.Values, ", ")
+		}
+	case FieldTypeIP:
+		if !isString || net.ParseIP(str) == nil {
+			return "must be an IP address"
+		}
+	case FieldTypeCIDR:
+		if !isString || !isValidCIDR(str) {
+			return "must be in CIDR notation"
+		}
+	}
+
+	return ""
+}
+
+// Validate custom fields against the configured schemas
+func validateCustomFields(fields map[string]interface{}, errs *ValidationErrors) {
+	schemas := CurrentCustomFields()
+	known := make(map[string]bool, len(schemas))
+	for _, schema := range schemas {
+		known[schema.Name] = true
+
+		value, ok := fields[schema.Name]
+		if !ok || value == nil {
+			if schema.Required {
+				errs.Add("custom_fields."+schema.Name, CodeRequired, schema.Name+" is required")
+			}
+			continue
+		}
+		if msg := schema.check(value); msg != "" {
+			errs.Add("custom_fields."+schema.Name, CodeInvalidValue, schema.Name+" "+msg)
+		}
+	}
+
+	for _, name := range sortedKeys(fields) {
+		if !known[name] {
+			errs.Add("custom_fields."+name, CodeInvalidValue, "unknown custom field "+name)
+		}
+	}
+}
@@ -0,0 +1,106 @@
+package models
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestLoadCustomFields(t *testing.T) {
+	dir := t.TempDir()
+
+	valid := filepath.Join(dir, "valid.json")
+	os.WriteFile(valid, []byte(`[
+		{"name": "owner", "type": "string", "required": true},
+		{"name": "env", "type": "enum", "values": ["dev", "prod"]}
+	]`), 0644)
+
+	schemas, err := LoadCustomFields(valid)
+	if err != nil {
+		t.Fatalf("Failed to load custom fields: %v", err)
+	}
+	if len(schemas) != 2 || schemas[1].Values[1] != "prod" {
+		t.Errorf("Unexpected schemas: %+v", schemas)
+	}
+
+	tests := []struct {
+		name    string
+		content string
+	}{
+		{"Unknown type", `[{"name": "owner", "type": "text"}]`},
+		{"Enum without values", `[{"name": "env", "type": "enum"}]`},
+		{"Values on string", `[{"name": "owner", "type": "string", "values": ["a"]}]`},
+		{"Invalid name", `[{"name": "-owner", "type": "string"}]`},
+		{"Duplicate name", `[{"name": "owner", "type": "string"}, {"name": "owner", "type": "int"}]`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(dir, "invalid.json")
+			os.WriteFile(path, []byte(tt.content), 0644)
+			if _, err := LoadCustomFields(path); err == nil {
+				t.Error("Expected error for invalid schema")
+			}
+		})
+	}
+
+	if _, err := LoadCustomFields(filepath.Join(dir, "missing.json")); err == nil {
+		t.Error("Expected error for missing file")
+	}
+}
+
+func TestValidateCustomFields(t *testing.T) {
+	previous := CurrentCustomFields()
+	defer SetCustomFields(previous)
+
+	SetCustomFields([]CustomFieldSchema{
+		{Name: "owner", Type: FieldTypeString, Required: true},
+		{Name: "cost_center", Type: FieldTypeInt},
+		{Name: "env", Type: FieldTypeEnum, Values: []string{"dev", "prod"}},
+		{Name: "monitor", Type: FieldTypeIP},
+		{Name: "mgmt", Type: FieldTypeCIDR},
+	})
+
+	base := VLANInput{Name: "Test", VlanID: 100, Subnet: "192.168.1.0/24", Gateway: "192.168.1.1", Status: "active"}
+
+	tests := []struct {
+		name   string
+		fields map[string]interface{}
+		errors []string
+	}{
+		{"All fields valid", map[string]interface{}{"owner": "netops", "cost_center": float64(4100), "env": "prod", "monitor": "2001:db8::1", "mgmt": "10.0.0.0/24"}, nil},
+		{"Only required field", map[string]interface{}{"owner": "netops"}, nil},
+		{"Missing required field", nil, []string{"custom_fields.owner"}},
+		{"Wrong types", map[string]interface{}{"owner": float64(1), "cost_center": 1.5, "env": "qa", "monitor": "10.0.0", "mgmt": "10.0.0.1"},
+			[]string{"custom_fields.owner", "custom_fields.cost_center", "custom_fields.env", "custom_fields.monitor", "custom_fields.mgmt"}},
+		{"Unknown field", map[string]interface{}{"owner": "netops", "rack": "A1"}, []string{"custom_fields.rack"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			input := base
+			input.CustomFields = tt.fields
+
+			err := input.Validate()
+			if len(tt.errors) == 0 {
+				if err != nil {
+					t.Errorf("Expected no error, got %v", err)
+				}
+				return
+			}
+
+			var errs ValidationErrors
+			if !errors.As(err, &errs) {
+				t.Fatalf("Expected ValidationErrors, got %v", err)
+			}
+			if len(errs) != len(tt.errors) {
+				t.Fatalf("Expected %d errors, got %d: %v", len(tt.errors), len(errs), errs)
+			}
+			for i, field := range tt.errors {
+				if errs[i].Field != field {
+					t.Errorf("Expected error %d on %s, got %s", i, field, errs[i].Field)
+				}
+			}
+		})
+	}
+}
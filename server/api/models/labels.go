@@ -0,0 +1,72 @@
+package models
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// Maximum number of labels on a VLAN
+const MaxLabels = 64
+
+// Label keys: alphanumeric at both ends, with dots, dashes, underscores and slashes in between
+var labelKeyRegex = regexp.MustCompile(`^[A-Za-z0-9]([A-Za-z0-9._/-]{0,61}[A-Za-z0-9])?$`)
+
+// Single label requirement, either key=value or the bare key for presence
+type LabelRequirement struct {
+	Key      string
+	Value    string
+	HasValue bool
+}
+
+// Set of label requirements that must all match
+type LabelSelector []LabelRequirement
+
+// Parse label requirements of the form key=value or key
+func ParseLabelSelector(values []string) (LabelSelector, error) {
+	var selector LabelSelector
+	for _, value := range values {
+		key, labelValue, hasValue := strings.Cut(value, "=")
+		if !labelKeyRegex.MatchString(key) {
+			return nil, fmt.Errorf("invalid label selector %q, must be key=value or key", value)
+		}
+		selector = append(selector, LabelRequirement{Key: key, Value: labelValue, HasValue: hasValue})
+	}
+	return selector, nil
+}
+
+// Check whether the labels satisfy every requirement
+func (s LabelSelector) Matches(labels map[string]string) bool {
+	for _, requirement := range s {
+		value, ok := labels[requirement.Key]
+		if !ok || (requirement.HasValue && value != requirement.Value) {
+			return false
+		}
+	}
+	return true
+}
+
+// Validate label keys and values
+func validateLabels(labels map[string]string, errs *ValidationErrors) {
+	if len(labels) > MaxLabels {
+		errs.Add("labels", CodeTooLong, fmt.Sprintf("labels must have at most %d entries", MaxLabels))
+	}
+	for _, key := range sortedKeys(labels) {
+		if !labelKeyRegex.MatchString(key) {
+			errs.Add("labels."+key, CodeInvalidFormat, "label keys must be 1-63 letters, digits, '.', '_', '-' or '/', starting and ending with a letter or digit")
+		} else if len(labels[key]) > 255 {
+			errs.Add("labels."+key, CodeTooLong, "label values must be at most 255 characters")
+		}
+	}
+}
+
+// Map keys in sorted order, so validation errors are reported deterministically
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
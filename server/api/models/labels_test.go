@@ -0,0 +1,74 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestLabelSelector(t *testing.T) {
+	labels := map[string]string{"env": "prod", "team": "netops", "pci": ""}
+
+	tests := []struct {
+		name     string
+		values   []string
+		expected bool
+	}{
+		{"No requirements", nil, true},
+		{"Matching value", []string{"env=prod"}, true},
+		{"Different value", []string{"env=dev"}, false},
+		{"Key present", []string{"pci"}, true},
+		{"Key missing", []string{"owner"}, false},
+		{"Empty value", []string{"pci="}, true},
+		{"All must match", []string{"env=prod", "team=netops"}, true},
+		{"One fails", []string{"env=prod", "team=sec"}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			selector, err := ParseLabelSelector(tt.values)
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if got := selector.Matches(labels); got != tt.expected {
+				t.Errorf("Expected %v, got %v", tt.expected, got)
+			}
+		})
+	}
+
+	for _, value := range []string{"", "=prod", "env!=prod"} {
+		if _, err := ParseLabelSelector([]string{value}); err == nil {
+			t.Errorf("Expected error for selector %q", value)
+		}
+	}
+}
+
+func TestValidateLabels(t *testing.T) {
+	input := VLANInput{Name: "Test", VlanID: 100, Subnet: "192.168.1.0/24", Gateway: "192.168.1.1", Status: "active"}
+
+	input.Labels = map[string]string{"env": "prod", "example.com/owner": "netops", "pci": ""}
+	if err := input.Validate(); err != nil {
+		t.Errorf("Expected valid labels, got %v", err)
+	}
+
+	input.Labels = map[string]string{"env=prod": "x", "owner": strings.Repeat("a", 256)}
+	var errs ValidationErrors
+	if !errors.As(input.Validate(), &errs) || len(errs) != 2 {
+		t.Fatalf("Expected 2 validation errors, got %v", errs)
+	}
+	if errs[0].Field != "labels.env=prod" || errs[0].Code != CodeInvalidFormat {
+		t.Errorf("Unexpected error: %+v", errs[0])
+	}
+	if errs[1].Field != "labels.owner" || errs[1].Code != CodeTooLong {
+		t.Errorf("Unexpected error: %+v", errs[1])
+	}
+
+	input.Labels = make(map[string]string)
+	for i := 0; i <= MaxLabels; i++ {
+		input.Labels[fmt.Sprintf("key%d", i)] = "value"
+	}
+	if err := input.Validate(); err == nil {
+		t.Error("Expected error for too many labels")
+	}
+}
@@ -0,0 +1,160 @@
+package models
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// VLAN status lifecycle: the valid states, allowed transitions and deletable states
+type Lifecycle struct {
+	States      []string            `json:"states"`
+	Transitions map[string][]string `json:"transitions"`
+	Deletable   []string            `json:"deletable"`
+}
+
+// Recorded status change of a VLAN
+type StatusChange struct {
+	From      string    `json:"from"`
+	To        string    `json:"to"`
+	Reason    string    `json:"reason,omitempty"`
+	ChangedAt time.Time `json:"changed_at"`
+	ChangedBy string    `json:"changed_by,omitempty"`
+}
+
+// Structure for a status transition request
+type TransitionInput struct {
+	Status string `json:"status"`
+	Reason string `json:"reason"`
+}
+
+// Lifecycle used by validation, replaced at startup from configuration
+var currentLifecycle atomic.Pointer[Lifecycle]
+
+func init() {
+	currentLifecycle.Store(DefaultLifecycle())
+}
+
+// Default lifecycle used when none is configured
+func DefaultLifecycle() *Lifecycle {
+	return &Lifecycle{
+		States: []string{"active", "inactive", "maintenance", "planned", "reserved", "decommissioning"},
+		Transitions: map[string][]string{
+			"planned":         {"reserved", "active", "decommissioning"},
+			"reserved":        {"planned", "active", "decommissioning"},
+			"active":          {"maintenance", "inactive", "decommissioning"},
+			"maintenance":     {"active", "inactive", "decommissioning"},
+			"inactive":        {"active", "maintenance", "decommissioning"},
+			"decommissioning": {"inactive"},
+		},
+		Deletable: []string{"planned", "reserved", "inactive", "decommissioning"},
+	}
+}
+
+// Load a lifecycle from a JSON file
+func LoadLifecycle(path string) (*Lifecycle, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read lifecycle file: %w", err)
+	}
+
+	var lifecycle Lifecycle
+	if err := json.Unmarshal(data, &lifecycle); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal lifecycle: %w", err)
+	}
+
+	if err := lifecycle.Validate(); err != nil {
+		return nil, err
+	}
+
+	return &lifecycle, nil
+}
+
+// Set the lifecycle used by validation
+func SetLifecycle(lifecycle *Lifecycle) {
+	currentLifecycle.Store(lifecycle)
+}
+
+// Get the lifecycle used by validation
+func CurrentLifecycle() *Lifecycle {
+	return currentLifecycle.Load()
+}
+
+// Check that transitions and deletable states only reference known states
+func (l *Lifecycle) Validate() error {
+	if len(l.States) == 0 {
+		return fmt.Errorf("lifecycle must define at least one state")
+	}
+
+	for from, targets := range l.Transitions {
+		if !l.IsValidState(from) {
+			return fmt.Errorf("lifecycle transition from unknown state %q", from)
+		}
+		for _, to := range targets {
+			if !l.IsValidState(to) {
+				return fmt.Errorf("lifecycle transition from %q to unknown state %q", from, to)
+			}
+		}
+	}
+
+	for _, state := range l.Deletable {
+		if !l.IsValidState(state) {
+			return fmt.Errorf("lifecycle deletable state %q is unknown", state)
+		}
+	}
+
+	return nil
+}
+
+// Check whether a status is a known state
+func (l *Lifecycle) IsValidState(status string) bool {
+	return contains(l.States, status)
+}
+
+// Check whether a VLAN may move from one status to another.
+// Staying in the same status is always allowed.
+func (l *Lifecycle) CanTransition(from, to string) bool {
+	if from == to {
+		return true
+	}
+	return contains(l.Transitions[from], to)
+}
+
+// Check whether a VLAN in the given status may be deleted
+func (l *Lifecycle) CanDelete(status string) bool {
+	return contains(l.Deletable, status)
+}
+
+// Validate transition input
+func (t *TransitionInput) Validate() error {
+	var errs ValidationErrors
+
+	if !CurrentLifecycle().IsValidState(t.Status) {
+		errs.Add("status", CodeInvalidValue, statusMessage())
+	}
+	if t.Reason == "" {
+		errs.Add("reason", CodeRequired, "reason is required")
+	} else if len(t.Reason) > 1024 {
+		errs.Add("reason", CodeTooLong, "reason must be at most 1024 characters")
+	}
+
+	return errs.Err()
+}
+
+// Validation message listing the valid states
+func statusMessage() string {
+	return "status must be one of: " + strings.Join(CurrentLifecycle().States, ", ")
+}
+
+// Check whether a list contains a value
+func contains(list []string, value string) bool {
+	for _, item := range list {
+		if item == value {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,98 @@
+package models
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestDefaultLifecycle(t *testing.T) {
+	lifecycle := DefaultLifecycle()
+
+	if err := lifecycle.Validate(); err != nil {
+		t.Fatalf("Default lifecycle is invalid: %v", err)
+	}
+
+	tests := []struct {
+		from, to string
+		want     bool
+	}{
+		{"planned", "active", true},
+		{"active", "maintenance", true},
+		{"maintenance", "active", true},
+		{"active", "planned", false},
+		{"decommissioning", "active", false},
+		{"active", "active", true},
+	}
+	for _, tt := range tests {
+		if got := lifecycle.CanTransition(tt.from, tt.to); got != tt.want {
+			t.Errorf("CanTransition(%s, %s) = %v, want %v", tt.from, tt.to, got, tt.want)
+		}
+	}
+
+	if lifecycle.CanDelete("active") {
+		t.Error("Expected active VLANs to not be deletable")
+	}
+	if !lifecycle.CanDelete("decommissioning") {
+		t.Error("Expected decommissioning VLANs to be deletable")
+	}
+}
+
+func TestLoadLifecycle(t *testing.T) {
+	dir := t.TempDir()
+
+	valid := filepath.Join(dir, "valid.json")
+	os.WriteFile(valid, []byte(`{
+		"states": ["up", "down"],
+		"transitions": {"up": ["down"], "down": ["up"]},
+		"deletable": ["down"]
+	}`), 0644)
+
+	lifecycle, err := LoadLifecycle(valid)
+	if err != nil {
+		t.Fatalf("Failed to load lifecycle: %v", err)
+	}
+	if !lifecycle.IsValidState("up") || lifecycle.IsValidState("active") {
+		t.Errorf("Unexpected states: %v", lifecycle.States)
+	}
+
+	invalid := filepath.Join(dir, "invalid.json")
+	os.WriteFile(invalid, []byte(`{"states": ["up"], "transitions": {"up": ["gone"]}}`), 0644)
+	if _, err := LoadLifecycle(invalid); err == nil {
+		t.Error("Expected error for transition to unknown state")
+	}
+
+	if _, err := LoadLifecycle(filepath.Join(dir, "missing.json")); err == nil {
+		t.Error("Expected error for missing file")
+	}
+}
+
+func TestValidateUsesCurrentLifecycle(t *testing.T) {
+	previous := CurrentLifecycle()
+	defer SetLifecycle(previous)
+
+	SetLifecycle(&Lifecycle{States: []string{"up", "down"}})
+
+	input := VLANInput{Name: "Test", VlanID: 100, Subnet: "192.168.1.0/24", Gateway: "192.168.1.1", Status: "up"}
+	if err := input.Validate(); err != nil {
+		t.Errorf("Expected configured state to be valid, got %v", err)
+	}
+
+	input.Status = "active"
+	if err := input.Validate(); err == nil || err.Error() != "status must be one of: up, down" {
+		t.Errorf("Unexpected error: %v", err)
+	}
+}
+
+func TestTransitionInputValidate(t *testing.T) {
+	input := TransitionInput{Status: "maintenance", Reason: "Firmware upgrade"}
+	if err := input.Validate(); err != nil {
+		t.Errorf("Unexpected error: %v", err)
+	}
+
+	input = TransitionInput{Status: "unknown"}
+	errs, ok := input.Validate().(ValidationErrors)
+	if !ok || len(errs) != 2 {
+		t.Errorf("Expected 2 validation errors, got %v", errs)
+	}
+}
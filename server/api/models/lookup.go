@@ -0,0 +1,14 @@
+package models
+
+// VLAN whose subnet contains a looked-up address
+type IPLookupMatch struct {
+	Subnet       string    `json:"subnet"`
+	PrefixLength int       `json:"prefix_length"`
+	VLAN         VLANModel `json:"vlan"`
+}
+
+// Result of an IP address lookup, most specific match first
+type IPLookupResult struct {
+	Address string          `json:"address"`
+	Matches []IPLookupMatch `json:"matches"`
+}
@@ -0,0 +1,265 @@
+package models
+
+import (
+	"fmt"
+	"sort"
+	"time"
+)
+
+// Layout of maintenance window start, end and until times, in the window's timezone
+const MaintenanceTimeLayout = "2006-01-02T15:04"
+
+// Status VLANs are moved to during a maintenance window
+const MaintenanceStatus = "maintenance"
+
+// Maintenance window recurrences
+const (
+	RecurrenceNone    = ""
+	RecurrenceDaily   = "daily"
+	RecurrenceWeekly  = "weekly"
+	RecurrenceMonthly = "monthly"
+)
+
+// Scheduled maintenance window attached to one or more VLANs
+type MaintenanceWindow struct {
+	ID            int    `json:"id"`
+	Name          string `json:"name"`
+	VLANRecordIDs []int  `json:"vlan_record_ids"`
+	Start         string `json:"start"`
+	End           string `json:"end"`
+	Timezone      string `json:"timezone"`
+	Recurrence    string `json:"recurrence,omitempty"`
+	Until         string `json:"until,omitempty"`
+
+	// Status each VLAN had before the scheduler moved it to maintenance,
+	// persisted so an occurrence in progress survives restarts
+	Applied map[int]string `json:"applied,omitempty"`
+
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// Structure for creating a maintenance window
+type MaintenanceWindowInput struct {
+	Name          string `json:"name"`
+	VLANRecordIDs []int  `json:"vlan_record_ids"`
+	Start         string `json:"start"`
+	End           string `json:"end"`
+	Timezone      string `json:"timezone"`
+	Recurrence    string `json:"recurrence,omitempty"`
+	Until         string `json:"until,omitempty"`
+}
+
+// Single occurrence of a maintenance window
+type MaintenanceOccurrence struct {
+	WindowID      int       `json:"window_id"`
+	Name          string    `json:"name"`
+	VLANRecordIDs []int     `json:"vlan_record_ids"`
+	Start         time.Time `json:"start"`
+	End           time.Time `json:"end"`
+	Active        bool      `json:"active"`
+}
+
+// Validate maintenance window input, collecting every violation
+func (m *MaintenanceWindowInput) Validate() error {
+	var errs ValidationErrors
+
+	if m.Name == "" {
+		errs.Add("name", CodeRequired, "name must be between 1 and 255 characters")
+	} else if len(m.Name) > 255 {
+		errs.Add("name", CodeTooLong, "name must be between 1 and 255 characters")
+	}
+
+	if len(m.VLANRecordIDs) == 0 {
+		errs.Add("vlan_record_ids", CodeRequired, "vlan_record_ids must contain at least one VLAN record ID")
+	}
+	for _, id := range m.VLANRecordIDs {
+		if id < 1 {
+			errs.Add("vlan_record_ids", CodeOutOfRange, "vlan_record_ids must be positive")
+			break
+		}
+	}
+
+	switch m.Recurrence {
+	case RecurrenceNone, RecurrenceDaily, RecurrenceWeekly, RecurrenceMonthly:
+	default:
+		errs.Add("recurrence", CodeInvalidValue, "recurrence must be one of: daily, weekly, monthly")
+	}
+
+	loc, err := time.LoadLocation(m.Timezone)
+	if m.Timezone == "" || err != nil {
+		errs.Add("timezone", CodeInvalidValue, "timezone must be an IANA time zone name (e.g., Europe/Tallinn)")
+		return errs.Err()
+	}
+
+	start, startErr := time.ParseInLocation(MaintenanceTimeLayout, m.Start, loc)
+	if startErr != nil {
+		errs.Add("start", CodeInvalidFormat, "start must be a local time in the format 2006-01-02T15:04")
+	}
+	end, endErr := time.ParseInLocation(MaintenanceTimeLayout, m.End, loc)
+	if endErr != nil {
+		errs.Add("end", CodeInvalidFormat, "end must be a local time in the format 2006-01-02T15:04")
+	}
+
+	if startErr == nil && endErr == nil {
+		if !end.After(start) {
+			errs.Add("end", CodeOutOfRange, "end must be after start")
+		} else if period := recurrencePeriod(m.Recurrence); period > 0 && end.Sub(start) >= period {
+			errs.Add("end", CodeOutOfRange, "window must be shorter than its recurrence period")
+		}
+	}
+
+	if m.Until != "" {
+		until, err := time.ParseInLocation(MaintenanceTimeLayout, m.Until, loc)
+		if err != nil {
+			errs.Add("until", CodeInvalidFormat, "until must be a local time in the format 2006-01-02T15:04")
+		} else if startErr == nil && until.Before(start) {
+			errs.Add("until", CodeOutOfRange, "until must not be before start")
+		}
+	}
+
+	return errs.Err()
+}
+
+// Occurrences of the window overlapping [from, to), ordered by start
+func (m *MaintenanceWindow) Occurrences(from, to time.Time) ([]MaintenanceOccurrence, error) {
+	loc, err := time.LoadLocation(m.Timezone)
+	if err != nil {
+		return nil, fmt.Errorf("invalid timezone %q: %w", m.Timezone, err)
+	}
+
+	start, err := time.ParseInLocation(MaintenanceTimeLayout, m.Start, loc)
+	if err != nil {
+		return nil, fmt.Errorf("invalid start %q: %w", m.Start, err)
+	}
+	end, err := time.ParseInLocation(MaintenanceTimeLayout, m.End, loc)
+	if err != nil {
+		return nil, fmt.Errorf("invalid end %q: %w", m.End, err)
+	}
+
+	var until time.Time
+	if m.Until != "" {
+		if until, err = time.ParseInLocation(MaintenanceTimeLayout, m.Until, loc); err != nil {
+			return nil, fmt.Errorf("invalid until %q: %w", m.Until, err)
+		}
+	}
+
+	var occurrences []MaintenanceOccurrence
+	for n := firstCandidate(m.Recurrence, start, from); ; n++ {
+		// Shift the wall-clock times so occurrences keep their local time across DST changes
+		occStart := shift(start, m.Recurrence, n)
+		occEnd := endOf(occStart, start, end)
+
+		if !occStart.Before(to) || (!until.IsZero() && occStart.After(until)) {
+			break
+		}
+		if occEnd.After(from) {
+			occurrences = append(occurrences, MaintenanceOccurrence{
+				WindowID:      m.ID,
+				Name:          m.Name,
+				VLANRecordIDs: m.VLANRecordIDs,
+				Start:         occStart,
+				End:           occEnd,
+			})
+		}
+		if m.Recurrence == RecurrenceNone {
+			break
+		}
+	}
+
+	return occurrences, nil
+}
+
+// Occurrence of the window in progress at the given time
+func (m *MaintenanceWindow) ActiveOccurrence(now time.Time) (*MaintenanceOccurrence, error) {
+	occurrences, err := m.Occurrences(now, now.Add(time.Nanosecond))
+	if err != nil || len(occurrences) == 0 {
+		return nil, err
+	}
+
+	occurrence := occurrences[0]
+	occurrence.Active = true
+	return &occurrence, nil
+}
+
+// Upcoming and in-progress occurrences of all windows within the horizon, ordered by start
+func UpcomingMaintenance(windows []MaintenanceWindow, now time.Time, horizon time.Duration) []MaintenanceOccurrence {
+	result := []MaintenanceOccurrence{}
+	for _, window := range windows {
+		occurrences, err := window.Occurrences(now, now.Add(horizon))
+		if err != nil {
+			continue
+		}
+		for _, occurrence := range occurrences {
+			occurrence.Active = !occurrence.Start.After(now)
+			result = append(result, occurrence)
+		}
+	}
+
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].Start.Before(result[j].Start)
+	})
+	return result
+}
+
+// Shift a time by n recurrence periods in its own location
+func shift(t time.Time, recurrence string, n int) time.Time {
+	switch recurrence {
+	case RecurrenceDaily:
+		return t.AddDate(0, 0, n)
+	case RecurrenceWeekly:
+		return t.AddDate(0, 0, 7*n)
+	case RecurrenceMonthly:
+		// Clamp to the last day of shorter months; AddDate would overflow into the next one
+		year, month := t.Year(), t.Month()+time.Month(n)
+		lastDay := time.Date(year, month+1, 0, 0, 0, 0, 0, time.UTC).Day()
+		return time.Date(year, month, min(t.Day(), lastDay), t.Hour(), t.Minute(), t.Second(), t.Nanosecond(), t.Location())
+	default:
+		return t
+	}
+}
+
+// End of the occurrence starting at occStart: the same number of days after it
+// and the same wall-clock time as the window's end, so clamping never moves the
+// end before the start
+func endOf(occStart, start, end time.Time) time.Time {
+	days := int(civilDate(end).Sub(civilDate(start)) / (24 * time.Hour))
+	return time.Date(occStart.Year(), occStart.Month(), occStart.Day()+days, end.Hour(), end.Minute(), end.Second(), end.Nanosecond(), end.Location())
+}
+
+// Calendar date of a time, in UTC so the difference between two dates is whole days
+func civilDate(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+}
+
+// Index of the first occurrence that may overlap from, skipping earlier ones
+func firstCandidate(recurrence string, start, from time.Time) int {
+	if recurrence == RecurrenceNone || !from.After(start) {
+		return 0
+	}
+
+	var n int
+	switch recurrence {
+	case RecurrenceDaily, RecurrenceWeekly:
+		n = int(from.Sub(start) / recurrencePeriod(recurrence))
+	case RecurrenceMonthly:
+		n = (from.Year()-start.Year())*12 + int(from.Month()-start.Month())
+	}
+
+	// Step back one period to cover an occurrence still in progress and DST shifts
+	return max(n-1, 0)
+}
+
+// Nominal length of a recurrence period
+func recurrencePeriod(recurrence string) time.Duration {
+	switch recurrence {
+	case RecurrenceDaily:
+		return 24 * time.Hour
+	case RecurrenceWeekly:
+		return 7 * 24 * time.Hour
+	case RecurrenceMonthly:
+		return 28 * 24 * time.Hour
+	default:
+		return 0
+	}
+}
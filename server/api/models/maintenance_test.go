@@ -0,0 +1,158 @@
+package models
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestMaintenanceWindowInputValidate(t *testing.T) {
+	valid := MaintenanceWindowInput{
+		Name:          "Core upgrade",
+		VLANRecordIDs: []int{1, 2},
+		Start:         "2025-03-01T22:00",
+		End:           "2025-03-02T02:00",
+		Timezone:      "Europe/Tallinn",
+	}
+
+	tests := []struct {
+		name   string
+		modify func(*MaintenanceWindowInput)
+		fields []string
+	}{
+		{"Valid window", func(m *MaintenanceWindowInput) {}, nil},
+		{"Valid weekly window", func(m *MaintenanceWindowInput) { m.Recurrence = RecurrenceWeekly; m.Until = "2025-06-01T00:00" }, nil},
+		{"Missing name and VLANs", func(m *MaintenanceWindowInput) { m.Name = ""; m.VLANRecordIDs = nil }, []string{"name", "vlan_record_ids"}},
+		{"Unknown timezone", func(m *MaintenanceWindowInput) { m.Timezone = "Mars/Olympus" }, []string{"timezone"}},
+		{"Bad start format", func(m *MaintenanceWindowInput) { m.Start = "2025-03-01 22:00" }, []string{"start"}},
+		{"End before start", func(m *MaintenanceWindowInput) { m.End = "2025-03-01T21:00" }, []string{"end"}},
+		{"Longer than recurrence", func(m *MaintenanceWindowInput) { m.Recurrence = RecurrenceDaily; m.End = "2025-03-03T00:00" }, []string{"end"}},
+		{"Unknown recurrence", func(m *MaintenanceWindowInput) { m.Recurrence = "yearly" }, []string{"recurrence"}},
+		{"Until before start", func(m *MaintenanceWindowInput) { m.Until = "2025-02-01T00:00" }, []string{"until"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			input := valid
+			tt.modify(&input)
+
+			err := input.Validate()
+			if len(tt.fields) == 0 {
+				if err != nil {
+					t.Errorf("Expected no error, got %v", err)
+				}
+				return
+			}
+
+			var errs ValidationErrors
+			if !errors.As(err, &errs) {
+				t.Fatalf("Expected ValidationErrors, got %v", err)
+			}
+			if len(errs) != len(tt.fields) {
+				t.Fatalf("Expected %d errors, got %d: %v", len(tt.fields), len(errs), errs)
+			}
+			for i, field := range tt.fields {
+				if errs[i].Field != field {
+					t.Errorf("Expected error %d on %s, got %s", i, field, errs[i].Field)
+				}
+			}
+		})
+	}
+}
+
+func TestMaintenanceWindowOccurrences(t *testing.T) {
+	window := MaintenanceWindow{
+		ID:         1,
+		Name:       "Nightly",
+		Start:      "2025-03-28T02:00",
+		End:        "2025-03-28T04:00",
+		Timezone:   "Europe/Tallinn",
+		Recurrence: RecurrenceDaily,
+		Until:      "2025-04-02T00:00",
+	}
+	loc, _ := time.LoadLocation("Europe/Tallinn")
+
+	occurrences, err := window.Occurrences(time.Date(2025, 3, 29, 12, 0, 0, 0, loc), time.Date(2025, 4, 10, 0, 0, 0, 0, loc))
+	if err != nil {
+		t.Fatalf("Failed to compute occurrences: %v", err)
+	}
+
+	// 30 March to 1 April; the window ends at until
+	if len(occurrences) != 3 {
+		t.Fatalf("Expected 3 occurrences, got %d", len(occurrences))
+	}
+
+	// Local start time is kept across the DST change on 30 March
+	for _, occurrence := range occurrences {
+		start := occurrence.Start.In(loc)
+		if start.Hour() != 2 || occurrence.End.Sub(occurrence.Start) > 2*time.Hour {
+			t.Errorf("Unexpected occurrence %v - %v", occurrence.Start, occurrence.End)
+		}
+	}
+	if occurrences[0].Start.In(loc).Day() != 30 {
+		t.Errorf("Expected first occurrence on 30 March, got %v", occurrences[0].Start)
+	}
+}
+
+func TestMaintenanceWindowActiveOccurrence(t *testing.T) {
+	window := MaintenanceWindow{
+		ID:         1,
+		Start:      "2025-01-31T23:00",
+		End:        "2025-02-01T01:00",
+		Timezone:   "UTC",
+		Recurrence: RecurrenceMonthly,
+	}
+
+	tests := []struct {
+		name   string
+		now    time.Time
+		active bool
+	}{
+		{"Before first occurrence", time.Date(2025, 1, 31, 22, 59, 0, 0, time.UTC), false},
+		{"During first occurrence", time.Date(2025, 2, 1, 0, 30, 0, 0, time.UTC), true},
+		{"At end of first occurrence", time.Date(2025, 2, 1, 1, 0, 0, 0, time.UTC), false},
+		{"During later occurrence", time.Date(2025, 7, 31, 23, 30, 0, 0, time.UTC), true},
+		{"On the last day of a shorter month", time.Date(2025, 2, 28, 23, 30, 0, 0, time.UTC), true},
+		{"End carried over the clamped start", time.Date(2025, 3, 1, 0, 30, 0, 0, time.UTC), true},
+		{"Not drifted into the next month", time.Date(2025, 3, 3, 23, 30, 0, 0, time.UTC), false},
+		{"Back on the 31st after a short month", time.Date(2025, 5, 31, 23, 30, 0, 0, time.UTC), true},
+		{"Between occurrences", time.Date(2025, 7, 15, 0, 0, 0, 0, time.UTC), false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			occurrence, err := window.ActiveOccurrence(tt.now)
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if (occurrence != nil) != tt.active {
+				t.Errorf("Expected active %v, got %+v", tt.active, occurrence)
+			}
+		})
+	}
+}
+
+func TestUpcomingMaintenance(t *testing.T) {
+	windows := []MaintenanceWindow{
+		{ID: 1, Start: "2025-05-10T10:00", End: "2025-05-10T12:00", Timezone: "UTC"},
+		{ID: 2, Start: "2025-05-01T08:00", End: "2025-05-01T09:00", Timezone: "UTC", Recurrence: RecurrenceWeekly},
+		{ID: 3, Start: "2025-04-01T08:00", End: "2025-04-01T09:00", Timezone: "UTC"},
+	}
+	now := time.Date(2025, 5, 8, 8, 30, 0, 0, time.UTC)
+
+	upcoming := UpcomingMaintenance(windows, now, 7*24*time.Hour)
+
+	// Window 2 on 8 May (in progress) and 15 May, window 1 on 10 May
+	if len(upcoming) != 3 {
+		t.Fatalf("Expected 3 occurrences, got %d", len(upcoming))
+	}
+	if upcoming[0].WindowID != 2 || !upcoming[0].Active {
+		t.Errorf("Expected window 2 in progress first, got %+v", upcoming[0])
+	}
+	if upcoming[1].WindowID != 1 || upcoming[1].Active {
+		t.Errorf("Expected window 1 second, got %+v", upcoming[1])
+	}
+	if upcoming[2].WindowID != 2 {
+		t.Errorf("Expected window 2 last, got %+v", upcoming[2])
+	}
+}
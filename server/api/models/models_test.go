@@ -1,6 +1,7 @@
 package models
 
 import (
+	"errors"
 	"testing"
 )
 
@@ -140,7 +141,34 @@ func TestVLANInputValidate(t *testing.T) {
 				Status:  "unknown",
 			},
 			wantErr: true,
-			errMsg:  "status must be one of: active, inactive, maintenance",
+			errMsg:  "status must be one of: active, inactive, maintenance, planned, reserved, decommissioning",
+		},
+		{
+			name: "Negative allocated count",
+			input: VLANInput{
+				Name:      "Test",
+				VlanID:    100,
+				Subnet:    "192.168.1.0/24",
+				Gateway:   "192.168.1.1",
+				Status:    "active",
+				Allocated: -1,
+			},
+			wantErr: true,
+			errMsg:  "allocated must not be negative",
+		},
+		{
+			name: "Valid with capacity fields",
+			input: VLANInput{
+				Name:      "Test",
+				VlanID:    100,
+				Subnet:    "192.168.1.0/24",
+				Gateway:   "192.168.1.1",
+				Status:    "active",
+				Site:      "tallinn",
+				Allocated: 120,
+				Reserved:  10,
+			},
+			wantErr: false,
 		},
 		{
 			name: "Valid with max VLAN ID",
@@ -236,6 +264,10 @@ func TestIPValidation(t *testing.T) {
 		{"1.1.1.256", false},
 		{"not-an-ip", false},
 		{"", false},
+		{"2001:db8::1", true},
+		{"::ffff:192.168.1.1", true},
+		{"2001:db8::g", false},
+		{"fe80::1%eth0", false},
 	}
 
 	for _, tt := range tests {
@@ -258,3 +290,68 @@ func TestIPValidation(t *testing.T) {
 		})
 	}
 }
+
+func TestVLANInputValidateCollectsAllErrors(t *testing.T) {
+	input := VLANInput{
+		Name:    "",
+		VlanID:  5000,
+		Subnet:  "invalid",
+		Gateway: "invalid",
+		Status:  "unknown",
+	}
+
+	err := input.Validate()
+	var errs ValidationErrors
+	if !errors.As(err, &errs) {
+		t.Fatalf("Expected ValidationErrors, got %T", err)
+	}
+
+	want := []FieldError{
+		{Field: "name", Code: CodeRequired},
+		{Field: "vlan_id", Code: CodeOutOfRange},
+		{Field: "subnet", Code: CodeInvalidFormat},
+		{Field: "gateway", Code: CodeInvalidFormat},
+		{Field: "status", Code: CodeInvalidValue},
+	}
+	if len(errs) != len(want) {
+		t.Fatalf("Expected %d errors, got %d: %v", len(want), len(errs), errs)
+	}
+	for i, fe := range errs {
+		if fe.Field != want[i].Field || fe.Code != want[i].Code {
+			t.Errorf("Error %d = %s/%s, want %s/%s", i, fe.Field, fe.Code, want[i].Field, want[i].Code)
+		}
+		if fe.Message == "" {
+			t.Errorf("Error %d has no message", i)
+		}
+	}
+}
+
+func TestPrefixInputValidate(t *testing.T) {
+	tests := []struct {
+		prefix   string
+		wantCode string
+	}{
+		{"10.20.0.0/16", ""},
+		{"2001:db8::/48", ""},
+		{"10.20.1.0/16", CodeInvalidValue},
+		{"not-a-prefix", CodeInvalidFormat},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.prefix, func(t *testing.T) {
+			input := PrefixInput{Prefix: tt.prefix}
+			err := input.Validate()
+			if tt.wantCode == "" {
+				if err != nil {
+					t.Errorf("Validate() unexpected error: %v", err)
+				}
+				return
+			}
+
+			var errs ValidationErrors
+			if !errors.As(err, &errs) || errs[0].Code != tt.wantCode {
+				t.Errorf("Validate() error = %v, want code %s", err, tt.wantCode)
+			}
+		})
+	}
+}
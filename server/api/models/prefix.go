@@ -0,0 +1,59 @@
+package models
+
+import (
+	"fmt"
+	"net/netip"
+	"time"
+)
+
+// Parent prefix (aggregate) that VLAN subnets are carved from
+type PrefixModel struct {
+	ID          int       `json:"id"`
+	Prefix      string    `json:"prefix"`
+	Description string    `json:"description,omitempty"`
+	Site        string    `json:"site,omitempty"`
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
+
+// Structure for creating a parent prefix
+type PrefixInput struct {
+	Prefix      string `json:"prefix"`
+	Description string `json:"description,omitempty"`
+	Site        string `json:"site,omitempty"`
+}
+
+// Structure for a subnet allocation request.
+// When VLAN is set, a VLAN is created with the carved subnet and a gateway chosen by GatewayPolicy.
+type AllocationRequest struct {
+	VLAN          *VLANInput `json:"vlan,omitempty"`
+	GatewayPolicy string     `json:"gateway_policy,omitempty"`
+}
+
+// Structure for a subnet allocation response
+type AllocationResponse struct {
+	Prefix  string     `json:"prefix"`
+	Subnet  string     `json:"subnet"`
+	Gateway string     `json:"gateway"`
+	VLAN    *VLANModel `json:"vlan,omitempty"`
+}
+
+// Validate prefix input, collecting every violation
+func (p *PrefixInput) Validate() error {
+	var errs ValidationErrors
+
+	if prefix, err := netip.ParsePrefix(p.Prefix); err != nil {
+		errs.Add("prefix", CodeInvalidFormat, "invalid prefix format, must be in CIDR notation (e.g., 10.20.0.0/16)")
+	} else if prefix.Masked() != prefix {
+		errs.Add("prefix", CodeInvalidValue, fmt.Sprintf("prefix must be a network address (did you mean %s?)", prefix.Masked()))
+	}
+
+	if len(p.Description) > 255 {
+		errs.Add("description", CodeTooLong, "description must be at most 255 characters")
+	}
+	if len(p.Site) > 255 {
+		errs.Add("site", CodeTooLong, "site must be at most 255 characters")
+	}
+
+	return errs.Err()
+}
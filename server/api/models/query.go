@@ -0,0 +1,237 @@
+package models
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/netip"
+	"sort"
+	"strings"
+)
+
+// Maximum page size of a VLAN query
+const MaxQueryLimit = 1000
+
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+// Filters, ordering and pagination of a VLAN list query.
+// Zero values mean no filter; a zero Limit returns every match.
+type VLANQuery struct {
+	Status    []string
+	Name      string
+	VlanIDMin int
+	VlanIDMax int
+	// VLANs whose subnet lies inside this prefix
+	SubnetWithin netip.Prefix
+	// VLANs whose subnet contains this address
+	SubnetContains netip.Addr
+	Labels         LabelSelector
+
+	Sort       string
+	Descending bool
+	Limit      int
+	Cursor     string
+}
+
+// One page of a VLAN query
+type VLANPage struct {
+	Items []VLANModel
+	// Cursor of the next page, empty on the last page
+	NextCursor string
+}
+
+// Position after the last item of a page
+type queryCursor struct {
+	Sort string `json:"s"`
+	Desc bool   `json:"d,omitempty"`
+	ID   int    `json:"id"`
+	Num  int64  `json:"n,omitempty"`
+	Str  string `json:"v,omitempty"`
+}
+
+// Sort key of a VLAN field, numeric or textual
+type sortKey struct {
+	num int64
+	str string
+}
+
+// Fields a VLAN query can be sorted by
+var sortFields = map[string]func(*VLANModel) sortKey{
+	"id":         func(v *VLANModel) sortKey { return sortKey{num: int64(v.ID)} },
+	"vlan_id":    func(v *VLANModel) sortKey { return sortKey{num: int64(v.VlanID)} },
+	"name":       func(v *VLANModel) sortKey { return sortKey{str: v.Name} },
+	"status":     func(v *VLANModel) sortKey { return sortKey{str: v.Status} },
+	"site":       func(v *VLANModel) sortKey { return sortKey{str: v.Site} },
+	"subnet":     func(v *VLANModel) sortKey { return sortKey{str: subnetSortKey(v.Subnet)} },
+	"created_at": func(v *VLANModel) sortKey { return sortKey{num: v.CreatedAt.UnixNano()} },
+	"updated_at": func(v *VLANModel) sortKey { return sortKey{num: v.UpdatedAt.UnixNano()} },
+}
+
+// Names of the sortable fields
+func SortFields() []string {
+	return sortedKeys(sortFields)
+}
+
+// Validate query values that do not depend on the data
+func (q *VLANQuery) Validate() error {
+	var errs ValidationErrors
+
+	for _, status := range q.Status {
+		if !CurrentLifecycle().IsValidState(status) {
+			errs.Add("status", CodeInvalidValue, statusMessage())
+			break
+		}
+	}
+	if q.VlanIDMin > 0 && q.VlanIDMax > 0 && q.VlanIDMin > q.VlanIDMax {
+		errs.Add("vlan_id", CodeOutOfRange, "vlan_id range start must not be after its end")
+	}
+	if q.Sort != "" && sortFields[q.Sort] == nil {
+		errs.Add("sort", CodeInvalidValue, "sort must be one of: "+strings.Join(SortFields(), ", "))
+	}
+	if q.Limit < 0 || q.Limit > MaxQueryLimit {
+		errs.Add("limit", CodeOutOfRange, fmt.Sprintf("limit must be between 1 and %d", MaxQueryLimit))
+	}
+	if q.Cursor != "" {
+		if _, err := q.decodeCursor(); err != nil {
+			errs.Add("cursor", CodeInvalidValue, "cursor is invalid or belongs to a different sort order")
+		}
+	}
+
+	return errs.Err()
+}
+
+// Check whether a VLAN passes every filter of the query
+func (q *VLANQuery) Matches(vlan *VLANModel) bool {
+	if len(q.Status) > 0 && !contains(q.Status, vlan.Status) {
+		return false
+	}
+	if q.Name != "" && !strings.Contains(strings.ToLower(vlan.Name), strings.ToLower(q.Name)) {
+		return false
+	}
+	if q.VlanIDMin > 0 && vlan.VlanID < q.VlanIDMin {
+		return false
+	}
+	if q.VlanIDMax > 0 && vlan.VlanID > q.VlanIDMax {
+		return false
+	}
+	if q.SubnetWithin.IsValid() || q.SubnetContains.IsValid() {
+		subnet, err := netip.ParsePrefix(vlan.Subnet)
+		if err != nil {
+			return false
+		}
+		subnet = subnet.Masked()
+		if q.SubnetWithin.IsValid() && (subnet.Bits() < q.SubnetWithin.Bits() || !q.SubnetWithin.Contains(subnet.Addr())) {
+			return false
+		}
+		if q.SubnetContains.IsValid() && !subnet.Contains(q.SubnetContains) {
+			return false
+		}
+	}
+	return q.Labels.Matches(vlan.Labels)
+}
+
+// Filter, sort and page a full list of VLANs.
+// Backends that cannot push the query down use this on their scan.
+func (q *VLANQuery) Apply(vlans []VLANModel) (*VLANPage, error) {
+	cursor, err := q.decodeCursor()
+	if err != nil {
+		return nil, err
+	}
+
+	sortBy := q.sortKeyFunc()
+	less := func(a, b sortKey, aID, bID int) bool {
+		if c := compareKeys(a, b); c != 0 {
+			return (c < 0) != q.Descending
+		}
+		// IDs break ties in the sort direction so the order is total
+		return aID != bID && (aID < bID) != q.Descending
+	}
+
+	matched := []VLANModel{}
+	for i := range vlans {
+		if !q.Matches(&vlans[i]) {
+			continue
+		}
+		// Skip everything up to and including the cursor position
+		if cursor != nil && !less(sortKey{num: cursor.Num, str: cursor.Str}, sortBy(&vlans[i]), cursor.ID, vlans[i].ID) {
+			continue
+		}
+		matched = append(matched, vlans[i])
+	}
+
+	sort.SliceStable(matched, func(i, j int) bool {
+		return less(sortBy(&matched[i]), sortBy(&matched[j]), matched[i].ID, matched[j].ID)
+	})
+
+	page := &VLANPage{Items: matched}
+	if q.Limit > 0 && len(matched) > q.Limit {
+		page.Items = matched[:q.Limit]
+		last := &page.Items[q.Limit-1]
+		key := sortBy(last)
+		page.NextCursor = q.encodeCursor(queryCursor{ID: last.ID, Num: key.num, Str: key.str})
+	}
+	return page, nil
+}
+
+// Sort key function of the query, by ID when no sort is given
+func (q *VLANQuery) sortKeyFunc() func(*VLANModel) sortKey {
+	if key := sortFields[q.Sort]; key != nil {
+		return key
+	}
+	return sortFields["id"]
+}
+
+// Encode a cursor for the query's sort order
+func (q *VLANQuery) encodeCursor(cursor queryCursor) string {
+	cursor.Sort = q.Sort
+	cursor.Desc = q.Descending
+	data, _ := json.Marshal(cursor)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+// Decode the query's cursor, rejecting cursors of another sort order
+func (q *VLANQuery) decodeCursor() (*queryCursor, error) {
+	if q.Cursor == "" {
+		return nil, nil
+	}
+
+	data, err := base64.RawURLEncoding.DecodeString(q.Cursor)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+	var cursor queryCursor
+	if err := json.Unmarshal(data, &cursor); err != nil {
+		return nil, ErrInvalidCursor
+	}
+	if cursor.Sort != q.Sort || cursor.Desc != q.Descending {
+		return nil, ErrInvalidCursor
+	}
+	return &cursor, nil
+}
+
+// Compare two sort keys
+func compareKeys(a, b sortKey) int {
+	switch {
+	case a.num < b.num:
+		return -1
+	case a.num > b.num:
+		return 1
+	}
+	return strings.Compare(a.str, b.str)
+}
+
+// Subnet sort key that orders by address and then prefix length, IPv4 before IPv6
+func subnetSortKey(subnet string) string {
+	prefix, err := netip.ParsePrefix(subnet)
+	if err != nil {
+		return "~" + subnet
+	}
+
+	addr := prefix.Masked().Addr()
+	family := 6
+	if addr.Is4() {
+		family = 4
+	}
+	return fmt.Sprintf("%d%x/%03d", family, addr.As16(), prefix.Bits())
+}
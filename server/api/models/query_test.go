@@ -0,0 +1,144 @@
+package models
+
+import (
+	"net/netip"
+	"testing"
+	"time"
+)
+
+func queryTestVLANs() []VLANModel {
+	created := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	return []VLANModel{
+		{ID: 1, Name: "Production", VlanID: 100, Subnet: "10.0.0.0/24", Status: "active", CreatedAt: created},
+		{ID: 2, Name: "Development", VlanID: 200, Subnet: "10.1.0.0/24", Status: "active", CreatedAt: created.Add(time.Hour)},
+		{ID: 3, Name: "Guest", VlanID: 300, Subnet: "192.168.0.0/24", Status: "inactive", CreatedAt: created.Add(2 * time.Hour)},
+		{ID: 4, Name: "Prod backup", VlanID: 150, Subnet: "10.0.0.0/16", Status: "maintenance", CreatedAt: created.Add(3 * time.Hour),
+			Labels: map[string]string{"env": "prod"}},
+		{ID: 5, Name: "Storage", VlanID: 250, Subnet: "10.0.2.0/24", Status: "active", CreatedAt: created.Add(4 * time.Hour)},
+	}
+}
+
+func ids(vlans []VLANModel) []int {
+	result := []int{}
+	for _, vlan := range vlans {
+		result = append(result, vlan.ID)
+	}
+	return result
+}
+
+func equalIDs(a, b []int) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func TestVLANQueryFilters(t *testing.T) {
+	tests := []struct {
+		name     string
+		query    VLANQuery
+		expected []int
+	}{
+		{"No filters", VLANQuery{}, []int{1, 2, 3, 4, 5}},
+		{"Status", VLANQuery{Status: []string{"inactive", "maintenance"}}, []int{3, 4}},
+		{"Name substring ignores case", VLANQuery{Name: "prod"}, []int{1, 4}},
+		{"VLAN ID range", VLANQuery{VlanIDMin: 150, VlanIDMax: 250}, []int{2, 4, 5}},
+		{"Open VLAN ID range", VLANQuery{VlanIDMin: 250}, []int{3, 5}},
+		{"Subnet within", VLANQuery{SubnetWithin: netip.MustParsePrefix("10.0.0.0/16")}, []int{1, 4, 5}},
+		{"Subnet contains", VLANQuery{SubnetContains: netip.MustParseAddr("10.0.0.7")}, []int{1, 4}},
+		{"Labels", VLANQuery{Labels: LabelSelector{{Key: "env", Value: "prod", HasValue: true}}}, []int{4}},
+		{"Combined", VLANQuery{Status: []string{"active"}, SubnetWithin: netip.MustParsePrefix("10.0.0.0/8"), VlanIDMax: 200}, []int{1, 2}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			page, err := tt.query.Apply(queryTestVLANs())
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if got := ids(page.Items); !equalIDs(got, tt.expected) {
+				t.Errorf("Expected %v, got %v", tt.expected, got)
+			}
+		})
+	}
+}
+
+func TestVLANQuerySort(t *testing.T) {
+	tests := []struct {
+		sort       string
+		descending bool
+		expected   []int
+	}{
+		{"", false, []int{1, 2, 3, 4, 5}},
+		{"name", false, []int{2, 3, 4, 1, 5}},
+		{"vlan_id", true, []int{3, 5, 2, 4, 1}},
+		{"status", false, []int{1, 2, 5, 3, 4}},
+		{"subnet", false, []int{4, 1, 5, 2, 3}},
+		{"created_at", true, []int{5, 4, 3, 2, 1}},
+	}
+
+	for _, tt := range tests {
+		query := VLANQuery{Sort: tt.sort, Descending: tt.descending}
+		page, err := query.Apply(queryTestVLANs())
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if got := ids(page.Items); !equalIDs(got, tt.expected) {
+			t.Errorf("Sort %q desc=%v: expected %v, got %v", tt.sort, tt.descending, tt.expected, got)
+		}
+	}
+}
+
+func TestVLANQueryPagination(t *testing.T) {
+	for _, descending := range []bool{false, true} {
+		query := VLANQuery{Sort: "status", Descending: descending, Limit: 2}
+		var all []int
+		for pages := 0; ; pages++ {
+			if pages > 5 {
+				t.Fatal("Pagination did not terminate")
+			}
+			page, err := query.Apply(queryTestVLANs())
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			all = append(all, ids(page.Items)...)
+			if page.NextCursor == "" {
+				break
+			}
+			query.Cursor = page.NextCursor
+		}
+
+		full, _ := (&VLANQuery{Sort: "status", Descending: descending}).Apply(queryTestVLANs())
+		if !equalIDs(all, ids(full.Items)) {
+			t.Errorf("desc=%v: paged %v, unpaged %v", descending, all, ids(full.Items))
+		}
+	}
+
+	// Cursors are bound to their sort order
+	first, _ := (&VLANQuery{Sort: "name", Limit: 1}).Apply(queryTestVLANs())
+	other := VLANQuery{Sort: "vlan_id", Cursor: first.NextCursor}
+	if _, err := other.Apply(queryTestVLANs()); err != ErrInvalidCursor {
+		t.Errorf("Expected ErrInvalidCursor, got %v", err)
+	}
+	if err := other.Validate(); err == nil {
+		t.Error("Expected validation error for foreign cursor")
+	}
+}
+
+func TestVLANQueryValidate(t *testing.T) {
+	valid := VLANQuery{Status: []string{"active"}, VlanIDMin: 100, VlanIDMax: 200, Sort: "name", Limit: 50}
+	if err := valid.Validate(); err != nil {
+		t.Errorf("Expected valid query, got %v", err)
+	}
+
+	invalid := VLANQuery{Status: []string{"gone"}, VlanIDMin: 300, VlanIDMax: 200, Sort: "color", Limit: MaxQueryLimit + 1, Cursor: "!"}
+	errs, ok := invalid.Validate().(ValidationErrors)
+	if !ok || len(errs) != 5 {
+		t.Errorf("Expected 5 validation errors, got %v", errs)
+	}
+}
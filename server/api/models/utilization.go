@@ -0,0 +1,47 @@
+package models
+
+import "time"
+
+// Address utilization of a single VLAN subnet
+type VLANUtilization struct {
+	ID                 int     `json:"id"`
+	VlanID             int     `json:"vlan_id"`
+	Name               string  `json:"name"`
+	Subnet             string  `json:"subnet"`
+	Status             string  `json:"status"`
+	Site               string  `json:"site,omitempty"`
+	Total              uint64  `json:"total"`
+	Usable             uint64  `json:"usable"`
+	Allocated          uint64  `json:"allocated"`
+	Reserved           uint64  `json:"reserved"`
+	Free               uint64  `json:"free"`
+	UtilizationPercent float64 `json:"utilization_percent"`
+	OverThreshold      bool    `json:"over_threshold"`
+	Error              string  `json:"error,omitempty"`
+}
+
+// Aggregated address counts for a group of VLANs
+type UtilizationTotals struct {
+	VLANs              int     `json:"vlans"`
+	Usable             uint64  `json:"usable"`
+	Allocated          uint64  `json:"allocated"`
+	Reserved           uint64  `json:"reserved"`
+	Free               uint64  `json:"free"`
+	UtilizationPercent float64 `json:"utilization_percent"`
+	OverThreshold      int     `json:"over_threshold"`
+}
+
+// Fleet-wide utilization summary
+type UtilizationSummary struct {
+	Total    UtilizationTotals            `json:"total"`
+	ByStatus map[string]UtilizationTotals `json:"by_status"`
+	BySite   map[string]UtilizationTotals `json:"by_site"`
+}
+
+// Structure for the utilization report response
+type UtilizationReport struct {
+	Threshold   float64            `json:"threshold"`
+	GeneratedAt time.Time          `json:"generated_at"`
+	VLANs       []VLANUtilization  `json:"vlans"`
+	Summary     UtilizationSummary `json:"summary"`
+}
@@ -1,9 +1,11 @@
 package models
 
 import (
-	"fmt"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
 	"net"
-	"regexp"
+	"net/netip"
 	"time"
 )
 
@@ -15,8 +17,19 @@ type VLANModel struct {
 	Subnet    string    `json:"subnet"`
 	Gateway   string    `json:"gateway"`
 	Status    string    `json:"status"`
+	Site      string    `json:"site,omitempty"`
+	Allocated int       `json:"allocated,omitempty"`
+	Reserved  int       `json:"reserved,omitempty"`
 	CreatedAt time.Time `json:"created_at"`
 	UpdatedAt time.Time `json:"updated_at"`
+	// Authenticated callers that created and last changed the VLAN
+	CreatedBy string `json:"created_by,omitempty"`
+	UpdatedBy string `json:"updated_by,omitempty"`
+
+	Labels       map[string]string      `json:"labels,omitempty"`
+	CustomFields map[string]interface{} `json:"custom_fields,omitempty"`
+
+	StatusHistory []StatusChange `json:"status_history,omitempty"`
 }
 
 // Structure for creating/updating a VLAN
@@ -26,12 +39,28 @@ type VLANInput struct {
 	Subnet  string `json:"subnet"`
 	Gateway string `json:"gateway"`
 	Status  string `json:"status"`
+	// Optional capacity planning fields
+	Site      string `json:"site,omitempty"`
+	Allocated int    `json:"allocated,omitempty"`
+	Reserved  int    `json:"reserved,omitempty"`
+	// Free-form labels and admin-defined custom fields
+	Labels       map[string]string      `json:"labels,omitempty"`
+	CustomFields map[string]interface{} `json:"custom_fields,omitempty"`
+	// Authenticated caller making the change, set by the handler
+	Actor string `json:"-"`
+	// Refuse a subnet overlapping another VLAN even when overlaps are allowed,
+	// set by the handler for subnets it allocated
+	RejectOverlap bool `json:"-"`
+	// Apply an update only while the stored VLAN still has this ETag, set by the handler
+	IfMatch string `json:"-"`
 }
 
 // Structure for an error response
 type ErrorResponse struct {
-	Error     string    `json:"error"`
-	Timestamp time.Time `json:"timestamp"`
+	Error     string       `json:"error"`
+	Details   []FieldError `json:"details,omitempty"`
+	RequestID string       `json:"request_id,omitempty"`
+	Timestamp time.Time    `json:"timestamp"`
 }
 
 // Structure for health check response
@@ -41,44 +70,102 @@ type HealthResponse struct {
 	Version   string    `json:"version"`
 }
 
+// Structure for a readiness or liveness probe response
+type ProbeResponse struct {
+	Status    string                 `json:"status"`
+	Timestamp time.Time              `json:"timestamp"`
+	Version   string                 `json:"version"`
+	Checks    map[string]CheckResult `json:"checks"`
+}
+
+// Outcome of one probe check
+type CheckResult struct {
+	Status     string  `json:"status"`
+	Detail     string  `json:"detail,omitempty"`
+	Error      string  `json:"error,omitempty"`
+	DurationMs float64 `json:"duration_ms"`
+}
+
 // Structure for JSON data structure
 type VLANData struct {
-	VLANs []VLANModel `json:"vlans"`
+	VLANs              []VLANModel         `json:"vlans"`
+	Prefixes           []PrefixModel       `json:"prefixes,omitempty"`
+	MaintenanceWindows []MaintenanceWindow `json:"maintenance_windows,omitempty"`
+	Webhooks           []Webhook           `json:"webhooks,omitempty"`
+}
+
+// Input that recreates the VLAN's editable fields
+func (v *VLANModel) Input() VLANInput {
+	return VLANInput{
+		Name:         v.Name,
+		VlanID:       v.VlanID,
+		Subnet:       v.Subnet,
+		Gateway:      v.Gateway,
+		Status:       v.Status,
+		Site:         v.Site,
+		Allocated:    v.Allocated,
+		Reserved:     v.Reserved,
+		Labels:       v.Labels,
+		CustomFields: v.CustomFields,
+	}
 }
 
-// Validate VLAN input
+// Strong entity tag of the VLAN's representation; it changes with every write
+func (v *VLANModel) ETag() string {
+	data, _ := json.Marshal(v)
+	sum := sha256.Sum256(data)
+	return `"` + hex.EncodeToString(sum[:16]) + `"`
+}
+
+// Validate VLAN input, collecting every violation
 func (v *VLANInput) Validate() error {
+	var errs ValidationErrors
+
 	// Validate name
-	if v.Name == "" || len(v.Name) > 255 {
-		return fmt.Errorf("name must be between 1 and 255 characters")
+	if v.Name == "" {
+		errs.Add("name", CodeRequired, "name must be between 1 and 255 characters")
+	} else if len(v.Name) > 255 {
+		errs.Add("name", CodeTooLong, "name must be between 1 and 255 characters")
 	}
 
 	// Validate VLAN ID
 	if v.VlanID < 1 || v.VlanID > 4094 {
-		return fmt.Errorf("vlan_id must be between 1 and 4094")
+		errs.Add("vlan_id", CodeOutOfRange, "vlan_id must be between 1 and 4094")
 	}
 
 	// Validate subnet CIDR
 	if !isValidCIDR(v.Subnet) {
-		return fmt.Errorf("invalid subnet format, must be in CIDR notation (e.g., 192.168.1.0/24)")
+		errs.Add("subnet", CodeInvalidFormat, "invalid subnet format, must be in CIDR notation (e.g., 192.168.1.0/24)")
 	}
 
 	// Validate gateway IP
 	if !isValidIP(v.Gateway) {
-		return fmt.Errorf("invalid gateway IP address format")
+		errs.Add("gateway", CodeInvalidFormat, "invalid gateway IP address format")
+	}
+
+	// Validate status against the configured lifecycle
+	if !CurrentLifecycle().IsValidState(v.Status) {
+		errs.Add("status", CodeInvalidValue, statusMessage())
 	}
 
-	// Validate status
-	validStatuses := map[string]bool{
-		"active":      true,
-		"inactive":    true,
-		"maintenance": true,
+	// Validate site
+	if len(v.Site) > 255 {
+		errs.Add("site", CodeTooLong, "site must be at most 255 characters")
 	}
-	if !validStatuses[v.Status] {
-		return fmt.Errorf("status must be one of: active, inactive, maintenance")
+
+	// Validate address counts
+	if v.Allocated < 0 {
+		errs.Add("allocated", CodeOutOfRange, "allocated must not be negative")
+	}
+	if v.Reserved < 0 {
+		errs.Add("reserved", CodeOutOfRange, "reserved must not be negative")
 	}
 
-	return nil
+	// Validate labels and custom fields
+	validateLabels(v.Labels, &errs)
+	validateCustomFields(v.CustomFields, &errs)
+
+	return errs.Err()
 }
 
 // Validate CIDR notation
@@ -87,13 +174,8 @@ func isValidCIDR(cidr string) bool {
 	return err == nil
 }
 
-// Validate IP address
+// Validate IP address, IPv4 or IPv6 without a zone
 func isValidIP(ip string) bool {
-	ipRegex := regexp.MustCompile(`^(\d{1,3}\.){3}\d{1,3}$`)
-	if !ipRegex.MatchString(ip) {
-		return false
-	}
-
-	parsedIP := net.ParseIP(ip)
-	return parsedIP != nil
+	addr, err := netip.ParseAddr(ip)
+	return err == nil && addr.Zone() == ""
 }
@@ -0,0 +1,95 @@
+package models
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"time"
+)
+
+// Event types a webhook can subscribe to
+var WebhookEventTypes = []string{"created", "updated", "deleted"}
+
+// Minimum length of a webhook signing secret
+const MinWebhookSecretLength = 16
+
+// Delivery states
+const (
+	DeliveryPending   = "pending"
+	DeliveryDelivered = "delivered"
+	DeliveryFailed    = "failed"
+	DeliveryDead      = "dead"
+)
+
+// Subscription that receives VLAN change events over HTTP
+type Webhook struct {
+	ID  int    `json:"id"`
+	URL string `json:"url"`
+	// Event types to deliver, every type when empty
+	Events []string `json:"events,omitempty"`
+	// HMAC-SHA256 signing key; only returned when the webhook is created
+	Secret    string    `json:"secret,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// Structure for creating a webhook
+type WebhookInput struct {
+	URL    string   `json:"url"`
+	Events []string `json:"events,omitempty"`
+	// Generated when left empty
+	Secret string `json:"secret,omitempty"`
+}
+
+// Delivery of one event to one webhook, with the outcome of its latest attempt
+type WebhookDelivery struct {
+	ID        int    `json:"id"`
+	WebhookID int    `json:"webhook_id"`
+	EventID   uint64 `json:"event_id"`
+	EventType string `json:"event_type"`
+	Status    string `json:"status"`
+	Attempts  int    `json:"attempts"`
+	// HTTP status of the latest attempt, zero when no response was received
+	ResponseStatus int       `json:"response_status,omitempty"`
+	Error          string    `json:"error,omitempty"`
+	CreatedAt      time.Time `json:"created_at"`
+	UpdatedAt      time.Time `json:"updated_at"`
+	// Signed body, kept on dead letters so they can be inspected and replayed
+	Payload json.RawMessage `json:"payload,omitempty"`
+}
+
+// Check whether the webhook wants events of a type
+func (w *Webhook) Wants(eventType string) bool {
+	return len(w.Events) == 0 || contains(w.Events, eventType)
+}
+
+// Copy of the webhook without its secret
+func (w Webhook) Redacted() Webhook {
+	w.Secret = ""
+	return w
+}
+
+// Validate webhook input, collecting every violation
+func (w *WebhookInput) Validate() error {
+	var errs ValidationErrors
+
+	if w.URL == "" {
+		errs.Add("url", CodeRequired, "url is required")
+	} else if parsed, err := url.Parse(w.URL); err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" {
+		errs.Add("url", CodeInvalidFormat, "url must be an absolute http or https URL")
+	} else if len(w.URL) > 2048 {
+		errs.Add("url", CodeTooLong, "url must be at most 2048 characters")
+	}
+
+	for _, eventType := range w.Events {
+		if !contains(WebhookEventTypes, eventType) {
+			errs.Add("events", CodeInvalidValue, "events must be among: created, updated, deleted")
+			break
+		}
+	}
+
+	if w.Secret != "" && len(w.Secret) < MinWebhookSecretLength {
+		errs.Add("secret", CodeInvalidValue, fmt.Sprintf("secret must be at least %d characters", MinWebhookSecretLength))
+	}
+
+	return errs.Err()
+}
@@ -0,0 +1,67 @@
+package models
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestWebhookInputValidate(t *testing.T) {
+	valid := WebhookInput{
+		URL:    "https://cmdb.example.com/hooks/vlan",
+		Events: []string{"created", "deleted"},
+		Secret: "0123456789abcdef",
+	}
+
+	tests := []struct {
+		name   string
+		modify func(*WebhookInput)
+		fields []string
+	}{
+		{"Valid webhook", func(w *WebhookInput) {}, nil},
+		{"All events and generated secret", func(w *WebhookInput) { w.Events = nil; w.Secret = "" }, nil},
+		{"Missing URL", func(w *WebhookInput) { w.URL = "" }, []string{"url"}},
+		{"Relative URL", func(w *WebhookInput) { w.URL = "/hooks/vlan" }, []string{"url"}},
+		{"Unsupported scheme", func(w *WebhookInput) { w.URL = "ftp://cmdb.example.com/hooks" }, []string{"url"}},
+		{"Unknown event and short secret", func(w *WebhookInput) { w.Events = []string{"renamed"}; w.Secret = "short" }, []string{"events", "secret"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			input := valid
+			tt.modify(&input)
+
+			err := input.Validate()
+			if len(tt.fields) == 0 {
+				if err != nil {
+					t.Errorf("Expected no error, got %v", err)
+				}
+				return
+			}
+
+			var errs ValidationErrors
+			if !errors.As(err, &errs) {
+				t.Fatalf("Expected ValidationErrors, got %v", err)
+			}
+			if len(errs) != len(tt.fields) {
+				t.Fatalf("Expected %d errors, got %d: %v", len(tt.fields), len(errs), errs)
+			}
+			for i, field := range tt.fields {
+				if errs[i].Field != field {
+					t.Errorf("Expected error %d on %s, got %s", i, field, errs[i].Field)
+				}
+			}
+		})
+	}
+}
+
+func TestWebhookWants(t *testing.T) {
+	all := Webhook{}
+	created := Webhook{Events: []string{"created"}}
+
+	if !all.Wants("deleted") {
+		t.Error("Expected a webhook without events to want every event")
+	}
+	if !created.Wants("created") || created.Wants("updated") {
+		t.Error("Expected a webhook to want only its events")
+	}
+}
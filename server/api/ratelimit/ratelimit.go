@@ -0,0 +1,118 @@
+// Package ratelimit limits how fast clients may send requests, with a token
+// bucket per client and request class.
+package ratelimit
+
+import (
+	"sync"
+	"time"
+)
+
+// Idle buckets are swept at most once per interval
+const sweepInterval = time.Minute
+
+// Allowance of a bucket: up to Requests at once, refilled evenly over Period
+type Limit struct {
+	Requests int
+	Period   time.Duration
+}
+
+// Outcome of a request against its bucket
+type Result struct {
+	Allowed   bool
+	Limit     int
+	Remaining int
+	// Time until the bucket is full again
+	Reset time.Duration
+	// Time until the next request would be allowed; zero when this one was
+	RetryAfter time.Duration
+}
+
+type bucket struct {
+	tokens  float64
+	updated time.Time
+	limit   Limit
+}
+
+// Token buckets by key. Buckets that have refilled completely are dropped, so
+// memory follows the number of recently active clients. The limiter is safe for
+// concurrent use.
+type Limiter struct {
+	mu        sync.Mutex
+	buckets   map[string]*bucket
+	now       func() time.Time
+	lastSweep time.Time
+}
+
+// Create an empty limiter
+func NewLimiter() *Limiter {
+	return &Limiter{buckets: make(map[string]*bucket), now: time.Now}
+}
+
+// Take a token from the key's bucket if one is left. A limit with no requests or
+// no period allows everything.
+func (l *Limiter) Allow(key string, limit Limit) Result {
+	return l.check(key, limit, true)
+}
+
+// Report whether the key's bucket has a token left, without taking it. Callers
+// that only count some requests, such as failed ones, peek before handling a
+// request and take the token with Allow afterwards.
+func (l *Limiter) Peek(key string, limit Limit) Result {
+	return l.check(key, limit, false)
+}
+
+// Refill the key's bucket and, when take is set, take a token from it
+func (l *Limiter) check(key string, limit Limit, take bool) Result {
+	if limit.Requests <= 0 || limit.Period <= 0 {
+		return Result{Allowed: true}
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.now()
+	l.sweep(now)
+
+	b, ok := l.buckets[key]
+	if !ok {
+		if !take {
+			return Result{Allowed: true, Limit: limit.Requests, Remaining: limit.Requests}
+		}
+		b = &bucket{tokens: float64(limit.Requests), updated: now, limit: limit}
+		l.buckets[key] = b
+	}
+
+	// Refill for the time since the last request; a changed limit applies from now on
+	b.limit = limit
+	rate := float64(limit.Requests) / float64(limit.Period)
+	b.tokens = min(float64(limit.Requests), b.tokens+float64(now.Sub(b.updated))*rate)
+	b.updated = now
+
+	result := Result{Limit: limit.Requests}
+	if b.tokens >= 1 {
+		if take {
+			b.tokens--
+		}
+		result.Allowed = true
+	} else {
+		result.RetryAfter = time.Duration((1 - b.tokens) / rate)
+	}
+	result.Remaining = int(b.tokens)
+	result.Reset = time.Duration((float64(limit.Requests) - b.tokens) / rate)
+	return result
+}
+
+// Drop buckets that have refilled completely. Callers hold the lock.
+func (l *Limiter) sweep(now time.Time) {
+	if now.Sub(l.lastSweep) < sweepInterval {
+		return
+	}
+	l.lastSweep = now
+
+	for key, b := range l.buckets {
+		rate := float64(b.limit.Requests) / float64(b.limit.Period)
+		if b.tokens+float64(now.Sub(b.updated))*rate >= float64(b.limit.Requests) {
+			delete(l.buckets, key)
+		}
+	}
+}
@@ -0,0 +1,100 @@
+package ratelimit
+
+import (
+	"testing"
+	"time"
+)
+
+// Limiter with a clock the test controls
+func newTestLimiter() (*Limiter, *time.Time) {
+	limiter := NewLimiter()
+	now := time.Date(2024, 7, 15, 10, 0, 0, 0, time.UTC)
+	limiter.now = func() time.Time { return now }
+	return limiter, &now
+}
+
+func TestAllow(t *testing.T) {
+	limiter, now := newTestLimiter()
+	limit := Limit{Requests: 3, Period: time.Minute}
+
+	// A full bucket allows a burst of the whole limit
+	for i := 2; i >= 0; i-- {
+		result := limiter.Allow("client", limit)
+		if !result.Allowed || result.Remaining != i || result.Limit != 3 {
+			t.Fatalf("Unexpected result %+v", result)
+		}
+	}
+
+	result := limiter.Allow("client", limit)
+	if result.Allowed || result.RetryAfter != 20*time.Second || result.Reset != time.Minute {
+		t.Errorf("Expected a denial with a retry after 20s, got %+v", result)
+	}
+
+	// Other keys have their own buckets
+	if !limiter.Allow("other", limit).Allowed {
+		t.Error("Expected another key to be allowed")
+	}
+
+	// Tokens come back evenly over the period
+	*now = now.Add(20 * time.Second)
+	if result := limiter.Allow("client", limit); !result.Allowed || result.Remaining != 0 {
+		t.Errorf("Expected one token after 20s, got %+v", result)
+	}
+	*now = now.Add(10 * time.Second)
+	if result := limiter.Allow("client", limit); result.Allowed || result.RetryAfter != 10*time.Second {
+		t.Errorf("Expected a retry after 10s, got %+v", result)
+	}
+}
+
+func TestPeek(t *testing.T) {
+	limiter, now := newTestLimiter()
+	limit := Limit{Requests: 2, Period: time.Minute}
+
+	if result := limiter.Peek("client", limit); !result.Allowed || result.Remaining != 2 {
+		t.Errorf("Expected a full bucket, got %+v", result)
+	}
+	if len(limiter.buckets) != 0 {
+		t.Errorf("Expected peeking not to create a bucket, got %d", len(limiter.buckets))
+	}
+
+	// Peeking takes no tokens
+	limiter.Allow("client", limit)
+	limiter.Peek("client", limit)
+	limiter.Allow("client", limit)
+	if result := limiter.Peek("client", limit); result.Allowed || result.RetryAfter != 30*time.Second {
+		t.Errorf("Expected an empty bucket refilling in 30s, got %+v", result)
+	}
+
+	*now = now.Add(30 * time.Second)
+	if result := limiter.Peek("client", limit); !result.Allowed || result.Remaining != 1 {
+		t.Errorf("Expected one token after 30s, got %+v", result)
+	}
+}
+
+func TestUnlimited(t *testing.T) {
+	limiter, _ := newTestLimiter()
+	for i := 0; i < 100; i++ {
+		if !limiter.Allow("client", Limit{}).Allowed {
+			t.Fatal("Expected a zero limit to allow every request")
+		}
+	}
+	if len(limiter.buckets) != 0 {
+		t.Errorf("Expected no buckets for unlimited requests, got %d", len(limiter.buckets))
+	}
+}
+
+func TestSweep(t *testing.T) {
+	limiter, now := newTestLimiter()
+	limiter.Allow("idle", Limit{Requests: 10, Period: time.Minute})
+	limiter.Allow("busy", Limit{Requests: 10, Period: time.Hour})
+
+	*now = now.Add(2 * time.Minute)
+	limiter.Allow("new", Limit{Requests: 10, Period: time.Minute})
+
+	if _, ok := limiter.buckets["idle"]; ok {
+		t.Error("Expected the refilled bucket to be swept")
+	}
+	if _, ok := limiter.buckets["busy"]; !ok {
+		t.Error("Expected the refilling bucket to be kept")
+	}
+}
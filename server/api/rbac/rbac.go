@@ -0,0 +1,147 @@
+// Package rbac decides which actions a role may perform. Roles and their
+// permissions are defined in a JSON policy file; callers are bound to a role
+// through their API key.
+package rbac
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+
+	"smit/server/api/models"
+)
+
+// Actions checked by the handlers
+const (
+	ActionVLANRead         = "vlan:read"
+	ActionVLANCreate       = "vlan:create"
+	ActionVLANUpdate       = "vlan:update"
+	ActionVLANDelete       = "vlan:delete"
+	ActionPrefixRead       = "prefix:read"
+	ActionPrefixWrite      = "prefix:write"
+	ActionPrefixAllocate   = "prefix:allocate"
+	ActionMaintenanceRead  = "maintenance:read"
+	ActionMaintenanceWrite = "maintenance:write"
+	ActionWebhookRead      = "webhook:read"
+	ActionWebhookWrite     = "webhook:write"
+	ActionAPIKeyManage     = "api-key:manage"
+	ActionAuditRead        = "audit:read"
+	ActionMetricsRead      = "metrics:read"
+)
+
+// Grants every action
+const AllActions = "*"
+
+// Every action a policy can grant
+var Actions = []string{
+	ActionVLANRead, ActionVLANCreate, ActionVLANUpdate, ActionVLANDelete,
+	ActionPrefixRead, ActionPrefixWrite, ActionPrefixAllocate,
+	ActionMaintenanceRead, ActionMaintenanceWrite,
+	ActionWebhookRead, ActionWebhookWrite,
+	ActionAPIKeyManage, ActionAuditRead, ActionMetricsRead,
+}
+
+// Permissions of a role
+type Role struct {
+	Actions []string `json:"actions"`
+	// VLAN fields the role may change with vlan:update; every field when empty
+	Fields []string `json:"fields,omitempty"`
+}
+
+// Roles by name
+type Policy struct {
+	Roles map[string]Role `json:"roles"`
+}
+
+// Load and validate a policy file
+func LoadPolicy(path string) (*Policy, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read access policy file: %w", err)
+	}
+
+	var policy Policy
+	if err := json.Unmarshal(data, &policy); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal access policy: %w", err)
+	}
+	if err := policy.Validate(); err != nil {
+		return nil, err
+	}
+	return &policy, nil
+}
+
+// Check that the policy defines roles and only names known actions and fields
+func (p *Policy) Validate() error {
+	if len(p.Roles) == 0 {
+		return fmt.Errorf("access policy must define at least one role")
+	}
+
+	for _, name := range p.RoleNames() {
+		role := p.Roles[name]
+		for _, action := range role.Actions {
+			if action != AllActions && !contains(Actions, action) {
+				return fmt.Errorf("role %q: unknown action %q", name, action)
+			}
+		}
+		for _, field := range role.Fields {
+			if !contains(models.VLANFields, field) {
+				return fmt.Errorf("role %q: unknown VLAN field %q", name, field)
+			}
+		}
+	}
+	return nil
+}
+
+// Names of the defined roles, sorted
+func (p *Policy) RoleNames() []string {
+	names := make([]string, 0, len(p.Roles))
+	for name := range p.Roles {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Check whether the policy defines a role
+func (p *Policy) HasRole(role string) bool {
+	_, ok := p.Roles[role]
+	return ok
+}
+
+// Check whether a role may perform an action; unknown roles may do nothing
+func (p *Policy) Allows(role, action string) bool {
+	r, ok := p.Roles[role]
+	if !ok {
+		return false
+	}
+	return contains(r.Actions, AllActions) || contains(r.Actions, action)
+}
+
+// Fields among the given ones that the role may not change
+func (p *Policy) DeniedFields(role string, fields []string) []string {
+	r, ok := p.Roles[role]
+	if !ok {
+		return fields
+	}
+	if len(r.Fields) == 0 {
+		return nil
+	}
+
+	var denied []string
+	for _, field := range fields {
+		if !contains(r.Fields, field) {
+			denied = append(denied, field)
+		}
+	}
+	return denied
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,94 @@
+package rbac
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+const testPolicy = `{
+  "roles": {
+    "viewer": {"actions": ["vlan:read", "prefix:read"]},
+    "operator": {"actions": ["vlan:read", "vlan:update"], "fields": ["status", "labels"]},
+    "admin": {"actions": ["*"]}
+  }
+}`
+
+func writePolicy(t *testing.T, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "policy.json")
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatalf("Failed to write policy: %v", err)
+	}
+	return path
+}
+
+func TestLoadPolicy(t *testing.T) {
+	policy, err := LoadPolicy(writePolicy(t, testPolicy))
+	if err != nil {
+		t.Fatalf("Failed to load policy: %v", err)
+	}
+
+	if names := policy.RoleNames(); !reflect.DeepEqual(names, []string{"admin", "operator", "viewer"}) {
+		t.Errorf("Unexpected roles %v", names)
+	}
+
+	tests := []struct {
+		role    string
+		action  string
+		allowed bool
+	}{
+		{"viewer", ActionVLANRead, true},
+		{"viewer", ActionVLANUpdate, false},
+		{"operator", ActionVLANUpdate, true},
+		{"operator", ActionVLANDelete, false},
+		{"admin", ActionVLANDelete, true},
+		{"admin", ActionAuditRead, true},
+		{"unknown", ActionVLANRead, false},
+		{"", ActionVLANRead, false},
+	}
+	for _, tt := range tests {
+		if allowed := policy.Allows(tt.role, tt.action); allowed != tt.allowed {
+			t.Errorf("Allows(%q, %q) = %v, expected %v", tt.role, tt.action, allowed, tt.allowed)
+		}
+	}
+}
+
+func TestDeniedFields(t *testing.T) {
+	policy, _ := LoadPolicy(writePolicy(t, testPolicy))
+
+	if denied := policy.DeniedFields("operator", []string{"status", "subnet", "labels", "gateway"}); !reflect.DeepEqual(denied, []string{"subnet", "gateway"}) {
+		t.Errorf("Unexpected denied fields %v", denied)
+	}
+	if denied := policy.DeniedFields("admin", []string{"subnet"}); denied != nil {
+		t.Errorf("Expected admin to change every field, got %v denied", denied)
+	}
+	if denied := policy.DeniedFields("unknown", []string{"name"}); !reflect.DeepEqual(denied, []string{"name"}) {
+		t.Errorf("Expected an unknown role to change nothing, got %v denied", denied)
+	}
+}
+
+func TestLoadPolicyErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+	}{
+		{"Invalid JSON", `{"roles": `},
+		{"No roles", `{"roles": {}}`},
+		{"Unknown action", `{"roles": {"viewer": {"actions": ["vlan:destroy"]}}}`},
+		{"Unknown field", `{"roles": {"operator": {"actions": ["vlan:update"], "fields": ["colour"]}}}`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := LoadPolicy(writePolicy(t, tt.content)); err == nil {
+				t.Error("Expected an error")
+			}
+		})
+	}
+
+	if _, err := LoadPolicy(filepath.Join(t.TempDir(), "missing.json")); err == nil {
+		t.Error("Expected an error for a missing file")
+	}
+}
@@ -0,0 +1,62 @@
+// Package recorder wraps a response writer to note what a handler sends, for
+// middleware that logs, measures, traces or replays responses.
+package recorder
+
+import (
+	"bytes"
+	"net/http"
+)
+
+// Records the status and size of a response, passing flushes through for streams.
+// A non-nil Body also receives a copy of everything written.
+type Recorder struct {
+	http.ResponseWriter
+	// First status written, zero until the handler writes
+	Status int
+	// Size of the body written
+	Bytes int64
+	// Copy of the body, kept only when set
+	Body *bytes.Buffer
+}
+
+// Create a recorder passing writes through to w
+func New(w http.ResponseWriter) *Recorder {
+	return &Recorder{ResponseWriter: w}
+}
+
+// Status the client received; a handler that writes nothing sends 200
+func (rec *Recorder) StatusCode() int {
+	if rec.Status == 0 {
+		return http.StatusOK
+	}
+	return rec.Status
+}
+
+func (rec *Recorder) WriteHeader(status int) {
+	if rec.Status == 0 {
+		rec.Status = status
+	}
+	rec.ResponseWriter.WriteHeader(status)
+}
+
+func (rec *Recorder) Write(b []byte) (int, error) {
+	if rec.Status == 0 {
+		rec.Status = http.StatusOK
+	}
+	n, err := rec.ResponseWriter.Write(b)
+	rec.Bytes += int64(n)
+	if rec.Body != nil {
+		rec.Body.Write(b[:n])
+	}
+	return n, err
+}
+
+func (rec *Recorder) Flush() {
+	if flusher, ok := rec.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+func (rec *Recorder) Unwrap() http.ResponseWriter {
+	return rec.ResponseWriter
+}
@@ -0,0 +1,44 @@
+package recorder
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestRecorder(t *testing.T) {
+	w := httptest.NewRecorder()
+	rec := New(w)
+	if rec.StatusCode() != http.StatusOK {
+		t.Errorf("Expected status %d before any write, got %d", http.StatusOK, rec.StatusCode())
+	}
+
+	rec.WriteHeader(http.StatusCreated)
+	rec.WriteHeader(http.StatusInternalServerError)
+	rec.Write([]byte("hello"))
+	if rec.Status != http.StatusCreated || rec.Bytes != 5 {
+		t.Errorf("Expected the first status and 5 bytes, got %d and %d", rec.Status, rec.Bytes)
+	}
+
+	rec.Flush()
+	if !w.Flushed {
+		t.Error("Expected the flush to pass through")
+	}
+	if http.NewResponseController(rec).Flush() != nil {
+		t.Error("Expected the response controller to reach the writer")
+	}
+}
+
+func TestRecorderBody(t *testing.T) {
+	w := httptest.NewRecorder()
+	rec := &Recorder{ResponseWriter: w, Body: &bytes.Buffer{}}
+	rec.Write([]byte(`{"id": 1}`))
+
+	if rec.Status != http.StatusOK {
+		t.Errorf("Expected an implicit %d, got %d", http.StatusOK, rec.Status)
+	}
+	if rec.Body.String() != `{"id": 1}` || w.Body.String() != `{"id": 1}` {
+		t.Errorf("Expected the body in both writers, got %q and %q", rec.Body.String(), w.Body.String())
+	}
+}
@@ -0,0 +1,125 @@
+// Package router keeps the table of API routes on top of http.ServeMux. Each
+// route is a path template with the methods it serves, so middleware such as
+// CORS can tell which methods a path allows and whether it exists at all, and
+// metrics can label requests by route.
+package router
+
+import (
+	"net/http"
+	"strings"
+)
+
+// Path template and the methods it serves
+type route struct {
+	template string
+	segments []string
+	methods  []string
+}
+
+// Router dispatching to handlers by path prefix and recording each route's methods.
+// Routes are registered at startup; the router is safe for concurrent serving afterwards.
+type Router struct {
+	mux        *http.ServeMux
+	routes     []route
+	registered map[string]bool
+}
+
+// Create an empty router
+func New() *Router {
+	return &Router{mux: http.NewServeMux(), registered: make(map[string]bool)}
+}
+
+// Register a handler for a path template such as /api/v1/vlans/{id}/transitions.
+// A {name} segment matches any single path segment. The handler receives every
+// path under the template's literal prefix and dispatches on its own; the
+// methods are what the route serves. Listing HEAD serves HEAD requests as GET.
+func (rt *Router) Handle(template string, handler http.HandlerFunc, methods ...string) {
+	rt.routes = append(rt.routes, route{template: template, segments: splitPath(template), methods: methods})
+
+	// Templates with parameters are served by the subtree of their literal prefix
+	pattern := template
+	if i := strings.Index(template, "{"); i >= 0 {
+		pattern = template[:i]
+	}
+	if !rt.registered[pattern] {
+		rt.registered[pattern] = true
+		rt.mux.HandleFunc(pattern, handler)
+	}
+}
+
+// Methods served by the route matching the path; ok is false when no route matches
+func (rt *Router) Methods(path string) (methods []string, ok bool) {
+	if r := rt.match(path); r != nil {
+		return r.methods, true
+	}
+	return nil, false
+}
+
+// Template of the route matching the path; ok is false when no route matches
+func (rt *Router) Route(path string) (template string, ok bool) {
+	if r := rt.match(path); r != nil {
+		return r.template, true
+	}
+	return "", false
+}
+
+// Serve a request, answering HEAD as GET on routes that list HEAD
+func (rt *Router) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method == http.MethodHead {
+		if route := rt.match(r.URL.Path); route != nil && contains(route.methods, http.MethodHead) {
+			// The server drops the body of a HEAD response by itself
+			get := r.WithContext(r.Context())
+			get.Method = http.MethodGet
+			r = get
+		}
+	}
+	rt.mux.ServeHTTP(w, r)
+}
+
+// Route matching the path, preferring literal segments over parameters
+func (rt *Router) match(path string) *route {
+	segments := splitPath(path)
+
+	var best *route
+	bestLiterals := -1
+	for i := range rt.routes {
+		r := &rt.routes[i]
+		if len(r.segments) != len(segments) {
+			continue
+		}
+
+		literals := 0
+		matched := true
+		for j, segment := range r.segments {
+			if strings.HasPrefix(segment, "{") && strings.HasSuffix(segment, "}") {
+				if segments[j] == "" {
+					matched = false
+					break
+				}
+				continue
+			}
+			if segment != segments[j] {
+				matched = false
+				break
+			}
+			literals++
+		}
+		if matched && literals > bestLiterals {
+			best, bestLiterals = r, literals
+		}
+	}
+	return best
+}
+
+func splitPath(path string) []string {
+	return strings.Split(strings.Trim(path, "/"), "/")
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,108 @@
+package router
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+)
+
+func newTestRouter() (*Router, *[]string) {
+	var served []string
+	record := func(name string) http.HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) {
+			served = append(served, name+" "+r.Method)
+			w.Write([]byte("body"))
+		}
+	}
+
+	rt := New()
+	rt.Handle("/api/v1/vlans", record("vlans"), "GET", "HEAD", "POST")
+	rt.Handle("/api/v1/vlans/{id}", record("vlans"), "GET", "HEAD", "PUT", "DELETE")
+	rt.Handle("/api/v1/vlans/by-tag/{vlan_id}", record("vlans"), "GET")
+	rt.Handle("/api/v1/vlans/{id}/transitions", record("vlans"), "GET", "POST")
+	rt.Handle("/api/v1/events", record("events"), "GET")
+	return rt, &served
+}
+
+func TestMethods(t *testing.T) {
+	rt, _ := newTestRouter()
+
+	tests := []struct {
+		path     string
+		expected []string
+		ok       bool
+	}{
+		{"/api/v1/vlans", []string{"GET", "HEAD", "POST"}, true},
+		{"/api/v1/vlans/", []string{"GET", "HEAD", "POST"}, true},
+		{"/api/v1/vlans/7", []string{"GET", "HEAD", "PUT", "DELETE"}, true},
+		{"/api/v1/vlans/7/transitions", []string{"GET", "POST"}, true},
+		{"/api/v1/vlans/by-tag/100", []string{"GET"}, true},
+		{"/api/v1/vlans/7/unknown", nil, false},
+		{"/api/v1/unknown", nil, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			methods, ok := rt.Methods(tt.path)
+			if ok != tt.ok || !reflect.DeepEqual(methods, tt.expected) {
+				t.Errorf("Expected %v, %v, got %v, %v", tt.expected, tt.ok, methods, ok)
+			}
+		})
+	}
+}
+
+func TestRoute(t *testing.T) {
+	rt, _ := newTestRouter()
+
+	for path, expected := range map[string]string{
+		"/api/v1/vlans":                "/api/v1/vlans",
+		"/api/v1/vlans/7":              "/api/v1/vlans/{id}",
+		"/api/v1/vlans/by-tag/100":     "/api/v1/vlans/by-tag/{vlan_id}",
+		"/api/v1/vlans/7/transitions/": "/api/v1/vlans/{id}/transitions",
+		"/api/v1/unknown":              "",
+	} {
+		if template, ok := rt.Route(path); template != expected || ok != (expected != "") {
+			t.Errorf("Route(%q) = %q, %v, expected %q", path, template, ok, expected)
+		}
+	}
+}
+
+func TestServeHTTP(t *testing.T) {
+	rt, served := newTestRouter()
+	server := httptest.NewServer(rt)
+	defer server.Close()
+
+	for _, tt := range []struct {
+		method string
+		path   string
+	}{
+		{"GET", "/api/v1/vlans"},
+		{"POST", "/api/v1/vlans/7/transitions"},
+		{"HEAD", "/api/v1/vlans/7"},
+		{"HEAD", "/api/v1/events"},
+	} {
+		req, _ := http.NewRequest(tt.method, server.URL+tt.path, nil)
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("Failed to make request: %v", err)
+		}
+		resp.Body.Close()
+	}
+
+	// HEAD is served as GET only where the route lists it
+	expected := []string{"vlans GET", "vlans POST", "vlans GET", "events HEAD"}
+	if !reflect.DeepEqual(*served, expected) {
+		t.Errorf("Expected %v, got %v", expected, *served)
+	}
+
+	resp, err := http.Head(server.URL + "/api/v1/vlans")
+	if err != nil {
+		t.Fatalf("Failed to make request: %v", err)
+	}
+	defer resp.Body.Close()
+	if body, _ := io.ReadAll(resp.Body); len(body) != 0 {
+		t.Errorf("Expected no body in a HEAD response, got %q", body)
+	}
+}
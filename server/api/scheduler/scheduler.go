@@ -0,0 +1,256 @@
+package scheduler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"smit/server/api/models"
+	"smit/server/api/storage"
+)
+
+// Default interval between maintenance reconciliations
+const DefaultInterval = 30 * time.Second
+
+// Scheduler moves VLANs into maintenance while their windows are in progress
+// and restores their previous status afterwards.
+// All state lives in storage, so a restarted scheduler picks up where it left off.
+type Scheduler struct {
+	vlans    storage.Storage
+	windows  storage.MaintenanceStorage
+	interval time.Duration
+}
+
+// Create a new scheduler
+func New(vlans storage.Storage, windows storage.MaintenanceStorage, interval time.Duration) *Scheduler {
+	if interval <= 0 {
+		interval = DefaultInterval
+	}
+
+	return &Scheduler{
+		vlans:    vlans,
+		windows:  windows,
+		interval: interval,
+	}
+}
+
+// Reconcile immediately and then on every interval until the context is cancelled
+func (s *Scheduler) Run(ctx context.Context) {
+	ticker := time.NewTicker(s.interval)
+	defer ticker.Stop()
+
+	for {
+		if err := s.Reconcile(time.Now()); err != nil {
+			slog.Error("Maintenance scheduler failed", "error", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Bring VLAN statuses in line with the windows in progress at the given time
+func (s *Scheduler) Reconcile(now time.Time) error {
+	windows, err := s.windows.GetAllMaintenanceWindows()
+	if err != nil {
+		return fmt.Errorf("failed to load maintenance windows: %w", err)
+	}
+
+	// Find windows in progress and the VLANs they cover
+	active := make(map[int]*models.MaintenanceOccurrence)
+	covered := make(map[int]int)
+	for _, window := range windows {
+		occurrence, err := window.ActiveOccurrence(now)
+		if err != nil {
+			slog.Error("Maintenance scheduler failed", "window", window.ID, "error", err)
+			continue
+		}
+		if occurrence == nil {
+			continue
+		}
+		active[window.ID] = occurrence
+		for _, vlanID := range window.VLANRecordIDs {
+			if _, ok := covered[vlanID]; !ok {
+				covered[vlanID] = window.ID
+			}
+		}
+	}
+
+	// Restore VLANs of finished windows first so overlapping windows can take them over
+	handover := make(map[int]map[int]string)
+	for _, window := range windows {
+		if active[window.ID] != nil || len(window.Applied) == 0 {
+			continue
+		}
+
+		for vlanID, previous := range window.Applied {
+			if other, ok := covered[vlanID]; ok {
+				// Another window is still in progress, it restores the VLAN when it ends
+				if handover[other] == nil {
+					handover[other] = make(map[int]string)
+				}
+				handover[other][vlanID] = previous
+				continue
+			}
+			s.restore(window, vlanID, previous)
+		}
+
+		// A window deleted in the meantime has nothing left to clear
+		if err := s.windows.SetMaintenanceApplied(window.ID, nil); err != nil && !errors.Is(err, storage.ErrMaintenanceWindowNotFound) {
+			return fmt.Errorf("failed to save maintenance window %d: %w", window.ID, err)
+		}
+	}
+
+	// Move VLANs of windows in progress to maintenance
+	for _, window := range windows {
+		if active[window.ID] == nil {
+			continue
+		}
+
+		applied := make(map[int]string, len(window.VLANRecordIDs))
+		for vlanID, previous := range window.Applied {
+			applied[vlanID] = previous
+		}
+		for vlanID, previous := range handover[window.ID] {
+			applied[vlanID] = previous
+		}
+
+		var moving []int
+		for _, vlanID := range window.VLANRecordIDs {
+			if _, ok := applied[vlanID]; ok || covered[vlanID] != window.ID {
+				continue
+			}
+			if previous, ok := s.plan(window, vlanID); ok {
+				applied[vlanID] = previous
+				moving = append(moving, vlanID)
+			}
+		}
+		if sameApplied(applied, window.Applied) {
+			continue
+		}
+
+		// Record the VLANs before moving them, so neither a crash nor a delete of the
+		// window can leave them in maintenance with nothing to restore them
+		if err := s.windows.SetMaintenanceApplied(window.ID, applied); err != nil {
+			if errors.Is(err, storage.ErrMaintenanceWindowNotFound) {
+				// Deleted before any of its VLANs were moved
+				continue
+			}
+			return fmt.Errorf("failed to save maintenance window %d: %w", window.ID, err)
+		}
+
+		recorded := make(map[int]string, len(applied))
+		for vlanID, previous := range applied {
+			recorded[vlanID] = previous
+		}
+		for _, vlanID := range moving {
+			if previous, ok := s.apply(window, vlanID); ok {
+				applied[vlanID] = previous
+			} else {
+				delete(applied, vlanID)
+			}
+		}
+
+		if !sameApplied(applied, recorded) {
+			if err := s.windows.SetMaintenanceApplied(window.ID, applied); err != nil {
+				if errors.Is(err, storage.ErrMaintenanceWindowNotFound) {
+					// Nobody is left to restore the VLANs when the window ends, so do it now
+					for vlanID, previous := range applied {
+						s.restore(window, vlanID, previous)
+					}
+					continue
+				}
+				return fmt.Errorf("failed to save maintenance window %d: %w", window.ID, err)
+			}
+		}
+	}
+
+	return nil
+}
+
+// Check whether a VLAN can be moved to maintenance, returning its current status
+func (s *Scheduler) plan(window models.MaintenanceWindow, vlanID int) (string, bool) {
+	vlan, err := s.vlans.GetByID(vlanID)
+	if err != nil {
+		if !errors.Is(err, storage.ErrVLANNotFound) {
+			slog.Error("Maintenance scheduler failed to load VLAN", "window", window.ID, "vlan", vlanID, "error", err)
+		}
+		return "", false
+	}
+
+	// A VLAN already in maintenance was put there by someone else and is left alone
+	if vlan.Status == models.MaintenanceStatus {
+		return "", false
+	}
+
+	if !models.CurrentLifecycle().CanTransition(vlan.Status, models.MaintenanceStatus) {
+		slog.Warn("Maintenance scheduler cannot move VLAN", "window", window.ID, "vlan", vlanID, "from", vlan.Status, "to", models.MaintenanceStatus)
+		return "", false
+	}
+
+	return vlan.Status, true
+}
+
+// Move a VLAN to maintenance, returning the status it had if the scheduler changed it
+func (s *Scheduler) apply(window models.MaintenanceWindow, vlanID int) (string, bool) {
+	change := models.StatusChange{
+		To:     models.MaintenanceStatus,
+		Reason: fmt.Sprintf("Maintenance window %d (%s) started", window.ID, window.Name),
+	}
+	vlan, err := s.vlans.UpdateStatus(vlanID, change)
+	if err != nil {
+		if !errors.Is(err, storage.ErrVLANNotFound) {
+			slog.Error("Maintenance scheduler failed to update VLAN", "window", window.ID, "vlan", vlanID, "error", err)
+		}
+		return "", false
+	}
+
+	// The status may have changed since it was recorded
+	return vlan.StatusHistory[len(vlan.StatusHistory)-1].From, true
+}
+
+// Restore the status a VLAN had before the window, unless it was changed manually since
+func (s *Scheduler) restore(window models.MaintenanceWindow, vlanID int, previous string) {
+	vlan, err := s.vlans.GetByID(vlanID)
+	if err != nil {
+		if !errors.Is(err, storage.ErrVLANNotFound) {
+			slog.Error("Maintenance scheduler failed to load VLAN", "window", window.ID, "vlan", vlanID, "error", err)
+		}
+		return
+	}
+
+	if vlan.Status != models.MaintenanceStatus {
+		return
+	}
+
+	if !models.CurrentLifecycle().CanTransition(vlan.Status, previous) {
+		slog.Warn("Maintenance scheduler cannot move VLAN back", "window", window.ID, "vlan", vlanID, "from", vlan.Status, "to", previous)
+		return
+	}
+
+	change := models.StatusChange{
+		To:     previous,
+		Reason: fmt.Sprintf("Maintenance window %d (%s) ended", window.ID, window.Name),
+	}
+	if _, err := s.vlans.UpdateStatus(vlanID, change); err != nil {
+		slog.Error("Maintenance scheduler failed to restore VLAN", "window", window.ID, "vlan", vlanID, "error", err)
+	}
+}
+
+// Compare two applied maps
+func sameApplied(a, b map[int]string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for k, v := range a {
+		if bv, ok := b[k]; !ok || bv != v {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,234 @@
+package scheduler
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"smit/server/api/models"
+	"smit/server/api/storage"
+)
+
+func newTestStorage(t *testing.T) *storage.JSONStorage {
+	store, err := storage.NewJSONStorage(filepath.Join(t.TempDir(), "scheduler_test.json"))
+	if err != nil {
+		t.Fatalf("Failed to create storage: %v", err)
+	}
+	return store
+}
+
+func createVLAN(t *testing.T, store *storage.JSONStorage, vlanID int, status string) *models.VLANModel {
+	vlan, err := store.Create(&models.VLANInput{
+		Name:    "VLAN",
+		VlanID:  vlanID,
+		Subnet:  "10.0.0.0/24",
+		Gateway: "10.0.0.1",
+		Status:  status,
+	})
+	if err != nil {
+		t.Fatalf("Failed to create VLAN: %v", err)
+	}
+	return vlan
+}
+
+func createWindow(t *testing.T, store *storage.JSONStorage, start, end string, vlanIDs ...int) *models.MaintenanceWindow {
+	window, err := store.CreateMaintenanceWindow(&models.MaintenanceWindowInput{
+		Name:          "Upgrade",
+		VLANRecordIDs: vlanIDs,
+		Start:         start,
+		End:           end,
+		Timezone:      "UTC",
+	})
+	if err != nil {
+		t.Fatalf("Failed to create maintenance window: %v", err)
+	}
+	return window
+}
+
+func statusOf(t *testing.T, store *storage.JSONStorage, id int) string {
+	vlan, err := store.GetByID(id)
+	if err != nil {
+		t.Fatalf("Failed to get VLAN: %v", err)
+	}
+	return vlan.Status
+}
+
+func TestReconcile(t *testing.T) {
+	store := newTestStorage(t)
+	active := createVLAN(t, store, 100, "active")
+	inactive := createVLAN(t, store, 200, "inactive")
+	planned := createVLAN(t, store, 300, "planned")
+	window := createWindow(t, store, "2025-05-01T10:00", "2025-05-01T12:00", active.ID, inactive.ID, planned.ID)
+
+	s := New(store, store, time.Minute)
+
+	// Nothing happens before the window
+	if err := s.Reconcile(time.Date(2025, 5, 1, 9, 0, 0, 0, time.UTC)); err != nil {
+		t.Fatalf("Reconcile failed: %v", err)
+	}
+	if status := statusOf(t, store, active.ID); status != "active" {
+		t.Errorf("Expected active before window, got %s", status)
+	}
+
+	// VLANs move to maintenance when the window starts, except where the lifecycle forbids it
+	if err := s.Reconcile(time.Date(2025, 5, 1, 10, 30, 0, 0, time.UTC)); err != nil {
+		t.Fatalf("Reconcile failed: %v", err)
+	}
+	if status := statusOf(t, store, active.ID); status != "maintenance" {
+		t.Errorf("Expected maintenance during window, got %s", status)
+	}
+	if status := statusOf(t, store, inactive.ID); status != "maintenance" {
+		t.Errorf("Expected maintenance during window, got %s", status)
+	}
+	if status := statusOf(t, store, planned.ID); status != "planned" {
+		t.Errorf("Expected planned VLAN to be left alone, got %s", status)
+	}
+
+	// Applied statuses are persisted, so a new scheduler picks them up
+	stored, _ := store.GetMaintenanceWindowByID(window.ID)
+	if len(stored.Applied) != 2 || stored.Applied[active.ID] != "active" || stored.Applied[inactive.ID] != "inactive" {
+		t.Errorf("Unexpected applied statuses: %v", stored.Applied)
+	}
+
+	// Previous statuses are restored when the window ends
+	if err := New(store, store, time.Minute).Reconcile(time.Date(2025, 5, 1, 12, 0, 0, 0, time.UTC)); err != nil {
+		t.Fatalf("Reconcile failed: %v", err)
+	}
+	if status := statusOf(t, store, active.ID); status != "active" {
+		t.Errorf("Expected active after window, got %s", status)
+	}
+	if status := statusOf(t, store, inactive.ID); status != "inactive" {
+		t.Errorf("Expected inactive after window, got %s", status)
+	}
+	if stored, _ := store.GetMaintenanceWindowByID(window.ID); stored.Applied != nil {
+		t.Errorf("Expected applied statuses to be cleared, got %v", stored.Applied)
+	}
+
+	// Both changes are recorded in the status history
+	vlan, _ := store.GetByID(active.ID)
+	if len(vlan.StatusHistory) != 2 {
+		t.Fatalf("Expected 2 status changes, got %d", len(vlan.StatusHistory))
+	}
+	if vlan.StatusHistory[0].Reason != "Maintenance window 1 (Upgrade) started" {
+		t.Errorf("Unexpected reason: %s", vlan.StatusHistory[0].Reason)
+	}
+}
+
+// Window storage whose windows are deleted right after the scheduler lists them
+type deletingWindows struct {
+	*storage.JSONStorage
+}
+
+func (d deletingWindows) GetAllMaintenanceWindows() ([]models.MaintenanceWindow, error) {
+	windows, err := d.JSONStorage.GetAllMaintenanceWindows()
+	for _, window := range windows {
+		d.DeleteMaintenanceWindow(window.ID)
+	}
+	return windows, err
+}
+
+// VLAN storage that checks each move to maintenance was recorded on the window first
+type recordingCheck struct {
+	*storage.JSONStorage
+	t *testing.T
+}
+
+func (r recordingCheck) UpdateStatus(id int, change models.StatusChange) (*models.VLANModel, error) {
+	if change.To == models.MaintenanceStatus {
+		window, _ := r.GetMaintenanceWindowByID(1)
+		if _, ok := window.Applied[id]; !ok {
+			r.t.Errorf("VLAN %d moved to maintenance before it was recorded, applied %v", id, window.Applied)
+		}
+	}
+	return r.JSONStorage.UpdateStatus(id, change)
+}
+
+func TestReconcileRecordsBeforeMoving(t *testing.T) {
+	store := newTestStorage(t)
+	vlan := createVLAN(t, store, 100, "active")
+	window := createWindow(t, store, "2025-05-01T10:00", "2025-05-01T12:00", vlan.ID)
+
+	if err := New(recordingCheck{store, t}, store, time.Minute).Reconcile(time.Date(2025, 5, 1, 11, 0, 0, 0, time.UTC)); err != nil {
+		t.Fatalf("Reconcile failed: %v", err)
+	}
+	if status := statusOf(t, store, vlan.ID); status != "maintenance" {
+		t.Errorf("Expected maintenance during window, got %s", status)
+	}
+
+	// A window with VLANs in maintenance cannot be deleted
+	if err := store.DeleteMaintenanceWindow(window.ID); !errors.Is(err, storage.ErrMaintenanceInProgress) {
+		t.Errorf("Expected ErrMaintenanceInProgress, got %v", err)
+	}
+}
+
+func TestReconcileDeletedWindow(t *testing.T) {
+	store := newTestStorage(t)
+	vlan := createVLAN(t, store, 100, "active")
+	createWindow(t, store, "2025-05-01T10:00", "2025-05-01T12:00", vlan.ID)
+
+	// A window deleted while the scheduler works on it is skipped without moving its VLANs
+	if err := New(store, deletingWindows{store}, time.Minute).Reconcile(time.Date(2025, 5, 1, 11, 0, 0, 0, time.UTC)); err != nil {
+		t.Fatalf("Reconcile failed: %v", err)
+	}
+	if status := statusOf(t, store, vlan.ID); status != "active" {
+		t.Errorf("Expected the VLAN to stay active, got %s", status)
+	}
+}
+
+func TestReconcileManualChanges(t *testing.T) {
+	store := newTestStorage(t)
+	manual := createVLAN(t, store, 100, "maintenance")
+	changed := createVLAN(t, store, 200, "active")
+	createWindow(t, store, "2025-05-01T10:00", "2025-05-01T12:00", manual.ID, changed.ID)
+
+	s := New(store, store, time.Minute)
+	if err := s.Reconcile(time.Date(2025, 5, 1, 11, 0, 0, 0, time.UTC)); err != nil {
+		t.Fatalf("Reconcile failed: %v", err)
+	}
+
+	// Someone takes the VLAN out of maintenance during the window
+	if _, err := store.UpdateStatus(changed.ID, models.StatusChange{To: "inactive", Reason: "Decommission early"}); err != nil {
+		t.Fatalf("Failed to update status: %v", err)
+	}
+
+	if err := s.Reconcile(time.Date(2025, 5, 1, 13, 0, 0, 0, time.UTC)); err != nil {
+		t.Fatalf("Reconcile failed: %v", err)
+	}
+	if status := statusOf(t, store, manual.ID); status != "maintenance" {
+		t.Errorf("Expected manually set maintenance to be kept, got %s", status)
+	}
+	if status := statusOf(t, store, changed.ID); status != "inactive" {
+		t.Errorf("Expected manual change to be kept, got %s", status)
+	}
+}
+
+func TestReconcileOverlappingWindows(t *testing.T) {
+	store := newTestStorage(t)
+	vlan := createVLAN(t, store, 100, "active")
+	createWindow(t, store, "2025-05-01T10:00", "2025-05-01T12:00", vlan.ID)
+	second := createWindow(t, store, "2025-05-01T11:00", "2025-05-01T14:00", vlan.ID)
+
+	s := New(store, store, time.Minute)
+	for _, hour := range []int{10, 11, 12} {
+		if err := s.Reconcile(time.Date(2025, 5, 1, hour, 30, 0, 0, time.UTC)); err != nil {
+			t.Fatalf("Reconcile failed: %v", err)
+		}
+	}
+
+	// The second window takes the VLAN over when the first one ends
+	if status := statusOf(t, store, vlan.ID); status != "maintenance" {
+		t.Errorf("Expected maintenance while second window runs, got %s", status)
+	}
+	stored, _ := store.GetMaintenanceWindowByID(second.ID)
+	if stored.Applied[vlan.ID] != "active" {
+		t.Errorf("Expected second window to own the VLAN, got %v", stored.Applied)
+	}
+
+	if err := s.Reconcile(time.Date(2025, 5, 1, 14, 0, 0, 0, time.UTC)); err != nil {
+		t.Fatalf("Reconcile failed: %v", err)
+	}
+	if status := statusOf(t, store, vlan.ID); status != "active" {
+		t.Errorf("Expected active after both windows, got %s", status)
+	}
+}
@@ -0,0 +1,140 @@
+package storage
+
+import (
+	"errors"
+	"time"
+
+	"smit/server/api/models"
+)
+
+var (
+	ErrMaintenanceWindowNotFound = errors.New("maintenance window not found")
+	// The window has VLANs in maintenance that it still has to restore
+	ErrMaintenanceInProgress = errors.New("maintenance window is in progress")
+)
+
+// MaintenanceStorage stores scheduled maintenance windows
+type MaintenanceStorage interface {
+	GetAllMaintenanceWindows() ([]models.MaintenanceWindow, error)
+	GetMaintenanceWindowByID(id int) (*models.MaintenanceWindow, error)
+	CreateMaintenanceWindow(window *models.MaintenanceWindowInput) (*models.MaintenanceWindow, error)
+	SetMaintenanceApplied(id int, applied map[int]string) error
+	DeleteMaintenanceWindow(id int) error
+}
+
+// Get all maintenance windows
+func (s *JSONStorage) GetAllMaintenanceWindows() ([]models.MaintenanceWindow, error) {
+	data, err := s.loadData()
+	if err != nil {
+		return nil, err
+	}
+
+	if data.MaintenanceWindows == nil {
+		return []models.MaintenanceWindow{}, nil
+	}
+	return data.MaintenanceWindows, nil
+}
+
+// Get maintenance window by ID
+func (s *JSONStorage) GetMaintenanceWindowByID(id int) (*models.MaintenanceWindow, error) {
+	data, err := s.loadData()
+	if err != nil {
+		return nil, err
+	}
+
+	for _, window := range data.MaintenanceWindows {
+		if window.ID == id {
+			return &window, nil
+		}
+	}
+
+	return nil, ErrMaintenanceWindowNotFound
+}
+
+// Create new maintenance window
+func (s *JSONStorage) CreateMaintenanceWindow(input *models.MaintenanceWindowInput) (*models.MaintenanceWindow, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	data, err := s.readData()
+	if err != nil {
+		return nil, err
+	}
+
+	maxID := 0
+	for _, window := range data.MaintenanceWindows {
+		if window.ID > maxID {
+			maxID = window.ID
+		}
+	}
+
+	now := time.Now()
+	newWindow := models.MaintenanceWindow{
+		ID:            maxID + 1,
+		Name:          input.Name,
+		VLANRecordIDs: input.VLANRecordIDs,
+		Start:         input.Start,
+		End:           input.End,
+		Timezone:      input.Timezone,
+		Recurrence:    input.Recurrence,
+		Until:         input.Until,
+		CreatedAt:     now,
+		UpdatedAt:     now,
+	}
+
+	data.MaintenanceWindows = append(data.MaintenanceWindows, newWindow)
+
+	if err := s.writeData(data); err != nil {
+		return nil, err
+	}
+
+	return &newWindow, nil
+}
+
+// Record which VLANs a maintenance window has moved to maintenance
+func (s *JSONStorage) SetMaintenanceApplied(id int, applied map[int]string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	data, err := s.readData()
+	if err != nil {
+		return err
+	}
+
+	for i, window := range data.MaintenanceWindows {
+		if window.ID == id {
+			if len(applied) == 0 {
+				applied = nil
+			}
+			data.MaintenanceWindows[i].Applied = applied
+			data.MaintenanceWindows[i].UpdatedAt = time.Now()
+			return s.writeData(data)
+		}
+	}
+
+	return ErrMaintenanceWindowNotFound
+}
+
+// Delete a maintenance window, unless it has VLANs in maintenance. The check runs
+// under the write lock, so it cannot race the scheduler recording applied VLANs.
+func (s *JSONStorage) DeleteMaintenanceWindow(id int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	data, err := s.readData()
+	if err != nil {
+		return err
+	}
+
+	for i, window := range data.MaintenanceWindows {
+		if window.ID == id {
+			if len(window.Applied) > 0 {
+				return ErrMaintenanceInProgress
+			}
+			data.MaintenanceWindows = append(data.MaintenanceWindows[:i], data.MaintenanceWindows[i+1:]...)
+			return s.writeData(data)
+		}
+	}
+
+	return ErrMaintenanceWindowNotFound
+}
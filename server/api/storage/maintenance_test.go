@@ -0,0 +1,74 @@
+package storage
+
+import (
+	"path/filepath"
+	"testing"
+
+	"smit/server/api/models"
+)
+
+func TestJSONStorageMaintenanceWindows(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "maintenance_test.json")
+	store, err := NewJSONStorage(path)
+	if err != nil {
+		t.Fatalf("Failed to create storage: %v", err)
+	}
+
+	input := &models.MaintenanceWindowInput{
+		Name:          "Core upgrade",
+		VLANRecordIDs: []int{1},
+		Start:         "2025-03-01T22:00",
+		End:           "2025-03-02T02:00",
+		Timezone:      "UTC",
+	}
+	created, err := store.CreateMaintenanceWindow(input)
+	if err != nil {
+		t.Fatalf("Failed to create maintenance window: %v", err)
+	}
+	if created.ID != 1 || created.Name != input.Name {
+		t.Errorf("Unexpected maintenance window: %+v", created)
+	}
+
+	// Applied statuses survive a reload
+	if err := store.SetMaintenanceApplied(created.ID, map[int]string{1: "active"}); err != nil {
+		t.Fatalf("Failed to set applied: %v", err)
+	}
+	reloaded, err := NewJSONStorage(path)
+	if err != nil {
+		t.Fatalf("Failed to reopen storage: %v", err)
+	}
+	window, err := reloaded.GetMaintenanceWindowByID(created.ID)
+	if err != nil {
+		t.Fatalf("Failed to get maintenance window: %v", err)
+	}
+	if window.Applied[1] != "active" {
+		t.Errorf("Expected applied status active, got %v", window.Applied)
+	}
+
+	// A window with applied statuses is in progress and cannot be deleted
+	if err := store.DeleteMaintenanceWindow(created.ID); err != ErrMaintenanceInProgress {
+		t.Errorf("Expected ErrMaintenanceInProgress, got %v", err)
+	}
+
+	// Clearing applied statuses
+	if err := store.SetMaintenanceApplied(created.ID, map[int]string{}); err != nil {
+		t.Fatalf("Failed to clear applied: %v", err)
+	}
+	if window, _ := store.GetMaintenanceWindowByID(created.ID); window.Applied != nil {
+		t.Errorf("Expected applied to be cleared, got %v", window.Applied)
+	}
+	if err := store.SetMaintenanceApplied(999, nil); err != ErrMaintenanceWindowNotFound {
+		t.Errorf("Expected ErrMaintenanceWindowNotFound, got %v", err)
+	}
+
+	// Delete
+	if err := store.DeleteMaintenanceWindow(created.ID); err != nil {
+		t.Fatalf("Failed to delete maintenance window: %v", err)
+	}
+	if _, err := store.GetMaintenanceWindowByID(created.ID); err != ErrMaintenanceWindowNotFound {
+		t.Errorf("Expected ErrMaintenanceWindowNotFound, got %v", err)
+	}
+	if windows, _ := store.GetAllMaintenanceWindows(); len(windows) != 0 {
+		t.Errorf("Expected 0 maintenance windows, got %d", len(windows))
+	}
+}
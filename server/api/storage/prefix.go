@@ -0,0 +1,110 @@
+package storage
+
+import (
+	"errors"
+	"time"
+
+	"smit/server/api/models"
+)
+
+var (
+	ErrPrefixNotFound = errors.New("prefix not found")
+	ErrPrefixExists   = errors.New("prefix already exists")
+)
+
+// PrefixStorage stores parent prefixes that VLAN subnets are carved from
+type PrefixStorage interface {
+	GetAllPrefixes() ([]models.PrefixModel, error)
+	GetPrefixByID(id int) (*models.PrefixModel, error)
+	CreatePrefix(prefix *models.PrefixInput) (*models.PrefixModel, error)
+	DeletePrefix(id int) error
+}
+
+// Get all parent prefixes
+func (s *JSONStorage) GetAllPrefixes() ([]models.PrefixModel, error) {
+	data, err := s.loadData()
+	if err != nil {
+		return nil, err
+	}
+
+	if data.Prefixes == nil {
+		return []models.PrefixModel{}, nil
+	}
+	return data.Prefixes, nil
+}
+
+// Get parent prefix by ID
+func (s *JSONStorage) GetPrefixByID(id int) (*models.PrefixModel, error) {
+	data, err := s.loadData()
+	if err != nil {
+		return nil, err
+	}
+
+	for _, prefix := range data.Prefixes {
+		if prefix.ID == id {
+			return &prefix, nil
+		}
+	}
+
+	return nil, ErrPrefixNotFound
+}
+
+// Create new parent prefix
+func (s *JSONStorage) CreatePrefix(input *models.PrefixInput) (*models.PrefixModel, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	data, err := s.readData()
+	if err != nil {
+		return nil, err
+	}
+
+	// Check if prefix already exists
+	maxID := 0
+	for _, prefix := range data.Prefixes {
+		if prefix.Prefix == input.Prefix {
+			return nil, ErrPrefixExists
+		}
+		if prefix.ID > maxID {
+			maxID = prefix.ID
+		}
+	}
+
+	now := time.Now()
+	newPrefix := models.PrefixModel{
+		ID:          maxID + 1,
+		Prefix:      input.Prefix,
+		Description: input.Description,
+		Site:        input.Site,
+		CreatedAt:   now,
+		UpdatedAt:   now,
+	}
+
+	data.Prefixes = append(data.Prefixes, newPrefix)
+
+	if err := s.writeData(data); err != nil {
+		return nil, err
+	}
+
+	return &newPrefix, nil
+}
+
+// Delete parent prefix
+func (s *JSONStorage) DeletePrefix(id int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	data, err := s.readData()
+	if err != nil {
+		return err
+	}
+
+	for i, prefix := range data.Prefixes {
+		if prefix.ID == id {
+			data.Prefixes = append(data.Prefixes[:i], data.Prefixes[i+1:]...)
+			return s.writeData(data)
+		}
+	}
+
+	return ErrPrefixNotFound
+}
@@ -0,0 +1,67 @@
+package storage
+
+import (
+	"path/filepath"
+	"testing"
+
+	"smit/server/api/models"
+)
+
+func TestJSONStoragePrefixes(t *testing.T) {
+	store, err := NewJSONStorage(filepath.Join(t.TempDir(), "prefix_test.json"))
+	if err != nil {
+		t.Fatalf("Failed to create storage: %v", err)
+	}
+
+	// Test GetAllPrefixes on empty storage
+	prefixes, err := store.GetAllPrefixes()
+	if err != nil {
+		t.Fatalf("Failed to get prefixes: %v", err)
+	}
+	if len(prefixes) != 0 {
+		t.Errorf("Expected 0 prefixes, got %d", len(prefixes))
+	}
+
+	// Test CreatePrefix
+	input := &models.PrefixInput{Prefix: "10.20.0.0/16", Description: "Campus aggregate"}
+	created, err := store.CreatePrefix(input)
+	if err != nil {
+		t.Fatalf("Failed to create prefix: %v", err)
+	}
+	if created.ID != 1 || created.Prefix != input.Prefix {
+		t.Errorf("Unexpected prefix: %+v", created)
+	}
+
+	// Test duplicate prefix
+	if _, err := store.CreatePrefix(input); err != ErrPrefixExists {
+		t.Errorf("Expected ErrPrefixExists, got %v", err)
+	}
+
+	// Test GetPrefixByID
+	retrieved, err := store.GetPrefixByID(created.ID)
+	if err != nil {
+		t.Fatalf("Failed to get prefix: %v", err)
+	}
+	if retrieved.Prefix != input.Prefix {
+		t.Errorf("Expected prefix %s, got %s", input.Prefix, retrieved.Prefix)
+	}
+	if _, err := store.GetPrefixByID(999); err != ErrPrefixNotFound {
+		t.Errorf("Expected ErrPrefixNotFound, got %v", err)
+	}
+
+	// Prefixes live next to VLANs in the same file
+	if _, err := store.Create(&models.VLANInput{Name: "VLAN", VlanID: 100, Subnet: "10.20.0.0/24", Gateway: "10.20.0.1", Status: "active"}); err != nil {
+		t.Fatalf("Failed to create VLAN: %v", err)
+	}
+	if prefixes, _ := store.GetAllPrefixes(); len(prefixes) != 1 {
+		t.Errorf("Expected 1 prefix after VLAN create, got %d", len(prefixes))
+	}
+
+	// Test DeletePrefix
+	if err := store.DeletePrefix(created.ID); err != nil {
+		t.Fatalf("Failed to delete prefix: %v", err)
+	}
+	if err := store.DeletePrefix(created.ID); err != ErrPrefixNotFound {
+		t.Errorf("Expected ErrPrefixNotFound, got %v", err)
+	}
+}
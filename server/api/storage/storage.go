@@ -5,40 +5,117 @@ import (
 	"errors"
 	"fmt"
 	"io"
+	"net/netip"
 	"os"
+	"path/filepath"
 	"smit/server/api/models"
 	"sync"
 	"time"
 )
 
 var (
-	ErrVLANNotFound = errors.New("VLAN not found")
-	ErrVLANExists   = errors.New("VLAN already exists")
+	ErrVLANNotFound  = errors.New("VLAN not found")
+	ErrVLANExists    = errors.New("VLAN already exists")
+	ErrSubnetOverlap = errors.New("subnet overlaps another VLAN")
+	// A status change or deletion the lifecycle does not allow
+	ErrInvalidTransition = errors.New("status transition not allowed")
+	ErrNotDeletable      = errors.New("VLAN cannot be deleted")
+	// The VLAN changed since the caller read the version it expects
+	ErrVersionMismatch = errors.New("VLAN has changed")
 )
 
+// Returned when a subnet overlaps the subnet of an existing VLAN; matches ErrSubnetOverlap
+type OverlapError struct {
+	VLAN models.VLANModel
+}
+
+func (e *OverlapError) Error() string {
+	return fmt.Sprintf("subnet overlaps %s of VLAN %d (%s)", e.VLAN.Subnet, e.VLAN.VlanID, e.VLAN.Name)
+}
+
+func (e *OverlapError) Is(target error) bool {
+	return target == ErrSubnetOverlap
+}
+
+// Returned when the lifecycle does not allow a VLAN to change status; matches ErrInvalidTransition
+type TransitionError struct {
+	From string
+	To   string
+}
+
+func (e *TransitionError) Error() string {
+	return fmt.Sprintf("VLAN cannot move from %s to %s", e.From, e.To)
+}
+
+func (e *TransitionError) Is(target error) bool {
+	return target == ErrInvalidTransition
+}
+
+// Returned when the lifecycle does not allow a VLAN in its status to be deleted; matches ErrNotDeletable
+type DeleteError struct {
+	Status string
+}
+
+func (e *DeleteError) Error() string {
+	return fmt.Sprintf("VLAN cannot be deleted while %s", e.Status)
+}
+
+func (e *DeleteError) Is(target error) bool {
+	return target == ErrNotDeletable
+}
+
 type Storage interface {
 	GetAll() ([]models.VLANModel, error)
+	Query(query models.VLANQuery) (*models.VLANPage, error)
 	GetByID(id int) (*models.VLANModel, error)
+	GetByVlanID(vlanID int) (*models.VLANModel, error)
 	Create(vlan *models.VLANInput) (*models.VLANModel, error)
 	Update(id int, vlan *models.VLANInput) (*models.VLANModel, error)
-	Delete(id int) error
+	UpdateStatus(id int, change models.StatusChange) (*models.VLANModel, error)
+	// Delete a VLAN; a non-empty ifMatch deletes it only while it still has that ETag
+	Delete(id int, ifMatch string) error
+
+	// Run every check of Create, Update and Delete and return their result without saving
+	CheckCreate(vlan *models.VLANInput) (*models.VLANModel, error)
+	CheckUpdate(id int, vlan *models.VLANInput) (*models.VLANModel, error)
+	CheckDelete(id int, ifMatch string) error
 }
 
 type JSONStorage struct {
-	filePath string
-	mu       sync.RWMutex
+	filePath      string
+	rejectOverlap bool
+	mu            sync.RWMutex
+	// Time of the last successful save, zero before the first one
+	lastSaved time.Time
+}
+
+// Option configures optional storage settings
+type Option func(*JSONStorage)
+
+// Reject VLANs whose subnet overlaps the subnet of another VLAN
+func WithOverlapCheck(enabled bool) Option {
+	return func(s *JSONStorage) {
+		s.rejectOverlap = enabled
+	}
 }
 
 // New JSON storage instance
-func NewJSONStorage(filePath string) (*JSONStorage, error) {
+func NewJSONStorage(filePath string, opts ...Option) (*JSONStorage, error) {
 	storage := &JSONStorage{
 		filePath: filePath,
 	}
 
+	for _, opt := range opts {
+		opt(storage)
+	}
+
 	// Create file if it doesn't exist
 	if _, err := os.Stat(filePath); os.IsNotExist(err) {
 		initialData := models.VLANData{VLANs: []models.VLANModel{}}
-		if err := storage.saveData(&initialData); err != nil {
+		storage.mu.Lock()
+		err := storage.writeData(&initialData)
+		storage.mu.Unlock()
+		if err != nil {
 			return nil, fmt.Errorf("failed to create initial data file: %w", err)
 		}
 	}
@@ -50,7 +127,11 @@ func NewJSONStorage(filePath string) (*JSONStorage, error) {
 func (s *JSONStorage) loadData() (*models.VLANData, error) {
 	s.mu.RLock()
 	defer s.mu.RUnlock()
+	return s.readData()
+}
 
+// Read and decode the data file; the caller holds the lock
+func (s *JSONStorage) readData() (*models.VLANData, error) {
 	file, err := os.Open(s.filePath)
 	if err != nil {
 		return nil, fmt.Errorf("failed to open file: %w", err)
@@ -70,23 +151,77 @@ func (s *JSONStorage) loadData() (*models.VLANData, error) {
 	return &vlanData, nil
 }
 
-// Save data to JSON file
-func (s *JSONStorage) saveData(data *models.VLANData) error {
-	s.mu.Lock()
-	defer s.mu.Unlock()
-
+// Save data to the JSON file through a temporary file and a rename, so a crash
+// never leaves a half-written file. The file is synced before the rename and the
+// directory after it, so a saved write survives a power loss. The caller holds
+// the write lock.
+func (s *JSONStorage) writeData(data *models.VLANData) error {
 	jsonData, err := json.MarshalIndent(data, "", "  ")
 	if err != nil {
 		return fmt.Errorf("failed to marshal data: %w", err)
 	}
 
-	if err := os.WriteFile(s.filePath, jsonData, 0644); err != nil {
+	tmp, err := os.CreateTemp(filepath.Dir(s.filePath), ".data-*")
+	if err != nil {
+		return fmt.Errorf("failed to write file: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(jsonData); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to write file: %w", err)
+	}
+	if err := tmp.Chmod(0644); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to write file: %w", err)
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to write file: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to write file: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), s.filePath); err != nil {
+		return fmt.Errorf("failed to write file: %w", err)
+	}
+	if err := syncDir(filepath.Dir(s.filePath)); err != nil {
 		return fmt.Errorf("failed to write file: %w", err)
 	}
 
+	s.lastSaved = time.Now()
 	return nil
 }
 
+// Flush a directory's entries to disk, so a rename into it is durable
+func syncDir(dir string) error {
+	d, err := os.Open(dir)
+	if err != nil {
+		return err
+	}
+	defer d.Close()
+	return d.Sync()
+}
+
+// Size of the data file in bytes
+func (s *JSONStorage) FileSize() (int64, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	info, err := os.Stat(s.filePath)
+	if err != nil {
+		return 0, err
+	}
+	return info.Size(), nil
+}
+
+// Time of the last successful save, zero when nothing has been saved since startup
+func (s *JSONStorage) LastSaved() time.Time {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.lastSaved
+}
+
 // Get all VLANs
 func (s *JSONStorage) GetAll() ([]models.VLANModel, error) {
 	data, err := s.loadData()
@@ -97,6 +232,17 @@ func (s *JSONStorage) GetAll() ([]models.VLANModel, error) {
 	return data.VLANs, nil
 }
 
+// Get VLANs matching a query, one page at a time
+func (s *JSONStorage) Query(query models.VLANQuery) (*models.VLANPage, error) {
+	data, err := s.loadData()
+	if err != nil {
+		return nil, err
+	}
+
+	// The JSON file has no indexes, so the query is applied to a full scan
+	return query.Apply(data.VLANs)
+}
+
 // Get VLAN by ID
 func (s *JSONStorage) GetByID(id int) (*models.VLANModel, error) {
 	data, err := s.loadData()
@@ -113,13 +259,56 @@ func (s *JSONStorage) GetByID(id int) (*models.VLANModel, error) {
 	return nil, ErrVLANNotFound
 }
 
+// Get VLAN by 802.1Q tag
+func (s *JSONStorage) GetByVlanID(vlanID int) (*models.VLANModel, error) {
+	data, err := s.loadData()
+	if err != nil {
+		return nil, err
+	}
+
+	for _, vlan := range data.VLANs {
+		if vlan.VlanID == vlanID {
+			return &vlan, nil
+		}
+	}
+
+	return nil, ErrVLANNotFound
+}
+
 // Create new VLAN
 func (s *JSONStorage) Create(input *models.VLANInput) (*models.VLANModel, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	data, err := s.readData()
+	if err != nil {
+		return nil, err
+	}
+
+	vlan, err := s.create(data, input)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.writeData(data); err != nil {
+		return nil, err
+	}
+
+	return vlan, nil
+}
+
+// Check whether a VLAN could be created, returning the VLAN Create would store
+func (s *JSONStorage) CheckCreate(input *models.VLANInput) (*models.VLANModel, error) {
 	data, err := s.loadData()
 	if err != nil {
 		return nil, err
 	}
 
+	return s.create(data, input)
+}
+
+// Add a new VLAN to the loaded data
+func (s *JSONStorage) create(data *models.VLANData, input *models.VLANInput) (*models.VLANModel, error) {
 	// Check if VLAN ID already exists
 	for _, vlan := range data.VLANs {
 		if vlan.VlanID == input.VlanID {
@@ -142,35 +331,78 @@ func (s *JSONStorage) Create(input *models.VLANInput) (*models.VLANModel, error)
 	// Create new VLAN
 	now := time.Now()
 	newVLAN := models.VLANModel{
-		ID:        newID,
-		Name:      input.Name,
-		VlanID:    input.VlanID,
-		Subnet:    input.Subnet,
-		Gateway:   input.Gateway,
-		Status:    input.Status,
-		CreatedAt: now,
-		UpdatedAt: now,
+		ID:           newID,
+		Name:         input.Name,
+		VlanID:       input.VlanID,
+		Subnet:       input.Subnet,
+		Gateway:      input.Gateway,
+		Status:       input.Status,
+		Site:         input.Site,
+		Allocated:    input.Allocated,
+		Reserved:     input.Reserved,
+		Labels:       input.Labels,
+		CustomFields: input.CustomFields,
+		CreatedAt:    now,
+		UpdatedAt:    now,
+		CreatedBy:    input.Actor,
+		UpdatedBy:    input.Actor,
 	}
 
-	data.VLANs = append(data.VLANs, newVLAN)
-
-	if err := s.saveData(data); err != nil {
+	if err := s.checkOverlap(data, newVLAN, input.RejectOverlap); err != nil {
 		return nil, err
 	}
 
+	data.VLANs = append(data.VLANs, newVLAN)
 	return &newVLAN, nil
 }
 
 // Update existing VLAN
 func (s *JSONStorage) Update(id int, input *models.VLANInput) (*models.VLANModel, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	data, err := s.readData()
+	if err != nil {
+		return nil, err
+	}
+
+	vlan, err := s.update(data, id, input)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.writeData(data); err != nil {
+		return nil, err
+	}
+
+	return vlan, nil
+}
+
+// Check whether a VLAN could be updated, returning the VLAN Update would store
+func (s *JSONStorage) CheckUpdate(id int, input *models.VLANInput) (*models.VLANModel, error) {
 	data, err := s.loadData()
 	if err != nil {
 		return nil, err
 	}
 
+	return s.update(data, id, input)
+}
+
+// Apply an update to a VLAN in the loaded data
+func (s *JSONStorage) update(data *models.VLANData, id int, input *models.VLANInput) (*models.VLANModel, error) {
 	// Find VLAN to update
 	for i, vlan := range data.VLANs {
 		if vlan.ID == id {
+			// Compare and set: refuse the update when the VLAN changed since the caller read it
+			if input.IfMatch != "" && vlan.ETag() != input.IfMatch {
+				return nil, ErrVersionMismatch
+			}
+
+			// Enforce the status lifecycle against the stored status
+			if !models.CurrentLifecycle().CanTransition(vlan.Status, input.Status) {
+				return nil, &TransitionError{From: vlan.Status, To: input.Status}
+			}
+
 			// Check if new VLAN ID conflicts with another VLAN
 			if vlan.VlanID != input.VlanID {
 				for _, v := range data.VLANs {
@@ -180,15 +412,68 @@ func (s *JSONStorage) Update(id int, input *models.VLANInput) (*models.VLANModel
 				}
 			}
 
+			// Record status changes in the history
+			if vlan.Status != input.Status {
+				data.VLANs[i].StatusHistory = append(data.VLANs[i].StatusHistory, models.StatusChange{
+					From:      vlan.Status,
+					To:        input.Status,
+					ChangedAt: time.Now(),
+					ChangedBy: input.Actor,
+				})
+			}
+
 			// Update VLAN
 			data.VLANs[i].Name = input.Name
 			data.VLANs[i].VlanID = input.VlanID
 			data.VLANs[i].Subnet = input.Subnet
 			data.VLANs[i].Gateway = input.Gateway
 			data.VLANs[i].Status = input.Status
+			data.VLANs[i].Site = input.Site
+			data.VLANs[i].Allocated = input.Allocated
+			data.VLANs[i].Reserved = input.Reserved
+			data.VLANs[i].Labels = input.Labels
+			data.VLANs[i].CustomFields = input.CustomFields
 			data.VLANs[i].UpdatedAt = time.Now()
+			data.VLANs[i].UpdatedBy = input.Actor
+
+			if err := s.checkOverlap(data, data.VLANs[i], input.RejectOverlap); err != nil {
+				return nil, err
+			}
+
+			return &data.VLANs[i], nil
+		}
+	}
+
+	return nil, ErrVLANNotFound
+}
 
-			if err := s.saveData(data); err != nil {
+// Update VLAN status and record the change with its reason
+func (s *JSONStorage) UpdateStatus(id int, change models.StatusChange) (*models.VLANModel, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	data, err := s.readData()
+	if err != nil {
+		return nil, err
+	}
+
+	for i, vlan := range data.VLANs {
+		if vlan.ID == id {
+			// A status change must move the VLAN somewhere the lifecycle allows
+			if vlan.Status == change.To || !models.CurrentLifecycle().CanTransition(vlan.Status, change.To) {
+				return nil, &TransitionError{From: vlan.Status, To: change.To}
+			}
+
+			now := time.Now()
+			change.From = vlan.Status
+			change.ChangedAt = now
+
+			data.VLANs[i].Status = change.To
+			data.VLANs[i].StatusHistory = append(data.VLANs[i].StatusHistory, change)
+			data.VLANs[i].UpdatedAt = now
+			data.VLANs[i].UpdatedBy = change.ChangedBy
+
+			if err := s.writeData(data); err != nil {
 				return nil, err
 			}
 
@@ -200,17 +485,45 @@ func (s *JSONStorage) Update(id int, input *models.VLANInput) (*models.VLANModel
 }
 
 // Delete VLAN
-func (s *JSONStorage) Delete(id int) error {
+func (s *JSONStorage) Delete(id int, ifMatch string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	data, err := s.readData()
+	if err != nil {
+		return err
+	}
+
+	if err := s.delete(data, id, ifMatch); err != nil {
+		return err
+	}
+
+	return s.writeData(data)
+}
+
+// Check whether a VLAN could be deleted
+func (s *JSONStorage) CheckDelete(id int, ifMatch string) error {
 	data, err := s.loadData()
 	if err != nil {
 		return err
 	}
 
+	return s.delete(data, id, ifMatch)
+}
+
+// Remove a VLAN from the loaded data
+func (s *JSONStorage) delete(data *models.VLANData, id int, ifMatch string) error {
 	// Find and remove VLAN
 	found := false
-	newVLANs := make([]models.VLANModel, 0, len(data.VLANs)-1)
+	newVLANs := make([]models.VLANModel, 0, len(data.VLANs))
 	for _, vlan := range data.VLANs {
 		if vlan.ID == id {
+			if ifMatch != "" && vlan.ETag() != ifMatch {
+				return ErrVersionMismatch
+			}
+			if !models.CurrentLifecycle().CanDelete(vlan.Status) {
+				return &DeleteError{Status: vlan.Status}
+			}
 			found = true
 			continue
 		}
@@ -222,5 +535,34 @@ func (s *JSONStorage) Delete(id int) error {
 	}
 
 	data.VLANs = newVLANs
-	return s.saveData(data)
+	return nil
+}
+
+// Reject a VLAN whose subnet overlaps another VLAN's, when overlap checks are enabled
+// or the write asks for one.
+// Subnets that do not parse are left to input validation.
+func (s *JSONStorage) checkOverlap(data *models.VLANData, vlan models.VLANModel, reject bool) error {
+	if !s.rejectOverlap && !reject {
+		return nil
+	}
+
+	subnet, err := netip.ParsePrefix(vlan.Subnet)
+	if err != nil {
+		return nil
+	}
+	subnet = subnet.Masked()
+
+	for _, other := range data.VLANs {
+		if other.ID == vlan.ID {
+			continue
+		}
+		otherSubnet, err := netip.ParsePrefix(other.Subnet)
+		if err != nil {
+			continue
+		}
+		if subnet.Overlaps(otherSubnet.Masked()) {
+			return &OverlapError{VLAN: other}
+		}
+	}
+	return nil
 }
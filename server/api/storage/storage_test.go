@@ -1,9 +1,13 @@
 package storage
 
 import (
+	"errors"
+	"fmt"
 	"os"
 	"path/filepath"
+	"sync"
 	"testing"
+	"time"
 
 	"smit/server/api/models"
 )
@@ -142,8 +146,14 @@ func TestJSONStorage(t *testing.T) {
 		t.Errorf("Expected ErrVLANExists, got %v", err)
 	}
 
-	// Test Delete
-	err = store.Delete(created.ID)
+	// Test Delete, which the lifecycle allows once the VLAN is inactive
+	if err := store.Delete(created.ID, ""); !errors.Is(err, ErrNotDeletable) {
+		t.Errorf("Expected ErrNotDeletable, got %v", err)
+	}
+	if _, err := store.UpdateStatus(created.ID, models.StatusChange{To: "inactive"}); err != nil {
+		t.Fatalf("Failed to update status: %v", err)
+	}
+	err = store.Delete(created.ID, "")
 	if err != nil {
 		t.Fatalf("Failed to delete VLAN: %v", err)
 	}
@@ -155,7 +165,7 @@ func TestJSONStorage(t *testing.T) {
 	}
 
 	// Test Delete with non-existent ID
-	err = store.Delete(999)
+	err = store.Delete(999, "")
 	if err != ErrVLANNotFound {
 		t.Errorf("Expected ErrVLANNotFound, got %v", err)
 	}
@@ -267,7 +277,7 @@ func TestJSONStorageEdgeCases(t *testing.T) {
 			VlanID:  i * 100,
 			Subnet:  "192.168.1.0/24",
 			Gateway: "192.168.1.1",
-			Status:  "active",
+			Status:  "planned",
 		}
 		created, err := store.Create(input)
 		if err != nil {
@@ -279,7 +289,7 @@ func TestJSONStorageEdgeCases(t *testing.T) {
 	}
 
 	// Delete a VLAN in the middle
-	err = store.Delete(3)
+	err = store.Delete(3, "")
 	if err != nil {
 		t.Fatalf("Failed to delete VLAN: %v", err)
 	}
@@ -300,3 +310,455 @@ func TestJSONStorageEdgeCases(t *testing.T) {
 		t.Errorf("Expected ID 6, got %d", created.ID)
 	}
 }
+
+func TestJSONStorageStatusHistory(t *testing.T) {
+	store, err := NewJSONStorage(filepath.Join(t.TempDir(), "history_test.json"))
+	if err != nil {
+		t.Fatalf("Failed to create storage: %v", err)
+	}
+
+	input := &models.VLANInput{
+		Name:    "Test VLAN",
+		VlanID:  100,
+		Subnet:  "192.168.100.0/24",
+		Gateway: "192.168.100.1",
+		Status:  "active",
+		Actor:   "alice",
+	}
+	created, err := store.Create(input)
+	if err != nil {
+		t.Fatalf("Failed to create VLAN: %v", err)
+	}
+	if created.CreatedBy != "alice" || created.UpdatedBy != "alice" {
+		t.Errorf("Expected alice as creator and editor, got %q/%q", created.CreatedBy, created.UpdatedBy)
+	}
+
+	// Test UpdateStatus records the reason and who made the change
+	updated, err := store.UpdateStatus(created.ID, models.StatusChange{To: "maintenance", Reason: "Upgrade", ChangedBy: "bob"})
+	if err != nil {
+		t.Fatalf("Failed to update status: %v", err)
+	}
+	if updated.Status != "maintenance" || len(updated.StatusHistory) != 1 || updated.UpdatedBy != "bob" {
+		t.Fatalf("Unexpected VLAN after status update: %+v", updated)
+	}
+	if change := updated.StatusHistory[0]; change.From != "active" || change.Reason != "Upgrade" || change.ChangedBy != "bob" {
+		t.Errorf("Unexpected status change: %+v", change)
+	}
+
+	// Test Update records status changes without a reason
+	input.Status = "inactive"
+	input.Actor = "carol"
+	updated, err = store.Update(created.ID, input)
+	if err != nil {
+		t.Fatalf("Failed to update VLAN: %v", err)
+	}
+	if len(updated.StatusHistory) != 2 || updated.StatusHistory[1].From != "maintenance" || updated.StatusHistory[1].ChangedBy != "carol" {
+		t.Errorf("Unexpected status history: %+v", updated.StatusHistory)
+	}
+	if updated.CreatedBy != "alice" || updated.UpdatedBy != "carol" {
+		t.Errorf("Expected carol as the last editor, got %q/%q", updated.CreatedBy, updated.UpdatedBy)
+	}
+
+	// Test UpdateStatus with non-existent ID
+	if _, err := store.UpdateStatus(999, models.StatusChange{To: "active"}); err != ErrVLANNotFound {
+		t.Errorf("Expected ErrVLANNotFound, got %v", err)
+	}
+}
+
+func TestJSONStorageLabelsAndCustomFields(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "labels_test.json")
+	store, err := NewJSONStorage(path)
+	if err != nil {
+		t.Fatalf("Failed to create storage: %v", err)
+	}
+
+	input := &models.VLANInput{
+		Name:         "Test VLAN",
+		VlanID:       100,
+		Subnet:       "192.168.100.0/24",
+		Gateway:      "192.168.100.1",
+		Status:       "active",
+		Labels:       map[string]string{"env": "prod"},
+		CustomFields: map[string]interface{}{"owner": "netops", "cost_center": float64(4100)},
+	}
+	created, err := store.Create(input)
+	if err != nil {
+		t.Fatalf("Failed to create VLAN: %v", err)
+	}
+
+	// Labels and custom fields survive a reload
+	reloaded, err := NewJSONStorage(path)
+	if err != nil {
+		t.Fatalf("Failed to reopen storage: %v", err)
+	}
+	vlan, err := reloaded.GetByID(created.ID)
+	if err != nil {
+		t.Fatalf("Failed to get VLAN: %v", err)
+	}
+	if vlan.Labels["env"] != "prod" || vlan.CustomFields["owner"] != "netops" || vlan.CustomFields["cost_center"] != float64(4100) {
+		t.Errorf("Unexpected labels or custom fields: %v %v", vlan.Labels, vlan.CustomFields)
+	}
+
+	// Update replaces them
+	input.Labels = map[string]string{"env": "dev"}
+	input.CustomFields = nil
+	updated, err := store.Update(created.ID, input)
+	if err != nil {
+		t.Fatalf("Failed to update VLAN: %v", err)
+	}
+	if updated.Labels["env"] != "dev" || updated.CustomFields != nil {
+		t.Errorf("Unexpected labels or custom fields after update: %v %v", updated.Labels, updated.CustomFields)
+	}
+}
+
+func TestJSONStorageQuery(t *testing.T) {
+	store, err := NewJSONStorage(filepath.Join(t.TempDir(), "query_test.json"))
+	if err != nil {
+		t.Fatalf("Failed to create storage: %v", err)
+	}
+
+	for i, status := range []string{"active", "inactive", "active"} {
+		if _, err := store.Create(&models.VLANInput{
+			Name:    fmt.Sprintf("VLAN %d", i),
+			VlanID:  100 + i,
+			Subnet:  fmt.Sprintf("10.0.%d.0/24", i),
+			Gateway: fmt.Sprintf("10.0.%d.1", i),
+			Status:  status,
+		}); err != nil {
+			t.Fatalf("Failed to create VLAN: %v", err)
+		}
+	}
+
+	page, err := store.Query(models.VLANQuery{Status: []string{"active"}, Sort: "vlan_id", Descending: true, Limit: 1})
+	if err != nil {
+		t.Fatalf("Failed to query VLANs: %v", err)
+	}
+	if len(page.Items) != 1 || page.Items[0].VlanID != 102 || page.NextCursor == "" {
+		t.Fatalf("Unexpected first page: %+v", page)
+	}
+
+	page, err = store.Query(models.VLANQuery{Status: []string{"active"}, Sort: "vlan_id", Descending: true, Limit: 1, Cursor: page.NextCursor})
+	if err != nil {
+		t.Fatalf("Failed to query VLANs: %v", err)
+	}
+	if len(page.Items) != 1 || page.Items[0].VlanID != 100 || page.NextCursor != "" {
+		t.Errorf("Unexpected last page: %+v", page)
+	}
+}
+
+func TestJSONStorageGetByVlanID(t *testing.T) {
+	store, err := NewJSONStorage(filepath.Join(t.TempDir(), "tag_test.json"))
+	if err != nil {
+		t.Fatalf("Failed to create storage: %v", err)
+	}
+
+	created, err := store.Create(&models.VLANInput{Name: "Test VLAN", VlanID: 200, Subnet: "192.168.200.0/24", Gateway: "192.168.200.1", Status: "active"})
+	if err != nil {
+		t.Fatalf("Failed to create VLAN: %v", err)
+	}
+
+	vlan, err := store.GetByVlanID(200)
+	if err != nil {
+		t.Fatalf("Failed to get VLAN by tag: %v", err)
+	}
+	if vlan.ID != created.ID {
+		t.Errorf("Expected record ID %d, got %d", created.ID, vlan.ID)
+	}
+
+	if _, err := store.GetByVlanID(created.ID); err != ErrVLANNotFound {
+		t.Errorf("Expected ErrVLANNotFound, got %v", err)
+	}
+}
+
+func TestJSONStorageCheckWrites(t *testing.T) {
+	store, err := NewJSONStorage(filepath.Join(t.TempDir(), "check_test.json"))
+	if err != nil {
+		t.Fatalf("Failed to create storage: %v", err)
+	}
+
+	store.Create(&models.VLANInput{Name: "Existing", VlanID: 100, Subnet: "10.1.0.0/24", Gateway: "10.1.0.1", Status: "active"})
+
+	input := &models.VLANInput{Name: "Planned", VlanID: 200, Subnet: "10.2.0.0/24", Gateway: "10.2.0.1", Status: "planned"}
+	vlan, err := store.CheckCreate(input)
+	if err != nil {
+		t.Fatalf("Failed to check create: %v", err)
+	}
+	if vlan.ID != 2 || vlan.Name != "Planned" {
+		t.Errorf("Expected would-be VLAN 2 Planned, got %d %s", vlan.ID, vlan.Name)
+	}
+
+	duplicate := *input
+	duplicate.VlanID = 100
+	if _, err := store.CheckCreate(&duplicate); err != ErrVLANExists {
+		t.Errorf("Expected ErrVLANExists, got %v", err)
+	}
+
+	update := models.VLANInput{Name: "Renamed", VlanID: 100, Subnet: "10.1.0.0/24", Gateway: "10.1.0.1", Status: "maintenance"}
+	vlan, err = store.CheckUpdate(1, &update)
+	if err != nil {
+		t.Fatalf("Failed to check update: %v", err)
+	}
+	if vlan.Name != "Renamed" || len(vlan.StatusHistory) != 1 {
+		t.Errorf("Expected renamed VLAN with a recorded status change, got %s with %d changes", vlan.Name, len(vlan.StatusHistory))
+	}
+	if _, err := store.CheckUpdate(99, &update); err != ErrVLANNotFound {
+		t.Errorf("Expected ErrVLANNotFound, got %v", err)
+	}
+
+	if err := store.CheckDelete(1, ""); !errors.Is(err, ErrNotDeletable) {
+		t.Errorf("Expected ErrNotDeletable for an active VLAN, got %v", err)
+	}
+	if err := store.CheckDelete(99, ""); err != ErrVLANNotFound {
+		t.Errorf("Expected ErrVLANNotFound, got %v", err)
+	}
+
+	// Nothing was saved
+	vlans, _ := store.GetAll()
+	if len(vlans) != 1 || vlans[0].Name != "Existing" || vlans[0].Status != "active" {
+		t.Errorf("Expected checks to leave the data unchanged, got %+v", vlans)
+	}
+
+	// The last VLAN may be deleted by another request between a handler's lookup and its checks
+	if _, err := store.UpdateStatus(1, models.StatusChange{To: "inactive"}); err != nil {
+		t.Fatalf("Failed to update status: %v", err)
+	}
+	if err := store.CheckDelete(1, ""); err != nil {
+		t.Errorf("Failed to check delete: %v", err)
+	}
+	if err := store.Delete(1, ""); err != nil {
+		t.Fatalf("Failed to delete VLAN: %v", err)
+	}
+	if err := store.CheckDelete(1, ""); err != ErrVLANNotFound {
+		t.Errorf("Expected ErrVLANNotFound with no VLANs left, got %v", err)
+	}
+	if err := store.Delete(1, ""); err != ErrVLANNotFound {
+		t.Errorf("Expected ErrVLANNotFound with no VLANs left, got %v", err)
+	}
+}
+
+func TestJSONStorageOverlapCheck(t *testing.T) {
+	store, err := NewJSONStorage(filepath.Join(t.TempDir(), "overlap_test.json"), WithOverlapCheck(true))
+	if err != nil {
+		t.Fatalf("Failed to create storage: %v", err)
+	}
+
+	if _, err := store.Create(&models.VLANInput{Name: "Site", VlanID: 100, Subnet: "10.4.0.0/16", Gateway: "10.4.0.1", Status: "active"}); err != nil {
+		t.Fatalf("Failed to create VLAN: %v", err)
+	}
+
+	tests := []struct {
+		name    string
+		subnet  string
+		overlap bool
+	}{
+		{"Inside", "10.4.17.0/24", true},
+		{"Containing", "10.0.0.0/8", true},
+		{"Same subnet", "10.4.0.0/16", true},
+		{"Disjoint", "10.5.0.0/24", false},
+		{"Other family", "2001:db8::/64", false},
+	}
+
+	for i, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			input := &models.VLANInput{Name: tt.name, VlanID: 200 + i, Subnet: tt.subnet, Gateway: "10.5.0.1", Status: "active"}
+			_, err := store.CheckCreate(input)
+
+			if tt.overlap {
+				var overlap *OverlapError
+				if !errors.Is(err, ErrSubnetOverlap) || !errors.As(err, &overlap) || overlap.VLAN.VlanID != 100 {
+					t.Errorf("Expected overlap with VLAN 100, got %v", err)
+				}
+			} else if err != nil {
+				t.Errorf("Unexpected error: %v", err)
+			}
+		})
+	}
+
+	// A VLAN does not overlap itself on update
+	if _, err := store.Update(1, &models.VLANInput{Name: "Site", VlanID: 100, Subnet: "10.4.0.0/17", Gateway: "10.4.0.1", Status: "active"}); err != nil {
+		t.Errorf("Unexpected error updating own subnet: %v", err)
+	}
+
+	store.Create(&models.VLANInput{Name: "Other", VlanID: 300, Subnet: "10.6.0.0/24", Gateway: "10.6.0.1", Status: "active"})
+	if _, err := store.Update(2, &models.VLANInput{Name: "Other", VlanID: 300, Subnet: "10.4.100.0/24", Gateway: "10.4.100.1", Status: "active"}); !errors.Is(err, ErrSubnetOverlap) {
+		t.Errorf("Expected ErrSubnetOverlap on update, got %v", err)
+	}
+
+	// A write can ask for the check when overlaps are otherwise allowed
+	open, err := NewJSONStorage(filepath.Join(t.TempDir(), "open_data.json"))
+	if err != nil {
+		t.Fatalf("Failed to create storage: %v", err)
+	}
+	open.Create(&models.VLANInput{Name: "Site", VlanID: 100, Subnet: "10.4.0.0/16", Gateway: "10.4.0.1", Status: "active"})
+	input := &models.VLANInput{Name: "Allocated", VlanID: 200, Subnet: "10.4.1.0/24", Gateway: "10.4.1.1", Status: "active", RejectOverlap: true}
+	if _, err := open.Create(input); !errors.Is(err, ErrSubnetOverlap) {
+		t.Errorf("Expected ErrSubnetOverlap when the write asks for the check, got %v", err)
+	}
+	input.RejectOverlap = false
+	if _, err := open.Create(input); err != nil {
+		t.Errorf("Expected the overlap to be allowed, got %v", err)
+	}
+}
+
+func TestJSONStorageFileStats(t *testing.T) {
+	testFile := filepath.Join(t.TempDir(), "test_data.json")
+	os.WriteFile(testFile, []byte(`{"vlans": []}`), 0644)
+
+	store, err := NewJSONStorage(testFile)
+	if err != nil {
+		t.Fatalf("Failed to create storage: %v", err)
+	}
+
+	// An existing file is not saved at startup
+	if !store.LastSaved().IsZero() {
+		t.Error("Expected no save before the first write")
+	}
+	if size, err := store.FileSize(); err != nil || size != 13 {
+		t.Errorf("Expected a size of 13 bytes, got %d, %v", size, err)
+	}
+
+	before := time.Now()
+	if _, err := store.Create(&models.VLANInput{Name: "Office", VlanID: 100, Subnet: "10.1.0.0/24", Gateway: "10.1.0.1", Status: "active"}); err != nil {
+		t.Fatalf("Failed to create VLAN: %v", err)
+	}
+	if store.LastSaved().Before(before) {
+		t.Errorf("Expected the save time to be recorded, got %v", store.LastSaved())
+	}
+	if size, _ := store.FileSize(); size <= 13 {
+		t.Errorf("Expected the file to grow, got %d bytes", size)
+	}
+}
+
+func TestJSONStorageConcurrentWrites(t *testing.T) {
+	dir := t.TempDir()
+	store, err := NewJSONStorage(filepath.Join(dir, "test_data.json"))
+	if err != nil {
+		t.Fatalf("Failed to create storage: %v", err)
+	}
+
+	// Every write holds the lock from load to save, so none is lost
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			input := &models.VLANInput{
+				Name:    fmt.Sprintf("VLAN %d", i),
+				VlanID:  100 + i,
+				Subnet:  fmt.Sprintf("10.%d.0.0/24", i),
+				Gateway: fmt.Sprintf("10.%d.0.1", i),
+				Status:  "active",
+			}
+			if _, err := store.Create(input); err != nil {
+				t.Errorf("Failed to create VLAN %d: %v", i, err)
+			}
+			if _, err := store.CreateWebhook(&models.WebhookInput{URL: fmt.Sprintf("https://example.com/%d", i)}); err != nil {
+				t.Errorf("Failed to create webhook %d: %v", i, err)
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	vlans, _ := store.GetAll()
+	webhooks, _ := store.GetAllWebhooks()
+	if len(vlans) != 20 || len(webhooks) != 20 {
+		t.Errorf("Expected 20 VLANs and 20 webhooks, got %d and %d", len(vlans), len(webhooks))
+	}
+
+	// The file is replaced by renaming, leaving no temporary files behind
+	entries, _ := os.ReadDir(dir)
+	if len(entries) != 1 {
+		t.Errorf("Expected only the data file, got %d entries", len(entries))
+	}
+	if info, err := os.Stat(filepath.Join(dir, "test_data.json")); err != nil || info.Mode().Perm() != 0644 {
+		t.Errorf("Expected the data file to keep mode 0644, got %v, %v", info, err)
+	}
+}
+
+func TestJSONStorageLifecycle(t *testing.T) {
+	store, err := NewJSONStorage(filepath.Join(t.TempDir(), "test_data.json"))
+	if err != nil {
+		t.Fatalf("Failed to create storage: %v", err)
+	}
+
+	input := models.VLANInput{Name: "Office", VlanID: 100, Subnet: "10.1.0.0/24", Gateway: "10.1.0.1", Status: "active"}
+	vlan, err := store.Create(&input)
+	if err != nil {
+		t.Fatalf("Failed to create VLAN: %v", err)
+	}
+
+	// Transitions are checked against the stored status, not the caller's copy
+	input.Status = "planned"
+	_, err = store.Update(vlan.ID, &input)
+	var transition *TransitionError
+	if !errors.As(err, &transition) || !errors.Is(err, ErrInvalidTransition) {
+		t.Fatalf("Expected a TransitionError, got %v", err)
+	}
+	if transition.From != "active" || transition.To != "planned" {
+		t.Errorf("Expected active to planned, got %s to %s", transition.From, transition.To)
+	}
+	if _, err := store.CheckUpdate(vlan.ID, &input); !errors.Is(err, ErrInvalidTransition) {
+		t.Errorf("Expected ErrInvalidTransition on a dry run, got %v", err)
+	}
+
+	// A status change to the current status is not a transition
+	if _, err := store.UpdateStatus(vlan.ID, models.StatusChange{To: "active"}); !errors.Is(err, ErrInvalidTransition) {
+		t.Errorf("Expected ErrInvalidTransition, got %v", err)
+	}
+	if _, err := store.UpdateStatus(vlan.ID, models.StatusChange{To: "decommissioning"}); err != nil {
+		t.Fatalf("Failed to update status: %v", err)
+	}
+	if _, err := store.UpdateStatus(vlan.ID, models.StatusChange{To: "active"}); !errors.Is(err, ErrInvalidTransition) {
+		t.Errorf("Expected ErrInvalidTransition from decommissioning, got %v", err)
+	}
+
+	// Deletion follows the lifecycle's deletable states
+	if err := store.Delete(vlan.ID, ""); err != nil {
+		t.Errorf("Failed to delete a decommissioning VLAN: %v", err)
+	}
+}
+
+func TestJSONStorageIfMatch(t *testing.T) {
+	store, err := NewJSONStorage(filepath.Join(t.TempDir(), "test_data.json"))
+	if err != nil {
+		t.Fatalf("Failed to create storage: %v", err)
+	}
+
+	input := models.VLANInput{Name: "Office", VlanID: 100, Subnet: "10.1.0.0/24", Gateway: "10.1.0.1", Status: "active"}
+	created, err := store.Create(&input)
+	if err != nil {
+		t.Fatalf("Failed to create VLAN: %v", err)
+	}
+
+	// The returned VLAN has the ETag a later read sees
+	stored, _ := store.GetByID(created.ID)
+	if created.ETag() != stored.ETag() {
+		t.Fatalf("Expected the created and stored VLAN to share an ETag, got %s and %s", created.ETag(), stored.ETag())
+	}
+
+	input.Name = "Renamed"
+	input.IfMatch = stored.ETag()
+	updated, err := store.Update(created.ID, &input)
+	if err != nil {
+		t.Fatalf("Failed to update with a matching ETag: %v", err)
+	}
+
+	// The same precondition no longer holds after the update
+	input.Name = "Lost"
+	if _, err := store.Update(created.ID, &input); !errors.Is(err, ErrVersionMismatch) {
+		t.Errorf("Expected ErrVersionMismatch, got %v", err)
+	}
+	if vlan, _ := store.GetByID(created.ID); vlan.Name != "Renamed" || vlan.ETag() != updated.ETag() {
+		t.Errorf("Expected the first update to be kept, got %+v", vlan)
+	}
+
+	// Deletes check the version the same way
+	store.UpdateStatus(created.ID, models.StatusChange{To: "inactive"})
+	if err := store.Delete(created.ID, updated.ETag()); !errors.Is(err, ErrVersionMismatch) {
+		t.Errorf("Expected ErrVersionMismatch, got %v", err)
+	}
+	current, _ := store.GetByID(created.ID)
+	if err := store.Delete(created.ID, current.ETag()); err != nil {
+		t.Errorf("Failed to delete with a matching ETag: %v", err)
+	}
+}
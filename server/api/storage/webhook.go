@@ -0,0 +1,101 @@
+package storage
+
+import (
+	"errors"
+	"time"
+
+	"smit/server/api/models"
+)
+
+var ErrWebhookNotFound = errors.New("webhook not found")
+
+// WebhookStorage stores webhook subscriptions
+type WebhookStorage interface {
+	GetAllWebhooks() ([]models.Webhook, error)
+	GetWebhookByID(id int) (*models.Webhook, error)
+	CreateWebhook(webhook *models.WebhookInput) (*models.Webhook, error)
+	DeleteWebhook(id int) error
+}
+
+// Get all webhooks
+func (s *JSONStorage) GetAllWebhooks() ([]models.Webhook, error) {
+	data, err := s.loadData()
+	if err != nil {
+		return nil, err
+	}
+
+	if data.Webhooks == nil {
+		return []models.Webhook{}, nil
+	}
+	return data.Webhooks, nil
+}
+
+// Get webhook by ID
+func (s *JSONStorage) GetWebhookByID(id int) (*models.Webhook, error) {
+	data, err := s.loadData()
+	if err != nil {
+		return nil, err
+	}
+
+	for _, webhook := range data.Webhooks {
+		if webhook.ID == id {
+			return &webhook, nil
+		}
+	}
+
+	return nil, ErrWebhookNotFound
+}
+
+// Create new webhook
+func (s *JSONStorage) CreateWebhook(input *models.WebhookInput) (*models.Webhook, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	data, err := s.readData()
+	if err != nil {
+		return nil, err
+	}
+
+	maxID := 0
+	for _, webhook := range data.Webhooks {
+		if webhook.ID > maxID {
+			maxID = webhook.ID
+		}
+	}
+
+	newWebhook := models.Webhook{
+		ID:        maxID + 1,
+		URL:       input.URL,
+		Events:    input.Events,
+		Secret:    input.Secret,
+		CreatedAt: time.Now(),
+	}
+
+	data.Webhooks = append(data.Webhooks, newWebhook)
+
+	if err := s.writeData(data); err != nil {
+		return nil, err
+	}
+
+	return &newWebhook, nil
+}
+
+// Delete webhook
+func (s *JSONStorage) DeleteWebhook(id int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	data, err := s.readData()
+	if err != nil {
+		return err
+	}
+
+	for i, webhook := range data.Webhooks {
+		if webhook.ID == id {
+			data.Webhooks = append(data.Webhooks[:i], data.Webhooks[i+1:]...)
+			return s.writeData(data)
+		}
+	}
+
+	return ErrWebhookNotFound
+}
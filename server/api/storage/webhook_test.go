@@ -0,0 +1,53 @@
+package storage
+
+import (
+	"path/filepath"
+	"testing"
+
+	"smit/server/api/models"
+)
+
+func TestJSONStorageWebhooks(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "webhook_test.json")
+	store, err := NewJSONStorage(path)
+	if err != nil {
+		t.Fatalf("Failed to create storage: %v", err)
+	}
+
+	input := &models.WebhookInput{URL: "https://cmdb.example.com/hooks/vlan", Events: []string{"created"}, Secret: "0123456789abcdef"}
+	created, err := store.CreateWebhook(input)
+	if err != nil {
+		t.Fatalf("Failed to create webhook: %v", err)
+	}
+	if created.ID != 1 || created.URL != input.URL || created.Secret != input.Secret {
+		t.Errorf("Unexpected webhook: %+v", created)
+	}
+
+	// Webhooks and their secrets survive a reload
+	reloaded, err := NewJSONStorage(path)
+	if err != nil {
+		t.Fatalf("Failed to reopen storage: %v", err)
+	}
+	webhook, err := reloaded.GetWebhookByID(created.ID)
+	if err != nil {
+		t.Fatalf("Failed to get webhook: %v", err)
+	}
+	if webhook.Secret != input.Secret {
+		t.Errorf("Expected secret to be persisted, got %q", webhook.Secret)
+	}
+
+	if err := store.DeleteWebhook(created.ID); err != nil {
+		t.Fatalf("Failed to delete webhook: %v", err)
+	}
+	if _, err := store.GetWebhookByID(created.ID); err != ErrWebhookNotFound {
+		t.Errorf("Expected ErrWebhookNotFound, got %v", err)
+	}
+	if err := store.DeleteWebhook(created.ID); err != ErrWebhookNotFound {
+		t.Errorf("Expected ErrWebhookNotFound, got %v", err)
+	}
+
+	webhooks, err := store.GetAllWebhooks()
+	if err != nil || len(webhooks) != 0 {
+		t.Errorf("Expected no webhooks, got %v, %v", webhooks, err)
+	}
+}
@@ -0,0 +1,104 @@
+package tracing
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestOTLPExporter(t *testing.T) {
+	var body []byte
+	var path, contentType, auth string
+	collector := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		path, contentType, auth = r.URL.Path, r.Header.Get("Content-Type"), r.Header.Get("Authorization")
+		body, _ = io.ReadAll(r.Body)
+	}))
+	defer collector.Close()
+
+	tracer := New(NewOTLPExporter(collector.URL+"/", map[string]string{"Authorization": "Bearer secret"}, nil), Options{ServiceName: "smit-test", SampleRatio: 1})
+	_, span := tracer.Start(context.Background(), "CreateVLAN", Int("vlan.id", 100), Bool("dry_run", false))
+	span.End()
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	tracer.Run(ctx)
+
+	if path != "/v1/traces" || contentType != "application/json" || auth != "Bearer secret" {
+		t.Errorf("Unexpected export request to %q with type %q and auth %q", path, contentType, auth)
+	}
+
+	var request otlpRequest
+	if err := json.Unmarshal(body, &request); err != nil {
+		t.Fatalf("Failed to decode export: %v", err)
+	}
+	resource := request.ResourceSpans[0]
+	if *resource.Resource.Attributes[0].Value.StringValue != "smit-test" {
+		t.Errorf("Expected service.name smit-test, got %+v", resource.Resource.Attributes)
+	}
+	exported := resource.ScopeSpans[0].Spans[0]
+	if exported.Name != "CreateVLAN" || exported.Kind != KindInternal || len(exported.TraceID) != 32 || len(exported.SpanID) != 16 {
+		t.Errorf("Unexpected span %+v", exported)
+	}
+	if *exported.Attributes[0].Value.IntValue != "100" || *exported.Attributes[1].Value.BoolValue {
+		t.Errorf("Unexpected attributes %+v", exported.Attributes)
+	}
+	start, _ := strconv.ParseInt(exported.StartTimeUnixNano, 10, 64)
+	end, _ := strconv.ParseInt(exported.EndTimeUnixNano, 10, 64)
+	if start == 0 || end < start {
+		t.Errorf("Expected the span to end after it started, got %s to %s", exported.StartTimeUnixNano, exported.EndTimeUnixNano)
+	}
+}
+
+func TestOTLPExporterFailure(t *testing.T) {
+	collector := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer collector.Close()
+
+	err := NewOTLPExporter(collector.URL, nil, nil).Export(context.Background(), []byte("{}"))
+	if err == nil || !strings.Contains(err.Error(), "503") {
+		t.Errorf("Expected the collector's status in the error, got %v", err)
+	}
+}
+
+func TestWriterExporter(t *testing.T) {
+	var out bytes.Buffer
+	tracer := New(NewWriterExporter(&out), Options{SampleRatio: 1, BatchSize: 1})
+	for _, name := range []string{"first", "second"} {
+		_, span := tracer.Start(context.Background(), name)
+		span.End()
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	tracer.Run(ctx)
+
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("Expected one line per batch of one, got %d:\n%s", len(lines), out.String())
+	}
+	for _, line := range lines {
+		var request otlpRequest
+		if err := json.Unmarshal([]byte(line), &request); err != nil {
+			t.Errorf("Expected each line to be an OTLP/JSON request: %v", err)
+		}
+	}
+}
+
+func TestParseHeaders(t *testing.T) {
+	headers, err := ParseHeaders("Authorization=Bearer abc, X-Scope = team=network ,")
+	if err != nil {
+		t.Fatalf("Failed to parse headers: %v", err)
+	}
+	if len(headers) != 2 || headers["Authorization"] != "Bearer abc" || headers["X-Scope"] != "team=network" {
+		t.Errorf("Unexpected headers %v", headers)
+	}
+
+	if _, err := ParseHeaders("Authorization"); err == nil {
+		t.Error("Expected a header without a value to be rejected")
+	}
+}
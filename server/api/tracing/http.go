@@ -0,0 +1,61 @@
+package tracing
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+
+	"smit/server/api/recorder"
+)
+
+// Request header carrying the caller's span context
+const traceparentHeader = "traceparent"
+
+// Looks up the route template a path is served by
+type Routes interface {
+	// Template of the route matching the path; ok is false when no route matches
+	Route(path string) (template string, ok bool)
+}
+
+// Set the traceparent header of an outgoing request, so the receiver joins the
+// context's trace. Nothing is set outside a trace.
+func Inject(ctx context.Context, header http.Header) {
+	if sc, ok := SpanContextFromContext(ctx); ok {
+		header.Set(traceparentHeader, sc.Traceparent())
+	}
+}
+
+// Record a server span for every request passing to next. A valid traceparent
+// header makes the span a child of the caller's span, so the request joins the
+// caller's trace; otherwise a new trace is started.
+func (t *Tracer) Handler(routes Routes, next http.Handler) http.Handler {
+	if t == nil {
+		return next
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		if parent, ok := ParseTraceparent(r.Header.Get(traceparentHeader)); ok {
+			ctx = ContextWithRemoteParent(ctx, parent)
+		}
+
+		name := r.Method
+		attributes := []Attribute{String("http.request.method", r.Method), String("url.path", r.URL.Path)}
+		if route, ok := routes.Route(r.URL.Path); ok {
+			name += " " + route
+			attributes = append(attributes, String("http.route", route))
+		}
+
+		ctx, span := t.start(ctx, name, KindServer, attributes)
+		defer span.End()
+
+		rec := recorder.New(w)
+		next.ServeHTTP(rec, r.WithContext(ctx))
+
+		status := rec.StatusCode()
+		span.SetAttributes(Int("http.response.status_code", status))
+		if status >= http.StatusInternalServerError {
+			span.SetError(strconv.Itoa(status) + " " + http.StatusText(status))
+		}
+	})
+}
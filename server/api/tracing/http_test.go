@@ -0,0 +1,110 @@
+package tracing
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// Routes with fixed templates per path
+type staticRoutes map[string]string
+
+func (s staticRoutes) Route(path string) (string, bool) {
+	template, ok := s[path]
+	return template, ok
+}
+
+func TestHandler(t *testing.T) {
+	exporter := &memoryExporter{}
+	tracer := New(exporter, Options{SampleRatio: 1})
+	routes := staticRoutes{"/api/v1/vlans/7": "/api/v1/vlans/{id}"}
+
+	var inner *Span
+	handler := tracer.Handler(routes, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		inner = SpanFromContext(r.Context())
+		if _, ok := w.(http.Flusher); !ok {
+			t.Error("Expected the response writer to support flushing")
+		}
+		if r.URL.Path == "/broken" {
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+	}))
+
+	req := httptest.NewRequest("GET", "/api/v1/vlans/7", nil)
+	req.Header.Set("traceparent", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+	if inner == nil {
+		t.Fatal("Expected the request context to carry the server span")
+	}
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("POST", "/broken", nil))
+
+	spans := flush(t, tracer, exporter)
+	server, ok := spans["GET /api/v1/vlans/{id}"]
+	if !ok {
+		t.Fatalf("Expected a span named after the route, got %v", spans)
+	}
+	if server.Kind != KindServer || server.TraceID != "4bf92f3577b34da6a3ce929d0e0e4736" || server.ParentSpanID != "00f067aa0ba902b7" {
+		t.Errorf("Expected a server span continuing the caller's trace, got %+v", server)
+	}
+	if server.Status != nil {
+		t.Errorf("Expected no error status, got %+v", server.Status)
+	}
+	attributes := make(map[string]otlpValue)
+	for _, attribute := range server.Attributes {
+		attributes[attribute.Key] = attribute.Value
+	}
+	if *attributes["http.route"].StringValue != "/api/v1/vlans/{id}" || *attributes["http.response.status_code"].IntValue != "200" {
+		t.Errorf("Unexpected attributes %+v", server.Attributes)
+	}
+
+	broken, ok := spans["POST"]
+	if !ok {
+		t.Fatalf("Expected an unmatched route to be named by method only, got %v", spans)
+	}
+	if broken.Status == nil || broken.Status.Code != otlpStatusError || broken.ParentSpanID != "" {
+		t.Errorf("Expected a failed root span, got %+v", broken)
+	}
+}
+
+func TestHandlerWithoutTracer(t *testing.T) {
+	var tracer *Tracer
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})
+	handler := tracer.Handler(staticRoutes{}, next)
+	if _, ok := handler.(http.HandlerFunc); !ok {
+		t.Fatal("Expected the handler to be returned unwrapped")
+	}
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/", nil))
+}
+
+func TestInject(t *testing.T) {
+	header := make(http.Header)
+	Inject(context.Background(), header)
+	if header.Get("traceparent") != "" {
+		t.Errorf("Expected no traceparent outside a trace, got %s", header.Get("traceparent"))
+	}
+
+	// Without a tracer the remote parent is passed on unchanged
+	const traceparent = "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"
+	parent, _ := ParseTraceparent(traceparent)
+	ctx := ContextWithRemoteParent(context.Background(), parent)
+	Inject(ctx, header)
+	if header.Get("traceparent") != traceparent {
+		t.Errorf("Expected %s, got %s", traceparent, header.Get("traceparent"))
+	}
+
+	// A client span replaces it as the receiver's parent
+	exporter := &memoryExporter{}
+	tracer := New(exporter, Options{SampleRatio: 1})
+	ctx, span := tracer.StartClient(ctx, "outgoing")
+	Inject(ctx, header)
+	span.End()
+	if header.Get("traceparent") != span.Context().Traceparent() {
+		t.Errorf("Expected the client span's traceparent, got %s", header.Get("traceparent"))
+	}
+
+	spans := flush(t, tracer, exporter)
+	if spans["outgoing"].Kind != KindClient || spans["outgoing"].ParentSpanID != "00f067aa0ba902b7" {
+		t.Errorf("Expected a client span continuing the remote trace, got %+v", spans["outgoing"])
+	}
+}
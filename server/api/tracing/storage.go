@@ -0,0 +1,130 @@
+package tracing
+
+import (
+	"context"
+	"errors"
+
+	"smit/server/api/models"
+	"smit/server/api/storage"
+)
+
+// Storage records a span for every call made through a copy bound to a request's
+// context. Calls on the unbound storage, such as those of background workers,
+// pass straight through.
+type Storage struct {
+	storage.Storage
+	tracer  *Tracer
+	backend string
+	ctx     context.Context
+}
+
+// Wrap a storage, naming the backend in its spans
+func NewStorage(next storage.Storage, backend string, tracer *Tracer) *Storage {
+	return &Storage{Storage: next, tracer: tracer, backend: backend}
+}
+
+// Copy of the storage whose calls are children of the context's span. A wrapped
+// storage that can be bound to a context is bound as well.
+func (s *Storage) WithContext(ctx context.Context) storage.Storage {
+	bound := *s
+	bound.ctx = ctx
+	if next, ok := s.Storage.(interface {
+		WithContext(ctx context.Context) storage.Storage
+	}); ok {
+		bound.Storage = next.WithContext(ctx)
+	}
+	return &bound
+}
+
+// Start the span of a storage call; the returned function ends it with the call's error
+func (s *Storage) trace(method string) func(error) {
+	if s.ctx == nil || s.tracer == nil {
+		return func(error) {}
+	}
+
+	_, span := s.tracer.Start(s.ctx, "storage."+method, String("storage.backend", s.backend), String("storage.method", method))
+	return func(err error) {
+		// Lookups of missing VLANs and conflicts are answers, not failures
+		if err != nil && !errors.Is(err, storage.ErrVLANNotFound) && !errors.Is(err, storage.ErrVLANExists) && !errors.Is(err, storage.ErrSubnetOverlap) {
+			span.SetError(err.Error())
+		}
+		span.End()
+	}
+}
+
+func (s *Storage) GetAll() ([]models.VLANModel, error) {
+	end := s.trace("GetAll")
+	vlans, err := s.Storage.GetAll()
+	end(err)
+	return vlans, err
+}
+
+func (s *Storage) Query(query models.VLANQuery) (*models.VLANPage, error) {
+	end := s.trace("Query")
+	page, err := s.Storage.Query(query)
+	end(err)
+	return page, err
+}
+
+func (s *Storage) GetByID(id int) (*models.VLANModel, error) {
+	end := s.trace("GetByID")
+	vlan, err := s.Storage.GetByID(id)
+	end(err)
+	return vlan, err
+}
+
+func (s *Storage) GetByVlanID(vlanID int) (*models.VLANModel, error) {
+	end := s.trace("GetByVlanID")
+	vlan, err := s.Storage.GetByVlanID(vlanID)
+	end(err)
+	return vlan, err
+}
+
+func (s *Storage) Create(input *models.VLANInput) (*models.VLANModel, error) {
+	end := s.trace("Create")
+	vlan, err := s.Storage.Create(input)
+	end(err)
+	return vlan, err
+}
+
+func (s *Storage) Update(id int, input *models.VLANInput) (*models.VLANModel, error) {
+	end := s.trace("Update")
+	vlan, err := s.Storage.Update(id, input)
+	end(err)
+	return vlan, err
+}
+
+func (s *Storage) UpdateStatus(id int, change models.StatusChange) (*models.VLANModel, error) {
+	end := s.trace("UpdateStatus")
+	vlan, err := s.Storage.UpdateStatus(id, change)
+	end(err)
+	return vlan, err
+}
+
+func (s *Storage) Delete(id int, ifMatch string) error {
+	end := s.trace("Delete")
+	err := s.Storage.Delete(id, ifMatch)
+	end(err)
+	return err
+}
+
+func (s *Storage) CheckCreate(input *models.VLANInput) (*models.VLANModel, error) {
+	end := s.trace("CheckCreate")
+	vlan, err := s.Storage.CheckCreate(input)
+	end(err)
+	return vlan, err
+}
+
+func (s *Storage) CheckUpdate(id int, input *models.VLANInput) (*models.VLANModel, error) {
+	end := s.trace("CheckUpdate")
+	vlan, err := s.Storage.CheckUpdate(id, input)
+	end(err)
+	return vlan, err
+}
+
+func (s *Storage) CheckDelete(id int, ifMatch string) error {
+	end := s.trace("CheckDelete")
+	err := s.Storage.CheckDelete(id, ifMatch)
+	end(err)
+	return err
+}
@@ -0,0 +1,58 @@
+package tracing
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+
+	"smit/server/api/models"
+	"smit/server/api/storage"
+)
+
+func TestStorage(t *testing.T) {
+	store, err := storage.NewJSONStorage(filepath.Join(t.TempDir(), "data.json"))
+	if err != nil {
+		t.Fatalf("Failed to create storage: %v", err)
+	}
+
+	exporter := &memoryExporter{}
+	tracer := New(exporter, Options{SampleRatio: 1})
+	traced := NewStorage(store, "json", tracer)
+
+	// Calls outside a request are not traced
+	input := models.VLANInput{Name: "Office", VlanID: 100, Subnet: "10.1.0.0/24", Gateway: "10.1.0.1", Status: "active"}
+	if _, err := traced.Create(&input); err != nil {
+		t.Fatalf("Failed to create VLAN: %v", err)
+	}
+	if spans := flush(t, tracer, exporter); len(spans) != 0 {
+		t.Errorf("Expected no spans from unbound calls, got %d", len(spans))
+	}
+
+	ctx, request := tracer.Start(context.Background(), "request")
+	bound := traced.WithContext(ctx)
+	if _, err := bound.GetByID(1); err != nil {
+		t.Fatalf("Failed to get VLAN: %v", err)
+	}
+	bound.GetByID(999)
+	duplicate := input
+	bound.Create(&duplicate)
+	request.End()
+
+	spans := flush(t, tracer, exporter)
+	get, ok := spans["storage.GetByID"]
+	if !ok {
+		t.Fatalf("Expected a span for GetByID, got %v", spans)
+	}
+	if get.ParentSpanID != spans["request"].SpanID {
+		t.Error("Expected storage spans to be children of the request's span")
+	}
+	if get.Status != nil {
+		t.Errorf("Expected a missing VLAN not to fail the span, got %+v", get.Status)
+	}
+	if create := spans["storage.Create"]; create.Status != nil {
+		t.Errorf("Expected a conflict not to fail the span, got %+v", create.Status)
+	}
+	if *get.Attributes[0].Value.StringValue != "json" {
+		t.Errorf("Expected the backend attribute, got %+v", get.Attributes)
+	}
+}
@@ -0,0 +1,369 @@
+// Package tracing records OpenTelemetry-compatible spans for requests and the
+// work they do, continues traces from W3C traceparent headers and exports spans
+// in batches as OTLP/JSON. A nil *Tracer and a nil *Span do nothing, so code can
+// be instrumented whether or not tracing is configured.
+//
+// The OpenTelemetry SDK is deliberately not used. Like the JWT validation in auth
+// and the Prometheus exposition in metrics, the server keeps to the standard
+// library, and the SDK would pull in a large dependency tree for the small part of
+// it used here: spans, W3C trace context propagation and OTLP/JSON over HTTP. The
+// wire formats are the standard ones, so collectors and other services see no
+// difference.
+package tracing
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"log/slog"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Defaults for batching finished spans
+const (
+	DefaultBatchSize = 512
+	DefaultInterval  = 5 * time.Second
+	DefaultQueueSize = 4096
+)
+
+// Identifier of a trace
+type TraceID [16]byte
+
+// Identifier of a span within a trace
+type SpanID [8]byte
+
+func (id TraceID) String() string { return hex.EncodeToString(id[:]) }
+func (id SpanID) String() string  { return hex.EncodeToString(id[:]) }
+
+// Check that the ID is not all zeros, which W3C Trace Context forbids
+func (id TraceID) IsValid() bool { return id != TraceID{} }
+func (id SpanID) IsValid() bool  { return id != SpanID{} }
+
+// Identity of a span as it is propagated between services
+type SpanContext struct {
+	TraceID TraceID
+	SpanID  SpanID
+	Sampled bool
+}
+
+// Parse a W3C traceparent header such as 00-<trace-id>-<span-id>-01
+func ParseTraceparent(value string) (SpanContext, bool) {
+	parts := strings.Split(strings.TrimSpace(value), "-")
+	if len(parts) < 4 || len(parts[0]) != 2 || parts[0] == "ff" {
+		return SpanContext{}, false
+	}
+	// Version 00 has exactly four fields; later versions may append more
+	if parts[0] == "00" && len(parts) != 4 {
+		return SpanContext{}, false
+	}
+
+	var sc SpanContext
+	var flags [1]byte
+	if !decodeHex(sc.TraceID[:], parts[1]) || !decodeHex(sc.SpanID[:], parts[2]) || !decodeHex(flags[:], parts[3]) {
+		return SpanContext{}, false
+	}
+	if !sc.TraceID.IsValid() || !sc.SpanID.IsValid() {
+		return SpanContext{}, false
+	}
+	sc.Sampled = flags[0]&1 == 1
+	return sc, true
+}
+
+// Lower-case hex only, as W3C Trace Context requires
+func decodeHex(dst []byte, s string) bool {
+	if len(s) != hex.EncodedLen(len(dst)) || strings.ToLower(s) != s {
+		return false
+	}
+	_, err := hex.Decode(dst, []byte(s))
+	return err == nil
+}
+
+// The span context as a traceparent header value
+func (sc SpanContext) Traceparent() string {
+	flags := "00"
+	if sc.Sampled {
+		flags = "01"
+	}
+	return fmt.Sprintf("00-%s-%s-%s", sc.TraceID, sc.SpanID, flags)
+}
+
+// Role of a span in a trace, numbered as in OTLP
+type SpanKind int
+
+const (
+	KindInternal SpanKind = 1
+	KindServer   SpanKind = 2
+	KindClient   SpanKind = 3
+)
+
+// Key and value describing a span; the value is a string, int64, float64 or bool
+type Attribute struct {
+	Key   string
+	Value interface{}
+}
+
+func String(key, value string) Attribute    { return Attribute{key, value} }
+func Int(key string, value int) Attribute   { return Attribute{key, int64(value)} }
+func Bool(key string, value bool) Attribute { return Attribute{key, value} }
+
+// Timed operation within a trace. Spans of unsampled traces carry their context
+// for propagation but are not recorded.
+type Span struct {
+	tracer  *Tracer
+	name    string
+	kind    SpanKind
+	context SpanContext
+	parent  SpanID
+	start   time.Time
+
+	mu         sync.Mutex
+	end        time.Time
+	attributes []Attribute
+	failed     bool
+	message    string
+}
+
+// Context of the span, for propagation
+func (s *Span) Context() SpanContext {
+	if s == nil {
+		return SpanContext{}
+	}
+	return s.context
+}
+
+// Add attributes to the span
+func (s *Span) SetAttributes(attributes ...Attribute) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.attributes = append(s.attributes, attributes...)
+}
+
+// Mark the span as failed with a description of the error
+func (s *Span) SetError(message string) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.failed = true
+	s.message = message
+}
+
+// Finish the span and queue it for export; later calls do nothing
+func (s *Span) End() {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	if !s.end.IsZero() {
+		s.mu.Unlock()
+		return
+	}
+	s.end = s.tracer.now()
+	s.mu.Unlock()
+
+	if s.context.Sampled {
+		s.tracer.enqueue(s)
+	}
+}
+
+type spanKey struct{}
+
+// Return a context carrying the span, which becomes the parent of spans started from it
+func ContextWithSpan(ctx context.Context, span *Span) context.Context {
+	return context.WithValue(ctx, spanKey{}, span)
+}
+
+// Span of the context, nil when there is none
+func SpanFromContext(ctx context.Context) *Span {
+	span, _ := ctx.Value(spanKey{}).(*Span)
+	return span
+}
+
+type remoteKey struct{}
+
+// Return a context whose next span continues a trace started by another service
+func ContextWithRemoteParent(ctx context.Context, parent SpanContext) context.Context {
+	return context.WithValue(ctx, remoteKey{}, parent)
+}
+
+// Span context to propagate from the context: its span's, or else the remote
+// parent's; ok is false when the context is not part of a trace
+func SpanContextFromContext(ctx context.Context) (SpanContext, bool) {
+	if span := SpanFromContext(ctx); span != nil {
+		return span.context, true
+	}
+	remote, ok := ctx.Value(remoteKey{}).(SpanContext)
+	return remote, ok && remote.TraceID.IsValid()
+}
+
+// Tracer settings
+type Options struct {
+	// service.name of the exported resource
+	ServiceName string
+	// Fraction of new traces to record; traces continued from a caller follow its decision
+	SampleRatio float64
+	BatchSize   int
+	Interval    time.Duration
+}
+
+// Starts spans and exports the finished ones in batches. The tracer is safe for
+// concurrent use; Run must be running for spans to be exported.
+type Tracer struct {
+	exporter Exporter
+	options  Options
+	queue    chan *Span
+	now      func() time.Time
+}
+
+// Create a tracer exporting to the given exporter
+func New(exporter Exporter, options Options) *Tracer {
+	if options.ServiceName == "" {
+		options.ServiceName = "smit"
+	}
+	if options.BatchSize <= 0 {
+		options.BatchSize = DefaultBatchSize
+	}
+	if options.Interval <= 0 {
+		options.Interval = DefaultInterval
+	}
+	return &Tracer{
+		exporter: exporter,
+		options:  options,
+		queue:    make(chan *Span, DefaultQueueSize),
+		now:      time.Now,
+	}
+}
+
+// Start an internal span as a child of the context's span
+func (t *Tracer) Start(ctx context.Context, name string, attributes ...Attribute) (context.Context, *Span) {
+	return t.start(ctx, name, KindInternal, attributes)
+}
+
+// Start a client span for an outgoing request as a child of the context's span
+func (t *Tracer) StartClient(ctx context.Context, name string, attributes ...Attribute) (context.Context, *Span) {
+	return t.start(ctx, name, KindClient, attributes)
+}
+
+func (t *Tracer) start(ctx context.Context, name string, kind SpanKind, attributes []Attribute) (context.Context, *Span) {
+	if t == nil {
+		return ctx, nil
+	}
+
+	span := &Span{tracer: t, name: name, kind: kind, start: t.now(), attributes: attributes}
+	span.context.SpanID = newSpanID()
+
+	if parent := SpanFromContext(ctx); parent != nil {
+		span.context.TraceID = parent.context.TraceID
+		span.context.Sampled = parent.context.Sampled
+		span.parent = parent.context.SpanID
+	} else if remote, ok := ctx.Value(remoteKey{}).(SpanContext); ok {
+		span.context.TraceID = remote.TraceID
+		span.context.Sampled = remote.Sampled
+		span.parent = remote.SpanID
+	} else {
+		span.context.TraceID = newTraceID()
+		span.context.Sampled = t.sample(span.context.TraceID)
+	}
+
+	return ContextWithSpan(ctx, span), span
+}
+
+// Decide whether to record a new trace, from the random low half of its ID
+func (t *Tracer) sample(id TraceID) bool {
+	ratio := t.options.SampleRatio
+	if ratio >= 1 {
+		return true
+	}
+	if ratio <= 0 {
+		return false
+	}
+	return binary.BigEndian.Uint64(id[8:])>>11 < uint64(ratio*(1<<53))
+}
+
+// Queue a finished span, dropping it when the exporter is falling behind
+func (t *Tracer) enqueue(span *Span) {
+	select {
+	case t.queue <- span:
+	default:
+	}
+}
+
+// Export finished spans in batches until the context is cancelled, then export
+// whatever is still queued
+func (t *Tracer) Run(ctx context.Context) {
+	ticker := time.NewTicker(t.options.Interval)
+	defer ticker.Stop()
+
+	var batch []*Span
+	for {
+		select {
+		case <-ctx.Done():
+			for {
+				select {
+				case span := <-t.queue:
+					batch = t.add(batch, span)
+				default:
+					t.export(batch)
+					return
+				}
+			}
+		case span := <-t.queue:
+			batch = t.add(batch, span)
+		case <-ticker.C:
+			t.export(batch)
+			batch = nil
+		}
+	}
+}
+
+// Add a span to the batch, exporting the batch once it is full
+func (t *Tracer) add(batch []*Span, span *Span) []*Span {
+	batch = append(batch, span)
+	if len(batch) < t.options.BatchSize {
+		return batch
+	}
+	t.export(batch)
+	return nil
+}
+
+// Export a batch; a failed batch is logged and dropped
+func (t *Tracer) export(batch []*Span) {
+	if len(batch) == 0 {
+		return
+	}
+
+	payload, err := encodeSpans(t.options.ServiceName, batch)
+	if err == nil {
+		// The export gets its own deadline so a hung collector cannot stall the queue
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		err = t.exporter.Export(ctx, payload)
+		cancel()
+	}
+	if err != nil {
+		slog.Error("Trace export failed", "spans", len(batch), "error", err)
+	}
+}
+
+func newTraceID() TraceID {
+	var id TraceID
+	for !id.IsValid() {
+		rand.Read(id[:])
+	}
+	return id
+}
+
+func newSpanID() SpanID {
+	var id SpanID
+	for !id.IsValid() {
+		rand.Read(id[:])
+	}
+	return id
+}
@@ -0,0 +1,171 @@
+package tracing
+
+import (
+	"context"
+	"encoding/json"
+	"sync"
+	"testing"
+)
+
+// Exporter keeping every payload in memory
+type memoryExporter struct {
+	mu       sync.Mutex
+	payloads [][]byte
+}
+
+func (e *memoryExporter) Export(ctx context.Context, payload []byte) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.payloads = append(e.payloads, payload)
+	return nil
+}
+
+// Export everything queued and return the spans written, by name
+func flush(t *testing.T, tracer *Tracer, exporter *memoryExporter) map[string]otlpSpan {
+	t.Helper()
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	tracer.Run(ctx)
+
+	spans := make(map[string]otlpSpan)
+	for _, payload := range exporter.payloads {
+		var request otlpRequest
+		if err := json.Unmarshal(payload, &request); err != nil {
+			t.Fatalf("Failed to decode payload: %v", err)
+		}
+		for _, resource := range request.ResourceSpans {
+			for _, scope := range resource.ScopeSpans {
+				for _, span := range scope.Spans {
+					spans[span.Name] = span
+				}
+			}
+		}
+	}
+	exporter.payloads = nil
+	return spans
+}
+
+func TestParseTraceparent(t *testing.T) {
+	sc, ok := ParseTraceparent("00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	if !ok {
+		t.Fatal("Expected a valid traceparent")
+	}
+	if sc.TraceID.String() != "4bf92f3577b34da6a3ce929d0e0e4736" || sc.SpanID.String() != "00f067aa0ba902b7" || !sc.Sampled {
+		t.Errorf("Unexpected span context %+v", sc)
+	}
+	if sc.Traceparent() != "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01" {
+		t.Errorf("Expected the header to round-trip, got %q", sc.Traceparent())
+	}
+
+	// Later versions may add fields after the flags
+	if _, ok := ParseTraceparent("01-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-00-extra"); !ok {
+		t.Error("Expected a future version to be accepted")
+	}
+
+	for _, value := range []string{
+		"",
+		"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7",
+		"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01-extra",
+		"ff-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01",
+		"00-00000000000000000000000000000000-00f067aa0ba902b7-01",
+		"00-4bf92f3577b34da6a3ce929d0e0e4736-0000000000000000-01",
+		"00-4BF92F3577B34DA6A3CE929D0E0E4736-00f067aa0ba902b7-01",
+		"00-4bf92f3577b34da6a3ce929d0e0e47-00f067aa0ba902b7-01",
+		"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-zz",
+	} {
+		if _, ok := ParseTraceparent(value); ok {
+			t.Errorf("Expected %q to be rejected", value)
+		}
+	}
+}
+
+func TestTracerParentage(t *testing.T) {
+	exporter := &memoryExporter{}
+	tracer := New(exporter, Options{ServiceName: "test", SampleRatio: 1})
+
+	ctx, root := tracer.Start(context.Background(), "root")
+	_, child := tracer.Start(ctx, "child", String("vlan.id", "7"))
+	child.SetError("disk full")
+	child.End()
+	root.End()
+	root.End()
+
+	spans := flush(t, tracer, exporter)
+	if len(spans) != 2 {
+		t.Fatalf("Expected 2 spans, got %d", len(spans))
+	}
+	if spans["child"].TraceID != spans["root"].TraceID {
+		t.Error("Expected the child to share the root's trace")
+	}
+	if spans["child"].ParentSpanID != spans["root"].SpanID {
+		t.Errorf("Expected the child's parent to be %s, got %s", spans["root"].SpanID, spans["child"].ParentSpanID)
+	}
+	if spans["root"].ParentSpanID != "" {
+		t.Error("Expected the root span to have no parent")
+	}
+	if spans["child"].Status == nil || spans["child"].Status.Code != otlpStatusError || spans["child"].Status.Message != "disk full" {
+		t.Errorf("Expected an error status, got %+v", spans["child"].Status)
+	}
+	if len(spans["child"].Attributes) != 1 || *spans["child"].Attributes[0].Value.StringValue != "7" {
+		t.Errorf("Unexpected attributes %+v", spans["child"].Attributes)
+	}
+}
+
+func TestTracerRemoteParent(t *testing.T) {
+	exporter := &memoryExporter{}
+	tracer := New(exporter, Options{SampleRatio: 1})
+
+	parent, _ := ParseTraceparent("00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	_, span := tracer.Start(ContextWithRemoteParent(context.Background(), parent), "server")
+	span.End()
+
+	spans := flush(t, tracer, exporter)
+	if spans["server"].TraceID != "4bf92f3577b34da6a3ce929d0e0e4736" || spans["server"].ParentSpanID != "00f067aa0ba902b7" {
+		t.Errorf("Expected the span to continue the remote trace, got %+v", spans["server"])
+	}
+
+	// The caller's decision not to sample is followed even with a ratio of 1
+	unsampled, _ := ParseTraceparent("00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-00")
+	ctx, span := tracer.Start(ContextWithRemoteParent(context.Background(), unsampled), "skipped")
+	_, child := tracer.Start(ctx, "skipped child")
+	child.End()
+	span.End()
+	if spans := flush(t, tracer, exporter); len(spans) != 0 {
+		t.Errorf("Expected unsampled spans not to be exported, got %d", len(spans))
+	}
+	if span.Context().Sampled || span.Context().TraceID != unsampled.TraceID {
+		t.Error("Expected an unsampled span to still carry the trace for propagation")
+	}
+}
+
+func TestTracerSampleRatio(t *testing.T) {
+	exporter := &memoryExporter{}
+	tracer := New(exporter, Options{SampleRatio: 0})
+	_, span := tracer.Start(context.Background(), "dropped")
+	span.End()
+	if spans := flush(t, tracer, exporter); len(spans) != 0 {
+		t.Errorf("Expected no spans with a ratio of 0, got %d", len(spans))
+	}
+
+	tracer = New(exporter, Options{SampleRatio: 0.5})
+	sampled := 0
+	for i := 0; i < 1000; i++ {
+		if _, span := tracer.Start(context.Background(), "root"); span.Context().Sampled {
+			sampled++
+		}
+	}
+	if sampled < 400 || sampled > 600 {
+		t.Errorf("Expected about half of 1000 traces to be sampled, got %d", sampled)
+	}
+}
+
+func TestNilTracer(t *testing.T) {
+	var tracer *Tracer
+	ctx, span := tracer.Start(context.Background(), "noop")
+	if span != nil || SpanFromContext(ctx) != nil {
+		t.Fatal("Expected a nil tracer to start no span")
+	}
+	span.SetAttributes(Bool("ok", true))
+	span.SetError("ignored")
+	span.End()
+}
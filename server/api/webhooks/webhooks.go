@@ -0,0 +1,376 @@
+// Package webhooks delivers VLAN change events to subscribed HTTP endpoints.
+// Payloads are signed with the subscription's secret, failed deliveries are retried
+// with exponential backoff, and deliveries that run out of attempts become dead letters.
+// Deliveries of changes made by traced requests carry a traceparent header, so
+// receivers join the trace of the request that made the change.
+package webhooks
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/netip"
+	"strconv"
+	"sync"
+	"time"
+
+	"smit/server/api/events"
+	"smit/server/api/models"
+	"smit/server/api/storage"
+	"smit/server/api/tracing"
+)
+
+// Delivery defaults
+const (
+	DefaultMaxAttempts = 6
+	DefaultBaseDelay   = time.Second
+	DefaultMaxDelay    = 5 * time.Minute
+	DefaultTimeout     = 10 * time.Second
+)
+
+// Headers sent with every delivery
+const (
+	SignatureHeader = "X-Smit-Signature"
+	TimestampHeader = "X-Smit-Timestamp"
+	EventHeader     = "X-Smit-Event"
+	DeliveryHeader  = "X-Smit-Delivery"
+)
+
+// Number of deliveries kept per webhook and dead letters kept in total
+const (
+	deliveryLogSize = 100
+	deadLetterSize  = 1000
+)
+
+// Optional dispatcher settings; zero values use the defaults
+type Options struct {
+	// Replaces the default client, which refuses non-public addresses and redirects
+	Client *http.Client
+	// Internal networks the default client may deliver to
+	AllowedNetworks []netip.Prefix
+	MaxAttempts     int
+	BaseDelay       time.Duration
+	MaxDelay        time.Duration
+	// Records a client span for every delivery attempt; nil records none
+	Tracer *tracing.Tracer
+}
+
+// Dispatcher delivers events from the broker to every webhook that wants them.
+// Delivery records live in memory and are lost on restart.
+type Dispatcher struct {
+	store       storage.WebhookStorage
+	broker      *events.Broker
+	client      *http.Client
+	maxAttempts int
+	baseDelay   time.Duration
+	maxDelay    time.Duration
+	tracer      *tracing.Tracer
+	// Nil when the caller supplied its own client
+	guard *destinationGuard
+
+	mu         sync.Mutex
+	lastID     int
+	deliveries map[int][]*models.WebhookDelivery
+	dead       []models.WebhookDelivery
+	inFlight   sync.WaitGroup
+}
+
+// Create a new dispatcher
+func New(store storage.WebhookStorage, broker *events.Broker, opts Options) *Dispatcher {
+	d := &Dispatcher{
+		store:       store,
+		broker:      broker,
+		client:      opts.Client,
+		maxAttempts: opts.MaxAttempts,
+		baseDelay:   opts.BaseDelay,
+		maxDelay:    opts.MaxDelay,
+		tracer:      opts.Tracer,
+		deliveries:  make(map[int][]*models.WebhookDelivery),
+	}
+
+	if d.client == nil {
+		d.guard = &destinationGuard{allowed: opts.AllowedNetworks}
+		d.client = d.guard.client()
+	}
+	if d.maxAttempts <= 0 {
+		d.maxAttempts = DefaultMaxAttempts
+	}
+	if d.baseDelay <= 0 {
+		d.baseDelay = DefaultBaseDelay
+	}
+	if d.maxDelay <= 0 {
+		d.maxDelay = DefaultMaxDelay
+	}
+
+	return d
+}
+
+// Check that a webhook URL does not point at a non-public address outside the
+// allowed networks. Returns ErrForbiddenDestination for such URLs; the check is
+// repeated on every connection, for hosts given by name.
+func (d *Dispatcher) CheckURL(rawURL string) error {
+	if d.guard == nil {
+		return nil
+	}
+	return d.guard.checkURL(rawURL)
+}
+
+// Deliver events until the context is cancelled, then wait for deliveries in flight
+func (d *Dispatcher) Run(ctx context.Context) {
+	defer d.inFlight.Wait()
+
+	lastID := d.broker.LastID()
+	for {
+		sub, backlog, complete := d.broker.Subscribe(lastID)
+		if !complete {
+			slog.Warn("Webhook dispatcher lost events", "after", lastID)
+		}
+		for _, event := range backlog {
+			d.Dispatch(ctx, event)
+			lastID = event.ID
+		}
+
+	receive:
+		for {
+			select {
+			case <-ctx.Done():
+				sub.Cancel()
+				return
+			case event, ok := <-sub.C:
+				if !ok {
+					// Dropped for falling behind, resubscribe and resume from the log
+					break receive
+				}
+				d.Dispatch(ctx, event)
+				lastID = event.ID
+			}
+		}
+	}
+}
+
+// Start deliveries of an event to every webhook that wants it
+func (d *Dispatcher) Dispatch(ctx context.Context, event events.Event) {
+	webhooks, err := d.store.GetAllWebhooks()
+	if err != nil {
+		slog.Error("Webhook dispatcher failed to load webhooks", "error", err)
+		return
+	}
+
+	payload, err := json.Marshal(event)
+	if err != nil {
+		slog.Error("Webhook dispatcher failed to encode event", "event", event.ID, "error", err)
+		return
+	}
+
+	// Deliveries continue the trace of the request behind the event
+	if event.Trace.TraceID.IsValid() {
+		ctx = tracing.ContextWithRemoteParent(ctx, event.Trace)
+	}
+
+	for _, webhook := range webhooks {
+		if !webhook.Wants(event.Type) {
+			continue
+		}
+
+		delivery := d.record(webhook.ID, event)
+		d.inFlight.Add(1)
+		go func(webhook models.Webhook) {
+			defer d.inFlight.Done()
+			d.deliver(ctx, webhook, delivery, payload)
+		}(webhook)
+	}
+}
+
+// Send a payload until it is accepted or the attempts run out
+func (d *Dispatcher) deliver(ctx context.Context, webhook models.Webhook, delivery *models.WebhookDelivery, payload []byte) {
+	for attempt := 1; ; attempt++ {
+		// Stop retrying for webhooks deleted in the meantime
+		if _, err := d.store.GetWebhookByID(webhook.ID); errors.Is(err, storage.ErrWebhookNotFound) {
+			d.update(delivery, func(delivery *models.WebhookDelivery) {
+				delivery.Status = models.DeliveryFailed
+				delivery.Error = "webhook was deleted"
+			})
+			return
+		}
+
+		status, err := d.send(ctx, webhook, delivery.ID, delivery.EventType, payload)
+		d.update(delivery, func(delivery *models.WebhookDelivery) {
+			delivery.Attempts = attempt
+			delivery.ResponseStatus = status
+			delivery.Error = ""
+			switch {
+			case err == nil:
+				delivery.Status = models.DeliveryDelivered
+			case attempt >= d.maxAttempts:
+				delivery.Status = models.DeliveryDead
+				delivery.Error = err.Error()
+			default:
+				delivery.Status = models.DeliveryFailed
+				delivery.Error = err.Error()
+			}
+		})
+
+		if err == nil {
+			return
+		}
+		if attempt >= d.maxAttempts {
+			d.bury(delivery, payload)
+			return
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(d.backoff(attempt)):
+		}
+	}
+}
+
+// POST a signed payload, returning the response status
+func (d *Dispatcher) send(ctx context.Context, webhook models.Webhook, deliveryID int, eventType string, payload []byte) (status int, err error) {
+	ctx, span := d.tracer.StartClient(ctx, "webhook.deliver",
+		tracing.Int("webhook.id", webhook.ID), tracing.Int("webhook.delivery.id", deliveryID), tracing.String("webhook.event", eventType))
+	defer func() {
+		if status != 0 {
+			span.SetAttributes(tracing.Int("http.response.status_code", status))
+		}
+		if err != nil {
+			span.SetError(err.Error())
+		}
+		span.End()
+	}()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, webhook.URL, bytes.NewReader(payload))
+	if err != nil {
+		return 0, err
+	}
+
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "smit-webhooks")
+	req.Header.Set(EventHeader, eventType)
+	req.Header.Set(DeliveryHeader, strconv.Itoa(deliveryID))
+	req.Header.Set(TimestampHeader, timestamp)
+	req.Header.Set(SignatureHeader, Sign(webhook.Secret, timestamp, payload))
+	tracing.Inject(ctx, req.Header)
+
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return resp.StatusCode, fmt.Errorf("receiver responded with %s", resp.Status)
+	}
+	return resp.StatusCode, nil
+}
+
+// Delay before the retry following an attempt, doubling up to the maximum
+func (d *Dispatcher) backoff(attempt int) time.Duration {
+	delay := d.baseDelay
+	for i := 1; i < attempt && delay < d.maxDelay; i++ {
+		delay *= 2
+	}
+	return min(delay, d.maxDelay)
+}
+
+// Signature of a payload: "sha256=" and the hex HMAC-SHA256 of "{timestamp}.{payload}".
+// Receivers recompute it with the shared secret and compare in constant time.
+func Sign(secret, timestamp string, payload []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp + "."))
+	mac.Write(payload)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// Start a delivery record in the webhook's log
+func (d *Dispatcher) record(webhookID int, event events.Event) *models.WebhookDelivery {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	d.lastID++
+	now := time.Now()
+	delivery := &models.WebhookDelivery{
+		ID:        d.lastID,
+		WebhookID: webhookID,
+		EventID:   event.ID,
+		EventType: event.Type,
+		Status:    models.DeliveryPending,
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+
+	entries := append(d.deliveries[webhookID], delivery)
+	if len(entries) > deliveryLogSize {
+		entries = entries[len(entries)-deliveryLogSize:]
+	}
+	d.deliveries[webhookID] = entries
+	return delivery
+}
+
+// Change a delivery record under the lock
+func (d *Dispatcher) update(delivery *models.WebhookDelivery, change func(*models.WebhookDelivery)) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	change(delivery)
+	delivery.UpdatedAt = time.Now()
+}
+
+// Move a delivery that ran out of attempts to the dead letters
+func (d *Dispatcher) bury(delivery *models.WebhookDelivery, payload []byte) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	dead := *delivery
+	dead.Payload = payload
+	d.dead = append(d.dead, dead)
+	if len(d.dead) > deadLetterSize {
+		d.dead = d.dead[len(d.dead)-deadLetterSize:]
+	}
+}
+
+// Recent deliveries of a webhook, newest first
+func (d *Dispatcher) Deliveries(webhookID int) []models.WebhookDelivery {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	entries := d.deliveries[webhookID]
+	deliveries := make([]models.WebhookDelivery, 0, len(entries))
+	for i := len(entries) - 1; i >= 0; i-- {
+		deliveries = append(deliveries, *entries[i])
+	}
+	return deliveries
+}
+
+// Deliveries that ran out of attempts, newest first; zero webhookID lists every webhook
+func (d *Dispatcher) DeadLetters(webhookID int) []models.WebhookDelivery {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	dead := []models.WebhookDelivery{}
+	for i := len(d.dead) - 1; i >= 0; i-- {
+		if webhookID == 0 || d.dead[i].WebhookID == webhookID {
+			dead = append(dead, d.dead[i])
+		}
+	}
+	return dead
+}
+
+// Drop the delivery log of a deleted webhook; its dead letters are kept
+func (d *Dispatcher) Forget(webhookID int) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	delete(d.deliveries, webhookID)
+}
@@ -0,0 +1,326 @@
+package webhooks
+
+import (
+	"context"
+	"crypto/hmac"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"smit/server/api/events"
+	"smit/server/api/models"
+	"smit/server/api/storage"
+	"smit/server/api/tracing"
+)
+
+const testSecret = "0123456789abcdef"
+
+// Networks of the local test receivers
+var loopback = []netip.Prefix{netip.MustParsePrefix("127.0.0.0/8"), netip.MustParsePrefix("::1/128")}
+
+// Local receiver that answers with the given statuses in turn, then 200
+type receiver struct {
+	mu       sync.Mutex
+	statuses []int
+	requests []*http.Request
+	bodies   [][]byte
+}
+
+func (rc *receiver) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	body, _ := io.ReadAll(r.Body)
+
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+	rc.requests = append(rc.requests, r)
+	rc.bodies = append(rc.bodies, body)
+
+	status := http.StatusOK
+	if len(rc.statuses) > 0 {
+		status, rc.statuses = rc.statuses[0], rc.statuses[1:]
+	}
+	w.WriteHeader(status)
+}
+
+func (rc *receiver) count() int {
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+	return len(rc.requests)
+}
+
+func newTestDispatcher(t *testing.T, url string, eventTypes []string) (*Dispatcher, *storage.JSONStorage) {
+	t.Helper()
+	store, err := storage.NewJSONStorage(filepath.Join(t.TempDir(), "webhooks_test.json"))
+	if err != nil {
+		t.Fatalf("Failed to create storage: %v", err)
+	}
+	if _, err := store.CreateWebhook(&models.WebhookInput{URL: url, Events: eventTypes, Secret: testSecret}); err != nil {
+		t.Fatalf("Failed to create webhook: %v", err)
+	}
+
+	dispatcher := New(store, events.NewBroker(10), Options{MaxAttempts: 3, BaseDelay: time.Millisecond, MaxDelay: 4 * time.Millisecond, AllowedNetworks: loopback})
+	return dispatcher, store
+}
+
+// Dispatch an event and wait for its deliveries to finish
+func dispatchAndWait(dispatcher *Dispatcher, event events.Event) {
+	dispatcher.Dispatch(context.Background(), event)
+	dispatcher.inFlight.Wait()
+}
+
+func TestDeliverySigned(t *testing.T) {
+	rc := &receiver{}
+	server := httptest.NewServer(rc)
+	defer server.Close()
+
+	dispatcher, _ := newTestDispatcher(t, server.URL, nil)
+	dispatchAndWait(dispatcher, events.Event{ID: 7, Type: events.TypeCreated, VLAN: models.VLANModel{ID: 1, Name: "Prod"}})
+
+	if rc.count() != 1 {
+		t.Fatalf("Expected 1 request, got %d", rc.count())
+	}
+	req, body := rc.requests[0], rc.bodies[0]
+
+	expected := Sign(testSecret, req.Header.Get(TimestampHeader), body)
+	if !hmac.Equal([]byte(req.Header.Get(SignatureHeader)), []byte(expected)) {
+		t.Errorf("Expected signature %s, got %s", expected, req.Header.Get(SignatureHeader))
+	}
+	if req.Header.Get(EventHeader) != events.TypeCreated {
+		t.Errorf("Expected event header created, got %s", req.Header.Get(EventHeader))
+	}
+
+	var event events.Event
+	if err := json.Unmarshal(body, &event); err != nil {
+		t.Fatalf("Failed to decode payload: %v", err)
+	}
+	if event.ID != 7 || event.VLAN.Name != "Prod" {
+		t.Errorf("Unexpected payload %s", body)
+	}
+
+	deliveries := dispatcher.Deliveries(1)
+	if len(deliveries) != 1 || deliveries[0].Status != models.DeliveryDelivered || deliveries[0].Attempts != 1 {
+		t.Errorf("Expected one delivered delivery, got %+v", deliveries)
+	}
+}
+
+func TestDeliveryTraceparent(t *testing.T) {
+	rc := &receiver{}
+	server := httptest.NewServer(rc)
+	defer server.Close()
+
+	parent, _ := tracing.ParseTraceparent("00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	event := events.Event{ID: 7, Type: events.TypeCreated, VLAN: models.VLANModel{ID: 1, Name: "Prod"}, Trace: parent}
+
+	// Without a tracer the receiver becomes a child of the request's span
+	dispatcher, _ := newTestDispatcher(t, server.URL, nil)
+	dispatchAndWait(dispatcher, events.Event{ID: 6, Type: events.TypeCreated})
+	dispatchAndWait(dispatcher, event)
+	if rc.count() != 2 {
+		t.Fatalf("Expected 2 requests, got %d", rc.count())
+	}
+	if header := rc.requests[0].Header.Get("traceparent"); header != "" {
+		t.Errorf("Expected no traceparent for an untraced event, got %s", header)
+	}
+	if header := rc.requests[1].Header.Get("traceparent"); header != parent.Traceparent() {
+		t.Errorf("Expected traceparent %s, got %s", parent.Traceparent(), header)
+	}
+
+	// With a tracer it becomes a child of the delivery's client span
+	dispatcher.tracer = tracing.New(tracing.NewWriterExporter(io.Discard), tracing.Options{SampleRatio: 1})
+	dispatchAndWait(dispatcher, event)
+	sc, ok := tracing.ParseTraceparent(rc.requests[2].Header.Get("traceparent"))
+	if !ok || sc.TraceID != parent.TraceID || sc.SpanID == parent.SpanID {
+		t.Errorf("Expected a delivery span in the request's trace, got %+v", sc)
+	}
+}
+
+func TestDeliveryRetries(t *testing.T) {
+	rc := &receiver{statuses: []int{http.StatusServiceUnavailable, http.StatusInternalServerError}}
+	server := httptest.NewServer(rc)
+	defer server.Close()
+
+	dispatcher, _ := newTestDispatcher(t, server.URL, nil)
+	dispatchAndWait(dispatcher, events.Event{ID: 1, Type: events.TypeUpdated})
+
+	if rc.count() != 3 {
+		t.Errorf("Expected 3 attempts, got %d", rc.count())
+	}
+	deliveries := dispatcher.Deliveries(1)
+	if len(deliveries) != 1 || deliveries[0].Status != models.DeliveryDelivered || deliveries[0].Attempts != 3 {
+		t.Errorf("Expected delivery on the third attempt, got %+v", deliveries)
+	}
+	if len(dispatcher.DeadLetters(0)) != 0 {
+		t.Error("Expected no dead letters")
+	}
+}
+
+func TestDeliveryDeadLetter(t *testing.T) {
+	rc := &receiver{statuses: []int{500, 500, 500}}
+	server := httptest.NewServer(rc)
+	defer server.Close()
+
+	dispatcher, _ := newTestDispatcher(t, server.URL, nil)
+	dispatchAndWait(dispatcher, events.Event{ID: 1, Type: events.TypeDeleted})
+
+	dead := dispatcher.DeadLetters(1)
+	if len(dead) != 1 {
+		t.Fatalf("Expected 1 dead letter, got %d", len(dead))
+	}
+	if dead[0].Status != models.DeliveryDead || dead[0].Attempts != 3 || dead[0].ResponseStatus != 500 || len(dead[0].Payload) == 0 {
+		t.Errorf("Unexpected dead letter %+v", dead[0])
+	}
+	if len(dispatcher.DeadLetters(2)) != 0 {
+		t.Error("Expected no dead letters for another webhook")
+	}
+}
+
+func TestDeliveryFiltersEventTypes(t *testing.T) {
+	rc := &receiver{}
+	server := httptest.NewServer(rc)
+	defer server.Close()
+
+	dispatcher, _ := newTestDispatcher(t, server.URL, []string{events.TypeDeleted})
+	dispatchAndWait(dispatcher, events.Event{ID: 1, Type: events.TypeCreated})
+	dispatchAndWait(dispatcher, events.Event{ID: 2, Type: events.TypeDeleted})
+
+	if rc.count() != 1 || rc.requests[0].Header.Get(EventHeader) != events.TypeDeleted {
+		t.Errorf("Expected only the deleted event, got %d requests", rc.count())
+	}
+}
+
+func TestDeliveryStopsForDeletedWebhook(t *testing.T) {
+	var store *storage.JSONStorage
+	calls := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		store.DeleteWebhook(1)
+		w.WriteHeader(http.StatusBadGateway)
+	}))
+	defer server.Close()
+
+	dispatcher, s := newTestDispatcher(t, server.URL, nil)
+	store = s
+	dispatchAndWait(dispatcher, events.Event{ID: 1, Type: events.TypeCreated})
+
+	if calls != 1 {
+		t.Errorf("Expected retries to stop after the webhook was deleted, got %d calls", calls)
+	}
+	if len(dispatcher.DeadLetters(0)) != 0 {
+		t.Error("Expected no dead letter for a deleted webhook")
+	}
+}
+
+func TestDispatcherRun(t *testing.T) {
+	rc := &receiver{}
+	server := httptest.NewServer(rc)
+	defer server.Close()
+
+	store, err := storage.NewJSONStorage(filepath.Join(t.TempDir(), "run_test.json"))
+	if err != nil {
+		t.Fatalf("Failed to create storage: %v", err)
+	}
+	store.CreateWebhook(&models.WebhookInput{URL: server.URL, Secret: testSecret})
+
+	broker := events.NewBroker(10)
+	dispatcher := New(store, broker, Options{AllowedNetworks: loopback})
+	vlans := events.NewStorage(store, broker)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		dispatcher.Run(ctx)
+		close(done)
+	}()
+
+	// Changes made before the dispatcher subscribed are not delivered, so keep creating until one is
+	deadline := time.Now().Add(5 * time.Second)
+	for tag := 100; rc.count() == 0; tag++ {
+		if time.Now().After(deadline) {
+			t.Fatal("Timed out waiting for delivery")
+		}
+		vlans.Create(&models.VLANInput{Name: "Prod", VlanID: tag, Subnet: "10.1.0.0/24", Gateway: "10.1.0.1", Status: "active"})
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	cancel()
+	<-done
+}
+
+func TestDeliveryRefusesInternalDestinations(t *testing.T) {
+	rc := &receiver{}
+	server := httptest.NewServer(rc)
+	defer server.Close()
+
+	// Loopback is refused unless allowed, at dial time for names and up front for addresses
+	dispatcher := New(nil, nil, Options{})
+	if err := dispatcher.CheckURL(server.URL); !errors.Is(err, ErrForbiddenDestination) {
+		t.Errorf("Expected %s to be refused, got %v", server.URL, err)
+	}
+	webhook := models.Webhook{ID: 1, URL: server.URL, Secret: testSecret}
+	if _, err := dispatcher.send(context.Background(), webhook, 1, events.TypeCreated, []byte("{}")); !errors.Is(err, ErrForbiddenDestination) {
+		t.Errorf("Expected the delivery to be refused, got %v", err)
+	}
+	if rc.count() != 0 {
+		t.Errorf("Expected no request to reach the receiver, got %d", rc.count())
+	}
+
+	for _, rawURL := range []string{"http://169.254.169.254/latest/meta-data", "http://10.0.0.5/hook", "http://[::ffff:192.168.1.1]/hook", "http://localhost:8080/hook"} {
+		if err := dispatcher.CheckURL(rawURL); !errors.Is(err, ErrForbiddenDestination) {
+			t.Errorf("Expected %s to be refused, got %v", rawURL, err)
+		}
+	}
+	for _, rawURL := range []string{"https://cmdb.example.com/hooks", "https://93.184.216.34/hooks"} {
+		if err := dispatcher.CheckURL(rawURL); err != nil {
+			t.Errorf("Expected %s to be accepted, got %v", rawURL, err)
+		}
+	}
+
+	// An allowed network is reachable
+	dispatcher = New(nil, nil, Options{AllowedNetworks: loopback})
+	if err := dispatcher.CheckURL(server.URL); err != nil {
+		t.Errorf("Expected an allowed network to be accepted, got %v", err)
+	}
+	if _, err := dispatcher.send(context.Background(), webhook, 1, events.TypeCreated, []byte("{}")); err != nil {
+		t.Errorf("Expected delivery to an allowed network, got %v", err)
+	}
+
+	// A caller's own client is trusted as is
+	if err := New(nil, nil, Options{Client: server.Client()}).CheckURL(server.URL); err != nil {
+		t.Errorf("Expected no check with a custom client, got %v", err)
+	}
+}
+
+func TestDeliveryDoesNotFollowRedirects(t *testing.T) {
+	rc := &receiver{}
+	target := httptest.NewServer(rc)
+	defer target.Close()
+	redirect := httptest.NewServer(http.RedirectHandler(target.URL, http.StatusTemporaryRedirect))
+	defer redirect.Close()
+
+	dispatcher := New(nil, nil, Options{AllowedNetworks: loopback})
+	webhook := models.Webhook{ID: 1, URL: redirect.URL, Secret: testSecret}
+	status, err := dispatcher.send(context.Background(), webhook, 1, events.TypeCreated, []byte("{}"))
+	if err == nil || status != http.StatusTemporaryRedirect {
+		t.Errorf("Expected a failed attempt with status 307, got %d, %v", status, err)
+	}
+	if rc.count() != 0 {
+		t.Errorf("Expected the redirect not to be followed, got %d requests", rc.count())
+	}
+}
+
+func TestBackoff(t *testing.T) {
+	dispatcher := New(nil, nil, Options{BaseDelay: time.Second, MaxDelay: 10 * time.Second})
+
+	expected := []time.Duration{time.Second, 2 * time.Second, 4 * time.Second, 8 * time.Second, 10 * time.Second, 10 * time.Second}
+	for i, want := range expected {
+		if got := dispatcher.backoff(i + 1); got != want {
+			t.Errorf("Attempt %d: expected %v, got %v", i+1, want, got)
+		}
+	}
+}